@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+
+	"ecommerce-backend/internal/handlers"
+)
+
+// runLambda implements the Lambda runtime API loop: fetch the next API
+// Gateway event, process it as an IPN and post the response back.
+func runLambda(runtimeAPI string, paymentHandler *handlers.PaymentHandler) {
+	baseURL := "http://" + runtimeAPI + "/2018-06-01/runtime/invocation/"
+
+	for {
+		resp, err := http.Get(baseURL + "next")
+		if err != nil {
+			log.Fatal("Failed to fetch next Lambda invocation:", err)
+		}
+
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			postLambdaResult(baseURL+requestID+"/error", map[string]string{"errorMessage": err.Error()})
+			continue
+		}
+
+		var event handlers.LambdaEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			postLambdaResult(baseURL+requestID+"/error", map[string]string{"errorMessage": "invalid event: " + err.Error()})
+			continue
+		}
+
+		// The provider is the last path segment, e.g. /api/v1/webhooks/momo.
+		// Events without a path come from the original VNPay-only deployment.
+		method := "vnpay"
+		if event.Path != "" {
+			method = path.Base(event.Path)
+		}
+
+		postLambdaResult(baseURL+requestID+"/response", paymentHandler.HandleIPNEvent(method, event))
+	}
+}
+
+func postLambdaResult(url string, result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to marshal Lambda result: %v", err)
+		return
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to post Lambda result: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,63 @@
+// Command ipn serves payment gateway IPN callbacks on their own, either as a small HTTP
+// server or, when AWS_LAMBDA_RUNTIME_API is set, as a Lambda custom runtime
+// (build it as "bootstrap" for the provided.al2 runtime).
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+
+	"ecommerce-backend/internal/config"
+	"ecommerce-backend/internal/database"
+	"ecommerce-backend/internal/handlers"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	cfg := config.Load()
+
+	// Schema migrations are owned by the API server; this binary only reads
+	// and updates payments.
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	providers := payments.NewRegistryFromConfig(cfg)
+	// Deliveries are queued here and sent by the API server's dispatcher.
+	dispatcher := webhooks.NewDispatcher(db, services.NewSecretBox(cfg.PartnerSecretKey))
+	reconciler := services.NewReconciler(db, providers, nil, cfg.PaymentPendingThreshold, cfg.PaymentExpiry)
+	reconciler.OnOrderPaid = handlers.OrderPlacedNotifier(db, dispatcher)
+	paymentHandler := handlers.NewPaymentHandler(db, cfg, providers, reconciler, dispatcher)
+
+	if runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API"); runtimeAPI != "" {
+		log.Println("🚀 IPN handler running as Lambda")
+		runLambda(runtimeAPI, paymentHandler)
+		return
+	}
+
+	r := gin.Default()
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "healthy", "message": "IPN handler is running"})
+	})
+	r.GET("/api/v1/webhooks/:method", paymentHandler.PaymentWebhook)
+	r.POST("/api/v1/webhooks/:method", paymentHandler.PaymentWebhook)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8081"
+	}
+
+	log.Printf("🚀 IPN handler starting on port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, r))
+}
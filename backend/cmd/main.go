@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,7 +15,10 @@ import (
 	"ecommerce-backend/internal/database"
 	"ecommerce-backend/internal/handlers"
 	"ecommerce-backend/internal/middleware"
+	"ecommerce-backend/internal/migrations"
+	"ecommerce-backend/internal/payments"
 	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
 )
 
 func main() {
@@ -29,7 +33,8 @@ func main() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	if err := database.Migrate(db); err != nil {
+	partnerSecrets := services.NewSecretBox(cfg.PartnerSecretKey)
+	if err := database.Migrate(db, migrations.UpgradeRefreshTokens, migrations.UpgradePartnerSecrets(partnerSecrets), migrations.UpgradePartnerAPIKeys); err != nil {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
@@ -57,8 +62,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 	AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001", "http://ecommerce.itmf.com.vn", "https://ecommerce.itmf.com.vn", "http://api-ecommerce.itmf.com.vn", "https://api-ecommerce.itmf.com.vn", "http://dev-ecommerce.itmf.com.vn", "https://dev-ecommerce.itmf.com.vn", "http://dev-api-ecommerce.itmf.com.vn", "https://dev-api-ecommerce.itmf.com.vn", "http://monitoring-ecommerce.itmf.com.vn", "https://monitoring-ecommerce.itmf.com.vn", "http://dev-ecommerce-alb-449822621.ap-southeast-1.elb.amazonaws.com", "http://dev-ecommerce-ec2-alb-1397175522.ap-southeast-1.elb.amazonaws.com", "http://dev-ecommerce-ecommerce-265912617.ap-southeast-1.elb.amazonaws.com"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.IdempotencyKeyHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.IdempotentReplayHeader},
 		AllowCredentials: true,
 	}))
 
@@ -68,15 +73,60 @@ func main() {
 
 	api := r.Group("/api/v1")
 	
-	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret)
-	userHandler := handlers.NewUserHandler(db)
-	productHandler := handlers.NewProductHandler(db, redisService)
+	var mailer services.Mailer = services.NewLogMailer()
+	if cfg.SMTPHost != "" {
+		mailer = services.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	var sessionDenylist services.SessionDenylist = services.NewMemorySessionDenylist()
+	if redisService != nil {
+		sessionDenylist = services.NewRedisSessionDenylist(redisService)
+	}
+	sessions := services.NewSessionManager(db, sessionDenylist)
+	emailVerifier := services.NewEmailVerifier(cfg.JWTSecret, cfg.EmailVerificationTTL)
+	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret, mailer, emailVerifier, cfg.FrontendURL, cfg.PasswordResetTTL, sessions)
+	userHandler := handlers.NewUserHandler(db, sessions)
+	webhookDispatcher := webhooks.NewDispatcher(db, partnerSecrets)
+	productHandler := handlers.NewProductHandler(db, redisService, webhookDispatcher)
 	categoryHandler := handlers.NewCategoryHandler(db)
 	cartHandler := handlers.NewCartHandler(db)
-	orderHandler := handlers.NewOrderHandler(db)
-	paymentHandler := handlers.NewPaymentHandler(db, cfg)
-	partnerHandler := handlers.NewPartnerHandler(db)
-	webhookProxy := handlers.NewWebhookProxy(cfg)
+	providers := payments.NewRegistryFromConfig(cfg)
+	orderHandler := handlers.NewOrderHandler(db, providers, webhookDispatcher)
+	var settlements payments.SettlementSource
+	if cfg.PaymentSettlementDir != "" {
+		settlements = payments.NewDirSettlementSource(cfg.PaymentSettlementDir)
+	}
+	reconciler := services.NewReconciler(db, providers, settlements, cfg.PaymentPendingThreshold, cfg.PaymentExpiry)
+	reconciler.OnOrderPaid = handlers.OrderPlacedNotifier(db, webhookDispatcher)
+	paymentHandler := handlers.NewPaymentHandler(db, cfg, providers, reconciler, webhookDispatcher)
+	partnerHandler := handlers.NewPartnerHandler(db, partnerSecrets, webhookDispatcher)
+	walletHandler := handlers.NewWalletHandler(db)
+	webhookHandler := handlers.NewWebhookHandler(db, webhookDispatcher)
+	payoutHandler := handlers.NewPayoutHandler(db, webhookDispatcher)
+	subOrderHandler := handlers.NewSubOrderHandler(db, webhookDispatcher)
+	inventoryHandler := handlers.NewInventoryHandler(db, redisService)
+	partnerKeyHandler := handlers.NewPartnerKeyHandler(db, partnerSecrets, cfg.PartnerKeyRotationGrace)
+
+	partnerApplicationHandler := handlers.NewPartnerApplicationHandler(db, partnerSecrets, mailer)
+	partnerStatsHandler := handlers.NewPartnerStatsHandler(db)
+	partnerSandboxHandler := handlers.NewPartnerSandboxHandler(db, payments.NewSandboxProvider(), webhookDispatcher)
+
+	var idempotencyStore services.IdempotencyStore = services.NewDBIdempotencyStore(db)
+	if redisService != nil {
+		idempotencyStore = services.NewRedisIdempotencyStore(redisService)
+	}
+	idempotency := middleware.IdempotencyMiddleware(idempotencyStore)
+
+	var partnerNonces services.NonceStore = services.NewMemoryNonceStore()
+	if redisService != nil {
+		partnerNonces = services.NewRedisNonceStore(redisService)
+	}
+	partnerAuth := middleware.PartnerAuthMiddleware(db, partnerSecrets, partnerNonces)
+
+	var rateLimiter services.RateLimiter = services.NewMemoryRateLimiter()
+	if redisService != nil {
+		rateLimiter = services.NewRedisRateLimiter(redisService)
+	}
+	inventorySyncLimit := middleware.PartnerRateLimitMiddleware(rateLimiter, "inventory_sync", cfg.InventorySyncRateLimit, time.Minute)
 
 	auth := api.Group("/auth")
 	{
@@ -84,24 +134,35 @@ func main() {
 		auth.POST("/login", authHandler.Login)
 		auth.POST("/google", authHandler.GoogleLogin)
 		auth.POST("/refresh", authHandler.RefreshToken)
-		auth.POST("/logout", middleware.AuthMiddleware(cfg.JWTSecret), authHandler.Logout)
+		auth.POST("/logout", middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), authHandler.Logout)
+		auth.POST("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/resend-verification", authHandler.ResendVerification)
+		auth.POST("/forgot-password", authHandler.ForgotPassword)
+		auth.POST("/reset-password", authHandler.ResetPassword)
 	}
 
 	users := api.Group("/users")
-	users.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	users.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist))
 	{
 		users.GET("/profile", userHandler.GetProfile)
 		users.PUT("/profile", userHandler.UpdateProfile)
+		users.PUT("/password", userHandler.ChangePassword)
+		users.GET("/sessions", userHandler.GetSessions)
+		users.DELETE("/sessions", userHandler.RevokeAllSessions)
+		users.DELETE("/sessions/:id", userHandler.RevokeSession)
 	}
 
 	adminUsers := api.Group("/admin/users")
-	adminUsers.Use(middleware.AuthMiddleware(cfg.JWTSecret), middleware.AdminMiddleware())
+	adminUsers.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
 	{
 		adminUsers.GET("", userHandler.GetAllUsers)
 		adminUsers.GET("/:id", userHandler.GetUser)
 		adminUsers.PUT("/:id", userHandler.UpdateUser)
 		adminUsers.PUT("/:id/password", userHandler.ChangeUserPassword)
+		adminUsers.POST("/:id/logout", userHandler.ForceLogout)
 		adminUsers.DELETE("/:id", userHandler.DeleteUser)
+		adminUsers.GET("/:id/wallet", walletHandler.GetUserWallet)
+		adminUsers.POST("/:id/wallet/credit", walletHandler.CreditWallet)
 	}
 
 	products := api.Group("/products")
@@ -116,7 +177,7 @@ func main() {
 	}
 
 	cart := api.Group("/cart")
-	cart.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	cart.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist))
 	{
 		cart.GET("", cartHandler.GetCart)
 		cart.POST("/add", cartHandler.AddToCart)
@@ -126,17 +187,21 @@ func main() {
 	}
 
 	protectedProducts := api.Group("/admin/products")
-	protectedProducts.Use(middleware.AuthMiddleware(cfg.JWTSecret), middleware.AdminMiddleware())
+	protectedProducts.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
 	{
+		protectedProducts.GET("", productHandler.GetAllProducts)
 		protectedProducts.GET("/pending", productHandler.GetPendingProducts)
 		protectedProducts.POST("", productHandler.CreateProduct)
 		protectedProducts.PUT("/:id", productHandler.UpdateProduct)
 		protectedProducts.POST("/:id/approve", productHandler.ApproveProduct)
+		protectedProducts.POST("/:id/reject", productHandler.RejectProduct)
+		protectedProducts.POST("/:id/suspend", productHandler.SuspendProduct)
+		protectedProducts.GET("/:id/moderation", productHandler.GetProductModeration)
 		protectedProducts.DELETE("/:id", productHandler.DeleteProduct)
 	}
 
 	adminCategories := api.Group("/admin/categories")
-	adminCategories.Use(middleware.AuthMiddleware(cfg.JWTSecret), middleware.AdminMiddleware())
+	adminCategories.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
 	{
 		adminCategories.GET("", categoryHandler.GetAllCategories)
 		adminCategories.GET("/:id", categoryHandler.GetCategory)
@@ -146,22 +211,26 @@ func main() {
 	}
 
 	orders := api.Group("/orders")
-	orders.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	orders.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist))
 	{
 		orders.GET("", orderHandler.GetUserOrders)
 		orders.GET("/:id", orderHandler.GetOrder)
-		orders.POST("", orderHandler.CreateOrder)
+		orders.POST("", middleware.RequireVerifiedEmail(db, cfg.RequireVerifiedEmail), idempotency, orderHandler.CreateOrder)
 	}
 
 	adminOrders := api.Group("/admin/orders")
-	adminOrders.Use(middleware.AuthMiddleware(cfg.JWTSecret), middleware.AdminMiddleware())
+	adminOrders.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
 	{
 		adminOrders.GET("", orderHandler.GetAllOrders)
 		adminOrders.PUT("/:id/status", orderHandler.UpdateOrderStatus)
+		adminOrders.PUT("/sub-orders/:id/status", subOrderHandler.UpdateSubOrderStatus)
+		adminOrders.GET("/:id/refunds", paymentHandler.GetOrderRefunds)
+		adminOrders.POST("/:id/refunds", paymentHandler.CreateRefund)
+		adminOrders.POST("/:id/cod-collection", paymentHandler.RecordCODCollection)
 	}
 
 	adminPartners := api.Group("/admin/partners")
-	adminPartners.Use(middleware.AuthMiddleware(cfg.JWTSecret), middleware.AdminMiddleware())
+	adminPartners.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
 	{
 		adminPartners.GET("", partnerHandler.GetAllPartners)
 		adminPartners.GET("/:id", partnerHandler.GetPartner)
@@ -169,28 +238,139 @@ func main() {
 		adminPartners.PUT("/:id", partnerHandler.UpdatePartner)
 		adminPartners.DELETE("/:id", partnerHandler.DeletePartner)
 		adminPartners.PATCH("/:id/toggle-status", partnerHandler.TogglePartnerStatus)
-		adminPartners.POST("/:id/regenerate-keys", partnerHandler.RegenerateAPIKeys)
+		adminPartners.POST("/:id/regenerate-keys", partnerKeyHandler.RegenerateAPIKeys)
+		adminPartners.GET("/:id/api-keys", partnerKeyHandler.GetAPIKeys)
+		adminPartners.POST("/:id/api-keys", partnerKeyHandler.CreateAPIKey)
+		adminPartners.POST("/:id/api-keys/:keyId/rotate", partnerKeyHandler.RotateAPIKey)
+		adminPartners.DELETE("/:id/api-keys/:keyId", partnerKeyHandler.RevokeAPIKey)
+	}
+
+	adminPayments := api.Group("/admin/payments")
+	adminPayments.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
+	{
+		adminPayments.POST("/reconcile", paymentHandler.ReconcilePayments)
+		adminPayments.GET("/reconciliation", paymentHandler.GetReconciliationReports)
+		adminPayments.POST("/reconciliation", paymentHandler.CreateReconciliationReport)
+		adminPayments.GET("/reconciliation/:id", paymentHandler.GetReconciliationReport)
+		adminPayments.GET("/:id", paymentHandler.GetPaymentDetail)
+	}
+
+	paymentRoutes := api.Group("/payments")
+	paymentRoutes.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist))
+	{
+		paymentRoutes.POST("/wallet/create", idempotency, paymentHandler.PayWithWallet)
+		paymentRoutes.POST("/:method/create", idempotency, paymentHandler.CreatePayment)
+		paymentRoutes.GET("/:method/return", paymentHandler.PaymentReturn)
+	}
+
+	wallet := api.Group("/wallet")
+	wallet.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist))
+	{
+		wallet.GET("", walletHandler.GetWallet)
+		wallet.GET("/transactions", walletHandler.GetWalletTransactions)
+		wallet.POST("/redeem", walletHandler.RedeemGiftCard)
 	}
 
-	payments := api.Group("/payments")
-	payments.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	giftCards := api.Group("/gift-cards")
+	giftCards.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist))
 	{
-		payments.POST("/vnpay/create", paymentHandler.CreateVNPayPayment)
-		payments.GET("/vnpay/return", paymentHandler.VNPayReturn)
+		giftCards.GET("", walletHandler.GetMyGiftCards)
+		giftCards.POST("/purchase", idempotency, walletHandler.PurchaseGiftCard)
 	}
 
-	api.POST("/webhooks/vnpay", webhookProxy.HandleVNPayWebhook)
+	adminGiftCards := api.Group("/admin/gift-cards")
+	adminGiftCards.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
+	{
+		adminGiftCards.GET("", walletHandler.GetAllGiftCards)
+		adminGiftCards.POST("", walletHandler.IssueGiftCards)
+		adminGiftCards.PATCH("/:id/disable", walletHandler.DisableGiftCard)
+	}
+
+	api.GET("/webhooks/:method", paymentHandler.PaymentWebhook)
+	api.POST("/webhooks/:method", paymentHandler.PaymentWebhook)
 	api.POST("/webhooks/vnpay/direct", paymentHandler.VNPayWebhook)
 
+	api.POST("/partner-applications", partnerApplicationHandler.Apply)
+
+	adminPartnerApplications := api.Group("/admin/partner-applications")
+	adminPartnerApplications.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
+	{
+		adminPartnerApplications.GET("", partnerApplicationHandler.GetApplications)
+		adminPartnerApplications.GET("/:id", partnerApplicationHandler.GetApplication)
+		adminPartnerApplications.POST("/:id/approve", partnerApplicationHandler.ApproveApplication)
+		adminPartnerApplications.POST("/:id/reject", partnerApplicationHandler.RejectApplication)
+	}
+
 	partners := api.Group("/partners")
-	partners.Use(middleware.PartnerAuthMiddleware(db))
+	partners.Use(partnerAuth)
+	{
+		scope := middleware.RequirePartnerScope
+
+		partners.GET("/profile", partnerHandler.GetProfile)
+		partners.PUT("/profile", partnerHandler.UpdateProfile)
+		partners.GET("/api-keys", partnerKeyHandler.GetPartnerAPIKeys)
+		partners.POST("/api-keys/rotate", partnerKeyHandler.RotatePartnerAPIKey)
+		partners.GET("/products", scope(services.ScopeCatalogRead), partnerHandler.GetProducts)
+		partners.POST("/products", scope(services.ScopeCatalogWrite), partnerHandler.CreateProduct)
+		partners.PUT("/products/:id", scope(services.ScopeCatalogWrite), partnerHandler.UpdateProduct)
+		partners.POST("/products/:id/submit", scope(services.ScopeCatalogWrite), partnerHandler.SubmitProduct)
+		partners.POST("/inventory/sync", scope(services.ScopeCatalogWrite), inventorySyncLimit, inventoryHandler.SyncInventory)
+		partners.GET("/products/:id/moderation", scope(services.ScopeCatalogRead), partnerHandler.GetProductModeration)
+		partners.GET("/sub-orders", scope(services.ScopeOrdersRead), subOrderHandler.GetPartnerSubOrders)
+		partners.GET("/sub-orders/:id", scope(services.ScopeOrdersRead), subOrderHandler.GetPartnerSubOrder)
+		partners.PUT("/sub-orders/:id/status", scope(services.ScopeOrdersWrite), subOrderHandler.UpdatePartnerSubOrder)
+		partners.GET("/orders", scope(services.ScopeOrdersRead), subOrderHandler.GetPartnerOrders)
+		partners.GET("/orders/:id", scope(services.ScopeOrdersRead), subOrderHandler.GetPartnerSubOrder)
+		partners.POST("/orders/:id/acknowledge", scope(services.ScopeOrdersWrite), subOrderHandler.AcknowledgeOrder)
+		partners.POST("/orders/:id/ship", scope(services.ScopeOrdersWrite), subOrderHandler.ShipOrder)
+		partners.POST("/orders/:id/items/:itemId/out-of-stock", scope(services.ScopeOrdersWrite), subOrderHandler.ReportOutOfStock)
+		partners.GET("/stats", scope(services.ScopeOrdersRead), partnerStatsHandler.GetStats)
+		partners.POST("/sandbox/orders", middleware.RequirePartnerSandbox(), scope(services.ScopeOrdersWrite), partnerSandboxHandler.CreateOrder)
+		partners.GET("/payouts", scope(services.ScopePayoutsRead), payoutHandler.GetPartnerPayouts)
+		partners.GET("/payouts/:id", scope(services.ScopePayoutsRead), payoutHandler.GetPartnerPayout)
+		partners.GET("/webhooks/deliveries", scope(services.ScopeWebhooks), webhookHandler.GetPartnerDeliveries)
+		partners.GET("/webhooks/deliveries/:id", scope(services.ScopeWebhooks), webhookHandler.GetPartnerDelivery)
+		partners.POST("/webhooks/deliveries/:id/replay", scope(services.ScopeWebhooks), webhookHandler.ReplayPartnerDelivery)
+	}
+
+	adminPayouts := api.Group("/admin/payouts")
+	adminPayouts.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
 	{
-		partners.GET("/products", partnerHandler.GetProducts)
-		partners.POST("/products", partnerHandler.CreateProduct)
-		partners.PUT("/products/:id", partnerHandler.UpdateProduct)
+		adminPayouts.GET("", payoutHandler.GetPayouts)
+		adminPayouts.POST("", payoutHandler.CreatePayouts)
+		adminPayouts.GET("/:id", payoutHandler.GetPayout)
+		adminPayouts.POST("/:id/paid", payoutHandler.MarkPayoutPaid)
 	}
 
-	api.POST("/webhooks/partner/payment", middleware.PartnerAuthMiddleware(db), partnerHandler.PaymentWebhook)
+	adminWebhooks := api.Group("/admin/webhooks")
+	adminWebhooks.Use(middleware.AuthMiddleware(cfg.JWTSecret, sessionDenylist), middleware.AdminMiddleware())
+	{
+		adminWebhooks.GET("/deliveries", webhookHandler.GetDeliveries)
+		adminWebhooks.GET("/deliveries/:id", webhookHandler.GetDelivery)
+		adminWebhooks.POST("/deliveries/:id/replay", webhookHandler.ReplayDelivery)
+	}
+
+	api.POST("/webhooks/partner/payment", partnerAuth, middleware.RequirePartnerScope(services.ScopeWebhooks), partnerHandler.PaymentWebhook)
+
+	if cfg.PaymentReconcileInterval > 0 {
+		go reconciler.Run(context.Background(), cfg.PaymentReconcileInterval)
+		log.Printf("🔁 Payment reconciler running every %s", cfg.PaymentReconcileInterval)
+	}
+
+	if cfg.WebhookPollInterval > 0 {
+		go webhookDispatcher.Run(context.Background(), cfg.WebhookPollInterval)
+		log.Printf("📬 Partner webhook worker running every %s", cfg.WebhookPollInterval)
+	}
+
+	if cfg.PartnerStatsRefreshInterval > 0 {
+		go services.RunPartnerStatsRefresher(context.Background(), db, cfg.PartnerStatsRefreshInterval)
+		log.Printf("📊 Partner stats refresher running every %s", cfg.PartnerStatsRefreshInterval)
+	}
+
+	if cfg.TokenCleanupInterval > 0 {
+		go services.RunTokenCleanup(context.Background(), db, cfg.TokenCleanupInterval)
+		log.Printf("🧹 Token cleanup running every %s", cfg.TokenCleanupInterval)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
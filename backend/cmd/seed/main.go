@@ -6,6 +6,7 @@ import (
 	"ecommerce-backend/internal/config"
 	"ecommerce-backend/internal/database"
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -220,7 +221,13 @@ func main() {
 		},
 	}
 
+	house, err := services.HousePartner(db)
+	if err != nil {
+		log.Fatal("Failed to create house partner:", err)
+	}
+
 	for i := range products {
+		products[i].PartnerID = house.ID
 		if err := db.Create(&products[i]).Error; err != nil {
 			log.Printf("Product %s might already exist", products[i].Name)
 		}
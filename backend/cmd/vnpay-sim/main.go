@@ -0,0 +1,46 @@
+// Command vnpay-sim runs a local VNPay sandbox for development. Point the API
+// at it with
+//
+//	VNPAY_URL=http://localhost:8090/paymentv2/vpcpay.html
+//	VNPAY_API_URL=http://localhost:8090/merchant_webapi/api/transaction
+//
+// and it will serve the pay page, redirect back to VNPAY_RETURN_URL and send
+// the IPN to VNPAY_SIM_IPN_URL using the same VNPAY_TMN_CODE/VNPAY_HASH_KEY.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"ecommerce-backend/internal/config"
+	"ecommerce-backend/internal/payments/vnpaysim"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	cfg := config.Load()
+	if cfg.VNPayTMNCode == "" || cfg.VNPayHashKey == "" {
+		log.Fatal("VNPAY_TMN_CODE and VNPAY_HASH_KEY must be set")
+	}
+
+	ipnURL := os.Getenv("VNPAY_SIM_IPN_URL")
+	if ipnURL == "" {
+		ipnURL = "http://localhost:8080/api/v1/webhooks/vnpay"
+	}
+
+	port := os.Getenv("VNPAY_SIM_PORT")
+	if port == "" {
+		port = "8090"
+	}
+
+	sim := vnpaysim.NewServer(cfg.VNPayTMNCode, cfg.VNPayHashKey, ipnURL)
+
+	log.Printf("🚀 VNPay simulator on http://localhost:%s%s (IPN -> %s)", port, vnpaysim.PayPath, ipnURL)
+	log.Fatal(http.ListenAndServe(":"+port, sim))
+}
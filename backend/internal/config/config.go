@@ -3,16 +3,60 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
 	DatabaseURL   string
 	JWTSecret     string
+	// PartnerSecretKey encrypts partner signing secrets at rest.
+	PartnerSecretKey string
 	Port          string
 	VNPayTMNCode  string
 	VNPayHashKey  string
 	VNPayURL      string
 	VNPayReturnURL string
+	VNPayAPIURL   string
+	MoMoPartnerCode string
+	MoMoAccessKey string
+	MoMoSecretKey string
+	MoMoEndpoint  string
+	MoMoReturnURL string
+	MoMoIPNURL    string
+	ZaloPayAppID  string
+	ZaloPayKey1   string
+	ZaloPayKey2   string
+	ZaloPayEndpoint string
+	ZaloPayReturnURL string
+	ZaloPayCallbackURL string
+	PaymentReconcileInterval time.Duration
+	PaymentPendingThreshold  time.Duration
+	PaymentExpiry            time.Duration
+	// PaymentSettlementDir holds the gateways' daily settlement files as
+	// <method>/<YYYY-MM-DD>.csv; empty reconciles by querying each payment.
+	PaymentSettlementDir     string
+	WebhookPollInterval      time.Duration
+	// PartnerStatsRefreshInterval is how often partner dashboard aggregates
+	// catch up with orders; 0 disables the refresher on this instance.
+	PartnerStatsRefreshInterval time.Duration
+	// InventorySyncRateLimit is how many inventory sync requests a partner
+	// may make per minute; 0 disables the limit.
+	InventorySyncRateLimit int
+	// PartnerKeyRotationGrace is how long a rotated partner API key keeps
+	// working by default.
+	PartnerKeyRotationGrace time.Duration
+	// FrontendURL is where links in emails to customers point.
+	FrontendURL string
+	// EmailVerificationTTL is how long an email verification link works.
+	EmailVerificationTTL time.Duration
+	// RequireVerifiedEmail blocks checkout until the customer has verified
+	// their email address.
+	RequireVerifiedEmail bool
+	// PasswordResetTTL is how long a password reset link works.
+	PasswordResetTTL time.Duration
+	// TokenCleanupInterval is how often expired refresh and password reset
+	// tokens are deleted; 0 disables the cleanup on this instance.
+	TokenCleanupInterval time.Duration
 	Environment   string
 	Debug         bool
 	CORSOrigins   []string
@@ -22,10 +66,9 @@ type Config struct {
 	SMTPPort      string
 	SMTPUser      string
 	SMTPPassword  string
+	SMTPFrom      string
 	UploadPath    string
 	BaseURL       string
-	LambdaWebhookURL string
-	UseWebhookLambda bool
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
@@ -35,8 +78,18 @@ func Load() *Config {
 	debug, _ := strconv.ParseBool(getEnv("DEBUG", "false"))
 	taxRate, _ := strconv.ParseFloat(getEnv("TAX_RATE", "0.1"), 64)
 	shippingCost, _ := strconv.ParseFloat(getEnv("SHIPPING_COST", "25000"), 64)
-	useWebhookLambda, _ := strconv.ParseBool(getEnv("USE_WEBHOOK_LAMBDA", "false"))
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
+	reconcileInterval, _ := time.ParseDuration(getEnv("PAYMENT_RECONCILE_INTERVAL", "10m"))
+	pendingThreshold, _ := time.ParseDuration(getEnv("PAYMENT_PENDING_THRESHOLD", "15m"))
+	paymentExpiry, _ := time.ParseDuration(getEnv("PAYMENT_EXPIRY", "24h"))
+	webhookPollInterval, _ := time.ParseDuration(getEnv("WEBHOOK_POLL_INTERVAL", "30s"))
+	statsRefreshInterval, _ := time.ParseDuration(getEnv("PARTNER_STATS_REFRESH_INTERVAL", "5m"))
+	inventorySyncRateLimit, _ := strconv.Atoi(getEnv("INVENTORY_SYNC_RATE_LIMIT", "10"))
+	keyRotationGrace, _ := time.ParseDuration(getEnv("PARTNER_KEY_ROTATION_GRACE", "24h"))
+	emailVerificationTTL, _ := time.ParseDuration(getEnv("EMAIL_VERIFICATION_TTL", "24h"))
+	requireVerifiedEmail, _ := strconv.ParseBool(getEnv("REQUIRE_VERIFIED_EMAIL", "false"))
+	passwordResetTTL, _ := time.ParseDuration(getEnv("PASSWORD_RESET_TTL", "30m"))
+	tokenCleanupInterval, _ := time.ParseDuration(getEnv("TOKEN_CLEANUP_INTERVAL", "1h"))
 
 	// For testing - disable Redis in dev until infrastructure is properly set up
 	redisAddr := getEnv("REDIS_ADDR", "")
@@ -48,11 +101,38 @@ func Load() *Config {
 	return &Config{
 		DatabaseURL:   getEnv("DATABASE_URL", "postgres://localhost/ecommerce?sslmode=disable"),
 		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		PartnerSecretKey: getEnv("PARTNER_SECRET_KEY", "your-partner-secret-key-change-in-production"),
 		Port:          getEnv("PORT", "8080"),
 		VNPayTMNCode:  getEnv("VNPAY_TMN_CODE", ""),
 		VNPayHashKey:  getEnv("VNPAY_HASH_KEY", ""),
 		VNPayURL:      getEnv("VNPAY_URL", "https://sandbox.vnpayment.vn/paymentv2/vpcpay.html"),
 		VNPayReturnURL: getEnv("VNPAY_RETURN_URL", "http://localhost:3000/payment/return"),
+		VNPayAPIURL:   getEnv("VNPAY_API_URL", "https://sandbox.vnpayment.vn/merchant_webapi/api/transaction"),
+		MoMoPartnerCode: getEnv("MOMO_PARTNER_CODE", ""),
+		MoMoAccessKey: getEnv("MOMO_ACCESS_KEY", ""),
+		MoMoSecretKey: getEnv("MOMO_SECRET_KEY", ""),
+		MoMoEndpoint:  getEnv("MOMO_ENDPOINT", "https://test-payment.momo.vn"),
+		MoMoReturnURL: getEnv("MOMO_RETURN_URL", "http://localhost:3000/payment/return"),
+		MoMoIPNURL:    getEnv("MOMO_IPN_URL", "http://localhost:8080/api/v1/webhooks/momo"),
+		ZaloPayAppID:  getEnv("ZALOPAY_APP_ID", ""),
+		ZaloPayKey1:   getEnv("ZALOPAY_KEY1", ""),
+		ZaloPayKey2:   getEnv("ZALOPAY_KEY2", ""),
+		ZaloPayEndpoint: getEnv("ZALOPAY_ENDPOINT", "https://sb-openapi.zalopay.vn"),
+		ZaloPayReturnURL: getEnv("ZALOPAY_RETURN_URL", "http://localhost:3000/payment/return"),
+		ZaloPayCallbackURL: getEnv("ZALOPAY_CALLBACK_URL", "http://localhost:8080/api/v1/webhooks/zalopay"),
+		PaymentReconcileInterval: reconcileInterval,
+		PaymentPendingThreshold:  pendingThreshold,
+		PaymentExpiry:            paymentExpiry,
+		PaymentSettlementDir:     getEnv("PAYMENT_SETTLEMENT_DIR", ""),
+		WebhookPollInterval:      webhookPollInterval,
+		PartnerStatsRefreshInterval: statsRefreshInterval,
+		InventorySyncRateLimit:   inventorySyncRateLimit,
+		PartnerKeyRotationGrace:  keyRotationGrace,
+		FrontendURL:              getEnv("FRONTEND_URL", "http://localhost:3000"),
+		EmailVerificationTTL:     emailVerificationTTL,
+		RequireVerifiedEmail:     requireVerifiedEmail,
+		PasswordResetTTL:         passwordResetTTL,
+		TokenCleanupInterval:     tokenCleanupInterval,
 		Environment:   getEnv("ENVIRONMENT", "development"),
 		Debug:         debug,
 		CORSOrigins:   []string{"http://localhost:3000", "http://localhost:3001"},
@@ -62,10 +142,9 @@ func Load() *Config {
 		SMTPPort:      getEnv("SMTP_PORT", "587"),
 		SMTPUser:      getEnv("SMTP_USER", ""),
 		SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:      getEnv("SMTP_FROM", "no-reply@localhost"),
 		UploadPath:    getEnv("UPLOAD_PATH", "./uploads"),
 		BaseURL:       getEnv("BASE_URL", "http://localhost:8080"),
-		LambdaWebhookURL: getEnv("LAMBDA_WEBHOOK_URL", ""),
-		UseWebhookLambda: useWebhookLambda,
 		RedisAddr:     redisAddr,
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       redisDB,
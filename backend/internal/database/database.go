@@ -73,9 +73,23 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 	return db, nil
 }
 
-func Migrate(db *gorm.DB) error {
+// Upgrade converts existing data before the models are auto-migrated.
+// Upgrades run on every start, so each must do nothing once it has been
+// applied.
+type Upgrade func(db *gorm.DB) error
+
+// Migrate brings the schema up to date with the models. The given upgrades
+// run in order before auto-migration.
+func Migrate(db *gorm.DB, upgrades ...Upgrade) error {
 	log.Println("🚀 Running database migrations with auto-migration...")
 
+	for _, upgrade := range upgrades {
+		if err := upgrade(db); err != nil {
+			log.Printf("❌ Data upgrade failed: %v", err)
+			return err
+		}
+	}
+
 	// Use the new auto-migration system
 	autoMigrator := NewAutoMigrator(db)
 	if err := autoMigrator.RunAutoMigration(); err != nil {
@@ -100,17 +114,38 @@ func NewAutoMigrator(db *gorm.DB) *AutoMigrator {
 			&models.User{},
 			&models.Category{},
 			&models.Product{},
+			&models.ProductModeration{},
 			&models.ProductImage{},
 			&models.ProductReview{},
 			&models.Cart{},
 			&models.CartItem{},
 			&models.Order{},
 			&models.OrderItem{},
+			&models.SubOrder{},
 			&models.ShippingAddress{},
 			&models.Payment{},
+			&models.Refund{},
+			&models.ReconciliationReport{},
+			&models.ReconciliationItem{},
+			&models.WalletAccount{},
+			&models.LedgerTransaction{},
+			&models.LedgerEntry{},
+			&models.GiftCard{},
+			&models.PaymentEvent{},
 			&models.Coupon{},
 			&models.Partner{},
+			&models.PartnerAPIKey{},
+			&models.PartnerApplication{},
+			&models.PartnerLedgerEntry{},
+			&models.PartnerPayout{},
+			&models.PartnerProductDailyStat{},
+			&models.AggregateWatermark{},
+			&models.WebhookDelivery{},
+			&models.WebhookAttempt{},
+			&models.IdempotencyKey{},
+			&models.Session{},
 			&models.RefreshToken{},
+			&models.PasswordResetToken{},
 			&models.AuditLog{},
 		},
 	}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"time"
 
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
 	"ecommerce-backend/pkg/auth"
 
 	"github.com/gin-gonic/gin"
@@ -17,12 +20,17 @@ import (
 )
 
 type AuthHandler struct {
-	db        *gorm.DB
-	jwtSecret string
+	db          *gorm.DB
+	jwtSecret   string
+	mailer      services.Mailer
+	verifier    *services.EmailVerifier
+	frontendURL string
+	resetTTL    time.Duration
+	sessions    *services.SessionManager
 }
 
-func NewAuthHandler(db *gorm.DB, jwtSecret string) *AuthHandler {
-	return &AuthHandler{db: db, jwtSecret: jwtSecret}
+func NewAuthHandler(db *gorm.DB, jwtSecret string, mailer services.Mailer, verifier *services.EmailVerifier, frontendURL string, resetTTL time.Duration, sessions *services.SessionManager) *AuthHandler {
+	return &AuthHandler{db: db, jwtSecret: jwtSecret, mailer: mailer, verifier: verifier, frontendURL: frontendURL, resetTTL: resetTTL, sessions: sessions}
 }
 
 type RegisterRequest struct {
@@ -93,26 +101,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, h.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
-		return
-	}
+	h.sendVerificationEmail(&user)
 
-	refreshTokenString, err := auth.GenerateRefreshToken(user.ID, h.jwtSecret)
+	accessToken, refreshTokenString, err := h.startSession(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	refreshToken := models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshTokenString,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-	}
-
-	if err := h.db.Create(&refreshToken).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 		return
 	}
 
@@ -141,26 +134,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, h.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
-		return
-	}
-
-	refreshTokenString, err := auth.GenerateRefreshToken(user.ID, h.jwtSecret)
+	accessToken, refreshTokenString, err := h.startSession(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	refreshToken := models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshTokenString,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-	}
-
-	if err := h.db.Create(&refreshToken).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 		return
 	}
 
@@ -171,6 +147,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token; the old refresh token stops working. Presenting an already
+// exchanged token revokes the whole sign-in and is logged as a security event.
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	type RefreshRequest struct {
 		RefreshToken string `json:"refresh_token" binding:"required"`
@@ -182,30 +161,69 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	var refreshToken models.RefreshToken
-	if err := h.db.Where("token = ? AND expires_at > ?", req.RefreshToken, time.Now()).First(&refreshToken).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+	session, refreshTokenString, err := h.sessions.Refresh(c.Request.Context(), req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			h.recordRefreshTokenReuse(c, session)
+		}
+		if errors.Is(err, services.ErrRefreshTokenReused) || errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
 		return
 	}
 
 	var user models.User
-	if err := h.db.Where("id = ? AND is_active = ?", refreshToken.UserID, true).First(&user).Error; err != nil {
+	if err := h.db.Where("id = ? AND is_active = ?", session.UserID, true).First(&user).Error; err != nil {
+		h.sessions.Revoke(c.Request.Context(), session.UserID, session.ID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
 
-	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, h.jwtSecret)
+	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, session.ID, h.jwtSecret)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"access_token": accessToken,
-		"user":         user,
+		"access_token":  accessToken,
+		"refresh_token": refreshTokenString,
+		"user":          user,
 	})
 }
 
+// startSession signs user in from the requesting device and returns the new
+// session's access and refresh tokens.
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User) (string, string, error) {
+	session, refreshToken, err := h.sessions.Start(user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return "", "", err
+	}
+	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, session.ID, h.jwtSecret)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// recordRefreshTokenReuse writes a security event for a reused refresh token.
+func (h *AuthHandler) recordRefreshTokenReuse(c *gin.Context, session *models.Session) {
+	log.Printf("⚠️  Refresh token reuse detected for user %d from %s; session %s revoked", session.UserID, c.ClientIP(), session.ID)
+	event := models.AuditLog{
+		UserID:     &session.UserID,
+		Action:     "refresh_token_reused",
+		Resource:   "session",
+		ResourceID: session.ID,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if err := h.db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+}
+
 func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 	var req GoogleLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -266,26 +284,9 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := auth.GenerateToken(user.ID, user.Email, user.Role, h.jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
-		return
-	}
-
-	refreshTokenString, err := auth.GenerateRefreshToken(user.ID, h.jwtSecret)
+	accessToken, refreshTokenString, err := h.startSession(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	refreshToken := models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshTokenString,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-	}
-
-	if err := h.db.Create(&refreshToken).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refresh token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 		return
 	}
 
@@ -356,18 +357,20 @@ func (h *AuthHandler) generateRandomPassword() string {
 	return string(password)
 }
 
+// Logout ends the session of the access token, which also revokes its
+// refresh token.
 func (h *AuthHandler) Logout(c *gin.Context) {
-	type LogoutRequest struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	var req LogoutRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	err := h.sessions.Revoke(c.Request.Context(), userID.(uint), c.GetString("session_id"))
+	if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
 		return
 	}
 
-	h.db.Where("token = ?", req.RefreshToken).Delete(&models.RefreshToken{})
-
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
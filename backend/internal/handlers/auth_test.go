@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshToken_ReuseRevokesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testdb.Open(t)
+	sessions := services.NewSessionManager(db, services.NewMemorySessionDenylist())
+	h := NewAuthHandler(db, "jwt-secret", services.NewLogMailer(), nil, "http://shop.local", time.Hour, sessions)
+
+	user := models.User{Email: "alice@example.com", Password: "hash", IsActive: true}
+	db.Create(&user)
+	session, first, err := sessions.Start(user.ID, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/auth/refresh", h.RefreshToken)
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(fmt.Sprintf(`{"refresh_token": %q}`, token)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := refresh(first)
+	if w.Code != http.StatusOK {
+		t.Fatalf("RefreshToken() = %d %s; want 200", w.Code, w.Body)
+	}
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+
+	// The exchanged token is presented again, as a thief holding a copy would.
+	if w := refresh(first); w.Code != http.StatusUnauthorized {
+		t.Fatalf("RefreshToken() with a reused token = %d %s; want 401", w.Code, w.Body)
+	}
+
+	var events []models.AuditLog
+	db.Where("action = ?", "refresh_token_reused").Find(&events)
+	if len(events) != 1 || events[0].ResourceID != session.ID || events[0].UserID == nil || *events[0].UserID != user.ID {
+		t.Errorf("security events = %+v; want one for session %s", events, session.ID)
+	}
+	db.First(session, "id = ?", session.ID)
+	if session.RevokedAt == nil {
+		t.Error("session was not revoked")
+	}
+	if w := refresh(body.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("RefreshToken() with the rotated token after reuse = %d %s; want 401", w.Code, w.Body)
+	}
+}
@@ -66,7 +66,7 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	}
 
 	var product models.Product
-	if err := h.db.Where("id = ? AND is_active = ?", req.ProductID, true).First(&product).Error; err != nil {
+	if err := h.db.Where("id = ? AND is_active = ? AND is_test = ?", req.ProductID, true, false).First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNotCODOrder = errors.New("order is not paid cash on delivery")
+
+// RecordCODCollection records the cash for a cash-on-delivery order as
+// collected. It completes the order's pending COD payment, creating one for
+// the amount still owed when the customer never started one, and settles the
+// order like a gateway payment would.
+func (h *PaymentHandler) RecordCODCollection(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var order models.Order
+	var payment models.Payment
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if order.PaymentMethod != "cod" {
+			return errNotCODOrder
+		}
+		if order.Status == "cancelled" || (order.PaymentStatus != "pending" && order.PaymentStatus != "partially_paid") {
+			return errOrderNotPayable
+		}
+
+		err := tx.Where("order_id = ? AND payment_method = ? AND status = ?", order.ID, "cod", "pending").First(&payment).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		paid, err := services.OrderPaidAmount(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		amount := math.Round(order.Total - paid)
+		if amount <= 0 {
+			return errInvalidPaymentAmount
+		}
+
+		payment = models.Payment{
+			OrderID:       order.ID,
+			PaymentMethod: "cod",
+			Status:        "pending",
+			Amount:        amount,
+			Currency:      "VND",
+			TransactionID: payments.NewTxnRef(order.ID),
+		}
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		case errors.Is(err, errNotCODOrder):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order is not paid cash on delivery"})
+		case errors.Is(err, errOrderNotPayable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Order payment already processed"})
+		case errors.Is(err, errInvalidPaymentAmount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order total must be greater than zero"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cash collection"})
+		}
+		return
+	}
+
+	collectedBy, _ := c.Get("user_email")
+	applied, orderPaid, err := services.TransitionPayment(h.db, &payment, payments.StatusCompleted, "",
+		fmt.Sprintf("Cash collected, recorded by %v", collectedBy))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cash collection"})
+		return
+	}
+	if !applied {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order payment already processed"})
+		return
+	}
+
+	// Partners heard about the order when it was confirmed; it is only new to
+	// them when the cash came in first.
+	if orderPaid && order.Status == "pending" {
+		emitOrderEvent(h.db, h.webhooks, order.ID, webhooks.EventOrderPlaced)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cash collection recorded",
+		"payment": payment,
+	})
+}
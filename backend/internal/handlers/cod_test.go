@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecordCODCollection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestPaymentHandler(t, payments.NewCODProvider())
+
+	r := gin.New()
+	r.POST("/admin/orders/:id/cod-collection", func(c *gin.Context) { c.Set("user_email", "admin@example.com") }, h.RecordCODCollection)
+	collect := func(orderID uint) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/orders/%d/cod-collection", orderID), nil))
+		return w
+	}
+
+	// Delivered before the cash was recorded.
+	order := models.Order{OrderNumber: "ORD-1", UserID: 1, Subtotal: 100000, Total: 100000, Status: "delivered", PaymentStatus: "pending", PaymentMethod: "cod"}
+	h.db.Create(&order)
+	pending := models.Payment{OrderID: order.ID, PaymentMethod: "cod", Status: "pending", Amount: 100000, Currency: "VND", TransactionID: "240201_1_1"}
+	h.db.Create(&pending)
+
+	if w := collect(order.ID); w.Code != http.StatusOK {
+		t.Fatalf("RecordCODCollection() = %d %s; want 200", w.Code, w.Body)
+	}
+	var payment models.Payment
+	h.db.First(&payment, pending.ID)
+	if payment.Status != "completed" || payment.ProcessedAt == nil {
+		t.Errorf("payment = %s processed at %v; want completed", payment.Status, payment.ProcessedAt)
+	}
+	var paid models.Order
+	h.db.First(&paid, order.ID)
+	if paid.PaymentStatus != "paid" || paid.Status != "delivered" {
+		t.Errorf("order = %s/%s; want paid and still delivered", paid.PaymentStatus, paid.Status)
+	}
+
+	if w := collect(order.ID); w.Code != http.StatusConflict {
+		t.Errorf("RecordCODCollection() twice = %d %s; want 409", w.Code, w.Body)
+	}
+
+	t.Run("No payment started", func(t *testing.T) {
+		order := models.Order{OrderNumber: "ORD-2", UserID: 1, Subtotal: 50000, Total: 50000, Status: "shipped", PaymentStatus: "pending", PaymentMethod: "cod"}
+		h.db.Create(&order)
+
+		if w := collect(order.ID); w.Code != http.StatusOK {
+			t.Fatalf("RecordCODCollection() = %d %s; want 200", w.Code, w.Body)
+		}
+		var collected []models.Payment
+		h.db.Where("order_id = ?", order.ID).Find(&collected)
+		if len(collected) != 1 || collected[0].Status != "completed" || collected[0].Amount != 50000 {
+			t.Errorf("payments = %+v; want one completed for 50000", collected)
+		}
+	})
+
+	t.Run("Gateway order", func(t *testing.T) {
+		order := models.Order{OrderNumber: "ORD-3", UserID: 1, Subtotal: 50000, Total: 50000, Status: "pending", PaymentStatus: "pending", PaymentMethod: "vnpay"}
+		h.db.Create(&order)
+
+		if w := collect(order.ID); w.Code != http.StatusBadRequest {
+			t.Errorf("RecordCODCollection() = %d %s; want 400", w.Code, w.Body)
+		}
+	})
+}
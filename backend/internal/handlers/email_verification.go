@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verificationResendInterval is the least time between two verification
+// emails to the same user.
+const verificationResendInterval = time.Minute
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyEmail marks the user a verification token was issued for as verified.
+// Verifying an already verified address succeeds again, so following the link
+// twice is harmless.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.verifier.UserID(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+	if err := h.verifier.Check(req.Token, user.Email, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	if !user.EmailVerified {
+		if err := h.db.Model(&user).Update("email_verified", true).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+			return
+		}
+		user.EmailVerified = true
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+		"user":    user,
+	})
+}
+
+// ResendVerification emails a new verification link to an active, unverified
+// account. It answers the same whether or not an email was sent, so it cannot
+// be used to find out which addresses have accounts.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	err := h.db.Where("LOWER(email) = ? AND is_active = ? AND email_verified = ?", strings.ToLower(req.Email), true, false).
+		First(&user).Error
+	if err == nil {
+		h.sendVerificationEmail(&user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is not verified yet, a verification email has been sent"})
+}
+
+// sendVerificationEmail emails user a verification link, unless one was sent
+// within verificationResendInterval. The send time is claimed with a
+// conditional update so concurrent requests send at most one email. The email
+// goes out in the background and failures are only logged: the user can
+// always ask for another link.
+func (h *AuthHandler) sendVerificationEmail(user *models.User) {
+	now := time.Now()
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND (verification_sent_at IS NULL OR verification_sent_at < ?)", user.ID, now.Add(-verificationResendInterval)).
+		Update("verification_sent_at", now)
+	if result.Error != nil {
+		log.Printf("Failed to record verification email for user %d: %v", user.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	token := h.verifier.Token(user.ID, user.Email, now)
+	link := strings.TrimRight(h.frontendURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	email, err := services.VerificationEmail(user, link, h.verifier.TTL())
+	if err != nil {
+		log.Printf("Failed to render verification email for user %d: %v", user.ID, err)
+		return
+	}
+	h.sendInBackground(email)
+}
+
+// sendInBackground hands email to the mailer without waiting for the relay,
+// so how long a request takes does not tell whether an email was sent.
+func (h *AuthHandler) sendInBackground(email services.Email) {
+	go func() {
+		if err := h.mailer.Send(email); err != nil {
+			log.Printf("Failed to email %s: %v", email.To, err)
+		}
+	}()
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxInventorySyncItems caps one sync request; larger catalogues are sent in
+// several batches.
+const maxInventorySyncItems = 1000
+
+type InventoryHandler struct {
+	db    *gorm.DB
+	redis *services.RedisService
+}
+
+func NewInventoryHandler(db *gorm.DB, redis *services.RedisService) *InventoryHandler {
+	return &InventoryHandler{db: db, redis: redis}
+}
+
+// InventorySyncItem sets the stock and/or price of the product with SKU.
+// Omitted fields are left unchanged.
+type InventorySyncItem struct {
+	SKU   string   `json:"sku"`
+	Stock *int     `json:"stock"`
+	Price *float64 `json:"price"`
+	Line  int      `json:"-"`
+}
+
+type InventorySyncResult struct {
+	Line           int      `json:"line"`
+	SKU            string   `json:"sku"`
+	Status         string   `json:"status"` // updated, unchanged, failed
+	Error          string   `json:"error,omitempty"`
+	Stock          *int     `json:"stock,omitempty"`
+	Price          *float64 `json:"price,omitempty"`
+	ReviewRequired bool     `json:"review_required,omitempty"`
+}
+
+// SyncInventory updates stock and prices of the calling partner's products in
+// bulk; a sandbox key syncs its test products. The batch is JSON ({"items": [{"sku", "stock", "price"}]}) or CSV
+// with a header row naming the sku, stock and price columns. Each item is
+// applied in its own transaction, so one bad SKU does not hold back the
+// rest; the response reports the outcome of every item.
+func (h *InventoryHandler) SyncInventory(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var items []InventorySyncItem
+	if c.ContentType() == "text/csv" {
+		parsed, err := parseInventoryCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		items = parsed
+	} else {
+		var req struct {
+			Items []InventorySyncItem `json:"items" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range req.Items {
+			req.Items[i].Line = i + 1
+		}
+		items = req.Items
+	}
+
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No items to sync"})
+		return
+	}
+	if len(items) > maxInventorySyncItems {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("At most %d items can be synced per request", maxInventorySyncItems)})
+		return
+	}
+
+	results := make([]InventorySyncResult, len(items))
+	var updated, unchanged, failed int
+	for i, item := range items {
+		results[i] = h.syncItem(partnerID.(uint), c.GetBool("sandbox"), item)
+		switch results[i].Status {
+		case "updated":
+			updated++
+		case "unchanged":
+			unchanged++
+		default:
+			failed++
+		}
+	}
+
+	if updated > 0 {
+		invalidateProductListCache(h.redis)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary": gin.H{
+			"total":     len(items),
+			"updated":   updated,
+			"unchanged": unchanged,
+			"failed":    failed,
+		},
+		"results": results,
+	})
+}
+
+func (h *InventoryHandler) syncItem(partnerID uint, sandbox bool, item InventorySyncItem) InventorySyncResult {
+	result := InventorySyncResult{Line: item.Line, SKU: item.SKU, Status: "failed"}
+
+	switch {
+	case item.SKU == "":
+		result.Error = "sku is required"
+		return result
+	case item.Stock == nil && item.Price == nil:
+		result.Error = "stock or price is required"
+		return result
+	case item.Stock != nil && *item.Stock < 0:
+		result.Error = "stock cannot be negative"
+		return result
+	case item.Price != nil && *item.Price <= 0:
+		result.Error = "price must be positive"
+		return result
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("sku = ? AND partner_id = ? AND is_test = ?", item.SKU, partnerID, sandbox).First(&product).Error; err != nil {
+			return err
+		}
+
+		stock, price := product.Stock, product.Price
+		updates := map[string]interface{}{}
+		if item.Stock != nil && *item.Stock != stock {
+			stock = *item.Stock
+			updates["stock"] = stock
+		}
+		priceChanged := item.Price != nil && *item.Price != price
+		if priceChanged {
+			price = *item.Price
+			updates["price"] = price
+		}
+
+		result.Stock = &stock
+		result.Price = &price
+		if len(updates) == 0 {
+			result.Status = "unchanged"
+			return nil
+		}
+
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+		// A new price goes through review like any other partner edit.
+		if priceChanged && !product.IsTest && (product.ModerationStatus == services.ModerationApproved || product.ModerationStatus == services.ModerationRejected) {
+			if err := services.ModerateProduct(tx, &product, services.ModerationPendingReview, "Price changed by inventory sync", services.ModerationActor{Type: "partner", ID: partnerID}); err != nil {
+				return err
+			}
+			result.ReviewRequired = true
+		}
+		result.Status = "updated"
+		return nil
+	})
+	if err != nil {
+		result.Status = "failed"
+		result.Stock, result.Price, result.ReviewRequired = nil, nil, false
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result.Error = "sku not found"
+		} else {
+			result.Error = "failed to update product"
+		}
+	}
+	return result
+}
+
+// parseInventoryCSV reads sync items from CSV with a header row. Column order
+// is free; sku is required and stock or price may be left empty.
+func parseInventoryCSV(r io.Reader) ([]InventorySyncItem, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV header row required")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["sku"]; !ok {
+		return nil, errors.New("CSV must have a sku column")
+	}
+
+	var items []InventorySyncItem
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		item := InventorySyncItem{SKU: field("sku"), Line: line}
+		if value := field("stock"); value != "" {
+			stock, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid stock %q", line, value)
+			}
+			item.Stock = &stock
+		}
+		if value := field("price"); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid price %q", line, value)
+			}
+			item.Price = &price
+		}
+		items = append(items, item)
+
+		if len(items) > maxInventorySyncItems {
+			return nil, fmt.Errorf("at most %d items can be synced per request", maxInventorySyncItems)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LambdaEvent is the subset of an API Gateway proxy event needed to process
+// an IPN when the handler runs inside AWS Lambda.
+type LambdaEvent struct {
+	HTTPMethod            string            `json:"httpMethod"`
+	Path                  string            `json:"path"`
+	QueryStringParameters map[string]string `json:"queryStringParameters"`
+	Headers               map[string]string `json:"headers"`
+	Body                  string            `json:"body"`
+	IsBase64Encoded       bool              `json:"isBase64Encoded"`
+	RequestContext        struct {
+		Identity struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+}
+
+type LambdaResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// PaymentWebhook receives server-to-server callbacks for the provider named by
+// the :method path parameter.
+func (h *PaymentHandler) PaymentWebhook(c *gin.Context) {
+	h.handleWebhook(c, c.Param("method"))
+}
+
+// VNPayWebhook receives VNPay IPN callbacks. VNPay sends them as GET query
+// strings, but form-encoded POSTs are accepted as well.
+func (h *PaymentHandler) VNPayWebhook(c *gin.Context) {
+	h.handleWebhook(c, "vnpay")
+}
+
+func (h *PaymentHandler) handleWebhook(c *gin.Context, method string) {
+	provider, ok := h.providers.Get(method)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+
+	// ParseForm merges the query string with form-encoded bodies; any other
+	// body (MoMo and ZaloPay post JSON) is passed through untouched.
+	if err := c.Request.ParseForm(); err != nil {
+		status, body := provider.CallbackResponse(payments.OutcomeInvalidSignature)
+		c.JSON(status, body)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		status, body := provider.CallbackResponse(payments.OutcomeInvalidSignature)
+		c.JSON(status, body)
+		return
+	}
+
+	status, resp := h.ProcessCallback(provider, payments.Callback{Params: c.Request.Form, Body: body}, c.Request.Method, c.ClientIP())
+	if resp == nil {
+		c.Status(status)
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// HandleIPNEvent processes a callback for method delivered through API
+// Gateway to Lambda.
+func (h *PaymentHandler) HandleIPNEvent(method string, event LambdaEvent) LambdaResponse {
+	provider, ok := h.providers.Get(method)
+	if !ok {
+		return lambdaJSONResponse(http.StatusNotFound, map[string]string{"error": "Unknown payment provider"})
+	}
+
+	params := url.Values{}
+	for key, value := range event.QueryStringParameters {
+		params.Set(key, value)
+	}
+
+	var body []byte
+	if event.Body != "" {
+		body = []byte(event.Body)
+		if event.IsBase64Encoded {
+			decoded, err := base64.StdEncoding.DecodeString(event.Body)
+			if err != nil {
+				return lambdaJSONResponse(provider.CallbackResponse(payments.OutcomeInvalidSignature))
+			}
+			body = decoded
+		}
+
+		if strings.HasPrefix(headerValue(event.Headers, "Content-Type"), "application/x-www-form-urlencoded") {
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return lambdaJSONResponse(provider.CallbackResponse(payments.OutcomeInvalidSignature))
+			}
+			for key, values := range form {
+				if len(values) > 0 {
+					params.Set(key, values[0])
+				}
+			}
+			body = nil
+		}
+	}
+
+	return lambdaJSONResponse(h.ProcessCallback(provider, payments.Callback{Params: params, Body: body}, event.HTTPMethod, event.RequestContext.Identity.SourceIP))
+}
+
+// ProcessCallback verifies and applies a server-to-server callback and returns
+// the reply the gateway expects. Every call is stored as a PaymentEvent
+// before the payment is touched. Replays of an already settled transaction
+// are acknowledged without changing anything, so gateway retries are safe.
+func (h *PaymentHandler) ProcessCallback(provider payments.Provider, cb payments.Callback, method, sourceIP string) (int, interface{}) {
+	payment, result, outcome := h.verifyCallback(provider, cb, nil)
+
+	event := models.PaymentEvent{
+		PaymentID:      paymentIDOf(payment),
+		Provider:       provider.Method(),
+		Type:           "ipn",
+		HTTPMethod:     method,
+		RawParams:      payments.MaskParams(cb.Params),
+		SignatureValid: outcome != payments.OutcomeInvalidSignature,
+		Outcome:        outcome.String(),
+		SourceIP:       sourceIP,
+	}
+	if len(cb.Body) > 0 {
+		event.RawParams = payments.MaskPayload(string(cb.Body))
+	}
+	if result != nil {
+		event.TxnRef = result.TxnRef
+	}
+	h.recordEvent(&event)
+
+	if outcome == payments.OutcomeOK {
+		if err := h.applyCallbackResult(payment, result); err != nil {
+			// Not acknowledged, so the gateway retries the callback.
+			return http.StatusInternalServerError, gin.H{"error": "Failed to process payment"}
+		}
+	}
+
+	return provider.CallbackResponse(outcome)
+}
+
+func lambdaJSONResponse(status int, body interface{}) LambdaResponse {
+	resp := LambdaResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}
+	if body != nil {
+		data, _ := json.Marshal(body)
+		resp.Body = string(data)
+	}
+	return resp
+}
+
+// headerValue looks up a header case-insensitively, as API Gateway passes
+// them through with whatever casing the client used.
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
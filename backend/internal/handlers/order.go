@@ -7,17 +7,23 @@ import (
 	"time"
 
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	providers *payments.Registry
+	webhooks  *webhooks.Dispatcher
 }
 
-func NewOrderHandler(db *gorm.DB) *OrderHandler {
-	return &OrderHandler{db: db}
+func NewOrderHandler(db *gorm.DB, providers *payments.Registry, dispatcher *webhooks.Dispatcher) *OrderHandler {
+	return &OrderHandler{db: db, providers: providers, webhooks: dispatcher}
 }
 
 func (h *OrderHandler) GetUserOrders(c *gin.Context) {
@@ -46,7 +52,7 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	orderID := c.Param("id")
 
 	var order models.Order
-	if err := h.db.Where("id = ? AND user_id = ?", orderID, userID).Preload("Items.Product").Preload("ShippingAddress").First(&order).Error; err != nil {
+	if err := h.db.Where("id = ? AND user_id = ?", orderID, userID).Preload("Items.Product").Preload("ShippingAddress").Preload("SubOrders").First(&order).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
@@ -76,13 +82,21 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	if req.PaymentMethod != "" && req.PaymentMethod != walletPaymentMethod {
+		if _, ok := h.providers.Get(req.PaymentMethod); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment method"})
+			return
+		}
+	}
+
 	// Calculate total
 	var total float64
 	var orderItems []models.OrderItem
 
 	for _, item := range req.Items {
 		var product models.Product
-		if err := h.db.First(&product, item.ProductID).Error; err != nil {
+		if err := h.db.Where("is_active = ? AND is_test = ? AND moderation_status = ?", true, false, services.ModerationApproved).
+			First(&product, item.ProductID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Product %d not found", item.ProductID)})
 			return
 		}
@@ -123,7 +137,19 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	req.ShippingAddress.OrderID = order.ID
 	order.ShippingAddress = req.ShippingAddress
 
-	if err := h.db.Create(&order).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := services.SplitOrder(tx, &order); err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", order.ID).Find(&order.Items).Error; err != nil {
+			return err
+		}
+		return tx.Where("order_id = ?", order.ID).Find(&order.SubOrders).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
@@ -137,6 +163,8 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	c.JSON(http.StatusCreated, order)
 }
 
+// GetAllOrders - Admin endpoint to list orders. Sandbox orders are listed
+// only with include_test=true.
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -145,8 +173,12 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	var orders []models.Order
 	var total int64
 
-	h.db.Model(&models.Order{}).Count(&total)
-	if err := h.db.Preload("User").Preload("Items.Product").Offset(offset).Limit(limit).Find(&orders).Error; err != nil {
+	query := h.db.Model(&models.Order{})
+	if c.Query("include_test") != "true" {
+		query = query.Where("is_test = ?", false)
+	}
+	query.Count(&total)
+	if err := query.Preload("User").Preload("Items.Product").Preload("SubOrders").Offset(offset).Limit(limit).Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
@@ -179,23 +211,61 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order.Status = req.Status
-	if req.Status == "shipped" {
-		now := time.Now()
-		order.ShippedAt = &now
-	} else if req.Status == "delivered" {
-		now := time.Now()
-		order.DeliveredAt = &now
-	} else if req.Status == "cancelled" {
-		now := time.Now()
-		order.CancelledAt = &now
-	}
+	previousStatus := order.Status
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		previousStatus = order.Status
+
+		var subOrders []models.SubOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", order.ID).Find(&subOrders).Error; err != nil {
+			return err
+		}
+
+		// Fulfilment statuses are applied to every sub-order that can still
+		// take them and the order's status follows from those.
+		if len(subOrders) > 0 && services.IsSubOrderStatus(req.Status) {
+			for i := range subOrders {
+				if services.SetSubOrderStatus(&subOrders[i], req.Status) != nil {
+					continue
+				}
+				if err := tx.Save(&subOrders[i]).Error; err != nil {
+					return err
+				}
+			}
+			_, err := services.SyncOrderStatus(tx, &order)
+			return err
+		}
 
-	if err := h.db.Save(&order).Error; err != nil {
+		order.Status = req.Status
+		if req.Status == "shipped" {
+			now := time.Now()
+			order.ShippedAt = &now
+		} else if req.Status == "delivered" {
+			now := time.Now()
+			order.DeliveredAt = &now
+		} else if req.Status == "cancelled" {
+			now := time.Now()
+			order.CancelledAt = &now
+		}
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		// Partners earn their share once the customer has the goods.
+		if order.Status == "delivered" {
+			_, err := services.RecordOrderEarnings(tx, order.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
 		return
 	}
 
+	emitOrderStatusEvent(h.db, h.webhooks, &order, previousStatus)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order status updated successfully",
 		"order":   order,
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateOrder_UnavailableProduct(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewOrderHandler(testdb.Open(t), payments.NewRegistry(payments.NewCODProvider()), nil)
+	r := gin.New()
+	r.POST("/orders", func(c *gin.Context) { c.Set("user_id", uint(1)) }, h.CreateOrder)
+
+	suspended := models.Product{Name: "Suspended", Slug: "suspended", SKU: "P1-1", Price: 1000, Stock: 10, PartnerID: 1}
+	h.db.Create(&suspended)
+	h.db.Model(&suspended).Update("moderation_status", services.ModerationSuspended)
+	inactive := models.Product{Name: "Inactive", Slug: "inactive", SKU: "P1-2", Price: 1000, Stock: 10, PartnerID: 1}
+	h.db.Create(&inactive)
+	h.db.Model(&inactive).Update("is_active", false)
+
+	for _, product := range []models.Product{suspended, inactive} {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"items": [{"product_id": %d, "quantity": 1}], "payment_method": "cod"}`, product.ID)
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("CreateOrder() with the %s product = %d %s; want 404", product.Name, w.Code, w.Body)
+		}
+	}
+
+	var orders int64
+	h.db.Model(&models.Order{}).Count(&orders)
+	if orders != 0 {
+		t.Errorf("orders created = %d; want 0", orders)
+	}
+}
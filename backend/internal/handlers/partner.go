@@ -1,26 +1,30 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PartnerHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	secrets  *services.SecretBox
+	webhooks *webhooks.Dispatcher
 }
 
-func NewPartnerHandler(db *gorm.DB) *PartnerHandler {
-	return &PartnerHandler{db: db}
+func NewPartnerHandler(db *gorm.DB, secrets *services.SecretBox, dispatcher *webhooks.Dispatcher) *PartnerHandler {
+	return &PartnerHandler{db: db, secrets: secrets, webhooks: dispatcher}
 }
 
 // Admin Partner Management
@@ -44,7 +48,7 @@ func (h *PartnerHandler) GetAllPartners(c *gin.Context) {
 
 	query.Count(&total)
 
-	if err := query.Offset(offset).Limit(limit).Find(&partners).Error; err != nil {
+	if err := query.Preload("APIKeys", usableAPIKeys).Offset(offset).Limit(limit).Find(&partners).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch partners"})
 		return
 	}
@@ -64,7 +68,7 @@ func (h *PartnerHandler) GetPartner(c *gin.Context) {
 	id := c.Param("id")
 
 	var partner models.Partner
-	if err := h.db.First(&partner, id).Error; err != nil {
+	if err := h.db.Preload("APIKeys", usableAPIKeys).First(&partner, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
 		} else {
@@ -83,20 +87,6 @@ type CreatePartnerRequest struct {
 	CommissionRate float64 `json:"commission_rate"`
 }
 
-// generateAPIKey generates a random API key
-func generateAPIKey() string {
-	bytes := make([]byte, 32)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
-}
-
-// generateSecretKey generates a random secret key
-func generateSecretKey() string {
-	bytes := make([]byte, 64)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
-}
-
 // CreatePartner - Admin endpoint to create a new partner
 func (h *PartnerHandler) CreatePartner(c *gin.Context) {
 	var req CreatePartnerRequest
@@ -112,21 +102,21 @@ func (h *PartnerHandler) CreatePartner(c *gin.Context) {
 		return
 	}
 
-	// Generate API and secret keys
-	apiKey := generateAPIKey()
-	secretKey := generateSecretKey()
-
 	partner := models.Partner{
 		Name:           req.Name,
 		Email:          req.Email,
-		APIKey:         apiKey,
-		SecretKey:      secretKey,
 		WebhookURL:     req.WebhookURL,
 		IsActive:       true,
 		CommissionRate: req.CommissionRate,
 	}
 
-	if err := h.db.Create(&partner).Error; err != nil {
+	var credentials *services.PartnerCredentials
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		credentials, err = services.OnboardPartner(tx, h.secrets, &partner)
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create partner"})
 		return
 	}
@@ -136,8 +126,10 @@ func (h *PartnerHandler) CreatePartner(c *gin.Context) {
 		"id": partner.ID,
 		"name": partner.Name,
 		"email": partner.Email,
-		"api_key": partner.APIKey,
-		"secret_key": partner.SecretKey,
+		"api_key": credentials.Key.APIKey,
+		"secret_key": credentials.SecretKey,
+		"webhook_secret": credentials.WebhookSecret,
+		"api_keys": []models.PartnerAPIKey{*credentials.Key},
 		"webhook_url": partner.WebhookURL,
 		"is_active": partner.IsActive,
 		"commission_rate": partner.CommissionRate,
@@ -151,6 +143,10 @@ type UpdatePartnerRequest struct {
 	Name           *string  `json:"name"`
 	Email          *string  `json:"email"`
 	WebhookURL     *string  `json:"webhook_url"`
+	ContactName    *string  `json:"contact_name"`
+	Phone          *string  `json:"phone"`
+	Address        *string  `json:"address"`
+	TaxCode        *string  `json:"tax_code"`
 	CommissionRate *float64 `json:"commission_rate"`
 	IsActive       *bool    `json:"is_active"`
 }
@@ -191,6 +187,18 @@ func (h *PartnerHandler) UpdatePartner(c *gin.Context) {
 	if req.WebhookURL != nil {
 		partner.WebhookURL = *req.WebhookURL
 	}
+	if req.ContactName != nil {
+		partner.ContactName = *req.ContactName
+	}
+	if req.Phone != nil {
+		partner.Phone = *req.Phone
+	}
+	if req.Address != nil {
+		partner.Address = *req.Address
+	}
+	if req.TaxCode != nil {
+		partner.TaxCode = *req.TaxCode
+	}
 	if req.CommissionRate != nil {
 		partner.CommissionRate = *req.CommissionRate
 	}
@@ -220,6 +228,11 @@ func (h *PartnerHandler) DeletePartner(c *gin.Context) {
 		return
 	}
 
+	if partner.IsHouse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The house partner cannot be deleted"})
+		return
+	}
+
 	if err := h.db.Delete(&partner).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete partner"})
 		return
@@ -253,42 +266,95 @@ func (h *PartnerHandler) TogglePartnerStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, partner)
 }
 
-// RegenerateAPIKeys - Admin endpoint to regenerate partner API keys
-func (h *PartnerHandler) RegenerateAPIKeys(c *gin.Context) {
-	id := c.Param("id")
+// Partner Profile (for partners to manage their own account details)
+
+type UpdatePartnerProfileRequest struct {
+	ContactName *string `json:"contact_name"`
+	Phone       *string `json:"phone"`
+	Address     *string `json:"address"`
+	WebhookURL  *string `json:"webhook_url" binding:"omitempty,url"`
+}
+
+// GetProfile returns the calling partner's account
+func (h *PartnerHandler) GetProfile(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
 
 	var partner models.Partner
-	if err := h.db.First(&partner, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch partner"})
-		}
+	if err := h.db.First(&partner, partnerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
 		return
 	}
 
-	// Generate new keys
-	newAPIKey := generateAPIKey()
-	newSecretKey := generateSecretKey()
+	c.JSON(http.StatusOK, partner)
+}
 
-	partner.APIKey = newAPIKey
-	partner.SecretKey = newSecretKey
+// UpdateProfile lets a partner change its contact details and webhook URL.
+// Name, email, tax code and commission stay under admin control.
+func (h *PartnerHandler) UpdateProfile(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
 
-	if err := h.db.Save(&partner).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate API keys"})
+	var req UpdatePartnerProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"api_key":    newAPIKey,
-		"secret_key": newSecretKey,
-	})
+	// Redirecting webhooks needs the same scope as managing deliveries.
+	if req.WebhookURL != nil {
+		scopes, _ := c.Get("partner_scopes")
+		granted, _ := scopes.([]string)
+		if !services.HasScope(granted, services.ScopeWebhooks) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + services.ScopeWebhooks + " scope"})
+			return
+		}
+	}
+
+	var partner models.Partner
+	if err := h.db.First(&partner, partnerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
+		return
+	}
+
+	var columns []string
+	if req.ContactName != nil {
+		partner.ContactName = strings.TrimSpace(*req.ContactName)
+		columns = append(columns, "contact_name")
+	}
+	if req.Phone != nil {
+		partner.Phone = strings.TrimSpace(*req.Phone)
+		columns = append(columns, "phone")
+	}
+	if req.Address != nil {
+		partner.Address = strings.TrimSpace(*req.Address)
+		columns = append(columns, "address")
+	}
+	if req.WebhookURL != nil {
+		partner.WebhookURL = *req.WebhookURL
+		columns = append(columns, "webhook_url")
+	}
+
+	if len(columns) > 0 {
+		if err := h.db.Model(&partner).Select(columns).Updates(&partner).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, partner)
 }
 
 // Partner Product Management (for partners to manage their own products)
 
 func (h *PartnerHandler) GetProducts(c *gin.Context) {
-	_, exists := c.Get("partner_id")
+	partnerID, exists := c.Get("partner_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
 		return
@@ -301,10 +367,11 @@ func (h *PartnerHandler) GetProducts(c *gin.Context) {
 	var products []models.Product
 	var total int64
 
-	// For now, return all products since Product model doesn't have PartnerID
-	// TODO: Add PartnerID field to Product model for proper partner isolation
-	query := h.db.Model(&models.Product{})
-	query.Count(&total)
+	query := partnerRecords(h.db.Model(&models.Product{}), c, partnerID)
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+		return
+	}
 
 	if err := query.Preload("Category").Offset(offset).Limit(limit).Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
@@ -330,10 +397,11 @@ type PartnerCreateProductRequest struct {
 	ImageURL    string  `json:"image_url"`
 	CategoryID  uint    `json:"category_id" binding:"required"`
 	Brand       string  `json:"brand"`
+	Draft       bool    `json:"draft"` // keep the product as a draft instead of submitting it for review
 }
 
 func (h *PartnerHandler) CreateProduct(c *gin.Context) {
-	_, exists := c.Get("partner_id")
+	partnerID, exists := c.Get("partner_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
 		return
@@ -353,7 +421,19 @@ func (h *PartnerHandler) CreateProduct(c *gin.Context) {
 
 	// Generate slug from name
 	slug := strings.ToLower(strings.ReplaceAll(req.Name, " ", "-"))
-	
+
+	// Products created with a sandbox key are test products, which have no
+	// reviewer and are approved straight away.
+	sandbox := c.GetBool("sandbox")
+	status := services.ModerationPendingReview
+	note := ""
+	if req.Draft {
+		status = services.ModerationDraft
+	} else if sandbox {
+		status = services.ModerationApproved
+		note = services.SandboxApprovalNote
+	}
+
 	product := models.Product{
 		Name:        req.Name,
 		Slug:        slug,
@@ -364,30 +444,43 @@ func (h *PartnerHandler) CreateProduct(c *gin.Context) {
 		ImageURL:    req.ImageURL,
 		CategoryID:  req.CategoryID,
 		Brand:       req.Brand,
-		IsActive:    false, // Partner products require approval
+		PartnerID:        partnerID.(uint),
+		IsActive:         status == services.ModerationApproved, // Partner products require approval first
+		ModerationStatus: status,
+		IsTest:           sandbox,
 	}
 
-	if err := h.db.Create(&product).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		// Explicitly update is_active after creation to override database default
+		if err := tx.Model(&product).Update("is_active", product.IsActive).Error; err != nil {
+			return err
+		}
+		return services.RecordModeration(tx, product.ID, "", status, note, services.ModerationActor{Type: "partner", ID: product.PartnerID})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
 
-	// Explicitly update is_active to false after creation to override database default
-	if err := h.db.Model(&product).Update("is_active", false).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set product status"})
-		return
-	}
-
 	h.db.Preload("Category").First(&product, product.ID)
 
+	message := "Product created successfully and pending approval"
+	if req.Draft {
+		message = "Product saved as draft"
+	} else if sandbox {
+		message = "Test product created and approved"
+	}
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Product created successfully and pending approval",
+		"message": message,
 		"product": product,
 	})
 }
 
 func (h *PartnerHandler) UpdateProduct(c *gin.Context) {
-	_, exists := c.Get("partner_id")
+	partnerID, exists := c.Get("partner_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
 		return
@@ -396,9 +489,9 @@ func (h *PartnerHandler) UpdateProduct(c *gin.Context) {
 	productID := c.Param("id")
 
 	var product models.Product
-	// For now, just check if product exists since Product model doesn't have PartnerID
-	// TODO: Add PartnerID field to Product model for proper partner isolation
-	if err := h.db.Where("id = ?", productID).First(&product).Error; err != nil {
+	// Another partner's product is reported as missing rather than forbidden
+	// so partners cannot probe which product IDs exist.
+	if err := partnerRecords(h.db, c, partnerID).Where("id = ?", productID).First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
@@ -415,6 +508,17 @@ func (h *PartnerHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	// Stock can change freely, but new content or a new price has to be
+	// reviewed again before it is shown to customers. Test products are never
+	// shown to customers and skip review.
+	contentChanged := product.Name != req.Name ||
+		product.Description != req.Description ||
+		product.Price != req.Price ||
+		product.SKU != req.SKU ||
+		product.ImageURL != req.ImageURL ||
+		product.CategoryID != req.CategoryID ||
+		product.Brand != req.Brand
+
 	product.Name = req.Name
 	product.Description = req.Description
 	product.Price = req.Price
@@ -424,7 +528,18 @@ func (h *PartnerHandler) UpdateProduct(c *gin.Context) {
 	product.CategoryID = req.CategoryID
 	product.Brand = req.Brand
 
-	if err := h.db.Save(&product).Error; err != nil {
+	message := "Product updated successfully"
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("moderation_status", "moderation_note", "is_active").Save(&product).Error; err != nil {
+			return err
+		}
+		if contentChanged && !product.IsTest && (product.ModerationStatus == services.ModerationApproved || product.ModerationStatus == services.ModerationRejected) {
+			message = "Product updated successfully and pending approval"
+			return services.ModerateProduct(tx, &product, services.ModerationPendingReview, "Edited by partner", services.ModerationActor{Type: "partner", ID: product.PartnerID})
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
@@ -432,61 +547,135 @@ func (h *PartnerHandler) UpdateProduct(c *gin.Context) {
 	h.db.Preload("Category").First(&product, product.ID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Product updated successfully",
+		"message": message,
 		"product": product,
 	})
 }
 
+// SubmitProduct sends one of the calling partner's draft or rejected
+// products for review. Test products are approved straight away.
+func (h *PartnerHandler) SubmitProduct(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var product models.Product
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := partnerRecords(tx.Clauses(clause.Locking{Strength: "UPDATE"}), c, partnerID).
+			Where("id = ?", c.Param("id")).First(&product).Error; err != nil {
+			return err
+		}
+		return services.SubmitForReview(tx, &product, "", services.ModerationActor{Type: "partner", ID: product.PartnerID})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case errors.Is(err, services.ErrInvalidModerationTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Only draft or rejected products can be submitted for review"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit product"})
+		}
+		return
+	}
+
+	message := "Product submitted for review"
+	if product.IsTest {
+		message = "Test product approved"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"product": product,
+	})
+}
+
+// GetProductModeration lists the moderation history of one of the calling
+// partner's products
+func (h *PartnerHandler) GetProductModeration(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var product models.Product
+	if err := partnerRecords(h.db, c, partnerID).Where("id = ?", c.Param("id")).First(&product).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	respondWithModerationHistory(c, h.db, &product)
+}
+
+// PaymentWebhookRequest settles the sandbox payment with TransactionID. The
+// request is authenticated by the partner's API key, so it carries no
+// signature of its own.
 type PaymentWebhookRequest struct {
-	OrderID       string  `json:"order_id" binding:"required"`
+	TransactionID string  `json:"transaction_id" binding:"required"`
 	PaymentID     string  `json:"payment_id" binding:"required"`
 	Status        string  `json:"status" binding:"required"`
 	Amount        float64 `json:"amount" binding:"required"`
 	Currency      string  `json:"currency"`
-	TransactionID string  `json:"transaction_id"`
-	Signature     string  `json:"signature" binding:"required"`
 }
 
+// PaymentWebhook lets a partner testing its integration settle the payment
+// of a sandbox order it has products in. Live payments are only ever settled
+// by the gateways, so live keys are refused.
 func (h *PartnerHandler) PaymentWebhook(c *gin.Context) {
 	partnerID, exists := c.Get("partner_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
 		return
 	}
+	if !c.GetBool("sandbox") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Payment webhooks are only accepted from sandbox keys"})
+		return
+	}
 
 	var req PaymentWebhookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	var partner models.Partner
-	if err := h.db.Where("id = ?", partnerID).First(&partner).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
+	if req.Status != payments.StatusCompleted && req.Status != payments.StatusFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be completed or failed"})
 		return
 	}
 
 	var payment models.Payment
-	if err := h.db.Where("transaction_id = ?", req.TransactionID).First(&payment).Error; err != nil {
+	err := h.db.Joins("JOIN orders ON orders.id = payments.order_id").
+		Where("payments.transaction_id = ? AND orders.is_test = ?", req.TransactionID, true).
+		Where("payments.order_id IN (?)", h.db.Model(&models.SubOrder{}).Select("order_id").Where("partner_id = ?", partnerID)).
+		First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+	if req.Amount != payment.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount does not match the payment"})
+		return
+	}
 
-	if req.Status == "completed" {
-		payment.Status = "completed"
-		var now = time.Now()
-		payment.ProcessedAt = &now
-		
-		h.db.Model(&models.Order{}).Where("id = ?", payment.OrderID).Updates(map[string]interface{}{
-			"payment_status": "paid",
-			"status":         "confirmed",
-		})
-	} else {
-		payment.Status = "failed"
+	applied, orderPaid, err := services.TransitionPayment(h.db, &payment, req.Status, req.PaymentID,
+		fmt.Sprintf("Partner webhook: %s", req.Status))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+	if !applied {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment already processed"})
+		return
 	}
 
-	payment.GatewayResponse = fmt.Sprintf("Partner webhook: %s", req.Status)
-	h.db.Save(&payment)
+	if orderPaid {
+		emitOrderEvent(h.db, h.webhooks, payment.OrderID, webhooks.EventOrderPlaced)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
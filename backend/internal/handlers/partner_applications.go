@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errApplicationReviewed = errors.New("application already reviewed")
+	errPartnerEmailTaken   = errors.New("partner email taken")
+)
+
+type PartnerApplicationHandler struct {
+	db      *gorm.DB
+	secrets *services.SecretBox
+	mailer  services.Mailer
+}
+
+func NewPartnerApplicationHandler(db *gorm.DB, secrets *services.SecretBox, mailer services.Mailer) *PartnerApplicationHandler {
+	return &PartnerApplicationHandler{db: db, secrets: secrets, mailer: mailer}
+}
+
+type PartnerApplicationRequest struct {
+	BusinessName string `json:"business_name" binding:"required"`
+	ContactName  string `json:"contact_name" binding:"required"`
+	Email        string `json:"email" binding:"required,email"`
+	Phone        string `json:"phone"`
+	Address      string `json:"address"`
+	TaxCode      string `json:"tax_code" binding:"required"`
+	Website      string `json:"website" binding:"omitempty,url"`
+	Description  string `json:"description"`
+}
+
+type ApproveApplicationRequest struct {
+	CommissionRate *float64 `json:"commission_rate" binding:"omitempty,min=0,max=100"`
+	Note           string   `json:"note"`
+}
+
+type RejectApplicationRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Apply - Public endpoint for a business to apply to become a partner
+func (h *PartnerApplicationHandler) Apply(c *gin.Context) {
+	var req PartnerApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	taxCode := strings.TrimSpace(req.TaxCode)
+
+	var partners int64
+	h.db.Model(&models.Partner{}).Where("LOWER(email) = ? OR tax_code = ?", email, taxCode).Count(&partners)
+	if partners > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A partner with this email or tax code already exists"})
+		return
+	}
+
+	var pending int64
+	h.db.Model(&models.PartnerApplication{}).
+		Where("status = ? AND (email = ? OR tax_code = ?)", services.ApplicationPending, email, taxCode).
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An application for this email or tax code is already under review"})
+		return
+	}
+
+	application := models.PartnerApplication{
+		BusinessName: strings.TrimSpace(req.BusinessName),
+		ContactName:  strings.TrimSpace(req.ContactName),
+		Email:        email,
+		Phone:        strings.TrimSpace(req.Phone),
+		Address:      strings.TrimSpace(req.Address),
+		TaxCode:      taxCode,
+		Website:      strings.TrimSpace(req.Website),
+		Description:  req.Description,
+		Status:       services.ApplicationPending,
+	}
+	if err := h.db.Create(&application).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit application"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Application submitted. We will email you once it has been reviewed.",
+		"id":      application.ID,
+		"status":  application.Status,
+	})
+}
+
+// GetApplications - Admin endpoint to list partner applications, oldest first.
+// Defaults to the pending review queue.
+func (h *PartnerApplicationHandler) GetApplications(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.DefaultQuery("status", services.ApplicationPending)
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.PartnerApplication{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var applications []models.PartnerApplication
+	if err := query.Order("created_at, id").Offset(offset).Limit(limit).Find(&applications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applications": applications,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetApplication - Admin endpoint to get a partner application
+func (h *PartnerApplicationHandler) GetApplication(c *gin.Context) {
+	var application models.PartnerApplication
+	if err := h.db.First(&application, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, application)
+}
+
+// ApproveApplication - Admin endpoint to approve an application. It creates
+// the partner and emails the first credentials to the applicant.
+func (h *PartnerApplicationHandler) ApproveApplication(c *gin.Context) {
+	var req ApproveApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var application models.PartnerApplication
+	var partner models.Partner
+	var credentials *services.PartnerCredentials
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&application, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if application.Status != services.ApplicationPending {
+			return errApplicationReviewed
+		}
+
+		var existing int64
+		if err := tx.Model(&models.Partner{}).Where("LOWER(email) = ?", application.Email).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errPartnerEmailTaken
+		}
+
+		partner = models.Partner{
+			Name:        application.BusinessName,
+			Email:       application.Email,
+			ContactName: application.ContactName,
+			Phone:       application.Phone,
+			Address:     application.Address,
+			TaxCode:     application.TaxCode,
+			IsActive:    true,
+		}
+		if req.CommissionRate != nil {
+			partner.CommissionRate = *req.CommissionRate
+		}
+		var err error
+		credentials, err = services.OnboardPartner(tx, h.secrets, &partner)
+		if err != nil {
+			return err
+		}
+
+		return h.review(c, tx, &application, services.ApplicationApproved, req.Note, &partner.ID)
+	})
+	if err != nil {
+		h.respondWithReviewError(c, err)
+		return
+	}
+
+	subject, body := services.PartnerApprovedEmail(&partner, credentials)
+	emailSent := h.send(application.Email, subject, body)
+
+	// The credentials are returned as well, so an admin can pass them on if
+	// the email does not arrive.
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Application approved",
+		"application":    application,
+		"partner":        partner,
+		"api_key":        credentials.Key.APIKey,
+		"secret_key":     credentials.SecretKey,
+		"webhook_secret": credentials.WebhookSecret,
+		"email_sent":     emailSent,
+	})
+}
+
+// RejectApplication - Admin endpoint to reject an application with a reason,
+// which is emailed to the applicant
+func (h *PartnerApplicationHandler) RejectApplication(c *gin.Context) {
+	var req RejectApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var application models.PartnerApplication
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&application, c.Param("id")).Error; err != nil {
+			return err
+		}
+		if application.Status != services.ApplicationPending {
+			return errApplicationReviewed
+		}
+		return h.review(c, tx, &application, services.ApplicationRejected, req.Reason, nil)
+	})
+	if err != nil {
+		h.respondWithReviewError(c, err)
+		return
+	}
+
+	subject, body := services.PartnerRejectedEmail(&application)
+	emailSent := h.send(application.Email, subject, body)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Application rejected",
+		"application": application,
+		"email_sent":  emailSent,
+	})
+}
+
+func (h *PartnerApplicationHandler) review(c *gin.Context, tx *gorm.DB, application *models.PartnerApplication, status, note string, partnerID *uint) error {
+	now := time.Now()
+	application.Status = status
+	application.ReviewNote = note
+	application.ReviewedAt = &now
+	application.PartnerID = partnerID
+	if userID, exists := c.Get("user_id"); exists {
+		reviewer := userID.(uint)
+		application.ReviewedBy = &reviewer
+	}
+	return tx.Model(application).
+		Select("status", "review_note", "reviewed_at", "reviewed_by", "partner_id").
+		Updates(application).Error
+}
+
+func (h *PartnerApplicationHandler) respondWithReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+	case errors.Is(err, errApplicationReviewed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Application has already been reviewed"})
+	case errors.Is(err, errPartnerEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "A partner with this email already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review application"})
+	}
+}
+
+// send delivers a notification. The review is already committed, so a
+// failure is logged and reported rather than undoing it.
+func (h *PartnerApplicationHandler) send(to, subject, body string) bool {
+	if err := h.mailer.Send(services.Email{To: to, Subject: subject, Text: body}); err != nil {
+		log.Printf("Failed to email %s: %v", to, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/webhooks"
+
+	"gorm.io/gorm"
+)
+
+// PartnerOrderItem is an order line as shown to the partner who sells it.
+type PartnerOrderItem struct {
+	OrderItemID uint    `json:"order_item_id"`
+	ProductID   uint    `json:"product_id"`
+	SKU         string  `json:"sku"`
+	Name        string  `json:"name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	TotalPrice  float64 `json:"total_price"`
+}
+
+// PartnerOrderEvent is the data of order webhooks. It only carries the lines
+// belonging to the receiving partner.
+type PartnerOrderEvent struct {
+	OrderID     uint               `json:"order_id"`
+	OrderNumber string             `json:"order_number"`
+	Status      string             `json:"status"`
+	Items       []PartnerOrderItem `json:"items"`
+	Total       float64            `json:"total"`
+	IsTest      bool               `json:"is_test"` // placed in the partner's sandbox
+}
+
+type PartnerProductEvent struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// emitOrderEvent sends eventType to every partner with products in the order.
+func emitOrderEvent(db *gorm.DB, dispatcher *webhooks.Dispatcher, orderID uint, eventType string) {
+	if dispatcher == nil {
+		return
+	}
+
+	var order models.Order
+	// Deleted products still belong to their partner.
+	err := db.Preload("Items.Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).First(&order, orderID).Error
+	if err != nil {
+		log.Printf("Failed to load order %d for %s webhooks: %v", orderID, eventType, err)
+		return
+	}
+
+	events := make(map[uint]*PartnerOrderEvent)
+	for _, item := range order.Items {
+		partnerID := item.Product.PartnerID
+		if partnerID == 0 {
+			continue
+		}
+		event, ok := events[partnerID]
+		if !ok {
+			event = &PartnerOrderEvent{OrderID: order.ID, OrderNumber: order.OrderNumber, Status: order.Status, IsTest: order.IsTest}
+			events[partnerID] = event
+		}
+		event.Items = append(event.Items, PartnerOrderItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			SKU:         item.Product.SKU,
+			Name:        item.Product.Name,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			TotalPrice:  item.TotalPrice,
+		})
+		event.Total += item.TotalPrice
+	}
+
+	for partnerID, event := range events {
+		dispatcher.Emit(partnerID, eventType, event)
+	}
+}
+
+// OrderPlacedNotifier returns a callback announcing a newly paid order to its
+// partners, for settlement paths outside the handlers such as the reconciler.
+func OrderPlacedNotifier(db *gorm.DB, dispatcher *webhooks.Dispatcher) func(orderID uint) {
+	return func(orderID uint) {
+		emitOrderEvent(db, dispatcher, orderID, webhooks.EventOrderPlaced)
+	}
+}
+
+// emitOrderStatusEvent notifies partners when the order moved to a status
+// they act on. Prepaid orders are placed once paid; a COD order is placed when
+// an admin confirms it.
+func emitOrderStatusEvent(db *gorm.DB, dispatcher *webhooks.Dispatcher, order *models.Order, previousStatus string) {
+	if order.Status == previousStatus {
+		return
+	}
+	switch order.Status {
+	case "confirmed":
+		if order.PaymentMethod == "cod" && previousStatus == "pending" {
+			emitOrderEvent(db, dispatcher, order.ID, webhooks.EventOrderPlaced)
+		}
+	case "shipped":
+		emitOrderEvent(db, dispatcher, order.ID, webhooks.EventOrderShipped)
+	case "cancelled":
+		emitOrderEvent(db, dispatcher, order.ID, webhooks.EventOrderCancelled)
+	}
+}
+
+func emitProductEvent(dispatcher *webhooks.Dispatcher, product *models.Product, eventType, reason string) {
+	if dispatcher == nil || product.PartnerID == 0 {
+		return
+	}
+	dispatcher.Emit(product.PartnerID, eventType, PartnerProductEvent{
+		ProductID: product.ID,
+		SKU:       product.SKU,
+		Name:      product.Name,
+		Reason:    reason,
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+
+	"gorm.io/gorm"
+)
+
+// createPartnerOrder creates an order for one product of a partner that
+// receives webhooks.
+func createPartnerOrder(t *testing.T, db *gorm.DB, paymentMethod string) models.Order {
+	t.Helper()
+	partner := models.Partner{Name: "Acme", Email: paymentMethod + "@example.com", IsActive: true, WebhookURL: "http://partner.local/hooks"}
+	if err := db.Create(&partner).Error; err != nil {
+		t.Fatalf("creating partner: %v", err)
+	}
+	product := models.Product{Name: "Widget", SKU: "W-" + paymentMethod, Price: 50000, Stock: 10, PartnerID: partner.ID}
+	db.Create(&product)
+
+	order := models.Order{
+		OrderNumber:   "ORD-" + paymentMethod,
+		UserID:        1,
+		Subtotal:      50000,
+		Total:         50000,
+		Status:        "pending",
+		PaymentStatus: "pending",
+		PaymentMethod: paymentMethod,
+		Items:         []models.OrderItem{{ProductID: product.ID, Quantity: 1, UnitPrice: 50000, TotalPrice: 50000}},
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	return order
+}
+
+func countOrderPlaced(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&models.WebhookDelivery{}).Where("event_type = ?", webhooks.EventOrderPlaced).Count(&count)
+	return count
+}
+
+func TestOrderPlacedEvent(t *testing.T) {
+	t.Run("Gateway payment", func(t *testing.T) {
+		h := newTestPaymentHandler(t)
+		h.webhooks = webhooks.NewDispatcher(h.db, services.NewSecretBox("encryption-key"))
+		order := createPartnerOrder(t, h.db, "vnpay")
+		if got := countOrderPlaced(h.db); got != 0 {
+			t.Fatalf("order.placed deliveries before payment = %d; want 0", got)
+		}
+
+		payment := models.Payment{OrderID: order.ID, PaymentMethod: "vnpay", Status: "pending", Amount: 50000, Currency: "VND", TransactionID: "240201_1_1"}
+		h.db.Create(&payment)
+		result := &payments.CallbackResult{TxnRef: payment.TransactionID, Success: true, Amount: 50000, Currency: "VND", ResponseCode: "00"}
+
+		if err := h.applyCallbackResult(&payment, result); err != nil {
+			t.Fatalf("applyCallbackResult: %v", err)
+		}
+		if got := countOrderPlaced(h.db); got != 1 {
+			t.Errorf("order.placed deliveries after payment = %d; want 1", got)
+		}
+
+		// A repeated callback does not announce the order again.
+		if err := h.applyCallbackResult(&payment, result); err != nil {
+			t.Fatalf("applyCallbackResult: %v", err)
+		}
+		if got := countOrderPlaced(h.db); got != 1 {
+			t.Errorf("order.placed deliveries after a repeated callback = %d; want 1", got)
+		}
+	})
+
+	t.Run("COD confirmed", func(t *testing.T) {
+		h := newTestPaymentHandler(t)
+		dispatcher := webhooks.NewDispatcher(h.db, services.NewSecretBox("encryption-key"))
+		order := createPartnerOrder(t, h.db, "cod")
+
+		order.Status = "confirmed"
+		emitOrderStatusEvent(h.db, dispatcher, &order, "pending")
+		if got := countOrderPlaced(h.db); got != 1 {
+			t.Errorf("order.placed deliveries after confirming = %d; want 1", got)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxRotationGrace bounds how long a rotated key may keep working.
+const maxRotationGrace = 30 * 24 * time.Hour
+
+type PartnerKeyHandler struct {
+	db      *gorm.DB
+	secrets *services.SecretBox
+	// grace is how long a rotated key keeps working by default.
+	grace time.Duration
+}
+
+func NewPartnerKeyHandler(db *gorm.DB, secrets *services.SecretBox, rotationGrace time.Duration) *PartnerKeyHandler {
+	return &PartnerKeyHandler{db: db, secrets: secrets, grace: rotationGrace}
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Sandbox   bool       `json:"sandbox"` // issue a test key for the partner's sandbox
+}
+
+type RotateAPIKeyRequest struct {
+	// GracePeriod overrides the default grace period, e.g. "72h". "0s"
+	// revokes the old key immediately.
+	GracePeriod string `json:"grace_period"`
+}
+
+// usableAPIKeys limits a preload to keys that can still authenticate,
+// newest first.
+func usableAPIKeys(db *gorm.DB) *gorm.DB {
+	return db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).Order("created_at desc")
+}
+
+// GetAPIKeys - Admin endpoint to list a partner's API keys, including
+// revoked and expired ones
+func (h *PartnerKeyHandler) GetAPIKeys(c *gin.Context) {
+	var keys []models.PartnerAPIKey
+	if err := h.db.Where("partner_id = ?", c.Param("id")).Order("created_at desc").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey - Admin endpoint to issue a named, scoped key to a partner
+func (h *PartnerKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes, err := services.NormalizeScopes(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "valid_scopes": services.AllPartnerScopes})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	var partner models.Partner
+	if err := h.db.First(&partner, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
+		return
+	}
+	if partner.IsHouse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The house partner cannot have API keys"})
+		return
+	}
+
+	key, secret, err := services.IssueAPIKey(h.db, h.secrets, partner.ID, req.Name, scopes, req.ExpiresAt, req.Sandbox)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	respondWithNewAPIKey(c, http.StatusCreated, key, secret, nil)
+}
+
+// RotateAPIKey - Admin endpoint to replace a partner's key. The old key keeps
+// working for the grace period.
+func (h *PartnerKeyHandler) RotateAPIKey(c *gin.Context) {
+	h.rotate(c, c.Param("id"), c.Param("keyId"))
+}
+
+// RevokeAPIKey - Admin endpoint to stop a key working immediately
+func (h *PartnerKeyHandler) RevokeAPIKey(c *gin.Context) {
+	now := time.Now()
+	result := h.db.Model(&models.PartnerAPIKey{}).
+		Where("id = ? AND partner_id = ? AND revoked_at IS NULL", c.Param("keyId"), c.Param("id")).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Active API key not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// RegenerateAPIKeys - Admin endpoint to issue a new key with every scope and
+// retire all of the partner's other live keys after the default grace period.
+// Sandbox keys are left alone.
+func (h *PartnerKeyHandler) RegenerateAPIKeys(c *gin.Context) {
+	var partner models.Partner
+	if err := h.db.First(&partner, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Partner not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch partner"})
+		}
+		return
+	}
+	if partner.IsHouse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The house partner cannot have API keys"})
+		return
+	}
+
+	var key *models.PartnerAPIKey
+	var secret string
+	var active []models.PartnerAPIKey
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("partner_id = ? AND sandbox = ?", partner.ID, false).Scopes(usableAPIKeys).Find(&active).Error; err != nil {
+			return err
+		}
+
+		scopes, _ := services.NormalizeScopes(services.AllPartnerScopes)
+		var err error
+		key, secret, err = services.IssueAPIKey(tx, h.secrets, partner.ID, "Default", scopes, nil, false)
+		if err != nil {
+			return err
+		}
+
+		for i := range active {
+			if err := services.RetireAPIKey(tx, &active[i], h.grace); err != nil {
+				return err
+			}
+			if err := tx.Model(&active[i]).Update("replaced_by_id", key.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate API keys"})
+		return
+	}
+
+	var previousExpiry *time.Time
+	if len(active) > 0 {
+		previousExpiry = active[0].ExpiresAt
+		if active[0].RevokedAt != nil {
+			previousExpiry = active[0].RevokedAt
+		}
+	}
+	respondWithNewAPIKey(c, http.StatusOK, key, secret, previousExpiry)
+}
+
+// GetPartnerAPIKeys lists the calling partner's usable keys
+func (h *PartnerKeyHandler) GetPartnerAPIKeys(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var keys []models.PartnerAPIKey
+	if err := h.db.Where("partner_id = ?", partnerID).Scopes(usableAPIKeys).Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RotatePartnerAPIKey replaces the key the request was signed with. The
+// partner deploys the new credentials while the old key is still accepted.
+func (h *PartnerKeyHandler) RotatePartnerAPIKey(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+	keyID, _ := c.Get("api_key_id")
+
+	h.rotate(c, partnerID, keyID)
+}
+
+func (h *PartnerKeyHandler) rotate(c *gin.Context, partnerID, keyID interface{}) {
+	var req RotateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grace := h.grace
+	if req.GracePeriod != "" {
+		parsed, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || parsed < 0 || parsed > maxRotationGrace {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period must be a duration between 0s and 720h"})
+			return
+		}
+		grace = parsed
+	}
+
+	var old models.PartnerAPIKey
+	var key *models.PartnerAPIKey
+	var secret string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("partner_id = ?", partnerID).First(&old, keyID).Error; err != nil {
+			return err
+		}
+		if !services.APIKeyUsable(&old, time.Now()) || old.ReplacedByID != nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		var err error
+		key, secret, err = services.RotateAPIKey(tx, h.secrets, &old, grace)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Active API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key"})
+		return
+	}
+
+	previousExpiry := old.ExpiresAt
+	if old.RevokedAt != nil {
+		previousExpiry = old.RevokedAt
+	}
+	respondWithNewAPIKey(c, http.StatusOK, key, secret, previousExpiry)
+}
+
+func respondWithNewAPIKey(c *gin.Context, status int, key *models.PartnerAPIKey, secret string, previousExpiry *time.Time) {
+	response := gin.H{
+		"key":        key,
+		"api_key":    key.APIKey,
+		"secret_key": secret,
+		"message":    "Please save the secret key as it will not be shown again.",
+	}
+	if previousExpiry != nil {
+		response["previous_key_expires_at"] = previousExpiry
+	}
+	c.JSON(status, response)
+}
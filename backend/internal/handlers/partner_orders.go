@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxPartnerOrdersLimit = 100
+
+var errOrderItemNotFound = errors.New("order item not found")
+
+type ShipSubOrderRequest struct {
+	Carrier        string `json:"carrier" binding:"required"`
+	TrackingNumber string `json:"tracking_number" binding:"required"`
+}
+
+// GetPartnerOrders is the calling partner's order feed: its sub-orders of
+// paid or confirmed cash-on-delivery orders, each holding only the partner's
+// own lines, newest first. Filters are status,
+// created_from and created_to (RFC 3339). Pages are fetched by passing the
+// previous response's next_cursor as cursor.
+func (h *SubOrderHandler) GetPartnerOrders(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > maxPartnerOrdersLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	query := services.FulfillableOrders(h.db, partnerRecords(h.db, c, partnerID))
+	if status := c.Query("status"); status != "" {
+		if !services.IsSubOrderStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+	for param, condition := range map[string]string{"created_from": "created_at >= ?", "created_to": "created_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 timestamp"})
+			return
+		}
+		query = query.Where(condition, at)
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		createdAt, id, err := services.DecodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = query.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	// One extra row tells whether another page follows.
+	var subOrders []models.SubOrder
+	if err := query.Preload("Items.Product").Preload("ShippingAddress").
+		Order("created_at desc, id desc").Limit(limit + 1).Find(&subOrders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	hasMore := len(subOrders) > limit
+	var nextCursor string
+	if hasMore {
+		subOrders = subOrders[:limit]
+		last := subOrders[limit-1]
+		nextCursor = services.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders":      subOrders,
+		"next_cursor": nextCursor,
+		"has_more":    hasMore,
+	})
+}
+
+// AcknowledgeOrder confirms the partner has seen a sub-order and is preparing
+// it, moving it to processing
+func (h *SubOrderHandler) AcknowledgeOrder(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	subOrder, order, err := h.changeSubOrder(partnerRecords(h.db, c, partnerID), c.Param("id"), func(tx *gorm.DB, subOrder *models.SubOrder) error {
+		return services.SetSubOrderStatus(subOrder, "processing")
+	})
+	if err != nil {
+		respondWithSubOrderError(c, err, subOrder, "processing")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Order acknowledged",
+		"sub_order":    subOrder,
+		"order_status": order.Status,
+	})
+}
+
+// ShipOrder marks a sub-order shipped with its carrier and tracking number.
+// Lines reported out of stock are not part of the shipment.
+func (h *SubOrderHandler) ShipOrder(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var req ShipSubOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subOrder, order, err := h.changeSubOrder(partnerRecords(h.db, c, partnerID), c.Param("id"), func(tx *gorm.DB, subOrder *models.SubOrder) error {
+		if err := services.SetSubOrderStatus(subOrder, "shipped"); err != nil {
+			return err
+		}
+		subOrder.Carrier = req.Carrier
+		subOrder.TrackingNumber = req.TrackingNumber
+		return nil
+	})
+	if err != nil {
+		respondWithSubOrderError(c, err, subOrder, "shipped")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Order marked as shipped",
+		"sub_order":    subOrder,
+		"order_status": order.Status,
+	})
+}
+
+// ReportOutOfStock drops a line the partner cannot supply from its sub-order
+// before it ships. A sub-order left with nothing to ship is cancelled. The
+// customer's refund for the line is issued by an admin.
+func (h *SubOrderHandler) ReportOutOfStock(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var item models.OrderItem
+	var cancelled bool
+	subOrder, order, err := h.changeSubOrder(partnerRecords(h.db, c, partnerID), c.Param("id"), func(tx *gorm.DB, subOrder *models.SubOrder) error {
+		if err := tx.Where("sub_order_id = ?", subOrder.ID).First(&item, c.Param("itemId")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errOrderItemNotFound
+			}
+			return err
+		}
+		var err error
+		cancelled, err = services.MarkItemOutOfStock(tx, subOrder, &item)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errOrderItemNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order line not found"})
+		case errors.Is(err, services.ErrInvalidSubOrderTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Lines can only be reported out of stock before the order ships"})
+		default:
+			respondWithSubOrderError(c, err, subOrder, "")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Line reported out of stock",
+		"item":                item,
+		"sub_order":           subOrder,
+		"sub_order_cancelled": cancelled,
+		"order_status":        order.Status,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newPartnerOrderRouter serves the partner order endpoints as partner 1
+// authenticated with a live key.
+func newPartnerOrderRouter(t *testing.T) (*SubOrderHandler, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	h := NewSubOrderHandler(testdb.Open(t), nil)
+	r := gin.New()
+	partner := r.Group("/partner", func(c *gin.Context) {
+		c.Set("partner_id", uint(1))
+		c.Set("sandbox", false)
+	})
+	partner.GET("/orders", h.GetPartnerOrders)
+	partner.POST("/orders/:id/acknowledge", h.AcknowledgeOrder)
+	partner.POST("/orders/:id/ship", h.ShipOrder)
+	return h, r
+}
+
+// createSubOrder creates an order in the given payment state with one
+// pending sub-order for partner 1.
+func createSubOrder(t *testing.T, h *SubOrderHandler, number, paymentMethod, paymentStatus, status string) models.SubOrder {
+	t.Helper()
+	order := models.Order{OrderNumber: number, UserID: 1, Subtotal: 1000, Total: 1000, PaymentMethod: paymentMethod, PaymentStatus: paymentStatus, Status: status}
+	if err := h.db.Create(&order).Error; err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	subOrder := models.SubOrder{OrderID: order.ID, PartnerID: 1, Number: number + "-1", Status: "pending", Subtotal: 1000}
+	if err := h.db.Create(&subOrder).Error; err != nil {
+		t.Fatalf("creating sub-order: %v", err)
+	}
+	return subOrder
+}
+
+func TestPartnerOrders_OnlyPaidOrConfirmedCOD(t *testing.T) {
+	h, r := newPartnerOrderRouter(t)
+
+	unpaid := createSubOrder(t, h, "ORD-1", "vnpay", "pending", "pending")
+	paid := createSubOrder(t, h, "ORD-2", "vnpay", "paid", "confirmed")
+	codPending := createSubOrder(t, h, "ORD-3", "cod", "pending", "pending")
+	codConfirmed := createSubOrder(t, h, "ORD-4", "cod", "pending", "confirmed")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partner/orders", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetPartnerOrders() = %d %s", w.Code, w.Body)
+	}
+	var body struct {
+		Orders []models.SubOrder `json:"orders"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	listed := make(map[uint]bool)
+	for _, subOrder := range body.Orders {
+		listed[subOrder.ID] = true
+	}
+	if len(body.Orders) != 2 || !listed[paid.ID] || !listed[codConfirmed.ID] {
+		t.Errorf("GetPartnerOrders() listed %v; want only the paid and confirmed COD sub-orders", listed)
+	}
+
+	tests := []struct {
+		name     string
+		subOrder models.SubOrder
+		expected int
+	}{
+		{"Unpaid", unpaid, http.StatusConflict},
+		{"COD awaiting confirmation", codPending, http.StatusConflict},
+		{"Paid", paid, http.StatusOK},
+		{"Confirmed COD", codConfirmed, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/partner/orders/%d/acknowledge", tt.subOrder.ID), nil))
+			if w.Code != tt.expected {
+				t.Errorf("AcknowledgeOrder() = %d %s; want %d", w.Code, w.Body, tt.expected)
+			}
+
+			w = httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/partner/orders/%d/ship", tt.subOrder.ID), strings.NewReader(`{"carrier": "GHN", "tracking_number": "GHN123"}`))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("ShipOrder() = %d %s; want %d", w.Code, w.Body, tt.expected)
+			}
+
+			var subOrder models.SubOrder
+			h.db.First(&subOrder, tt.subOrder.ID)
+			if tt.expected == http.StatusConflict && subOrder.Status != "pending" {
+				t.Errorf("sub-order status = %s; want it left pending", subOrder.Status)
+			}
+		})
+	}
+}
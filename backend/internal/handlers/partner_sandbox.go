@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errSandboxOutOfStock = errors.New("insufficient stock")
+
+// partnerRecords limits query to the calling partner's rows in the mode of
+// its API key: sandbox keys only see test products and orders, and live keys
+// never do.
+func partnerRecords(query *gorm.DB, c *gin.Context, partnerID interface{}) *gorm.DB {
+	return query.Where("partner_id = ? AND is_test = ?", partnerID, c.GetBool("sandbox"))
+}
+
+// PartnerSandboxHandler lets partners place test orders against their test
+// products, so they can exercise order flows without real customers.
+type PartnerSandboxHandler struct {
+	db       *gorm.DB
+	provider payments.Provider
+	webhooks *webhooks.Dispatcher
+}
+
+func NewPartnerSandboxHandler(db *gorm.DB, provider payments.Provider, dispatcher *webhooks.Dispatcher) *PartnerSandboxHandler {
+	return &PartnerSandboxHandler{db: db, provider: provider, webhooks: dispatcher}
+}
+
+type SandboxOrderItem struct {
+	SKU      string `json:"sku" binding:"required"`
+	Quantity int    `json:"quantity" binding:"required,min=1"`
+}
+
+type CreateSandboxOrderRequest struct {
+	Items []SandboxOrderItem `json:"items" binding:"required,min=1,dive"`
+	// AutoCompletePayment settles the payment straight away. Set it to false
+	// to settle it through the partner payment webhook instead.
+	AutoCompletePayment *bool `json:"auto_complete_payment"`
+}
+
+// sandboxShippingAddress is where every sandbox order is "shipped".
+func sandboxShippingAddress() models.ShippingAddress {
+	return models.ShippingAddress{
+		FirstName:  "Sandbox",
+		LastName:   "Customer",
+		Address1:   "1 Test Street",
+		City:       "Ho Chi Minh City",
+		PostalCode: "700000",
+		Country:    "VN",
+		Phone:      "0900000000",
+	}
+}
+
+// CreateOrder places a test order for the calling partner's test products as
+// the sandbox customer, and pays it through the sandbox gateway. The order
+// then goes through the order feed, fulfilment actions and webhooks like a
+// real one. Its payment is completed at once unless auto_complete_payment is
+// false, in which case the partner completes it by posting the returned
+// transaction_id to the payment webhook.
+func (h *PartnerSandboxHandler) CreateOrder(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var req CreateSandboxOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	autoComplete := req.AutoCompletePayment == nil || *req.AutoCompletePayment
+
+	var total float64
+	var orderItems []models.OrderItem
+	for _, item := range req.Items {
+		var product models.Product
+		if err := partnerRecords(h.db, c, partnerID).Where("sku = ?", item.SKU).First(&product).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Test product %s not found", item.SKU)})
+			return
+		}
+
+		itemTotal := product.Price * float64(item.Quantity)
+		total += itemTotal
+		orderItems = append(orderItems, models.OrderItem{
+			ProductID:  product.ID,
+			Quantity:   item.Quantity,
+			UnitPrice:  product.Price,
+			TotalPrice: itemTotal,
+		})
+	}
+
+	customer, err := services.SandboxCustomer(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		return
+	}
+
+	now := time.Now()
+	order := models.Order{
+		OrderNumber:     fmt.Sprintf("TEST-%d-%d", partnerID, now.UnixNano()),
+		UserID:          customer.ID,
+		Status:          "pending",
+		PaymentStatus:   "pending",
+		PaymentMethod:   h.provider.Method(),
+		Subtotal:        total,
+		Total:           total,
+		Currency:        "VND",
+		Notes:           "Partner sandbox order",
+		OrderDate:       now,
+		Items:           orderItems,
+		ShippingAddress: sandboxShippingAddress(),
+		IsTest:          true,
+	}
+
+	var payment models.Payment
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := services.SplitOrder(tx, &order); err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			result := tx.Model(&models.Product{}).Where("id = ? AND stock >= ?", item.ProductID, item.Quantity).
+				Update("stock", gorm.Expr("stock - ?", item.Quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errSandboxOutOfStock
+			}
+		}
+
+		payment = models.Payment{
+			OrderID:       order.ID,
+			PaymentMethod: h.provider.Method(),
+			Status:        "pending",
+			Amount:        total,
+			Currency:      "VND",
+			TransactionID: payments.NewTxnRef(order.ID),
+		}
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		if errors.Is(err, errSandboxOutOfStock) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock for a test product"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		return
+	}
+
+	result, err := h.provider.Initiate(c.Request.Context(), payments.InitiateRequest{
+		TxnRef:    payment.TransactionID,
+		Amount:    int64(math.Round(payment.Amount)),
+		OrderInfo: fmt.Sprintf("Payment for order %s", order.OrderNumber),
+		IPAddr:    c.ClientIP(),
+		CreatedAt: payment.CreatedAt,
+	})
+	if err != nil {
+		h.db.Model(&payment).Updates(map[string]interface{}{"status": "failed", "gateway_response": err.Error()})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to initiate sandbox payment"})
+		return
+	}
+	h.db.Model(&payment).Update("gateway_response", result.RawResponse)
+
+	if autoComplete {
+		_, orderPaid, err := services.TransitionPayment(h.db, &payment, payments.StatusCompleted, "SANDBOX_"+payment.TransactionID,
+			"Sandbox: completed automatically")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete sandbox payment"})
+			return
+		}
+		if orderPaid {
+			emitOrderEvent(h.db, h.webhooks, order.ID, webhooks.EventOrderPlaced)
+		}
+	}
+
+	h.db.Preload("Items.Product").Preload("SubOrders").Preload("ShippingAddress").First(&order, order.ID)
+	h.db.First(&payment, payment.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"order":          order,
+		"payment":        payment,
+		"transaction_id": payment.TransactionID,
+	})
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultStatsRange = 30 * 24 * time.Hour
+	maxStatsRange     = 2 * 366 * 24 * time.Hour
+	maxTopProducts    = 50
+)
+
+type PartnerStatsHandler struct {
+	db *gorm.DB
+}
+
+func NewPartnerStatsHandler(db *gorm.DB) *PartnerStatsHandler {
+	return &PartnerStatsHandler{db: db}
+}
+
+type partnerStatsPeriod struct {
+	Period         string  `json:"period"`
+	Revenue        float64 `json:"revenue"`
+	UnitsSold      int     `json:"units_sold"`
+	RefundedAmount float64 `json:"refunded_amount"`
+	ReturnRate     float64 `json:"return_rate"`
+	ReviewCount    int     `json:"review_count"`
+	AverageRating  float64 `json:"average_rating"`
+	ratingSum      int
+}
+
+func (p *partnerStatsPeriod) computeRates() {
+	p.ReturnRate = services.ReturnRate(p.RefundedAmount, p.Revenue)
+	p.AverageRating = services.AverageRating(p.ratingSum, p.ReviewCount)
+}
+
+type partnerTopProduct struct {
+	ProductID      uint    `json:"product_id"`
+	Name           string  `json:"name"`
+	SKU            string  `json:"sku"`
+	Orders         int     `json:"orders"`
+	UnitsSold      int     `json:"units_sold"`
+	Revenue        float64 `json:"revenue"`
+	RefundedAmount float64 `json:"refunded_amount"`
+}
+
+// GetStats returns the calling partner's sales dashboard for from..to
+// (YYYY-MM-DD, inclusive, UTC; the last 30 days by default) bucketed by
+// interval (day, week or month). Figures come from the daily aggregates kept
+// by the stats refresher, so they lag orders by up to one refresh interval;
+// refreshed_at says how far they have caught up.
+func (h *PartnerStatsHandler) GetStats(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to, err := parseStatsDate(c.Query("to"), today)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2024-01-31"})
+		return
+	}
+	from, err := parseStatsDate(c.Query("from"), to.Add(-defaultStatsRange+24*time.Hour))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2024-01-01"})
+		return
+	}
+	if from.After(to) || to.Sub(from) > maxStatsRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to and the range at most two years"})
+		return
+	}
+
+	interval := c.DefaultQuery("interval", services.StatsIntervalDay)
+	if !services.IsStatsInterval(interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be day, week or month"})
+		return
+	}
+
+	top, _ := strconv.Atoi(c.DefaultQuery("top", "5"))
+	if top <= 0 || top > maxTopProducts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "top must be between 1 and 50"})
+		return
+	}
+
+	var days []struct {
+		Day            time.Time
+		Revenue        float64
+		UnitsSold      int
+		RefundedAmount float64
+		ReviewCount    int
+		RatingSum      int
+	}
+	if err := h.db.Model(&models.PartnerProductDailyStat{}).
+		Select("day, SUM(revenue) AS revenue, SUM(units_sold) AS units_sold, SUM(refunded_amount) AS refunded_amount, SUM(review_count) AS review_count, SUM(rating_sum) AS rating_sum").
+		Where("partner_id = ? AND day BETWEEN ? AND ?", partnerID, from, to).
+		Group("day").Scan(&days).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statistics"})
+		return
+	}
+
+	periods := services.StatsPeriods(from, to, interval)
+	series := make([]partnerStatsPeriod, len(periods))
+	index := make(map[time.Time]int, len(periods))
+	for i, period := range periods {
+		series[i].Period = period.Format("2006-01-02")
+		index[period] = i
+	}
+
+	var totals partnerStatsPeriod
+	for _, day := range days {
+		bucket := &series[index[services.StatsPeriodStart(day.Day, interval)]]
+		for _, p := range []*partnerStatsPeriod{bucket, &totals} {
+			p.Revenue += day.Revenue
+			p.UnitsSold += day.UnitsSold
+			p.RefundedAmount += day.RefundedAmount
+			p.ReviewCount += day.ReviewCount
+			p.ratingSum += day.RatingSum
+		}
+	}
+	totals.computeRates()
+	for i := range series {
+		series[i].computeRates()
+	}
+
+	var topProducts []partnerTopProduct
+	if err := h.db.Table("partner_product_daily_stats s").
+		Select("s.product_id, p.name, p.sku, SUM(s.orders) AS orders, SUM(s.units_sold) AS units_sold, SUM(s.revenue) AS revenue, SUM(s.refunded_amount) AS refunded_amount").
+		Joins("LEFT JOIN products p ON p.id = s.product_id").
+		Where("s.partner_id = ? AND s.day BETWEEN ? AND ? AND s.units_sold > 0", partnerID, from, to).
+		Group("s.product_id, p.name, p.sku").
+		Order("revenue DESC, units_sold DESC").Limit(top).
+		Scan(&topProducts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statistics"})
+		return
+	}
+
+	var pendingApproval int64
+	h.db.Model(&models.Product{}).
+		Where("partner_id = ? AND moderation_status = ?", partnerID, services.ModerationPendingReview).
+		Count(&pendingApproval)
+
+	var watermark models.AggregateWatermark
+	h.db.Where("name = ?", services.PartnerStatsAggregate).Limit(1).Find(&watermark)
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"interval": interval,
+		"totals": gin.H{
+			"revenue":         totals.Revenue,
+			"units_sold":      totals.UnitsSold,
+			"refunded_amount": totals.RefundedAmount,
+			"return_rate":     totals.ReturnRate,
+			"review_count":    totals.ReviewCount,
+			"average_rating":  totals.AverageRating,
+		},
+		"series":                    series,
+		"top_products":              topProducts,
+		"products_pending_approval": pendingApproval,
+		"refreshed_at":              watermark.Through,
+	})
+}
+
+func parseStatsDate(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
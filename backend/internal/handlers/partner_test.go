@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newPartnerProductRouter serves the partner product endpoints as partner 1
+// authenticated with a live key.
+func newPartnerProductRouter(t *testing.T) (*PartnerHandler, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	h := NewPartnerHandler(testdb.Open(t), nil, nil)
+	r := gin.New()
+	partner := r.Group("/partner", func(c *gin.Context) {
+		c.Set("partner_id", uint(1))
+		c.Set("sandbox", false)
+	})
+	partner.GET("/products", h.GetProducts)
+	partner.PUT("/products/:id", h.UpdateProduct)
+	return h, r
+}
+
+func TestPartnerGetProducts(t *testing.T) {
+	h, r := newPartnerProductRouter(t)
+
+	category := models.Category{Name: "Phones"}
+	h.db.Create(&category)
+	for _, product := range []models.Product{
+		{Name: "Own", Slug: "own", SKU: "P1-1", Price: 1000, CategoryID: category.ID, PartnerID: 1},
+		{Name: "Own draft", Slug: "own-draft", SKU: "P1-2", Price: 1000, CategoryID: category.ID, PartnerID: 1, ModerationStatus: services.ModerationDraft},
+		{Name: "Own test", Slug: "own-test", SKU: "P1-3", Price: 1000, CategoryID: category.ID, PartnerID: 1, IsTest: true},
+		{Name: "Other", Slug: "other", SKU: "P2-1", Price: 1000, CategoryID: category.ID, PartnerID: 2},
+	} {
+		h.db.Create(&product)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partner/products", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetProducts() = %d %s", w.Code, w.Body)
+	}
+
+	var body struct {
+		Products   []models.Product `json:"products"`
+		Pagination struct {
+			Total int64 `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Products) != 2 || body.Pagination.Total != 2 {
+		t.Fatalf("GetProducts() returned %d products (total %d); want the 2 live products of partner 1", len(body.Products), body.Pagination.Total)
+	}
+	for _, product := range body.Products {
+		if product.PartnerID != 1 || product.IsTest {
+			t.Errorf("GetProducts() returned %s of partner %d (test %v)", product.Name, product.PartnerID, product.IsTest)
+		}
+		if product.Category.Name != "Phones" {
+			t.Errorf("product %s category = %q; want it preloaded", product.Name, product.Category.Name)
+		}
+	}
+}
+
+func TestPartnerUpdateProduct(t *testing.T) {
+	h, r := newPartnerProductRouter(t)
+
+	own := models.Product{Name: "Phone", Slug: "phone", SKU: "P1-1", Price: 1000, Stock: 5, CategoryID: 1, PartnerID: 1, IsActive: true, ModerationStatus: services.ModerationApproved}
+	other := models.Product{Name: "Tablet", Slug: "tablet", SKU: "P2-1", Price: 2000, Stock: 5, CategoryID: 1, PartnerID: 2, IsActive: true, ModerationStatus: services.ModerationApproved}
+	h.db.Create(&own)
+	h.db.Create(&other)
+
+	update := func(id uint, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/partner/products/%d", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A stock change stays live.
+	if w := update(own.ID, `{"name": "Phone", "price": 1000, "stock": 9, "sku": "P1-1", "category_id": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("UpdateProduct(stock) = %d %s", w.Code, w.Body)
+	}
+	h.db.First(&own, own.ID)
+	if own.Stock != 9 || own.ModerationStatus != services.ModerationApproved || !own.IsActive {
+		t.Errorf("after stock change = {stock %d, %s, active %v}; want {9, approved, true}", own.Stock, own.ModerationStatus, own.IsActive)
+	}
+
+	// A price change goes back to review.
+	if w := update(own.ID, `{"name": "Phone", "price": 1500, "stock": 9, "sku": "P1-1", "category_id": 1}`); w.Code != http.StatusOK {
+		t.Fatalf("UpdateProduct(price) = %d %s", w.Code, w.Body)
+	}
+	h.db.First(&own, own.ID)
+	if own.Price != 1500 || own.ModerationStatus != services.ModerationPendingReview || own.IsActive {
+		t.Errorf("after price change = {price %.0f, %s, active %v}; want {1500, pending_review, false}", own.Price, own.ModerationStatus, own.IsActive)
+	}
+
+	// Another partner's product looks like it does not exist.
+	if w := update(other.ID, `{"name": "Mine now", "price": 1, "stock": 0, "sku": "P2-1", "category_id": 1}`); w.Code != http.StatusNotFound {
+		t.Errorf("UpdateProduct(other partner's product) = %d; want 404", w.Code)
+	}
+	h.db.First(&other, other.ID)
+	if other.Name != "Tablet" || other.Price != 2000 {
+		t.Errorf("other partner's product changed to {%s, %.0f}", other.Name, other.Price)
+	}
+
+	if w := update(own.ID, `{"name": "Phone", "price": 1500, "stock": 9, "sku": "P2-1", "category_id": 1}`); w.Code != http.StatusConflict {
+		t.Errorf("UpdateProduct(duplicate SKU) = %d; want 409", w.Code)
+	}
+}
+
+func TestPartnerPaymentWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewPartnerHandler(testdb.Open(t), nil, nil)
+	r := gin.New()
+	route := func(sandbox bool) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("partner_id", uint(1))
+			c.Set("sandbox", sandbox)
+		}
+	}
+	r.POST("/live/payment", route(false), h.PaymentWebhook)
+	r.POST("/sandbox/payment", route(true), h.PaymentWebhook)
+
+	createPayment := func(number string, partnerID uint) models.Payment {
+		order := models.Order{OrderNumber: number, UserID: 1, Subtotal: 1000, Total: 1000, Status: "pending", PaymentStatus: "pending", IsTest: true}
+		h.db.Create(&order)
+		h.db.Create(&models.SubOrder{OrderID: order.ID, PartnerID: partnerID, Number: number + "-1", Subtotal: 1000, IsTest: true})
+		payment := models.Payment{OrderID: order.ID, PaymentMethod: "sandbox", Status: "pending", Amount: 1000, Currency: "VND", TransactionID: "TXN-" + number}
+		h.db.Create(&payment)
+		return payment
+	}
+	own := createPayment("ORD-1", 1)
+	other := createPayment("ORD-2", 2)
+
+	send := func(path string, payment models.Payment) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"payment_id": "PAY-1", "status": "completed", "amount": 1000, "transaction_id": %q}`, payment.TransactionID)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("/live/payment", own); w.Code != http.StatusForbidden {
+		t.Errorf("webhook with a live key = %d %s; want 403", w.Code, w.Body)
+	}
+	if w := send("/sandbox/payment", other); w.Code != http.StatusNotFound {
+		t.Errorf("webhook for another partner's order = %d %s; want 404", w.Code, w.Body)
+	}
+	if w := send("/sandbox/payment", own); w.Code != http.StatusOK {
+		t.Fatalf("webhook = %d %s; want 200", w.Code, w.Body)
+	}
+	if w := send("/sandbox/payment", own); w.Code != http.StatusConflict {
+		t.Errorf("repeated webhook = %d %s; want 409", w.Code, w.Body)
+	}
+
+	var order models.Order
+	h.db.First(&order, own.OrderID)
+	if order.PaymentStatus != "paid" || order.Status != "confirmed" {
+		t.Errorf("order = %s/%s; want paid/confirmed", order.PaymentStatus, order.Status)
+	}
+	var otherOrder models.Order
+	h.db.First(&otherOrder, other.OrderID)
+	if otherOrder.PaymentStatus != "pending" {
+		t.Errorf("other partner's order payment status = %s; want pending", otherOrder.PaymentStatus)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	db         *gorm.DB
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(db *gorm.DB, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{db: db, dispatcher: dispatcher}
+}
+
+// GetDeliveries - Admin endpoint to list webhook deliveries, filtered by
+// partner_id, status and event_type
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	query := h.db.Model(&models.WebhookDelivery{})
+	if partnerID := c.Query("partner_id"); partnerID != "" {
+		query = query.Where("partner_id = ?", partnerID)
+	}
+	h.listDeliveries(c, query)
+}
+
+// GetDelivery - Admin endpoint to view a delivery and its attempts
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	h.showDelivery(c, h.db)
+}
+
+// ReplayDelivery - Admin endpoint to resend a failed delivery
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	h.replayDelivery(c, h.db)
+}
+
+// GetPartnerDeliveries lists the calling partner's webhook deliveries
+func (h *WebhookHandler) GetPartnerDeliveries(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+	h.listDeliveries(c, h.db.Model(&models.WebhookDelivery{}).Where("partner_id = ?", partnerID))
+}
+
+// GetPartnerDelivery shows one of the calling partner's deliveries
+func (h *WebhookHandler) GetPartnerDelivery(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+	h.showDelivery(c, h.db.Where("partner_id = ?", partnerID))
+}
+
+// ReplayPartnerDelivery resends one of the calling partner's failed deliveries
+func (h *WebhookHandler) ReplayPartnerDelivery(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+	h.replayDelivery(c, h.db.Where("partner_id = ?", partnerID))
+}
+
+func (h *WebhookHandler) listDeliveries(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType := c.Query("event_type"); eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query.Count(&total)
+	if err := query.Order("created_at desc").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (h *WebhookHandler) showDelivery(c *gin.Context, query *gorm.DB) {
+	var delivery models.WebhookDelivery
+	err := query.Preload("AttemptLog", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&delivery, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+func (h *WebhookHandler) replayDelivery(c *gin.Context, query *gorm.DB) {
+	var delivery models.WebhookDelivery
+	if err := query.First(&delivery, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook delivery not found"})
+		return
+	}
+
+	if err := h.dispatcher.Replay(&delivery); err != nil {
+		if errors.Is(err, webhooks.ErrNotReplayable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only failed deliveries can be replayed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay webhook delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Webhook delivery queued for replay"})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// passwordResetInterval is the least time between two password reset emails
+// to the same user.
+const passwordResetInterval = time.Minute
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// ForgotPassword emails a password reset link to an active account. It
+// answers the same whether or not the account exists.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	err := h.db.Where("LOWER(email) = ? AND is_active = ?", strings.ToLower(req.Email), true).First(&user).Error
+	if err == nil {
+		h.sendPasswordResetEmail(&user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password using a token from a reset email. The
+// token is used up, and every session of the user is signed out.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID uint
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		userID, err = services.RedeemPasswordResetToken(tx, req.Token, time.Now())
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return services.ErrInvalidResetToken
+			}
+			return err
+		}
+		return services.SetPassword(tx, &user, req.NewPassword)
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired password reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	// The refresh tokens are already revoked; this also ends access tokens.
+	if _, err := h.sessions.RevokeAll(c.Request.Context(), userID, ""); err != nil {
+		log.Printf("Failed to revoke sessions of user %d after password reset: %v", userID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please sign in with your new password"})
+}
+
+// sendPasswordResetEmail emails user a new reset link, unless one was sent
+// within passwordResetInterval. The send time is claimed with a conditional
+// update, as for verification emails, so concurrent requests issue at most one
+// token. The email goes out in the background and failures are only logged,
+// so the response does not reveal whether the account exists.
+func (h *AuthHandler) sendPasswordResetEmail(user *models.User) {
+	now := time.Now()
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND (password_reset_sent_at IS NULL OR password_reset_sent_at < ?)", user.ID, now.Add(-passwordResetInterval)).
+		Update("password_reset_sent_at", now)
+	if result.Error != nil {
+		log.Printf("Failed to record password reset email for user %d: %v", user.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var token string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = services.IssuePasswordResetToken(tx, user.ID, h.resetTTL)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to issue password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	link := strings.TrimRight(h.frontendURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	email, err := services.PasswordResetEmail(user, link, h.resetTTL)
+	if err != nil {
+		log.Printf("Failed to render password reset email for user %d: %v", user.ID, err)
+		return
+	}
+	h.sendInBackground(email)
+}
@@ -1,226 +1,271 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"net/url"
-	"sort"
-	"strconv"
-	"strings"
-	"time"
 
 	"ecommerce-backend/internal/config"
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errInvalidPaymentAmount = errors.New("order total must be greater than zero")
+
 type PaymentHandler struct {
-	db     *gorm.DB
-	config *config.Config
+	db         *gorm.DB
+	config     *config.Config
+	providers  *payments.Registry
+	reconciler *services.Reconciler
+	webhooks   *webhooks.Dispatcher
 }
 
-func NewPaymentHandler(db *gorm.DB, cfg *config.Config) *PaymentHandler {
-	return &PaymentHandler{db: db, config: cfg}
+func NewPaymentHandler(db *gorm.DB, cfg *config.Config, providers *payments.Registry, reconciler *services.Reconciler, dispatcher *webhooks.Dispatcher) *PaymentHandler {
+	return &PaymentHandler{db: db, config: cfg, providers: providers, reconciler: reconciler, webhooks: dispatcher}
 }
 
-type CreateVNPayPaymentRequest struct {
-	OrderID uint   `json:"order_id" binding:"required"`
-	Amount  int64  `json:"amount" binding:"required"`
+type CreatePaymentRequest struct {
+	OrderID   uint   `json:"order_id" binding:"required"`
 	OrderInfo string `json:"order_info"`
 }
 
-func (h *PaymentHandler) CreateVNPayPayment(c *gin.Context) {
+// CreatePayment starts a payment for an order with the provider named by the
+// :method path parameter, e.g. POST /payments/vnpay/create.
+func (h *PaymentHandler) CreatePayment(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	var req CreateVNPayPaymentRequest
+	provider, ok := h.providers.Get(c.Param("method"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment method"})
+		return
+	}
+
+	var req CreatePaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	var order models.Order
-	if err := h.db.Where("id = ? AND user_id = ?", req.OrderID, userID).First(&order).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	var payment models.Payment
+	var amount int64
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// The order is locked like PayWithWallet does, so a wallet tender
+		// cannot land between sizing this payment and recording it.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", req.OrderID, userID).First(&order).Error; err != nil {
+			return err
+		}
+		if order.PaymentStatus != "pending" && order.PaymentStatus != "partially_paid" {
+			return errOrderNotPayable
+		}
+
+		paid, err := services.OrderPaidAmount(tx, order.ID)
+		if err != nil {
+			return err
+		}
+
+		// The amount always comes from the order so the client cannot pay
+		// less than what is owed. Anything already paid from the wallet is
+		// deducted.
+		amount = int64(math.Round(order.Total - paid))
+		if amount <= 0 {
+			return errInvalidPaymentAmount
+		}
+
+		payment = models.Payment{
+			OrderID:       order.ID,
+			PaymentMethod: provider.Method(),
+			Status:        "pending",
+			Amount:        float64(amount),
+			Currency:      "VND",
+			TransactionID: payments.NewTxnRef(order.ID),
+		}
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		case errors.Is(err, errOrderNotPayable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order payment already processed"})
+		case errors.Is(err, errInvalidPaymentAmount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order total must be greater than zero"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+		}
 		return
 	}
 
-	if order.PaymentStatus != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order payment already processed"})
-		return
+	orderInfo := req.OrderInfo
+	if orderInfo == "" {
+		orderInfo = fmt.Sprintf("Payment for order %s", order.OrderNumber)
 	}
 
-	txnRef := fmt.Sprintf("ORDER_%d_%d", order.ID, time.Now().Unix())
-	
-	payment := models.Payment{
-		OrderID:       order.ID,
-		PaymentMethod: "vnpay",
-		Status:        "pending",
-		Amount:        float64(req.Amount),
-		Currency:      "VND",
-		TransactionID: txnRef,
+	result, err := provider.Initiate(c.Request.Context(), payments.InitiateRequest{
+		TxnRef:    payment.TransactionID,
+		Amount:    amount,
+		OrderInfo: orderInfo,
+		IPAddr:    c.ClientIP(),
+		CreatedAt: payment.CreatedAt,
+	})
+
+	event := models.PaymentEvent{
+		PaymentID:      &payment.ID,
+		Provider:       provider.Method(),
+		Type:           "initiate",
+		TxnRef:         payment.TransactionID,
+		SignatureValid: true,
+		Outcome:        "ok",
+		SourceIP:       c.ClientIP(),
+	}
+	switch {
+	case err != nil:
+		event.Outcome = err.Error()
+	case result.RawResponse != "":
+		event.HTTPMethod = http.MethodPost
+		event.URL, _ = splitURL(result.PaymentURL)
+		event.RawParams = payments.MaskPayload(result.RawResponse)
+	default:
+		var query url.Values
+		event.HTTPMethod = http.MethodGet
+		event.URL, query = splitURL(result.PaymentURL)
+		event.RawParams = payments.MaskParams(query)
 	}
+	h.recordEvent(&event)
 
-	if err := h.db.Create(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+	if err != nil {
+		h.db.Model(&payment).Updates(map[string]interface{}{"status": "failed", "gateway_response": err.Error()})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to initiate payment with gateway"})
 		return
 	}
 
-	paymentURL := h.createVNPayURL(txnRef, req.Amount, req.OrderInfo)
-
-	h.db.Save(&payment)
+	if order.PaymentMethod != provider.Method() {
+		h.db.Model(&order).Update("payment_method", provider.Method())
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"payment_url": paymentURL,
+		"payment_url": result.PaymentURL,
 		"payment_id":  payment.ID,
+		"amount":      amount,
 	})
 }
 
-func (h *PaymentHandler) VNPayReturn(c *gin.Context) {
-	params := c.Request.URL.Query()
-	
-	if !h.validateVNPayResponse(params) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment response"})
+// PaymentReturn handles the customer's redirect back from the gateway. The
+// frontend forwards the gateway's query string unchanged.
+func (h *PaymentHandler) PaymentReturn(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	provider, ok := h.providers.Get(c.Param("method"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment method"})
 		return
 	}
 
-	txnRef := params.Get("vnp_TxnRef")
-	responseCode := params.Get("vnp_ResponseCode")
-	amount := params.Get("vnp_Amount")
+	params := c.Request.URL.Query()
+	payment, result, outcome := h.verifyCallback(provider, payments.Callback{Params: params}, userID)
+
+	event := models.PaymentEvent{
+		PaymentID:      paymentIDOf(payment),
+		Provider:       provider.Method(),
+		Type:           "return",
+		HTTPMethod:     c.Request.Method,
+		RawParams:      payments.MaskParams(params),
+		SignatureValid: outcome != payments.OutcomeInvalidSignature,
+		Outcome:        outcome.String(),
+		SourceIP:       c.ClientIP(),
+	}
+	if result != nil {
+		event.TxnRef = result.TxnRef
+	}
+	h.recordEvent(&event)
 
-	var payment models.Payment
-	if err := h.db.Where("transaction_id = ?", txnRef).First(&payment).Error; err != nil {
+	switch outcome {
+	case payments.OutcomeOK:
+		if err := h.applyCallbackResult(payment, result); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
+			return
+		}
+	case payments.OutcomeAlreadyConfirmed:
+		// The IPN usually lands before the browser returns; report the
+		// stored outcome without touching it.
+	case payments.OutcomeNotFound:
 		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
 		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment response"})
+		return
 	}
 
-	if responseCode == "00" {
-		payment.Status = "completed"
-		payment.ProcessedAt = &[]time.Time{time.Now()}[0]
-		
-		h.db.Model(&models.Order{}).Where("id = ?", payment.OrderID).Updates(map[string]interface{}{
-			"payment_status": "paid",
-			"status":         "confirmed",
-		})
-	} else {
-		payment.Status = "failed"
-	}
-
-	payment.GatewayResponse = fmt.Sprintf("ResponseCode: %s, Amount: %s", responseCode, amount)
-	h.db.Save(&payment)
-
 	c.JSON(http.StatusOK, gin.H{
 		"status":  payment.Status,
 		"message": "Payment processed",
 	})
 }
 
-func (h *PaymentHandler) VNPayWebhook(c *gin.Context) {
-	params := c.Request.URL.Query()
-	
-	if !h.validateVNPayResponse(params) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook signature"})
-		return
+// verifyCallback checks a return/IPN callback against the stored payment. The
+// payment is only safe to update when the outcome is OutcomeOK. When userID is
+// set the payment's order must belong to that user.
+func (h *PaymentHandler) verifyCallback(provider payments.Provider, cb payments.Callback, userID interface{}) (*models.Payment, *payments.CallbackResult, payments.CallbackOutcome) {
+	result, err := provider.VerifyCallback(cb)
+	if errors.Is(err, payments.ErrMerchantMismatch) {
+		return nil, nil, payments.OutcomeMerchantMismatch
+	}
+	if err != nil {
+		return nil, nil, payments.OutcomeInvalidSignature
 	}
 
-	txnRef := params.Get("vnp_TxnRef")
-	responseCode := params.Get("vnp_ResponseCode")
+	query := h.db.Where("transaction_id = ? AND payment_method = ?", result.TxnRef, provider.Method())
+	if userID != nil {
+		query = query.Where("order_id IN (?)", h.db.Model(&models.Order{}).Select("id").Where("user_id = ?", userID))
+	}
 
 	var payment models.Payment
-	if err := h.db.Where("transaction_id = ?", txnRef).First(&payment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
-		return
+	if err := query.First(&payment).Error; err != nil {
+		return nil, result, payments.OutcomeNotFound
 	}
 
-	if responseCode == "00" && payment.Status == "pending" {
-		payment.Status = "completed"
-		payment.ProcessedAt = &[]time.Time{time.Now()}[0]
-		
-		h.db.Model(&models.Order{}).Where("id = ?", payment.OrderID).Updates(map[string]interface{}{
-			"payment_status": "paid",
-			"status":         "confirmed",
-		})
-
-		h.db.Save(&payment)
+	if result.Amount != payment.Amount || result.Currency != payment.Currency {
+		return &payment, result, payments.OutcomeInvalidAmount
 	}
 
-	c.JSON(http.StatusOK, gin.H{"RspCode": "00", "Message": "Success"})
-}
-
-func (h *PaymentHandler) createVNPayURL(txnRef string, amount int64, orderInfo string) string {
-	params := url.Values{}
-	params.Set("vnp_Version", "2.1.0")
-	params.Set("vnp_Command", "pay")
-	params.Set("vnp_TmnCode", h.config.VNPayTMNCode)
-	params.Set("vnp_Amount", strconv.FormatInt(amount*100, 10))
-	params.Set("vnp_CurrCode", "VND")
-	params.Set("vnp_TxnRef", txnRef)
-	params.Set("vnp_OrderInfo", orderInfo)
-	params.Set("vnp_OrderType", "other")
-	params.Set("vnp_Locale", "vn")
-	params.Set("vnp_ReturnUrl", h.config.VNPayReturnURL)
-	params.Set("vnp_CreateDate", time.Now().Format("20060102150405"))
-	params.Set("vnp_IpAddr", "127.0.0.1")
-
-	keys := make([]string, 0, len(params))
-	for k := range params {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	var signData strings.Builder
-	for i, k := range keys {
-		if i > 0 {
-			signData.WriteString("&")
-		}
-		signData.WriteString(k)
-		signData.WriteString("=")
-		signData.WriteString(params.Get(k))
+	if payment.Status != "pending" {
+		return &payment, result, payments.OutcomeAlreadyConfirmed
 	}
 
-	h256 := hmac.New(sha256.New, []byte(h.config.VNPayHashKey))
-	h256.Write([]byte(signData.String()))
-	signature := hex.EncodeToString(h256.Sum(nil))
-
-	params.Set("vnp_SecureHash", signature)
-
-	return h.config.VNPayURL + "?" + params.Encode()
+	return &payment, result, payments.OutcomeOK
 }
 
-func (h *PaymentHandler) validateVNPayResponse(params url.Values) bool {
-	signature := params.Get("vnp_SecureHash")
-	params.Del("vnp_SecureHash")
-	params.Del("vnp_SecureHashType")
-
-	keys := make([]string, 0, len(params))
-	for k := range params {
-		if params.Get(k) != "" {
-			keys = append(keys, k)
-		}
+// applyCallbackResult moves a pending payment to completed or failed. The
+// conditional update keeps concurrent return/IPN callbacks from applying the
+// same result twice. Partners hear about the order once it is paid.
+func (h *PaymentHandler) applyCallbackResult(payment *models.Payment, result *payments.CallbackResult) error {
+	status := payments.StatusFailed
+	if result.Success {
+		status = payments.StatusCompleted
 	}
-	sort.Strings(keys)
 
-	var signData strings.Builder
-	for i, k := range keys {
-		if i > 0 {
-			signData.WriteString("&")
-		}
-		signData.WriteString(k)
-		signData.WriteString("=")
-		signData.WriteString(params.Get(k))
+	_, orderPaid, err := services.TransitionPayment(h.db, payment, status, result.GatewayTransactionNo,
+		fmt.Sprintf("ResponseCode: %s, Amount: %.0f", result.ResponseCode, result.Amount))
+	if err != nil {
+		log.Printf("Failed to apply %s callback for payment %d: %v", payment.PaymentMethod, payment.ID, err)
+		return err
 	}
-
-	h256 := hmac.New(sha256.New, []byte(h.config.VNPayHashKey))
-	h256.Write([]byte(signData.String()))
-	expectedSignature := hex.EncodeToString(h256.Sum(nil))
-
-	return signature == expectedSignature
+	if orderPaid {
+		emitOrderEvent(h.db, h.webhooks, payment.OrderID, webhooks.EventOrderPlaced)
+	}
+	return nil
 }
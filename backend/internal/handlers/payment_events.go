@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordEvent stores a gateway interaction in the payment audit trail.
+func (h *PaymentHandler) recordEvent(event *models.PaymentEvent) {
+	services.RecordPaymentEvent(h.db, event)
+}
+
+// paymentIDOf returns a pointer suitable for PaymentEvent.PaymentID.
+func paymentIDOf(payment *models.Payment) *uint {
+	if payment == nil {
+		return nil
+	}
+	id := payment.ID
+	return &id
+}
+
+// splitURL separates a payment URL into its base and query string so the
+// query can be masked and stored as RawParams.
+func splitURL(rawURL string) (string, url.Values) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil
+	}
+	query := u.Query()
+	u.RawQuery = ""
+	return u.String(), query
+}
+
+// GetPaymentDetail - Admin endpoint returning a payment with its refunds and
+// the full gateway event trail
+func (h *PaymentHandler) GetPaymentDetail(c *gin.Context) {
+	var payment models.Payment
+	err := h.db.Preload("Order").
+		Preload("Refunds", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		First(&payment, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
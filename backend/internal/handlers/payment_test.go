@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/config"
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/payments/vnpaysim"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testVNPayTmnCode = "TESTTMN1"
+	testVNPayHashKey = "SECRETKEY"
+)
+
+func newTestPaymentHandler(t *testing.T, providers ...payments.Provider) *PaymentHandler {
+	t.Helper()
+	if len(providers) == 0 {
+		providers = append(providers, payments.NewVNPayProvider(testVNPayTmnCode, testVNPayHashKey, "http://vnpay.local/pay", "http://vnpay.local/api", "http://shop.local/return"))
+	}
+	db := testdb.Open(t)
+	registry := payments.NewRegistry(providers...)
+	return NewPaymentHandler(db, &config.Config{}, registry, services.NewReconciler(db, registry, nil, 0, 0), nil)
+}
+
+// vnpayCallback builds a return/IPN query string signed with hashKey.
+func vnpayCallback(hashKey, tmnCode, txnRef, amount, currency string) url.Values {
+	params := url.Values{}
+	params.Set("vnp_TmnCode", tmnCode)
+	params.Set("vnp_TxnRef", txnRef)
+	params.Set("vnp_Amount", amount)
+	params.Set("vnp_ResponseCode", "00")
+	params.Set("vnp_TransactionStatus", "00")
+	params.Set("vnp_TransactionNo", "14226112")
+	if currency != "" {
+		params.Set("vnp_CurrCode", currency)
+	}
+	params.Set("vnp_SecureHash", payments.VNPaySignParams(hashKey, params))
+	return params
+}
+
+func TestVerifyCallback(t *testing.T) {
+	h := newTestPaymentHandler(t)
+	provider, _ := h.providers.Get("vnpay")
+
+	order := models.Order{OrderNumber: "ORD-1", UserID: 1, Subtotal: 50000, Total: 50000}
+	h.db.Create(&order)
+	pending := models.Payment{OrderID: order.ID, PaymentMethod: "vnpay", Status: "pending", Amount: 50000, Currency: "VND", TransactionID: "240201_1_1"}
+	completed := models.Payment{OrderID: order.ID, PaymentMethod: "vnpay", Status: "completed", Amount: 50000, Currency: "VND", TransactionID: "240201_1_2"}
+	h.db.Create(&pending)
+	h.db.Create(&completed)
+
+	tampered := vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_1", "5000000", "")
+	tampered.Set("vnp_Amount", "100")
+
+	tests := []struct {
+		name     string
+		params   url.Values
+		userID   interface{}
+		expected payments.CallbackOutcome
+	}{
+		{"Valid", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_1", "5000000", ""), nil, payments.OutcomeOK},
+		{"Valid for owner", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_1", "5000000", ""), uint(1), payments.OutcomeOK},
+		{"Tampered", tampered, nil, payments.OutcomeInvalidSignature},
+		{"Signed with another key", vnpayCallback("OTHERKEY", testVNPayTmnCode, "240201_1_1", "5000000", ""), nil, payments.OutcomeInvalidSignature},
+		{"Another merchant", vnpayCallback(testVNPayHashKey, "OTHERTMN", "240201_1_1", "5000000", ""), nil, payments.OutcomeMerchantMismatch},
+		{"Unknown reference", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_9", "5000000", ""), nil, payments.OutcomeNotFound},
+		{"Another user's order", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_1", "5000000", ""), uint(2), payments.OutcomeNotFound},
+		{"Wrong amount", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_1", "4000000", ""), nil, payments.OutcomeInvalidAmount},
+		{"Wrong currency", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_1", "5000000", "USD"), nil, payments.OutcomeInvalidAmount},
+		{"Already settled", vnpayCallback(testVNPayHashKey, testVNPayTmnCode, "240201_1_2", "5000000", ""), nil, payments.OutcomeAlreadyConfirmed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, outcome := h.verifyCallback(provider, payments.Callback{Params: tt.params}, tt.userID)
+			if outcome != tt.expected {
+				t.Errorf("verifyCallback() = %v, want %v", outcome, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessCallback_MerchantMismatch(t *testing.T) {
+	h := newTestPaymentHandler(t)
+	provider, _ := h.providers.Get("vnpay")
+
+	params := vnpayCallback(testVNPayHashKey, "OTHERTMN", "240201_1_1", "5000000", "")
+	_, body := h.ProcessCallback(provider, payments.Callback{Params: params}, "GET", "203.0.113.1")
+	if code := body.(map[string]string)["RspCode"]; code != "99" {
+		t.Errorf("RspCode = %s, want 99", code)
+	}
+
+	var event models.PaymentEvent
+	if err := h.db.Last(&event).Error; err != nil {
+		t.Fatalf("no payment event recorded: %v", err)
+	}
+	// The message is signed correctly, so the audit trail must not say otherwise.
+	if !event.SignatureValid || event.Outcome != "merchant_mismatch" {
+		t.Errorf("event = {SignatureValid: %v, Outcome: %s}, want {true, merchant_mismatch}", event.SignatureValid, event.Outcome)
+	}
+}
+
+// newSimulatedShop serves the payment routes of h with vnpaysim standing in
+// for VNPay. Requests are authenticated as user 1.
+func newSimulatedShop(t *testing.T) (*PaymentHandler, *vnpaysim.Server, *httptest.Server) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	sim, gateway := vnpaysim.NewTestServer(testVNPayTmnCode, testVNPayHashKey, "")
+	t.Cleanup(gateway.Close)
+
+	h := newTestPaymentHandler(t, payments.NewVNPayProvider(testVNPayTmnCode, testVNPayHashKey, gateway.URL+vnpaysim.PayPath, gateway.URL+vnpaysim.APIPath, "http://shop.local/payment/return"))
+
+	r := gin.New()
+	authenticated := func(c *gin.Context) { c.Set("user_id", uint(1)) }
+	r.POST("/payments/:method/create", authenticated, h.CreatePayment)
+	r.GET("/payments/:method/return", authenticated, h.PaymentReturn)
+	r.GET("/webhooks/:method", h.PaymentWebhook)
+	shop := httptest.NewServer(r)
+	t.Cleanup(shop.Close)
+
+	sim.IPNURL = shop.URL + "/webhooks/vnpay"
+	return h, sim, shop
+}
+
+// startPayment creates an order for user 1 and starts paying it with VNPay,
+// returning the order and the gateway's pay page URL.
+func startPayment(t *testing.T, h *PaymentHandler, shop *httptest.Server, total float64) (models.Order, string) {
+	t.Helper()
+	order := models.Order{OrderNumber: fmt.Sprintf("ORD-%d", time.Now().UnixNano()), UserID: 1, Subtotal: total, Total: total, Status: "pending", PaymentStatus: "pending", PaymentMethod: "vnpay"}
+	if err := h.db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(shop.URL+"/payments/vnpay/create", "application/json", strings.NewReader(fmt.Sprintf(`{"order_id": %d}`, order.ID)))
+	if err != nil {
+		t.Fatalf("create payment error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		PaymentURL string `json:"payment_url"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&created) != nil || created.PaymentURL == "" {
+		t.Fatalf("create payment status = %d, payment_url = %q", resp.StatusCode, created.PaymentURL)
+	}
+	return order, created.PaymentURL
+}
+
+// returnToShop forwards the gateway's return redirect to the return endpoint
+// the way the frontend does and reports the payment status it answers with.
+func returnToShop(t *testing.T, shop *httptest.Server, returnURL string) string {
+	t.Helper()
+	u, err := url.Parse(returnURL)
+	if err != nil {
+		t.Fatalf("invalid return URL %q", returnURL)
+	}
+
+	resp, err := http.Get(shop.URL + "/payments/vnpay/return?" + u.RawQuery)
+	if err != nil {
+		t.Fatalf("return error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("return status = %d", resp.StatusCode)
+	}
+	return body.Status
+}
+
+func TestPaymentFlow_VNPaySimulator(t *testing.T) {
+	h, sim, shop := newSimulatedShop(t)
+
+	t.Run("Paid", func(t *testing.T) {
+		order, payURL := startPayment(t, h, shop, 150000)
+
+		returnURL, err := sim.Pay(payURL, vnpaysim.OutcomeSuccess)
+		if err != nil {
+			t.Fatalf("Pay() error = %v", err)
+		}
+
+		// The IPN settled the order before the customer got back.
+		var payment models.Payment
+		h.db.Where("order_id = ?", order.ID).First(&payment)
+		if txn, _ := sim.Transaction(payment.TransactionID); !strings.Contains(txn.IPNResponse, `"RspCode":"00"`) {
+			t.Errorf("IPN response = %q, want RspCode 00", txn.IPNResponse)
+		}
+		h.db.First(&order, order.ID)
+		if order.PaymentStatus != "paid" || order.Status != "confirmed" {
+			t.Errorf("order after IPN = {%s, %s}; want {paid, confirmed}", order.PaymentStatus, order.Status)
+		}
+
+		if status := returnToShop(t, shop, returnURL); status != "completed" {
+			t.Errorf("return payment status = %s; want completed", status)
+		}
+		h.db.First(&payment, payment.ID)
+		if payment.Status != "completed" || payment.GatewayTransactionNo == "" {
+			t.Errorf("payment = {%s, %q}; want completed with a gateway transaction", payment.Status, payment.GatewayTransactionNo)
+		}
+	})
+
+	t.Run("Declined", func(t *testing.T) {
+		order, payURL := startPayment(t, h, shop, 80000)
+
+		returnURL, err := sim.Pay(payURL, vnpaysim.OutcomeFailure)
+		if err != nil {
+			t.Fatalf("Pay() error = %v", err)
+		}
+		if status := returnToShop(t, shop, returnURL); status != "failed" {
+			t.Errorf("return payment status = %s; want failed", status)
+		}
+		h.db.First(&order, order.ID)
+		if order.PaymentStatus != "pending" || order.Status != "pending" {
+			t.Errorf("order after declined payment = {%s, %s}; want {pending, pending}", order.PaymentStatus, order.Status)
+		}
+	})
+
+	t.Run("IPN lost", func(t *testing.T) {
+		sim.IPNURL = ""
+		defer func() { sim.IPNURL = shop.URL + "/webhooks/vnpay" }()
+
+		order, payURL := startPayment(t, h, shop, 60000)
+		if _, err := sim.Pay(payURL, vnpaysim.OutcomeSuccess); err != nil {
+			t.Fatalf("Pay() error = %v", err)
+		}
+		h.db.First(&order, order.ID)
+		if order.PaymentStatus != "pending" {
+			t.Fatalf("order payment status without IPN = %s; want pending", order.PaymentStatus)
+		}
+
+		// The customer never came back either; the reconciler's querydr,
+		// dated with the payment's vnp_CreateDate, settles it.
+		if err := h.reconciler.ReconcilePendingPayments(context.Background()); err != nil {
+			t.Fatalf("ReconcilePendingPayments() error = %v", err)
+		}
+		h.db.First(&order, order.ID)
+		if order.PaymentStatus != "paid" || order.Status != "confirmed" {
+			t.Errorf("order after reconciliation = {%s, %s}; want {paid, confirmed}", order.PaymentStatus, order.Status)
+		}
+	})
+}
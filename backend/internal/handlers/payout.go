@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PayoutHandler struct {
+	db       *gorm.DB
+	webhooks *webhooks.Dispatcher
+}
+
+func NewPayoutHandler(db *gorm.DB, dispatcher *webhooks.Dispatcher) *PayoutHandler {
+	return &PayoutHandler{db: db, webhooks: dispatcher}
+}
+
+type CreatePayoutsRequest struct {
+	PeriodEnd string `json:"period_end"` // YYYY-MM-DD, defaults to yesterday
+	PartnerID uint   `json:"partner_id"`
+}
+
+// CreatePayouts - Admin endpoint to generate payout statements for every
+// partner (or one) from ledger entries up to and including period_end.
+// Partners whose unpaid balance is not positive carry it forward.
+func (h *PayoutHandler) CreatePayouts(c *gin.Context) {
+	// The body is optional; an empty one pays every partner up to yesterday.
+	var req CreatePayoutsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	periodEnd := services.StartOfDay(time.Now()).AddDate(0, 0, -1)
+	if req.PeriodEnd != "" {
+		day, err := time.ParseInLocation("2006-01-02", req.PeriodEnd, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period_end, expected YYYY-MM-DD"})
+			return
+		}
+		periodEnd = day
+	}
+	cutoff := periodEnd.AddDate(0, 0, 1)
+
+	query := h.db.Model(&models.PartnerLedgerEntry{}).
+		Where("payout_id IS NULL AND created_at < ?", cutoff).
+		Distinct("partner_id")
+	if req.PartnerID != 0 {
+		query = query.Where("partner_id = ?", req.PartnerID)
+	}
+
+	var partnerIDs []uint
+	if err := query.Pluck("partner_id", &partnerIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate payouts"})
+		return
+	}
+
+	payouts := make([]models.PartnerPayout, 0, len(partnerIDs))
+	for _, partnerID := range partnerIDs {
+		payout, err := h.createPayout(partnerID, periodEnd, cutoff)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate payout for partner %d", partnerID), "payouts": payouts})
+			return
+		}
+		if payout == nil {
+			continue
+		}
+		payouts = append(payouts, *payout)
+
+		if h.webhooks != nil {
+			h.webhooks.Emit(partnerID, webhooks.EventPayoutCreated, payout)
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payouts": payouts})
+}
+
+// createPayout gathers a partner's unpaid entries before cutoff into a
+// payout. It returns nil when the balance is not positive.
+func (h *PayoutHandler) createPayout(partnerID uint, periodEnd, cutoff time.Time) (*models.PartnerPayout, error) {
+	var payout *models.PartnerPayout
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var entries []models.PartnerLedgerEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("partner_id = ? AND payout_id IS NULL AND created_at < ?", partnerID, cutoff).
+			Order("id").Find(&entries).Error; err != nil {
+			return err
+		}
+
+		var amount, commission float64
+		ids := make([]uint, len(entries))
+		for i, entry := range entries {
+			amount += entry.PartnerAmount
+			commission += entry.CommissionAmount
+			ids[i] = entry.ID
+		}
+		if amount <= 0 {
+			return nil
+		}
+
+		payout = &models.PartnerPayout{
+			PartnerID:  partnerID,
+			PeriodEnd:  periodEnd,
+			Amount:     amount,
+			Commission: commission,
+			EntryCount: len(entries),
+			Currency:   "VND",
+			Status:     "pending",
+		}
+		if err := tx.Create(payout).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PartnerLedgerEntry{}).Where("id IN ?", ids).Update("payout_id", payout.ID).Error
+	})
+	return payout, err
+}
+
+// GetPayouts - Admin endpoint to list payouts, filtered by partner_id and status
+func (h *PayoutHandler) GetPayouts(c *gin.Context) {
+	query := h.db.Model(&models.PartnerPayout{})
+	if partnerID := c.Query("partner_id"); partnerID != "" {
+		query = query.Where("partner_id = ?", partnerID)
+	}
+	response, ok := h.listPayouts(c, query)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPayout - Admin endpoint to download a payout statement as JSON, or CSV
+// with ?format=csv
+func (h *PayoutHandler) GetPayout(c *gin.Context) {
+	h.showPayout(c, h.db)
+}
+
+// MarkPayoutPaid - Admin endpoint to record that a payout was transferred
+func (h *PayoutHandler) MarkPayoutPaid(c *gin.Context) {
+	var req struct {
+		Reference string `json:"reference" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	result := h.db.Model(&models.PartnerPayout{}).
+		Where("id = ? AND status = ?", c.Param("id"), "pending").
+		Updates(map[string]interface{}{"status": "paid", "reference": req.Reference, "paid_at": &now})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payout"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending payout not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payout marked as paid"})
+}
+
+// GetPartnerPayouts returns the calling partner's balance and payouts
+func (h *PayoutHandler) GetPartnerPayouts(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var unpaid float64
+	if err := h.db.Model(&models.PartnerLedgerEntry{}).
+		Where("partner_id = ? AND payout_id IS NULL", partnerID).
+		Select("COALESCE(SUM(partner_amount), 0)").Scan(&unpaid).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	var totals struct {
+		Pending float64
+		Paid    float64
+	}
+	if err := h.db.Model(&models.PartnerPayout{}).Where("partner_id = ?", partnerID).
+		Select("COALESCE(SUM(CASE WHEN status = 'pending' THEN amount END), 0) AS pending, " +
+			"COALESCE(SUM(CASE WHEN status = 'paid' THEN amount END), 0) AS paid").
+		Scan(&totals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch balance"})
+		return
+	}
+
+	response, ok := h.listPayouts(c, h.db.Model(&models.PartnerPayout{}).Where("partner_id = ?", partnerID))
+	if !ok {
+		return
+	}
+	response["balance"] = gin.H{
+		"unpaid":          unpaid,
+		"pending_payouts": totals.Pending,
+		"paid":            totals.Paid,
+		"currency":        "VND",
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPartnerPayout returns one of the calling partner's payout statements
+func (h *PayoutHandler) GetPartnerPayout(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+	h.showPayout(c, h.db.Where("partner_id = ?", partnerID))
+}
+
+// listPayouts builds a page of payouts, or writes an error and returns false.
+func (h *PayoutHandler) listPayouts(c *gin.Context, query *gorm.DB) (gin.H, bool) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var payouts []models.PartnerPayout
+	var total int64
+
+	query.Count(&total)
+	if err := query.Order("created_at desc").Offset(offset).Limit(limit).Find(&payouts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payouts"})
+		return nil, false
+	}
+
+	return gin.H{
+		"payouts": payouts,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	}, true
+}
+
+func (h *PayoutHandler) showPayout(c *gin.Context, query *gorm.DB) {
+	var payout models.PartnerPayout
+	err := query.Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&payout, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		filename := fmt.Sprintf("payout-%d-%s.csv", payout.ID, payout.PeriodEnd.Format("2006-01-02"))
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		writePayoutCSV(csv.NewWriter(c.Writer), &payout)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payout": payout})
+}
+
+func writePayoutCSV(w *csv.Writer, payout *models.PartnerPayout) {
+	w.Write([]string{"entry_id", "created_at", "type", "order_id", "order_item_id", "description",
+		"gross_amount", "commission_rate", "commission_amount", "partner_amount", "currency"})
+
+	for _, entry := range payout.Entries {
+		w.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			entry.Type,
+			formatOptionalID(entry.OrderID),
+			formatOptionalID(entry.OrderItemID),
+			entry.Description,
+			strconv.FormatFloat(entry.GrossAmount, 'f', 0, 64),
+			strconv.FormatFloat(entry.CommissionRate, 'f', -1, 64),
+			strconv.FormatFloat(entry.CommissionAmount, 'f', 0, 64),
+			strconv.FormatFloat(entry.PartnerAmount, 'f', 0, 64),
+			entry.Currency,
+		})
+	}
+
+	w.Write([]string{"", "", "total", "", "", fmt.Sprintf("Payout %d", payout.ID),
+		"", "", strconv.FormatFloat(payout.Commission, 'f', 0, 64),
+		strconv.FormatFloat(payout.Amount, 'f', 0, 64), payout.Currency})
+	w.Flush()
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
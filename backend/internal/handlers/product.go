@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -9,20 +11,24 @@ import (
 
 	"ecommerce-backend/internal/models"
 	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductHandler struct {
-	db    *gorm.DB
-	redis *services.RedisService
+	db       *gorm.DB
+	redis    *services.RedisService
+	webhooks *webhooks.Dispatcher
 }
 
-func NewProductHandler(db *gorm.DB, redis *services.RedisService) *ProductHandler {
+func NewProductHandler(db *gorm.DB, redis *services.RedisService, dispatcher *webhooks.Dispatcher) *ProductHandler {
 	return &ProductHandler{
-		db:    db,
-		redis: redis,
+		db:       db,
+		redis:    redis,
+		webhooks: dispatcher,
 	}
 }
 
@@ -66,7 +72,7 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	}
 
 	offset := (page - 1) * limit
-	query := h.db.Where("is_active = ?", true)
+	query := h.db.Where("is_active = ? AND is_test = ?", true, false)
 
 	if search != "" {
 		query = query.Where("name ILIKE ?", "%"+search+"%")
@@ -110,10 +116,14 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 }
 
 func (h *ProductHandler) invalidateProductListCache() {
-	if h.redis != nil {
+	invalidateProductListCache(h.redis)
+}
+
+func invalidateProductListCache(redis *services.RedisService) {
+	if redis != nil {
 		ctx := context.Background()
 		pattern := "products:list:*"
-		if err := h.redis.DeletePattern(ctx, pattern); err != nil {
+		if err := redis.DeletePattern(ctx, pattern); err != nil {
 			log.Printf("Failed to invalidate product list cache: %v", err)
 		} else {
 			log.Printf("Invalidated product list cache with pattern: %s", pattern)
@@ -125,7 +135,7 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	id := c.Param("id")
 
 	var product models.Product
-	if err := h.db.Preload("Category").Where("is_active = ?", true).First(&product, id).Error; err != nil {
+	if err := h.db.Preload("Category").Where("is_active = ? AND is_test = ?", true, false).First(&product, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
@@ -146,7 +156,14 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	house, err := services.HousePartner(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
+	}
+
 	product := models.Product{
+		PartnerID:        house.ID,
 		Name:             req.Name,
 		Slug:             req.Name, // Simple slug generation
 		Description:      req.Description,
@@ -160,6 +177,7 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		Tags:             req.Tags,
 		IsFeatured:       req.IsFeatured,
 		IsActive:         true,
+		ModerationStatus: services.ModerationApproved,
 	}
 
 	if err := h.db.Create(&product).Error; err != nil {
@@ -239,31 +257,157 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
 
-// ApproveProduct - Admin endpoint to approve partner products
+type ModerationRequest struct {
+	Note string `json:"note"`
+}
+
+// ApproveProduct - Admin endpoint to approve a product pending review, or
+// reinstate a suspended one
 func (h *ProductHandler) ApproveProduct(c *gin.Context) {
-	id := c.Param("id")
+	var req ModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.moderate(c, services.ModerationApproved, req.Note, webhooks.EventProductApproved, "Product approved successfully")
+}
 
+// RejectProduct - Admin endpoint to reject a product pending review. The
+// partner sees the reason and may edit and resubmit the product.
+func (h *ProductHandler) RejectProduct(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.moderate(c, services.ModerationRejected, req.Reason, webhooks.EventProductRejected, "Product rejected")
+}
+
+// SuspendProduct - Admin endpoint to take an approved product off sale
+func (h *ProductHandler) SuspendProduct(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.moderate(c, services.ModerationSuspended, req.Reason, webhooks.EventProductSuspended, "Product suspended")
+}
+
+// GetProductModeration - Admin endpoint to list a product's moderation history
+func (h *ProductHandler) GetProductModeration(c *gin.Context) {
 	var product models.Product
-	if err := h.db.First(&product, id).Error; err != nil {
+	if err := h.db.Unscoped().First(&product, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
 
-	product.IsActive = true
-	if err := h.db.Save(&product).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve product"})
+	respondWithModerationHistory(c, h.db, &product)
+}
+
+func (h *ProductHandler) moderate(c *gin.Context, status, note, eventType, message string) {
+	var actorID uint
+	if userID, exists := c.Get("user_id"); exists {
+		actorID = userID.(uint)
+	}
+
+	var product models.Product
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, c.Param("id")).Error; err != nil {
+			return err
+		}
+		return services.ModerateProduct(tx, &product, status, note, services.ModerationActor{Type: "admin", ID: actorID})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case errors.Is(err, services.ErrInvalidModerationTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Product cannot move from " + product.ModerationStatus + " to " + status})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product moderation status"})
+		}
 		return
 	}
 
 	h.invalidateProductListCache()
+	emitProductEvent(h.webhooks, &product, eventType, note)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Product approved successfully",
+		"message": message,
 		"product": product,
 	})
 }
 
-// GetPendingProducts - Admin endpoint to get products pending approval
+func respondWithModerationHistory(c *gin.Context, db *gorm.DB, product *models.Product) {
+	var history []models.ProductModeration
+	if err := db.Where("product_id = ?", product.ID).Order("created_at, id").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moderation history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id":        product.ID,
+		"moderation_status": product.ModerationStatus,
+		"moderation_note":   product.ModerationNote,
+		"history":           history,
+	})
+}
+
+// GetAllProducts - Admin endpoint to list products in every moderation
+// status, optionally for one partner or status. Partners' test products are
+// listed only with include_test=true.
+func (h *ProductHandler) GetAllProducts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var products []models.Product
+	var total int64
+
+	query := h.db.Model(&models.Product{})
+	if c.Query("include_test") != "true" {
+		query = query.Where("is_test = ?", false)
+	}
+	if partnerID := c.Query("partner_id"); partnerID != "" {
+		id, err := strconv.ParseUint(partnerID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid partner ID"})
+			return
+		}
+		query = query.Where("partner_id = ?", id)
+	}
+	if status := c.Query("moderation_status"); status != "" {
+		query = query.Where("moderation_status = ?", status)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+		return
+	}
+	if err := query.Preload("Category").Order("id desc").Offset(offset).Limit(limit).Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// GetPendingProducts - Admin endpoint to get products pending review
 func (h *ProductHandler) GetPendingProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -272,7 +416,10 @@ func (h *ProductHandler) GetPendingProducts(c *gin.Context) {
 	var products []models.Product
 	var total int64
 
-	query := h.db.Where("is_active = ?", false)
+	query := h.db.Where("moderation_status = ?", services.ModerationPendingReview)
+	if partnerID := c.Query("partner_id"); partnerID != "" {
+		query = query.Where("partner_id = ?", partnerID)
+	}
 	query.Model(&models.Product{}).Count(&total)
 	query.Preload("Category").Offset(offset).Limit(limit).Find(&products)
 
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetAllProducts_PartnerFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewProductHandler(testdb.Open(t), nil, nil)
+	r := gin.New()
+	r.GET("/admin/products", h.GetAllProducts)
+
+	h.db.Create(&models.Product{Name: "Own", Slug: "own", SKU: "P1-1", Price: 1000, PartnerID: 1})
+	h.db.Create(&models.Product{Name: "Other", Slug: "other", SKU: "P2-1", Price: 1000, PartnerID: 2})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/products?partner_id=2", nil))
+	var body struct {
+		Products []models.Product `json:"products"`
+		Total    int64            `json:"total"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+		t.Fatalf("GetAllProducts(partner_id=2) = %d %s", w.Code, w.Body)
+	}
+	if body.Total != 1 || len(body.Products) != 1 || body.Products[0].PartnerID != 2 {
+		t.Errorf("GetAllProducts(partner_id=2) = %+v", body)
+	}
+
+	for _, partnerID := range []string{"abc", "-1", "1.5"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/products?partner_id="+partnerID, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GetAllProducts(partner_id=%s) = %d; want 400", partnerID, w.Code)
+		}
+	}
+}
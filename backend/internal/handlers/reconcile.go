@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReconcilePayments - Admin endpoint to run a reconciliation pass immediately
+func (h *PaymentHandler) ReconcilePayments(c *gin.Context) {
+	if err := h.reconciler.ReconcilePendingPayments(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pending payments reconciled"})
+}
+
+// GetReconciliationReports - Admin endpoint to list daily reconciliation reports
+func (h *PaymentHandler) GetReconciliationReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.ReconciliationReport{})
+	if c.Query("mismatches") == "true" {
+		query = query.Where("mismatch_count > 0")
+	}
+
+	var reports []models.ReconciliationReport
+	var total int64
+
+	query.Count(&total)
+	if err := query.Order("report_date desc").Offset(offset).Limit(limit).Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetReconciliationReport - Admin endpoint to view a report and its mismatches
+func (h *PaymentHandler) GetReconciliationReport(c *gin.Context) {
+	var report models.ReconciliationReport
+	if err := h.db.Preload("Items").First(&report, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// CreateReconciliationReport - Admin endpoint to (re)build the report for a day
+func (h *PaymentHandler) CreateReconciliationReport(c *gin.Context) {
+	var req struct {
+		Date string `json:"date" binding:"required"` // YYYY-MM-DD
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	report, err := h.reconciler.GenerateReport(c.Request.Context(), day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reconciliation report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"report": report})
+}
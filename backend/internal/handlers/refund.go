@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errNoRefundablePayment = errors.New("no refundable payment")
+	errInvalidRefundAmount = errors.New("invalid refund amount")
+)
+
+type CreateRefundRequest struct {
+	PaymentID uint    `json:"payment_id"`
+	Amount    float64 `json:"amount" binding:"min=0"`
+	Reason    string  `json:"reason" binding:"required"`
+	// ToWallet refunds as store credit instead of through the gateway.
+	// Wallet payments are always refunded to the wallet.
+	ToWallet bool `json:"to_wallet"`
+}
+
+// CreateRefund - Admin endpoint to refund all or part of an order's payment.
+// Omitting amount refunds whatever is still refundable on the payment.
+func (h *PaymentHandler) CreateRefund(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var req CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var order models.Order
+	if err := h.db.First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	var requestedBy *uint
+	if userID, exists := c.Get("user_id"); exists {
+		id := userID.(uint)
+		requestedBy = &id
+	}
+
+	var refund models.Refund
+	var payment models.Payment
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ? AND status IN ?", order.ID, []string{"completed", "partially_refunded"})
+		if req.PaymentID != 0 {
+			query = query.Where("id = ?", req.PaymentID)
+		}
+		if err := query.Order("created_at desc").First(&payment).Error; err != nil {
+			return errNoRefundablePayment
+		}
+
+		// Pending refunds count against the balance so concurrent requests
+		// cannot refund more than was paid.
+		var pending float64
+		if err := tx.Model(&models.Refund{}).
+			Where("payment_id = ? AND status = ?", payment.ID, "pending").
+			Select("COALESCE(SUM(amount), 0)").Scan(&pending).Error; err != nil {
+			return err
+		}
+
+		remaining := payment.Amount - payment.RefundedAmount - pending
+		amount := req.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		amount = math.Round(amount)
+		if amount <= 0 || amount > remaining {
+			return fmt.Errorf("%w: at most %.0f can be refunded", errInvalidRefundAmount, remaining)
+		}
+
+		refundType := "partial"
+		if amount == payment.Amount {
+			refundType = "full"
+		}
+
+		refund = models.Refund{
+			PaymentID:   payment.ID,
+			OrderID:     order.ID,
+			Type:        refundType,
+			Status:      "pending",
+			Amount:      amount,
+			Currency:    payment.Currency,
+			Reason:      req.Reason,
+			RequestID:   fmt.Sprintf("REFUND_%d_%d", payment.ID, time.Now().UnixNano()),
+			RequestedBy: requestedBy,
+		}
+		return tx.Create(&refund).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errNoRefundablePayment):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No refundable payment found for order"})
+		case errors.Is(err, errInvalidRefundAmount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create refund"})
+		}
+		return
+	}
+
+	if payment.PaymentMethod == walletPaymentMethod || req.ToWallet {
+		if err := h.refundToWallet(&refund, &order, requestedBy); err != nil {
+			h.failRefund(&refund, err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund to wallet", "refund": refund})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Refund credited to customer wallet",
+			"refund":  refund,
+		})
+		return
+	}
+
+	provider, ok := h.providers.Get(payment.PaymentMethod)
+	if !ok {
+		h.failRefund(&refund, fmt.Sprintf("no payment provider for method %s", payment.PaymentMethod))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refunds are not supported for this payment method"})
+		return
+	}
+
+	createdBy, _ := c.Get("user_email")
+	result, err := provider.Refund(c.Request.Context(), payments.RefundRequest{
+		RequestID:            refund.RequestID,
+		TxnRef:               payment.TransactionID,
+		GatewayTransactionNo: payment.GatewayTransactionNo,
+		TransactionDate:      payment.CreatedAt,
+		Amount:               int64(refund.Amount),
+		Full:                 refund.Type == "full",
+		Description:          fmt.Sprintf("Refund order %s", order.OrderNumber),
+		CreatedBy:            fmt.Sprint(createdBy),
+		IPAddr:               c.ClientIP(),
+		RequestedAt:          refund.CreatedAt,
+	})
+
+	event := models.PaymentEvent{
+		PaymentID:      &payment.ID,
+		Provider:       provider.Method(),
+		Type:           "refund",
+		TxnRef:         payment.TransactionID,
+		HTTPMethod:     http.MethodPost,
+		SignatureValid: !errors.Is(err, payments.ErrInvalidSignature),
+		SourceIP:       c.ClientIP(),
+	}
+	if err != nil {
+		event.Outcome = err.Error()
+	} else {
+		event.Outcome = result.Status
+		event.RawParams = payments.MaskPayload(result.RawResponse)
+	}
+	h.recordEvent(&event)
+
+	if err != nil {
+		// The gateway may have accepted the refund before the call failed, so
+		// it stays pending for the reconciler to look up rather than freeing
+		// the balance for a second refund.
+		refund.GatewayResponse = err.Error()
+		if err := h.db.Save(&refund).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update refund"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":  "Refund request to payment gateway failed; it will be verified by reconciliation",
+			"refund": refund,
+		})
+		return
+	}
+
+	refund.TransactionNo = result.TransactionNo
+	refund.GatewayResponse = result.RawResponse
+	switch result.Status {
+	case payments.StatusCompleted:
+		if err := h.settleRefund(&refund); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Refund accepted by gateway but failed to update records"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Refund processed successfully",
+			"refund":  refund,
+		})
+	case payments.StatusPending:
+		// The gateway settles the refund later; it stays pending and keeps
+		// counting against the refundable balance until the reconciler
+		// finds it settled.
+		if err := h.db.Save(&refund).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Refund accepted by gateway but failed to update records"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Refund accepted by gateway and is being processed",
+			"refund":  refund,
+		})
+	default:
+		h.failRefund(&refund, result.RawResponse)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":         "Payment gateway rejected the refund",
+			"response_code": result.ResponseCode,
+			"refund":        refund,
+		})
+	}
+}
+
+// GetOrderRefunds - Admin endpoint to list refund attempts for an order
+func (h *PaymentHandler) GetOrderRefunds(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var refunds []models.Refund
+	if err := h.db.Where("order_id = ?", orderID).Order("created_at desc").Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"refunds": refunds})
+}
+
+func (h *PaymentHandler) failRefund(refund *models.Refund, reason string) {
+	refund.Status = "failed"
+	refund.GatewayResponse = reason
+	h.db.Save(refund)
+}
+
+// settleRefund marks refund completed and rolls the settled total up into the
+// payment and order statuses.
+func (h *PaymentHandler) settleRefund(refund *models.Refund) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		return services.SettleRefund(tx, refund)
+	})
+}
+
+// refundToWallet credits refund to the order owner's wallet and settles it in
+// the same transaction, so the credit and the refund record cannot disagree.
+func (h *PaymentHandler) refundToWallet(refund *models.Refund, order *models.Order, createdBy *uint) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		wallet, err := services.UserWallet(tx, order.UserID)
+		if err != nil {
+			return err
+		}
+		source, err := services.SystemAccount(tx, services.AccountRefunds)
+		if err != nil {
+			return err
+		}
+
+		txn, err := services.PostTransfer(tx, services.Transfer{
+			From:        source,
+			To:          wallet,
+			Amount:      refund.Amount,
+			Type:        "refund",
+			Reference:   refund.RequestID,
+			Description: fmt.Sprintf("Refund for order %s", order.OrderNumber),
+			CreatedBy:   createdBy,
+		})
+		if err != nil {
+			return err
+		}
+
+		refund.TransactionNo = fmt.Sprintf("LEDGER_%d", txn.ID)
+		return services.SettleRefund(tx, refund)
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateRefund_GatewayOutcome(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		err      error
+		status   string
+		expected string
+	}{
+		{name: "Transport error", err: errors.New("context deadline exceeded"), expected: "pending"},
+		{name: "Rejected", status: payments.StatusFailed, expected: "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := payments.NewFakeProvider("momo")
+			gateway.Err = tt.err
+			if tt.status != "" {
+				gateway.RefundStatus = tt.status
+			}
+			h := newTestPaymentHandler(t, gateway)
+
+			order := models.Order{OrderNumber: "ORD-1", UserID: 1, Subtotal: 100000, Total: 100000, Status: "delivered", PaymentStatus: "paid", PaymentMethod: "momo"}
+			h.db.Create(&order)
+			payment := models.Payment{OrderID: order.ID, PaymentMethod: "momo", Status: "completed", Amount: 100000, Currency: "VND", TransactionID: "240201_1_1", GatewayTransactionNo: "2588659987"}
+			h.db.Create(&payment)
+
+			r := gin.New()
+			r.POST("/admin/orders/:id/refunds", h.CreateRefund)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/orders/%d/refunds", order.ID), strings.NewReader(`{"reason": "Damaged"}`))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusBadGateway {
+				t.Fatalf("CreateRefund() = %d %s; want 502", w.Code, w.Body)
+			}
+
+			var refund models.Refund
+			h.db.Where("payment_id = ?", payment.ID).First(&refund)
+			if refund.Status != tt.expected {
+				t.Errorf("refund status = %s; want %s", refund.Status, tt.expected)
+			}
+		})
+	}
+}
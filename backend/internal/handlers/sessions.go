@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionResponse is a session as shown to its user.
+type SessionResponse struct {
+	models.Session
+	// Current marks the session of the request's own access token.
+	Current bool `json:"current"`
+}
+
+// GetSessions lists the signed-in user's active sessions with the device, IP
+// address and time each was last seen, which is when it last signed in or
+// refreshed its access token.
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions, err := h.sessions.Active(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	current := c.GetString("session_id")
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = SessionResponse{Session: session, Current: session.ID == current}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// RevokeSession signs the user out of one of their sessions.
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.sessions.Revoke(c.Request.Context(), userID.(uint), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions signs the user out everywhere. With
+// keep_current=true the session making the request stays signed in.
+func (h *UserHandler) RevokeAllSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	except := ""
+	if c.Query("keep_current") == "true" {
+		except = c.GetString("session_id")
+	}
+
+	revoked, err := h.sessions.RevokeAll(c.Request.Context(), userID.(uint), except)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sessions revoked successfully",
+		"revoked": revoked,
+	})
+}
+
+// ForceLogout lets an admin sign a user out of every session.
+func (h *UserHandler) ForceLogout(c *gin.Context) {
+	userID := c.Param("id")
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	revoked, err := h.sessions.RevokeAll(c.Request.Context(), user.ID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User signed out of all sessions",
+		"revoked": revoked,
+	})
+}
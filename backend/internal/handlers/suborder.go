@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SubOrderHandler struct {
+	db       *gorm.DB
+	webhooks *webhooks.Dispatcher
+}
+
+func NewSubOrderHandler(db *gorm.DB, dispatcher *webhooks.Dispatcher) *SubOrderHandler {
+	return &SubOrderHandler{db: db, webhooks: dispatcher}
+}
+
+type UpdateSubOrderRequest struct {
+	Status         string `json:"status" binding:"required"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+// GetPartnerSubOrders lists the calling partner's sub-orders, filtered by status
+func (h *SubOrderHandler) GetPartnerSubOrders(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := services.FulfillableOrders(h.db, partnerRecords(h.db.Model(&models.SubOrder{}), c, partnerID))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var subOrders []models.SubOrder
+	var total int64
+
+	query.Count(&total)
+	if err := query.Preload("Items.Product").Preload("ShippingAddress").
+		Order("created_at desc").Offset(offset).Limit(limit).Find(&subOrders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sub_orders": subOrders,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetPartnerSubOrder shows one of the calling partner's sub-orders with the
+// shipping address
+func (h *SubOrderHandler) GetPartnerSubOrder(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var subOrder models.SubOrder
+	if err := services.FulfillableOrders(h.db, partnerRecords(h.db, c, partnerID)).Preload("Items.Product").Preload("ShippingAddress").
+		First(&subOrder, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sub-order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sub_order": subOrder})
+}
+
+// UpdatePartnerSubOrder lets a partner move its sub-order through
+// processing, shipped and delivered. Cancelling is left to admins, who also
+// handle the customer's refund.
+func (h *SubOrderHandler) UpdatePartnerSubOrder(c *gin.Context) {
+	partnerID, exists := c.Get("partner_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Partner not authenticated"})
+		return
+	}
+
+	var req UpdateSubOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status == "cancelled" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Partners cannot cancel sub-orders"})
+		return
+	}
+
+	h.updateSubOrder(c, partnerRecords(h.db, c, partnerID), req)
+}
+
+// UpdateSubOrderStatus - Admin endpoint to move any sub-order, including the
+// house partner's, to a new status
+func (h *SubOrderHandler) UpdateSubOrderStatus(c *gin.Context) {
+	var req UpdateSubOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.updateSubOrder(c, h.db, req)
+}
+
+func (h *SubOrderHandler) updateSubOrder(c *gin.Context, query *gorm.DB, req UpdateSubOrderRequest) {
+	if !services.IsSubOrderStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	subOrder, order, err := h.changeSubOrder(query, c.Param("id"), func(tx *gorm.DB, subOrder *models.SubOrder) error {
+		if err := services.SetSubOrderStatus(subOrder, req.Status); err != nil {
+			return err
+		}
+		if req.Carrier != "" {
+			subOrder.Carrier = req.Carrier
+		}
+		if req.TrackingNumber != "" {
+			subOrder.TrackingNumber = req.TrackingNumber
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithSubOrderError(c, err, subOrder, req.Status)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Sub-order status updated successfully",
+		"sub_order":    subOrder,
+		"order_status": order.Status,
+	})
+}
+
+// changeSubOrder locks the sub-order with id matched by query and its parent
+// order, applies change, saves the sub-order and re-derives the order's
+// status, notifying partners if it moved. Only cancelling is allowed before
+// the order is paid or, for cash on delivery, confirmed.
+func (h *SubOrderHandler) changeSubOrder(query *gorm.DB, id interface{}, change func(tx *gorm.DB, subOrder *models.SubOrder) error) (*models.SubOrder, *models.Order, error) {
+	var subOrder models.SubOrder
+	if err := query.First(&subOrder, id).Error; err != nil {
+		return &subOrder, nil, err
+	}
+
+	var order models.Order
+	var previousStatus string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the parent first, as RecordOrderEarnings does.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, subOrder.OrderID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&subOrder, subOrder.ID).Error; err != nil {
+			return err
+		}
+
+		if err := change(tx, &subOrder); err != nil {
+			return err
+		}
+		if subOrder.Status != "cancelled" && !services.IsOrderFulfillable(&order) {
+			return services.ErrOrderNotFulfillable
+		}
+		if err := tx.Save(&subOrder).Error; err != nil {
+			return err
+		}
+
+		var err error
+		previousStatus, err = services.SyncOrderStatus(tx, &order)
+		return err
+	})
+	if err != nil {
+		return &subOrder, &order, err
+	}
+
+	emitOrderStatusEvent(h.db, h.webhooks, &order, previousStatus)
+	return &subOrder, &order, nil
+}
+
+func respondWithSubOrderError(c *gin.Context, err error, subOrder *models.SubOrder, status string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sub-order not found"})
+	case errors.Is(err, services.ErrInvalidSubOrderTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Sub-order cannot move from " + subOrder.Status + " to " + status})
+	case errors.Is(err, services.ErrOrderNotFulfillable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order has not been paid or confirmed yet"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sub-order"})
+	}
+}
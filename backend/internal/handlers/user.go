@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -12,11 +14,12 @@ import (
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	sessions *services.SessionManager
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *gorm.DB, sessions *services.SessionManager) *UserHandler {
+	return &UserHandler{db: db, sessions: sessions}
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
@@ -156,6 +159,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	user.Country = req.Country
 	user.PostalCode = req.PostalCode
 
+	wasActive, previousRole := user.IsActive, user.Role
 	if req.Role != "" {
 		user.Role = req.Role
 	}
@@ -171,6 +175,15 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Access tokens carry the role, so a deactivated or re-roled user is
+	// signed out rather than left with tokens that no longer match.
+	if (wasActive && !user.IsActive) || user.Role != previousRole {
+		if _, err := h.sessions.RevokeAll(c.Request.Context(), user.ID, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User updated but failed to revoke sessions"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User updated successfully",
 		"user":    user,
@@ -196,24 +209,76 @@ func (h *UserHandler) ChangeUserPassword(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return services.SetPassword(tx, &user, req.NewPassword)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
+	h.revokeSessionsAfterPasswordChange(c, user.ID)
 
-	user.Password = string(hashedPassword)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password updated successfully",
+	})
+}
 
-	if err := h.db.Save(&user).Error; err != nil {
+type ChangeOwnPasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangePassword lets a signed-in user change their password. The current
+// password is required, and all of the user's sessions are signed out, so
+// the client has to sign in again.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ChangeOwnPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return services.SetPassword(tx, &user, req.NewPassword)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
+	h.revokeSessionsAfterPasswordChange(c, user.ID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password updated successfully",
+		"message": "Password updated successfully. Please sign in again",
 	})
 }
 
+// revokeSessionsAfterPasswordChange ends the user's sessions once their
+// password has changed. SetPassword has already revoked their refresh tokens,
+// so a failure here only leaves access tokens working until they expire; it
+// is logged rather than reported.
+func (h *UserHandler) revokeSessionsAfterPasswordChange(c *gin.Context, userID uint) {
+	if _, err := h.sessions.RevokeAll(c.Request.Context(), userID, ""); err != nil {
+		log.Printf("Failed to revoke sessions of user %d after password change: %v", userID, err)
+	}
+}
+
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
 
@@ -227,6 +292,9 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	if _, err := h.sessions.RevokeAll(c.Request.Context(), user.ID, ""); err != nil {
+		log.Printf("Failed to revoke sessions of deleted user %d: %v", user.ID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User deleted successfully",
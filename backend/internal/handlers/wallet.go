@@ -0,0 +1,629 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// walletPaymentMethod is the Payment.PaymentMethod of store credit tenders.
+// The wallet is not a gateway, so it is not in the provider registry.
+const walletPaymentMethod = "wallet"
+
+const maxGiftCardsPerIssue = 100
+
+var (
+	errOrderNotPayable       = errors.New("order payment already processed")
+	errGiftCardOrder         = errors.New("gift cards cannot be bought with store credit")
+	errGiftCardUnavailable   = errors.New("gift card is not valid")
+	errGatewayPaymentPending = errors.New("a gateway payment for the order is pending")
+)
+
+type WalletPaymentRequest struct {
+	OrderID uint    `json:"order_id" binding:"required"`
+	Amount  float64 `json:"amount" binding:"min=0"`
+}
+
+// PayWithWallet pays all or part of an order from the customer's store
+// credit. Omitting amount uses as much of the balance as the order still
+// needs; the remainder is then paid through CreatePayment with a gateway.
+func (h *PaymentHandler) PayWithWallet(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req WalletPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payment models.Payment
+	var wallet *models.WalletAccount
+	var remaining float64
+	var orderPaid bool
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the order serialises tenders for it, so two wallet
+		// payments cannot both see the same remaining amount.
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", req.OrderID, userID).First(&order).Error; err != nil {
+			return err
+		}
+		if order.PaymentStatus != "pending" && order.PaymentStatus != "partially_paid" {
+			return errOrderNotPayable
+		}
+
+		var giftCards int64
+		if err := tx.Model(&models.GiftCard{}).Where("order_id = ?", order.ID).Count(&giftCards).Error; err != nil {
+			return err
+		}
+		if giftCards > 0 {
+			return errGiftCardOrder
+		}
+
+		paid, err := services.OrderPaidAmount(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		owed := math.Round(order.Total - paid)
+
+		wallet, err = services.UserWallet(tx, order.UserID)
+		if err != nil {
+			return err
+		}
+
+		amount := math.Round(req.Amount)
+		if amount == 0 {
+			amount = math.Min(wallet.Balance, owed)
+		}
+		if amount <= 0 {
+			return services.ErrInsufficientFunds
+		}
+		if amount > owed {
+			return fmt.Errorf("%w: at most %.0f is owed", services.ErrInvalidAmount, owed)
+		}
+
+		// A pending gateway payment was sized for the whole remainder and
+		// the customer may still complete it, so store credit cannot be
+		// added on top until the gateway has settled or expired it.
+		var pending int64
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, "pending").
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return errGatewayPaymentPending
+		}
+
+		now := time.Now()
+		payment = models.Payment{
+			OrderID:       order.ID,
+			PaymentMethod: walletPaymentMethod,
+			Status:        "completed",
+			Amount:        amount,
+			Currency:      "VND",
+			TransactionID: payments.NewTxnRef(order.ID),
+			ProcessedAt:   &now,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		sales, err := services.SystemAccount(tx, services.AccountSales)
+		if err != nil {
+			return err
+		}
+		txn, err := services.PostTransfer(tx, services.Transfer{
+			From:        wallet,
+			To:          sales,
+			Amount:      amount,
+			Type:        "payment",
+			Reference:   payment.TransactionID,
+			Description: fmt.Sprintf("Payment for order %s", order.OrderNumber),
+		})
+		if err != nil {
+			return err
+		}
+
+		payment.GatewayTransactionNo = fmt.Sprintf("LEDGER_%d", txn.ID)
+		if err := tx.Model(&payment).Update("gateway_transaction_no", payment.GatewayTransactionNo).Error; err != nil {
+			return err
+		}
+
+		remaining = owed - amount
+		orderPaid, err = services.SettleOrderPayment(tx, order.ID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		case errors.Is(err, errOrderNotPayable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order payment already processed"})
+		case errors.Is(err, errGiftCardOrder):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gift cards cannot be bought with store credit"})
+		case errors.Is(err, errGatewayPaymentPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "A gateway payment for this order is still being processed"})
+		case errors.Is(err, services.ErrInsufficientFunds):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient wallet balance"})
+		case errors.Is(err, services.ErrInvalidAmount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pay with wallet"})
+		}
+		return
+	}
+
+	if orderPaid {
+		emitOrderEvent(h.db, h.webhooks, req.OrderID, webhooks.EventOrderPlaced)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payment":        payment,
+		"wallet_balance": wallet.Balance,
+		"remaining":      remaining,
+	})
+}
+
+type WalletHandler struct {
+	db *gorm.DB
+}
+
+func NewWalletHandler(db *gorm.DB) *WalletHandler {
+	return &WalletHandler{db: db}
+}
+
+// GetWallet returns the current user's balance and latest ledger entries
+func (h *WalletHandler) GetWallet(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.respondWithWallet(c, userID.(uint))
+}
+
+// GetWalletTransactions returns the current user's ledger entries
+func (h *WalletHandler) GetWalletTransactions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.LedgerEntry{}).
+		Where("account_id IN (?)", h.db.Model(&models.WalletAccount{}).Select("id").Where("code = ?", services.UserWalletCode(userID.(uint))))
+
+	var entries []models.LedgerEntry
+	var total int64
+
+	query.Count(&total)
+	if err := query.Preload("Transaction").Order("id desc").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// RedeemGiftCard moves a gift card's balance into the current user's wallet
+func (h *WalletHandler) RedeemGiftCard(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid := userID.(uint)
+	var card models.GiftCard
+	var wallet *models.WalletAccount
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code = ?", services.NormalizeGiftCardCode(req.Code)).First(&card).Error; err != nil {
+			return errGiftCardUnavailable
+		}
+		if card.Status != "active" || (card.ExpiresAt != nil && card.ExpiresAt.Before(time.Now())) {
+			return errGiftCardUnavailable
+		}
+
+		account, err := services.LockAccount(tx, card.AccountID)
+		if err != nil {
+			return err
+		}
+		wallet, err = services.UserWallet(tx, uid)
+		if err != nil {
+			return err
+		}
+
+		if _, err := services.PostTransfer(tx, services.Transfer{
+			From:        account,
+			To:          wallet,
+			Amount:      account.Balance,
+			Type:        "gift_card_redeem",
+			Reference:   fmt.Sprintf("gift_card:%d", card.ID),
+			Description: "Gift card redeemed",
+			CreatedBy:   &uid,
+		}); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		card.Status = "redeemed"
+		card.RedeemedBy = &uid
+		card.RedeemedAt = &now
+		return tx.Save(&card).Error
+	})
+	if err != nil {
+		if errors.Is(err, errGiftCardUnavailable) || errors.Is(err, services.ErrInvalidAmount) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gift card is invalid, expired or already redeemed"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem gift card"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Gift card redeemed",
+		"amount":         card.Amount,
+		"wallet_balance": wallet.Balance,
+	})
+}
+
+// PurchaseGiftCard creates an order for a gift card. The card is funded and
+// its code revealed once the order is paid through a payment gateway.
+func (h *WalletHandler) PurchaseGiftCard(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Amount float64 `json:"amount" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid := userID.(uint)
+	amount := math.Round(req.Amount)
+	order := models.Order{
+		OrderNumber:   fmt.Sprintf("GC-%d-%d", uid, time.Now().UnixNano()),
+		UserID:        uid,
+		Status:        "pending",
+		PaymentStatus: "pending",
+		Subtotal:      amount,
+		Total:         amount,
+		Currency:      "VND",
+		Notes:         "Gift card purchase",
+		OrderDate:     time.Now(),
+	}
+
+	var card models.GiftCard
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("ShippingAddress").Create(&order).Error; err != nil {
+			return err
+		}
+		var err error
+		card, err = createGiftCard(tx, amount, nil)
+		if err != nil {
+			return err
+		}
+		card.Status = "pending_payment"
+		card.PurchasedBy = &uid
+		card.OrderID = &order.ID
+		return tx.Save(&card).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create gift card order"})
+		return
+	}
+
+	card.Code = ""
+	c.JSON(http.StatusCreated, gin.H{
+		"order":     order,
+		"gift_card": card,
+	})
+}
+
+// GetMyGiftCards lists gift cards bought by the current user. Codes are only
+// shown once the card has been paid for.
+func (h *WalletHandler) GetMyGiftCards(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var cards []models.GiftCard
+	if err := h.db.Where("purchased_by = ?", userID).Order("created_at desc").Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch gift cards"})
+		return
+	}
+
+	for i := range cards {
+		if cards[i].Status == "pending_payment" {
+			cards[i].Code = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gift_cards": cards})
+}
+
+type IssueGiftCardsRequest struct {
+	Amount    float64    `json:"amount" binding:"required,gt=0"`
+	Quantity  int        `json:"quantity" binding:"min=0"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// IssueGiftCards - Admin endpoint to issue funded gift cards, e.g. for
+// promotions or customer service
+func (h *WalletHandler) IssueGiftCards(c *gin.Context) {
+	var req IssueGiftCardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quantity := req.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+	if quantity > maxGiftCardsPerIssue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d gift cards can be issued at once", maxGiftCardsPerIssue)})
+		return
+	}
+
+	var issuedBy *uint
+	if userID, exists := c.Get("user_id"); exists {
+		id := userID.(uint)
+		issuedBy = &id
+	}
+
+	cards := make([]models.GiftCard, 0, quantity)
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		source, err := services.SystemAccount(tx, services.AccountGiftCardsIssued)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < quantity; i++ {
+			card, err := createGiftCard(tx, req.Amount, req.ExpiresAt)
+			if err != nil {
+				return err
+			}
+			card.IssuedBy = issuedBy
+			if err := tx.Save(&card).Error; err != nil {
+				return err
+			}
+
+			account, err := services.LockAccount(tx, card.AccountID)
+			if err != nil {
+				return err
+			}
+			if _, err := services.PostTransfer(tx, services.Transfer{
+				From:        source,
+				To:          account,
+				Amount:      card.Amount,
+				Type:        "gift_card_issue",
+				Reference:   fmt.Sprintf("gift_card:%d", card.ID),
+				Description: "Gift card issued",
+				CreatedBy:   issuedBy,
+			}); err != nil {
+				return err
+			}
+			cards = append(cards, card)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue gift cards"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"gift_cards": cards})
+}
+
+// createGiftCard creates an active, unfunded gift card with its own account.
+func createGiftCard(tx *gorm.DB, amount float64, expiresAt *time.Time) (models.GiftCard, error) {
+	code := services.NewGiftCardCode()
+	account := models.WalletAccount{Code: services.GiftCardAccountCode(code), Type: "gift_card", Currency: "VND"}
+	if err := tx.Create(&account).Error; err != nil {
+		return models.GiftCard{}, err
+	}
+
+	card := models.GiftCard{
+		Code:      code,
+		AccountID: account.ID,
+		Amount:    math.Round(amount),
+		Currency:  "VND",
+		Status:    "active",
+		ExpiresAt: expiresAt,
+	}
+	err := tx.Create(&card).Error
+	return card, err
+}
+
+// GetAllGiftCards - Admin endpoint to list gift cards
+func (h *WalletHandler) GetAllGiftCards(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.GiftCard{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var cards []models.GiftCard
+	var total int64
+
+	query.Count(&total)
+	if err := query.Order("created_at desc").Offset(offset).Limit(limit).Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch gift cards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"gift_cards": cards,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// DisableGiftCard - Admin endpoint to block a gift card from being redeemed.
+// Its balance stays on the card's account.
+func (h *WalletHandler) DisableGiftCard(c *gin.Context) {
+	result := h.db.Model(&models.GiftCard{}).
+		Where("id = ? AND status IN ?", c.Param("id"), []string{"active", "pending_payment"}).
+		Update("status", "disabled")
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable gift card"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found or already redeemed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Gift card disabled"})
+}
+
+// GetUserWallet - Admin endpoint to view a customer's wallet
+func (h *WalletHandler) GetUserWallet(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	h.respondWithWallet(c, uint(userID))
+}
+
+// CreditWallet - Admin endpoint to grant promotional store credit
+func (h *WalletHandler) CreditWallet(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Amount float64 `json:"amount" binding:"required,gt=0"`
+		Reason string  `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var createdBy *uint
+	if adminID, exists := c.Get("user_id"); exists {
+		id := adminID.(uint)
+		createdBy = &id
+	}
+
+	var wallet *models.WalletAccount
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		source, err := services.SystemAccount(tx, services.AccountPromotions)
+		if err != nil {
+			return err
+		}
+		wallet, err = services.UserWallet(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = services.PostTransfer(tx, services.Transfer{
+			From:        source,
+			To:          wallet,
+			Amount:      req.Amount,
+			Type:        "promotion",
+			Reference:   fmt.Sprintf("user:%d", user.ID),
+			Description: req.Reason,
+			CreatedBy:   createdBy,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAmount) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to credit wallet"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Wallet credited",
+		"wallet_balance": wallet.Balance,
+	})
+}
+
+func (h *WalletHandler) respondWithWallet(c *gin.Context, userID uint) {
+	wallet := models.WalletAccount{Code: services.UserWalletCode(userID), Type: "user", UserID: &userID, Currency: "VND"}
+	if err := h.db.Where("code = ?", wallet.Code).Limit(1).Find(&wallet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet"})
+		return
+	}
+
+	var entries []models.LedgerEntry
+	if wallet.ID != 0 {
+		if err := h.db.Where("account_id = ?", wallet.ID).Preload("Transaction").
+			Order("id desc").Limit(20).Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallet":  wallet,
+		"entries": entries,
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestPayWithWallet_GatewayPaymentPending(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestPaymentHandler(t)
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		wallet, err := services.UserWallet(tx, 1)
+		if err != nil {
+			return err
+		}
+		promotions, err := services.SystemAccount(tx, services.AccountPromotions)
+		if err != nil {
+			return err
+		}
+		_, err = services.PostTransfer(tx, services.Transfer{From: promotions, To: wallet, Amount: 50000, Type: "promotion", Reference: "TEST"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("funding wallet: %v", err)
+	}
+
+	order := models.Order{OrderNumber: "ORD-1", UserID: 1, Subtotal: 100000, Total: 100000, Status: "pending", PaymentStatus: "pending"}
+	h.db.Create(&order)
+	gateway := models.Payment{OrderID: order.ID, PaymentMethod: "vnpay", Status: "pending", Amount: 100000, Currency: "VND", TransactionID: "240201_1_1"}
+	h.db.Create(&gateway)
+
+	r := gin.New()
+	r.POST("/payments/wallet/create", func(c *gin.Context) { c.Set("user_id", uint(1)) }, h.PayWithWallet)
+	pay := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/payments/wallet/create", strings.NewReader(fmt.Sprintf(`{"order_id": %d}`, order.ID)))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := pay(); w.Code != http.StatusConflict {
+		t.Fatalf("PayWithWallet() with a pending gateway payment = %d %s; want 409", w.Code, w.Body)
+	}
+	h.db.First(&gateway, gateway.ID)
+	if gateway.Status != "pending" {
+		t.Errorf("gateway payment status = %s; want it left pending", gateway.Status)
+	}
+	var tenders int64
+	h.db.Model(&models.Payment{}).Where("order_id = ? AND payment_method = ?", order.ID, walletPaymentMethod).Count(&tenders)
+	if tenders != 0 {
+		t.Errorf("wallet tenders = %d; want none", tenders)
+	}
+
+	h.db.Model(&gateway).Update("status", "expired")
+	if w := pay(); w.Code != http.StatusOK {
+		t.Fatalf("PayWithWallet() after the gateway payment expired = %d %s; want 200", w.Code, w.Body)
+	}
+	h.db.First(&order, order.ID)
+	if order.PaymentStatus != "partially_paid" {
+		t.Errorf("order payment status = %s; want partially_paid", order.PaymentStatus)
+	}
+}
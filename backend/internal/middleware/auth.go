@@ -1,24 +1,29 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strings"
 
-	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"gorm.io/gorm"
 )
 
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// SessionID is the session the token was issued for.
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// AuthMiddleware accepts requests carrying a valid access token whose session
+// has not been revoked. Tokens issued before sessions existed have no session
+// and are rejected; clients get a new one by refreshing.
+func AuthMiddleware(jwtSecret string, denylist services.SessionDenylist) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -39,15 +44,29 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return []byte(jwtSecret), nil
 		})
 
-		if err != nil || !token.Valid {
+		if err != nil || !token.Valid || claims.SessionID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := denylist.Denied(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			log.Printf("❌ Session denylist unavailable: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify session, please retry"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
@@ -71,32 +90,6 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
-func PartnerAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		apiKey := c.GetHeader("X-API-Key")
-		secretKey := c.GetHeader("X-Secret-Key")
-
-		if apiKey == "" || secretKey == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "API credentials required"})
-			c.Abort()
-			return
-		}
-
-		// Validate API keys against database
-		var partner models.Partner
-		if err := db.Where("api_key = ? AND secret_key = ? AND is_active = ?", apiKey, secretKey, true).First(&partner).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API credentials"})
-			c.Abort()
-			return
-		}
-
-		c.Set("api_key", apiKey)
-		c.Set("secret_key", secretKey)
-		c.Set("partner_id", partner.ID)
-		c.Next()
-	}
-}
-
 func RateLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("X-RateLimit-Limit", "1000")
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const secret = "jwt-secret"
+	denylist := services.NewMemorySessionDenylist()
+	router := gin.New()
+	router.GET("/me", AuthMiddleware(secret, denylist), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("session_id"))
+	})
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token, err := auth.GenerateToken(1, "alice@example.com", "user", "session-1", secret)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if w := send(token); w.Code != http.StatusOK || w.Body.String() != "session-1" {
+		t.Fatalf("valid token: status = %d, body = %q; want 200 session-1", w.Code, w.Body.String())
+	}
+
+	withoutSession, _ := auth.GenerateToken(1, "alice@example.com", "user", "", secret)
+	if w := send(withoutSession); w.Code != http.StatusUnauthorized {
+		t.Errorf("token without session: status = %d; want 401", w.Code)
+	}
+
+	otherSecret, _ := auth.GenerateToken(1, "alice@example.com", "user", "session-1", "other-secret")
+	if w := send(otherSecret); w.Code != http.StatusUnauthorized {
+		t.Errorf("token signed with another secret: status = %d; want 401", w.Code)
+	}
+
+	denylist.Deny(context.Background(), "session-1", time.Minute)
+	if w := send(token); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session: status = %d; want 401", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on responses replayed from the store.
+	IdempotentReplayHeader = "Idempotent-Replayed"
+
+	idempotencyTTL        = 24 * time.Hour
+	maxIdempotencyKeySize = 255
+)
+
+// responseRecorder keeps a copy of everything the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes a POST safe to retry. The first response for a
+// user's Idempotency-Key is stored and replayed for repeats with the same
+// body; reusing the key with a different body is rejected with 422. Requests
+// without the header are passed through. It must run after AuthMiddleware.
+func IdempotencyMiddleware(store services.IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeySize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := c.Get("user_id")
+		key := fmt.Sprintf("idempotency:%v:%s:%s:%s", userID, c.Request.Method, c.Request.URL.Path, idempotencyKey)
+		hash := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(hash[:])
+
+		ctx := c.Request.Context()
+		existing, err := store.Reserve(ctx, key, &services.IdempotencyRecord{RequestHash: requestHash}, idempotencyTTL)
+		if err != nil {
+			// Failing open keeps checkout working when the store is down.
+			log.Printf("⚠️  Idempotency store unavailable, processing %s without it: %v", key, err)
+			c.Next()
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request body"})
+			case !existing.Completed:
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header(IdempotentReplayHeader, "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				store.Release(ctx, key)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not cached so the client can retry them.
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := store.Release(ctx, key); err != nil {
+				log.Printf("Failed to release idempotency key %s: %v", key, err)
+			}
+			return
+		}
+
+		record := &services.IdempotencyRecord{
+			RequestHash: requestHash,
+			Completed:   true,
+			StatusCode:  recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := store.Save(ctx, key, record, idempotencyTTL); err != nil {
+			log.Printf("Failed to store idempotent response for %s: %v", key, err)
+		}
+	}
+}
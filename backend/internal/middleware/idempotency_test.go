@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]services.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, key string, record *services.IdempotencyRecord, ttl time.Duration) (*services.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[key]; ok {
+		return &existing, nil
+	}
+	s.records[key] = *record
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Save(ctx context.Context, key string, record *services.IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func newIdempotentRouter(status *int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: make(map[string]services.IdempotencyRecord)}
+
+	r := gin.New()
+	r.POST("/orders", func(c *gin.Context) { c.Set("user_id", uint(7)) }, IdempotencyMiddleware(store), func(c *gin.Context) {
+		*calls++
+		c.JSON(*status, gin.H{"order_id": *calls})
+	})
+	return r
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		firstStatus    int
+		secondBody     string
+		expectedStatus int
+		expectedCalls  int
+		replayed       bool
+	}{
+		{
+			name:           "Same body is replayed",
+			firstStatus:    http.StatusCreated,
+			secondBody:     `{"items":[1]}`,
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  1,
+			replayed:       true,
+		},
+		{
+			name:           "Different body is rejected",
+			firstStatus:    http.StatusCreated,
+			secondBody:     `{"items":[2]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCalls:  1,
+		},
+		{
+			name:           "Server errors can be retried",
+			firstStatus:    http.StatusInternalServerError,
+			secondBody:     `{"items":[1]}`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, calls := tt.firstStatus, 0
+			r := newIdempotentRouter(&status, &calls)
+
+			send := func(body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+				req.Header.Set(IdempotencyKeyHeader, "retry-1")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+
+			first := send(`{"items":[1]}`)
+			second := send(tt.secondBody)
+
+			if second.Code != tt.expectedStatus {
+				t.Errorf("second status = %d, want %d", second.Code, tt.expectedStatus)
+			}
+			if calls != tt.expectedCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.expectedCalls)
+			}
+			if tt.replayed {
+				if second.Header().Get(IdempotentReplayHeader) != "true" || second.Body.String() != first.Body.String() {
+					t.Errorf("second response = %q, want replay of %q", second.Body.String(), first.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_WithoutHeader(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	r := newIdempotentRouter(&status, &calls)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`)))
+	}
+
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/testdb"
+	"ecommerce-backend/pkg/partnerauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyPartnerRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		signedAt time.Time
+		secret   string
+		tamper   func(r *http.Request)
+		expected error
+	}{
+		{
+			name:     "Valid signature",
+			signedAt: now,
+			secret:   "secret",
+		},
+		{
+			name:     "Clock skew within window",
+			signedAt: now.Add(-4 * time.Minute),
+			secret:   "secret",
+		},
+		{
+			name:     "Stale timestamp",
+			signedAt: now.Add(-6 * time.Minute),
+			secret:   "secret",
+			expected: errStaleTimestamp,
+		},
+		{
+			name:     "Wrong secret",
+			signedAt: now,
+			secret:   "other",
+			expected: errBadSignature,
+		},
+		{
+			name:     "Tampered body",
+			signedAt: now,
+			secret:   "secret",
+			tamper: func(r *http.Request) {
+				r.Body = io.NopCloser(strings.NewReader(`{"price":1}`))
+			},
+			expected: errBadSignature,
+		},
+		{
+			name:     "Tampered query",
+			signedAt: now,
+			secret:   "secret",
+			tamper: func(r *http.Request) {
+				r.URL.RawQuery = "page=3"
+			},
+			expected: errBadSignature,
+		},
+		{
+			name:     "Missing nonce",
+			signedAt: now,
+			secret:   "secret",
+			tamper: func(r *http.Request) {
+				r.Header.Del(partnerauth.HeaderNonce)
+			},
+			expected: errMissingSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/partners/products/5?page=2", strings.NewReader(`{"price":100}`))
+			if err := partnerauth.SignRequest(req, "key", tt.secret, tt.signedAt); err != nil {
+				t.Fatalf("SignRequest: %v", err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+
+			err := verifyPartnerRequest(req, "secret", now)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("verifyPartnerRequest() = %v, want %v", err, tt.expected)
+			}
+			if err == nil {
+				body, _ := io.ReadAll(req.Body)
+				if string(body) != `{"price":100}` {
+					t.Errorf("body after verification = %q, want it restored", body)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyPartnerRequest_InvalidTimestamp(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/partners/products", nil)
+	partnerauth.SignRequest(req, "key", "secret", time.Now())
+	req.Header.Set(partnerauth.HeaderTimestamp, "yesterday")
+
+	if err := verifyPartnerRequest(req, "secret", time.Now()); !errors.Is(err, errStaleTimestamp) {
+		t.Errorf("verifyPartnerRequest() = %v, want %v", err, errStaleTimestamp)
+	}
+
+	// A correctly formatted timestamp far in the future is also rejected.
+	req.Header.Set(partnerauth.HeaderTimestamp, strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	if err := verifyPartnerRequest(req, "secret", time.Now()); !errors.Is(err, errStaleTimestamp) {
+		t.Errorf("verifyPartnerRequest() = %v, want %v", err, errStaleTimestamp)
+	}
+}
+
+func TestPartnerAuthMiddleware_RejectsReplayedNonce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testdb.Open(t)
+	secrets := services.NewSecretBox("encryption-key")
+
+	partner := models.Partner{Name: "Acme", Email: "acme@example.com", IsActive: true}
+	db.Create(&partner)
+	key, secret, err := services.IssueAPIKey(db, secrets, partner.ID, "Default", services.ScopeCatalogRead, nil, false)
+	if err != nil {
+		t.Fatalf("IssueAPIKey: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/partners/products", PartnerAuthMiddleware(db, secrets, services.NewMemoryNonceStore()), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"partner_id": c.GetUint("partner_id")})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/partners/products", nil)
+	if err := partnerauth.SignRequest(req, key.APIKey, secret, time.Now()); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request = %d %s; want 200", w.Code, w.Body)
+	}
+
+	// The same signed request sent again, as an eavesdropper would.
+	replay := httptest.NewRequest(http.MethodGet, "/api/v1/partners/products", nil)
+	replay.Header = req.Header.Clone()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, replay)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "nonce has already been used") {
+		t.Errorf("replayed request = %d %s; want 401 for the reused nonce", w.Code, w.Body)
+	}
+
+	// A fresh signature with a new nonce is still accepted.
+	fresh := httptest.NewRequest(http.MethodGet, "/api/v1/partners/products", nil)
+	partnerauth.SignRequest(fresh, key.APIKey, secret, time.Now())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, fresh)
+	if w.Code != http.StatusOK {
+		t.Errorf("request with a new nonce = %d %s; want 200", w.Code, w.Body)
+	}
+}
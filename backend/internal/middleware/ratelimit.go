@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PartnerRateLimitMiddleware allows each authenticated partner limit
+// requests per window to the routes it guards. scope keeps the counters of
+// different routes apart. A limit of 0 disables it. It must run after
+// PartnerAuthMiddleware.
+func PartnerRateLimitMiddleware(limiter services.RateLimiter, scope string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		partnerID, exists := c.Get("partner_id")
+		if limit <= 0 || !exists {
+			c.Next()
+			return
+		}
+
+		key := fmt.Sprintf("ratelimit:%s:partner:%v", scope, partnerID)
+		result, err := limiter.Allow(c.Request.Context(), key, limit, window)
+		if err != nil {
+			// Better to let a partner through than to stop their sync
+			// because the counter store is down.
+			log.Printf("⚠️  Rate limiter unavailable for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		resetSeconds := strconv.Itoa(int(math.Ceil(result.ResetIn.Seconds())))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", resetSeconds)
+
+		if !result.Allowed {
+			c.Header("Retry-After", resetSeconds)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded, retry after " + resetSeconds + " seconds"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
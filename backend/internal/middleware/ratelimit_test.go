@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPartnerRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := services.NewMemoryRateLimiter()
+	router := gin.New()
+	router.POST("/sync", func(c *gin.Context) {
+		if id := c.GetHeader("X-Partner"); id != "" {
+			c.Set("partner_id", id)
+		}
+		c.Next()
+	}, PartnerRateLimitMiddleware(limiter, "sync", 2, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(partner string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sync", nil)
+		req.Header.Set("X-Partner", partner)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send("1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d; want 200", i+1, w.Code)
+		}
+	}
+
+	w := send("1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status = %d; want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("missing rate limit headers: %v", w.Header())
+	}
+
+	if w := send("2"); w.Code != http.StatusOK {
+		t.Errorf("other partner: status = %d; want 200", w.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"ecommerce-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RequireVerifiedEmail rejects requests from users who have not verified their
+// email address yet. It does nothing unless enabled, so checkout can be gated
+// per environment. It must run after AuthMiddleware.
+func RequireVerifiedEmail(db *gorm.DB, enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			c.Next()
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := db.Select("id", "email_verified").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		if !user.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address before placing an order"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -22,17 +22,38 @@ func NewAutoMigrator(db *gorm.DB) *AutoMigrator {
 			&models.User{},
 			&models.Category{},
 			&models.Product{},
+			&models.ProductModeration{},
 			&models.ProductImage{},
 			&models.ProductReview{},
 			&models.Cart{},
 			&models.CartItem{},
 			&models.Order{},
 			&models.OrderItem{},
+			&models.SubOrder{},
 			&models.ShippingAddress{},
 			&models.Payment{},
+			&models.Refund{},
+			&models.ReconciliationReport{},
+			&models.ReconciliationItem{},
+			&models.WalletAccount{},
+			&models.LedgerTransaction{},
+			&models.LedgerEntry{},
+			&models.GiftCard{},
+			&models.PaymentEvent{},
 			&models.Coupon{},
 			&models.Partner{},
+			&models.PartnerAPIKey{},
+			&models.PartnerApplication{},
+			&models.PartnerLedgerEntry{},
+			&models.PartnerPayout{},
+			&models.PartnerProductDailyStat{},
+			&models.AggregateWatermark{},
+			&models.WebhookDelivery{},
+			&models.WebhookAttempt{},
+			&models.IdempotencyKey{},
+			&models.Session{},
 			&models.RefreshToken{},
+			&models.PasswordResetToken{},
 			&models.AuditLog{},
 		},
 	}
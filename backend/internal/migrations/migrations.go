@@ -1,8 +1,13 @@
 package migrations
 
 import (
+	"ecommerce-backend/internal/config"
+	"ecommerce-backend/internal/database"
 	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/services"
 	"log"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -31,6 +36,111 @@ func GetAllMigrations() []MigrationStep {
 			Up:          migration003Up,
 			Down:        migration003Down,
 		},
+		{
+			Version:     "004_payment_events",
+			Name:        "Generalise payment callbacks into payment events",
+			Description: "Moves stored IPN callbacks into the payment_events audit trail",
+			Up:          migration004Up,
+			Down:        migration004Down,
+		},
+		{
+			Version:     "005_product_partner_ownership",
+			Name:        "Assign products to partners",
+			Description: "Adds products.partner_id and gives existing products to the house partner",
+			Up:          migration005Up,
+			Down:        migration005Down,
+		},
+		{
+			Version:     "006_encrypt_partner_secrets",
+			Name:        "Encrypt partner secrets",
+			Description: "Encrypts partner signing secrets with PARTNER_SECRET_KEY and drops the plaintext column",
+			Up:          migration006Up,
+			Down:        migration006Down,
+		},
+		{
+			Version:     "007_partner_sub_orders",
+			Name:        "Split orders into sub-orders",
+			Description: "Creates sub_orders and splits existing orders into one sub-order per partner",
+			Up:          migration007Up,
+			Down:        migration007Down,
+		},
+		{
+			Version:     "008_product_moderation",
+			Name:        "Product moderation status",
+			Description: "Adds products.moderation_status and product_moderations, marking inactive partner products as pending review",
+			Up:          migration008Up,
+			Down:        migration008Down,
+		},
+		{
+			Version:     "009_partner_api_keys",
+			Name:        "Partner API keys",
+			Description: "Moves each partner's API key into partner_api_keys with every scope; the partner secret stays as the webhook signing secret",
+			Up:          migration009Up,
+			Down:        migration009Down,
+		},
+		{
+			Version:     "010_partner_applications",
+			Name:        "Partner applications",
+			Description: "Adds partner_applications and contact details on partners for self-service onboarding",
+			Up:          migration010Up,
+			Down:        migration010Down,
+		},
+		{
+			Version:     "011_partner_fulfilment",
+			Name:        "Partner fulfilment",
+			Description: "Adds order_items.status for out-of-stock lines and sub_orders.acknowledged_at",
+			Up:          migration011Up,
+			Down:        migration011Down,
+		},
+		{
+			Version:     "012_partner_stats",
+			Name:        "Partner statistics aggregates",
+			Description: "Adds partner_product_daily_stats and aggregate_watermarks, plus updated_at indexes the incremental refresh scans",
+			Up:          migration012Up,
+			Down:        migration012Down,
+		},
+		{
+			Version:     "013_partner_sandbox",
+			Name:        "Partner sandbox",
+			Description: "Adds sandbox API keys and the is_test flag on products, orders and sub-orders",
+			Up:          migration013Up,
+			Down:        migration013Down,
+		},
+		{
+			Version:     "014_email_verification",
+			Name:        "Email verification",
+			Description: "Adds verification_sent_at to users to throttle verification emails",
+			Up:          migration014Up,
+			Down:        migration014Down,
+		},
+		{
+			Version:     "015_password_reset",
+			Name:        "Password reset",
+			Description: "Adds password_reset_tokens for single-use password reset links",
+			Up:          migration015Up,
+			Down:        migration015Down,
+		},
+		{
+			Version:     "016_refresh_token_rotation",
+			Name:        "Refresh token rotation",
+			Description: "Stores refresh tokens as hashes grouped into families, with rotation and revocation times",
+			Up:          migration016Up,
+			Down:        migration016Down,
+		},
+		{
+			Version:     "017_sessions",
+			Name:        "Sessions",
+			Description: "Adds sessions, one per sign-in, named by the refresh token family",
+			Up:          migration017Up,
+			Down:        migration017Down,
+		},
+		{
+			Version:     "018_password_reset_throttle",
+			Name:        "Password reset throttle",
+			Description: "Adds password_reset_sent_at to users to throttle password reset emails",
+			Up:          migration018Up,
+			Down:        migration018Down,
+		},
 		// Add more migrations here as your schema evolves
 	}
 }
@@ -196,6 +306,198 @@ func migration003Down(db *gorm.DB) error {
 	return nil
 }
 
+// Migration 004: Payment event audit trail
+func migration004Up(db *gorm.DB) error {
+	log.Println("🧾 Moving payment callbacks into payment events...")
+
+	if err := db.AutoMigrate(&models.PaymentEvent{}); err != nil {
+		return err
+	}
+
+	if !db.Migrator().HasTable("payment_callbacks") {
+		log.Println("✅ No payment callbacks to move")
+		return nil
+	}
+
+	type paymentCallback struct {
+		ID             uint
+		Provider       string
+		TxnRef         string
+		HTTPMethod     string
+		RawParams      string
+		SignatureValid bool
+		Outcome        string
+		SourceIP       string
+		CreatedAt      time.Time
+	}
+
+	var callbacks []paymentCallback
+	if err := db.Table("payment_callbacks").Order("id").Find(&callbacks).Error; err != nil {
+		return err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, callback := range callbacks {
+			event := models.PaymentEvent{
+				Provider:       callback.Provider,
+				Type:           "ipn",
+				TxnRef:         callback.TxnRef,
+				HTTPMethod:     callback.HTTPMethod,
+				RawParams:      payments.MaskPayload(callback.RawParams),
+				SignatureValid: callback.SignatureValid,
+				Outcome:        callback.Outcome,
+				SourceIP:       callback.SourceIP,
+				CreatedAt:      callback.CreatedAt,
+			}
+
+			var payment models.Payment
+			if err := tx.Where("transaction_id = ? AND payment_method = ?", callback.TxnRef, callback.Provider).First(&payment).Error; err == nil {
+				event.PaymentID = &payment.ID
+			}
+
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("DROP TABLE payment_callbacks").Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Println("✅ Payment callbacks moved to payment events")
+	return nil
+}
+
+func migration004Down(db *gorm.DB) error {
+	log.Println("🗑️  Dropping payment events...")
+	return db.Exec("DROP TABLE IF EXISTS payment_events").Error
+}
+
+// Migration 005: Partner ownership of products
+func migration005Up(db *gorm.DB) error {
+	log.Println("🏠 Assigning existing products to the house partner...")
+
+	if err := db.AutoMigrate(&models.Partner{}, &models.Product{}); err != nil {
+		return err
+	}
+
+	house, err := services.HousePartner(db)
+	if err != nil {
+		return err
+	}
+
+	result := db.Model(&models.Product{}).Unscoped().
+		Where("partner_id = 0 OR partner_id IS NULL").
+		Update("partner_id", house.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	log.Printf("✅ %d products assigned to house partner %d", result.RowsAffected, house.ID)
+	return nil
+}
+
+func migration005Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing product ownership...")
+	if err := db.Exec("ALTER TABLE products DROP COLUMN IF EXISTS partner_id").Error; err != nil {
+		return err
+	}
+	if err := db.Unscoped().Where("email = ?", services.HousePartnerEmail).Delete(&models.Partner{}).Error; err != nil {
+		return err
+	}
+	return db.Exec("ALTER TABLE partners DROP COLUMN IF EXISTS is_house").Error
+}
+
+// Migration 006: Encrypt partner secrets
+func migration006Up(db *gorm.DB) error {
+	return UpgradePartnerSecrets(services.NewSecretBox(config.Load().PartnerSecretKey))(db)
+}
+
+// UpgradePartnerSecrets encrypts plaintext partner secrets with box and drops
+// the NOT NULL secret_key column the Partner model no longer fills. The API
+// server runs it on start, so partners can still be created when migration
+// 006 has not been applied; it does nothing once the column is gone.
+func UpgradePartnerSecrets(box *services.SecretBox) database.Upgrade {
+	return func(db *gorm.DB) error {
+		if !db.Migrator().HasTable("partners") || !db.Migrator().HasColumn("partners", "secret_key") {
+			return nil
+		}
+
+		log.Println("🔐 Encrypting partner secrets...")
+		if err := db.AutoMigrate(&models.Partner{}); err != nil {
+			return err
+		}
+
+		type partnerSecret struct {
+			ID        uint
+			SecretKey string
+		}
+
+		encrypted := 0
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Secrets a previous, interrupted run already encrypted are
+			// left alone.
+			var secrets []partnerSecret
+			if err := tx.Table("partners").Select("id, secret_key").
+				Where("secret_key <> '' AND (encrypted_secret IS NULL OR encrypted_secret = '')").
+				Find(&secrets).Error; err != nil {
+				return err
+			}
+
+			for _, secret := range secrets {
+				ciphertext, err := box.Encrypt(secret.SecretKey)
+				if err != nil {
+					return err
+				}
+				if err := tx.Table("partners").Where("id = ?", secret.ID).Update("encrypted_secret", ciphertext).Error; err != nil {
+					return err
+				}
+			}
+			encrypted = len(secrets)
+			return tx.Migrator().DropColumn(&models.Partner{}, "secret_key")
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("✅ Encrypted %d partner secrets", encrypted)
+		return nil
+	}
+}
+
+func migration006Down(db *gorm.DB) error {
+	log.Println("🔓 Restoring plaintext partner secrets...")
+
+	if err := db.Exec("ALTER TABLE partners ADD COLUMN IF NOT EXISTS secret_key text NOT NULL DEFAULT ''").Error; err != nil {
+		return err
+	}
+
+	type partnerSecret struct {
+		ID              uint
+		EncryptedSecret string
+	}
+
+	var secrets []partnerSecret
+	if err := db.Table("partners").Select("id, encrypted_secret").Where("encrypted_secret <> ''").Find(&secrets).Error; err != nil {
+		return err
+	}
+
+	box := services.NewSecretBox(config.Load().PartnerSecretKey)
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, secret := range secrets {
+			plaintext, err := box.Decrypt(secret.EncryptedSecret)
+			if err != nil {
+				return err
+			}
+			if err := tx.Table("partners").Where("id = ?", secret.ID).Update("secret_key", plaintext).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("ALTER TABLE partners DROP COLUMN encrypted_secret").Error
+	})
+}
+
 // Example of how to add a new migration when you modify models
 func ExampleNewMigration() MigrationStep {
 	return MigrationStep{
@@ -225,3 +527,414 @@ func ExampleNewMigration() MigrationStep {
 		},
 	}
 }
+
+// Migration 007: Per-partner sub-orders
+func migration007Up(db *gorm.DB) error {
+	log.Println("📦 Splitting orders into sub-orders...")
+
+	if err := db.AutoMigrate(&models.OrderItem{}, &models.SubOrder{}); err != nil {
+		return err
+	}
+
+	var orders []models.Order
+	if err := db.Where("id IN (?)", db.Model(&models.OrderItem{}).Select("order_id").Where("sub_order_id IS NULL")).
+		Find(&orders).Error; err != nil {
+		return err
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := services.SplitOrder(tx, order); err != nil {
+				return err
+			}
+			// Existing orders were fulfilled as a whole, so every sub-order
+			// takes the order's progress.
+			status := "pending"
+			switch {
+			case order.CancelledAt != nil:
+				status = "cancelled"
+			case order.DeliveredAt != nil:
+				status = "delivered"
+			case order.ShippedAt != nil:
+				status = "shipped"
+			case order.Status == "processing":
+				status = "processing"
+			}
+			return tx.Model(&models.SubOrder{}).Where("order_id = ?", order.ID).Updates(map[string]interface{}{
+				"status":       status,
+				"shipped_at":   order.ShippedAt,
+				"delivered_at": order.DeliveredAt,
+				"cancelled_at": order.CancelledAt,
+			}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Split %d orders into sub-orders", len(orders))
+	return nil
+}
+
+func migration007Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing sub-orders...")
+	if err := db.Exec("ALTER TABLE order_items DROP COLUMN IF EXISTS sub_order_id").Error; err != nil {
+		return err
+	}
+	return db.Migrator().DropTable("sub_orders")
+}
+
+// Migration 008: Product moderation workflow
+func migration008Up(db *gorm.DB) error {
+	log.Println("🛡️  Adding product moderation status...")
+
+	if err := db.AutoMigrate(&models.Product{}, &models.ProductModeration{}); err != nil {
+		return err
+	}
+
+	// Inactive products were either awaiting approval or switched off by an
+	// admin; both now need a review before going back on sale.
+	result := db.Model(&models.Product{}).Unscoped().
+		Where("is_active = ?", false).
+		Update("moderation_status", services.ModerationPendingReview)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	log.Printf("✅ %d products marked as pending review", result.RowsAffected)
+	return nil
+}
+
+func migration008Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing product moderation...")
+	if err := db.Migrator().DropTable("product_moderations"); err != nil {
+		return err
+	}
+	if err := db.Exec("ALTER TABLE products DROP COLUMN IF EXISTS moderation_note").Error; err != nil {
+		return err
+	}
+	return db.Exec("ALTER TABLE products DROP COLUMN IF EXISTS moderation_status").Error
+}
+
+// Migration 009: Multiple scoped API keys per partner
+func migration009Up(db *gorm.DB) error {
+	return UpgradePartnerAPIKeys(db)
+}
+
+// UpgradePartnerAPIKeys moves each partner's API key and secret to
+// partner_api_keys and drops the NOT NULL api_key column the Partner model no
+// longer fills. Like UpgradePartnerSecrets, which must run first, the API
+// server runs it on start and it does nothing once the column is gone.
+func UpgradePartnerAPIKeys(db *gorm.DB) error {
+	if !db.Migrator().HasTable("partners") || !db.Migrator().HasColumn("partners", "api_key") {
+		return nil
+	}
+
+	log.Println("🔑 Moving partner API keys...")
+	if err := db.AutoMigrate(&models.PartnerAPIKey{}); err != nil {
+		return err
+	}
+
+	type partnerKey struct {
+		ID              uint
+		APIKey          string
+		EncryptedSecret string
+		IsHouse         bool
+	}
+
+	scopes, err := services.NormalizeScopes(services.AllPartnerScopes)
+	if err != nil {
+		return err
+	}
+
+	moved := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Keys a previous, interrupted run already moved are left alone.
+		var partners []partnerKey
+		if err := tx.Table("partners").Select("id, api_key, encrypted_secret, is_house").
+			Where("api_key NOT IN (?)", tx.Model(&models.PartnerAPIKey{}).Select("api_key")).
+			Find(&partners).Error; err != nil {
+			return err
+		}
+
+		for _, partner := range partners {
+			// The house partner never had a usable secret.
+			if partner.IsHouse || partner.APIKey == "" || partner.EncryptedSecret == "" {
+				continue
+			}
+			key := models.PartnerAPIKey{
+				PartnerID:       partner.ID,
+				Name:            "Default",
+				APIKey:          partner.APIKey,
+				EncryptedSecret: partner.EncryptedSecret,
+				Scopes:          scopes,
+			}
+			if err := tx.Create(&key).Error; err != nil {
+				return err
+			}
+			moved++
+		}
+		return tx.Migrator().DropColumn(&models.Partner{}, "api_key")
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Moved %d partner API keys", moved)
+	return nil
+}
+
+func migration009Down(db *gorm.DB) error {
+	log.Println("🗑️  Restoring single partner API keys...")
+
+	if err := db.Exec("ALTER TABLE partners ADD COLUMN IF NOT EXISTS api_key text").Error; err != nil {
+		return err
+	}
+	// Keep each partner's oldest usable key; partners without one get a
+	// random key that nobody holds the secret for.
+	if err := db.Exec(`UPDATE partners SET api_key = k.api_key, encrypted_secret = k.encrypted_secret FROM (
+		SELECT DISTINCT ON (partner_id) partner_id, api_key, encrypted_secret FROM partner_api_keys
+		WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY partner_id, created_at
+	) k WHERE partners.id = k.partner_id`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec("UPDATE partners SET api_key = md5(random()::text) WHERE api_key IS NULL").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("ALTER TABLE partners ALTER COLUMN api_key SET NOT NULL").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_partners_api_key ON partners (api_key)").Error; err != nil {
+		return err
+	}
+	return db.Migrator().DropTable("partner_api_keys")
+}
+
+// Migration 010: Partner self-service onboarding
+func migration010Up(db *gorm.DB) error {
+	log.Println("📝 Adding partner applications...")
+	if err := db.AutoMigrate(&models.Partner{}, &models.PartnerApplication{}); err != nil {
+		return err
+	}
+	log.Println("✅ Partner applications added")
+	return nil
+}
+
+func migration010Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing partner applications...")
+	if err := db.Migrator().DropTable("partner_applications"); err != nil {
+		return err
+	}
+	for _, column := range []string{"contact_name", "phone", "address", "tax_code"} {
+		if err := db.Exec("ALTER TABLE partners DROP COLUMN IF EXISTS " + column).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migration 011: Partner order fulfilment
+func migration011Up(db *gorm.DB) error {
+	log.Println("📦 Adding partner fulfilment tracking...")
+	if err := db.AutoMigrate(&models.OrderItem{}, &models.SubOrder{}); err != nil {
+		return err
+	}
+	log.Println("✅ Partner fulfilment tracking added")
+	return nil
+}
+
+func migration011Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing partner fulfilment tracking...")
+	for _, stmt := range []string{
+		"ALTER TABLE order_items DROP COLUMN IF EXISTS status",
+		"ALTER TABLE order_items DROP COLUMN IF EXISTS out_of_stock_at",
+		"ALTER TABLE sub_orders DROP COLUMN IF EXISTS acknowledged_at",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migration 012: Partner statistics aggregates
+func migration012Up(db *gorm.DB) error {
+	log.Println("📊 Adding partner statistics aggregates...")
+	// The aggregates are filled by the stats refresher, which backfills
+	// everything on its first run.
+	if err := db.AutoMigrate(&models.PartnerProductDailyStat{}, &models.AggregateWatermark{}, &models.Order{}, &models.SubOrder{}, &models.ProductReview{}); err != nil {
+		return err
+	}
+	log.Println("✅ Partner statistics aggregates added")
+	return nil
+}
+
+func migration012Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing partner statistics aggregates...")
+	for _, stmt := range []string{
+		"DROP INDEX IF EXISTS idx_orders_updated_at",
+		"DROP INDEX IF EXISTS idx_sub_orders_updated_at",
+		"DROP INDEX IF EXISTS idx_product_reviews_updated_at",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return db.Migrator().DropTable("aggregate_watermarks", "partner_product_daily_stats")
+}
+
+// Migration 013: Partner sandbox
+func migration013Up(db *gorm.DB) error {
+	log.Println("🧪 Adding partner sandbox...")
+	if err := db.AutoMigrate(&models.PartnerAPIKey{}, &models.Product{}, &models.Order{}, &models.SubOrder{}); err != nil {
+		return err
+	}
+	log.Println("✅ Partner sandbox added")
+	return nil
+}
+
+func migration013Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing partner sandbox...")
+	// Without the flags sandbox keys and test data would pass for live ones,
+	// so they are revoked and removed first.
+	for _, stmt := range []string{
+		"UPDATE partner_api_keys SET revoked_at = NOW() WHERE sandbox AND revoked_at IS NULL",
+		"DELETE FROM payments WHERE order_id IN (SELECT id FROM orders WHERE is_test)",
+		"DELETE FROM shipping_addresses WHERE order_id IN (SELECT id FROM orders WHERE is_test)",
+		"DELETE FROM order_items WHERE order_id IN (SELECT id FROM orders WHERE is_test)",
+		"DELETE FROM sub_orders WHERE is_test",
+		"DELETE FROM orders WHERE is_test",
+		"UPDATE products SET deleted_at = NOW(), is_active = false WHERE is_test AND deleted_at IS NULL",
+		"ALTER TABLE partner_api_keys DROP COLUMN IF EXISTS sandbox",
+		"ALTER TABLE products DROP COLUMN IF EXISTS is_test",
+		"ALTER TABLE orders DROP COLUMN IF EXISTS is_test",
+		"ALTER TABLE sub_orders DROP COLUMN IF EXISTS is_test",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migration 014: Email verification
+func migration014Up(db *gorm.DB) error {
+	log.Println("📧 Adding email verification...")
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		return err
+	}
+	log.Println("✅ Email verification added")
+	return nil
+}
+
+func migration014Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing email verification...")
+	return db.Exec("ALTER TABLE users DROP COLUMN IF EXISTS verification_sent_at").Error
+}
+
+// Migration 015: Password reset
+func migration015Up(db *gorm.DB) error {
+	log.Println("🔑 Adding password reset tokens...")
+	if err := db.AutoMigrate(&models.PasswordResetToken{}); err != nil {
+		return err
+	}
+	log.Println("✅ Password reset tokens added")
+	return nil
+}
+
+func migration015Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing password reset tokens...")
+	return db.Migrator().DropTable("password_reset_tokens")
+}
+
+// Migration 016: Refresh token rotation
+func migration016Up(db *gorm.DB) error {
+	log.Println("🔄 Adding refresh token rotation...")
+	if err := UpgradeRefreshTokens(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&models.RefreshToken{}); err != nil {
+		return err
+	}
+	log.Println("✅ Refresh token rotation added")
+	return nil
+}
+
+func migration016Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing refresh token rotation...")
+	// Hashed tokens cannot be turned back into tokens, so everyone has to
+	// sign in again.
+	for _, stmt := range []string{
+		"DELETE FROM refresh_tokens",
+		"ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token_hash",
+		"ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id",
+		"ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS rotated_at",
+		"ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS revoked_at",
+		"ALTER TABLE refresh_tokens ADD COLUMN token text NOT NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token ON refresh_tokens (token)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpgradeRefreshTokens converts refresh tokens stored in plain text, from
+// before rotation was added, to hashed tokens that each start their own
+// family, so nobody is signed out by the upgrade. It does nothing once the
+// plain text column is gone, and must run before RefreshToken is migrated.
+func UpgradeRefreshTokens(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("refresh_tokens", "token") {
+		return nil
+	}
+
+	log.Println("🔄 Hashing stored refresh tokens...")
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			"ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash varchar(64)",
+			"ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id varchar(32)",
+			"UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'), family_id = md5('legacy-' || id::text)",
+			"ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL",
+			"ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL",
+			"ALTER TABLE refresh_tokens DROP COLUMN token",
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Migration 017: Sessions
+func migration017Up(db *gorm.DB) error {
+	log.Println("🖥️  Adding sessions...")
+	if err := db.AutoMigrate(&models.Session{}); err != nil {
+		return err
+	}
+	log.Println("✅ Sessions added")
+	return nil
+}
+
+func migration017Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing sessions...")
+	return db.Migrator().DropTable("sessions")
+}
+
+// Migration 018: Password reset throttle
+func migration018Up(db *gorm.DB) error {
+	log.Println("🔑 Adding password reset throttle...")
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		return err
+	}
+	log.Println("✅ Password reset throttle added")
+	return nil
+}
+
+func migration018Down(db *gorm.DB) error {
+	log.Println("🗑️  Removing password reset throttle...")
+	return db.Exec("ALTER TABLE users DROP COLUMN IF EXISTS password_reset_sent_at").Error
+}
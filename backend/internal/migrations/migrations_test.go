@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"testing"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/internal/testdb"
+)
+
+func TestUpgradePartnerSecrets(t *testing.T) {
+	db := testdb.Open(t)
+	box := services.NewSecretBox("encryption-key")
+
+	// The schema as it was before migration 006.
+	if err := db.Exec("ALTER TABLE partners ADD COLUMN `secret_key` text NOT NULL DEFAULT ''").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO partners (name, email, secret_key, is_active) VALUES ('Acme', 'acme@example.com', 'plain-secret', true)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Running on every start must be harmless once applied.
+	for i := 0; i < 2; i++ {
+		if err := UpgradePartnerSecrets(box)(db); err != nil {
+			t.Fatalf("UpgradePartnerSecrets() run %d error = %v", i+1, err)
+		}
+	}
+
+	if db.Migrator().HasColumn("partners", "secret_key") {
+		t.Error("secret_key column was not dropped")
+	}
+	var partner models.Partner
+	db.Where("email = ?", "acme@example.com").First(&partner)
+	if secret, err := box.Decrypt(partner.EncryptedSecret); err != nil || secret != "plain-secret" {
+		t.Errorf("encrypted secret decrypts to %q, %v; want plain-secret", secret, err)
+	}
+
+	// The model no longer sets secret_key, which used to fail the insert.
+	if err := db.Create(&models.Partner{Name: "Globex", Email: "globex@example.com"}).Error; err != nil {
+		t.Errorf("creating a partner after the upgrade: %v", err)
+	}
+}
+
+func TestUpgradePartnerAPIKeys(t *testing.T) {
+	db := testdb.Open(t)
+
+	// The schema as it was before migration 009.
+	if err := db.Exec("ALTER TABLE partners ADD COLUMN `api_key` text NOT NULL DEFAULT ''").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO partners (name, email, api_key, encrypted_secret, is_active) VALUES ('Acme', 'acme@example.com', 'pk_acme', 'ciphertext', true)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Running on every start must be harmless once applied.
+	for i := 0; i < 2; i++ {
+		if err := UpgradePartnerAPIKeys(db); err != nil {
+			t.Fatalf("UpgradePartnerAPIKeys() run %d error = %v", i+1, err)
+		}
+	}
+
+	if db.Migrator().HasColumn("partners", "api_key") {
+		t.Error("api_key column was not dropped")
+	}
+	var keys []models.PartnerAPIKey
+	db.Find(&keys)
+	if len(keys) != 1 || keys[0].APIKey != "pk_acme" || keys[0].EncryptedSecret != "ciphertext" {
+		t.Errorf("partner API keys = %+v; want the moved pk_acme key only", keys)
+	}
+
+	// The model no longer sets api_key, which used to fail the insert.
+	if err := db.Create(&models.Partner{Name: "Globex", Email: "globex@example.com"}).Error; err != nil {
+		t.Errorf("creating a partner after the upgrade: %v", err)
+	}
+}
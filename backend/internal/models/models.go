@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,6 +21,12 @@ type User struct {
 	Role        string         `json:"role" gorm:"default:customer"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
 	EmailVerified bool         `json:"email_verified" gorm:"default:false"`
+	// VerificationSentAt is when the last verification email went out, to
+	// throttle resends.
+	VerificationSentAt *time.Time `json:"-"`
+	// PasswordResetSentAt is when the last password reset email went out, to
+	// throttle them.
+	PasswordResetSentAt *time.Time `json:"-"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -58,10 +65,14 @@ type Product struct {
 	Images          []ProductImage `json:"images,omitempty" gorm:"foreignKey:ProductID"`
 	CategoryID      uint           `json:"category_id"`
 	Category        Category       `json:"category" gorm:"foreignKey:CategoryID"`
+	PartnerID       uint           `json:"partner_id" gorm:"index"` // owner; the house partner for the store's own catalogue
 	Brand           string         `json:"brand"`
 	Tags            string         `json:"tags"`
 	IsFeatured      bool           `json:"is_featured" gorm:"default:false"`
 	IsActive        bool           `json:"is_active" gorm:"default:true"`
+	ModerationStatus string        `json:"moderation_status" gorm:"default:approved;index"` // draft, pending_review, approved, rejected, suspended
+	ModerationNote  string         `json:"moderation_note"` // the reviewer's latest note to the partner
+	IsTest          bool           `json:"is_test" gorm:"not null;default:false;index"` // sandbox product, never listed publicly
 	ViewCount       int            `json:"view_count" gorm:"default:0"`
 	Rating          float64        `json:"rating" gorm:"default:0"`
 	ReviewCount     int            `json:"review_count" gorm:"default:0"`
@@ -71,6 +82,18 @@ type Product struct {
 	Reviews         []ProductReview `json:"reviews,omitempty" gorm:"foreignKey:ProductID"`
 }
 
+// ProductModeration is one step in a product's review history.
+type ProductModeration struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ProductID  uint      `json:"product_id" gorm:"not null;index"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status" gorm:"not null"`
+	Note       string    `json:"note"`
+	ActorType  string    `json:"actor_type" gorm:"not null"` // admin, partner
+	ActorID    uint      `json:"actor_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 type ProductImage struct {
 	ID        uint   `json:"id" gorm:"primaryKey"`
 	ProductID uint   `json:"product_id" gorm:"not null"`
@@ -91,7 +114,7 @@ type ProductReview struct {
 	Comment   string    `json:"comment"`
 	IsVerified bool     `json:"is_verified" gorm:"default:false"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"index"`
 }
 
 type Cart struct {
@@ -131,23 +154,50 @@ type Order struct {
 	ShippingAddress ShippingAddress `json:"shipping_address" gorm:"foreignKey:OrderID"`
 	Items           []OrderItem     `json:"items" gorm:"foreignKey:OrderID"`
 	Payments        []Payment       `json:"payments,omitempty" gorm:"foreignKey:OrderID"`
+	SubOrders       []SubOrder      `json:"sub_orders,omitempty" gorm:"foreignKey:OrderID"`
+	IsTest          bool            `json:"is_test" gorm:"not null;default:false;index"` // placed in a partner sandbox, excluded from reports
 	OrderDate       time.Time       `json:"order_date"`
 	ShippedAt       *time.Time      `json:"shipped_at"`
 	DeliveredAt     *time.Time      `json:"delivered_at"`
 	CancelledAt     *time.Time      `json:"cancelled_at"`
 	CreatedAt       time.Time       `json:"created_at"`
-	UpdatedAt       time.Time       `json:"updated_at"`
+	UpdatedAt       time.Time       `json:"updated_at" gorm:"index"`
 	DeletedAt       gorm.DeletedAt  `json:"-" gorm:"index"`
 }
 
 type OrderItem struct {
-	ID         uint    `json:"id" gorm:"primaryKey"`
-	OrderID    uint    `json:"order_id" gorm:"not null"`
-	ProductID  uint    `json:"product_id" gorm:"not null"`
-	Product    Product `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity   int     `json:"quantity" gorm:"not null;check:quantity > 0"`
-	UnitPrice  float64 `json:"unit_price" gorm:"not null"`
-	TotalPrice float64 `json:"total_price" gorm:"not null"`
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	OrderID      uint       `json:"order_id" gorm:"not null"`
+	SubOrderID   *uint      `json:"sub_order_id" gorm:"index"`
+	ProductID    uint       `json:"product_id" gorm:"not null"`
+	Product      Product    `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity     int        `json:"quantity" gorm:"not null;check:quantity > 0"`
+	UnitPrice    float64    `json:"unit_price" gorm:"not null"`
+	TotalPrice   float64    `json:"total_price" gorm:"not null"`
+	Status       string     `json:"status" gorm:"not null;default:'active'"` // active, out_of_stock
+	OutOfStockAt *time.Time `json:"out_of_stock_at"`
+}
+
+// SubOrder is the part of an order sold by one partner. Each partner ships
+// its own sub-order; the parent order's status is derived from them.
+type SubOrder struct {
+	ID              uint             `json:"id" gorm:"primaryKey"`
+	OrderID         uint             `json:"order_id" gorm:"not null;index"`
+	PartnerID       uint             `json:"partner_id" gorm:"not null;index"`
+	Number          string           `json:"number" gorm:"uniqueIndex;not null"`
+	Status          string           `json:"status" gorm:"default:pending"` // pending, processing, shipped, delivered, cancelled
+	Subtotal        float64          `json:"subtotal" gorm:"not null"`
+	IsTest          bool             `json:"is_test" gorm:"not null;default:false;index"` // copied from the order
+	Carrier         string           `json:"carrier"`
+	TrackingNumber  string           `json:"tracking_number"`
+	Items           []OrderItem      `json:"items" gorm:"foreignKey:SubOrderID"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty" gorm:"foreignKey:OrderID;references:OrderID;-:migration"`
+	AcknowledgedAt  *time.Time       `json:"acknowledged_at"`
+	ShippedAt       *time.Time       `json:"shipped_at"`
+	DeliveredAt     *time.Time       `json:"delivered_at"`
+	CancelledAt     *time.Time       `json:"cancelled_at"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at" gorm:"index"`
 }
 
 type ShippingAddress struct {
@@ -174,13 +224,156 @@ type Payment struct {
 	Amount          float64        `json:"amount" gorm:"not null"`
 	Currency        string         `json:"currency" gorm:"default:VND"`
 	TransactionID   string         `json:"transaction_id"`
+	GatewayTransactionNo string    `json:"gateway_transaction_no"`
 	GatewayResponse string         `json:"gateway_response,omitempty"`
+	RefundedAmount  float64        `json:"refunded_amount" gorm:"default:0"`
+	Refunds         []Refund       `json:"refunds,omitempty" gorm:"foreignKey:PaymentID"`
+	Events          []PaymentEvent `json:"events,omitempty" gorm:"foreignKey:PaymentID"`
 	ProcessedAt     *time.Time     `json:"processed_at"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// PaymentEvent is an immutable record of one interaction with a payment
+// gateway: the outbound payment URL, the customer's return, IPNs, status
+// queries and refunds. Secrets in RawParams are masked before it is stored.
+type PaymentEvent struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	PaymentID      *uint     `json:"payment_id" gorm:"index"`
+	Provider       string    `json:"provider" gorm:"not null"`
+	Type           string    `json:"type" gorm:"not null"` // initiate, return, ipn, query, refund
+	TxnRef         string    `json:"txn_ref" gorm:"index"`
+	HTTPMethod     string    `json:"http_method"`
+	URL            string    `json:"url"`
+	RawParams      string    `json:"raw_params" gorm:"type:text"`
+	SignatureValid bool      `json:"signature_valid"`
+	Outcome        string    `json:"outcome"`
+	SourceIP       string    `json:"source_ip"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+var ErrPaymentEventImmutable = errors.New("payment events cannot be modified")
+
+func (e *PaymentEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrPaymentEventImmutable
+}
+
+func (e *PaymentEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrPaymentEventImmutable
+}
+
+// Refund records a single attempt to return money for a Payment. Partial
+// refunds create one row each; the payment's RefundedAmount tracks the
+// settled total.
+type Refund struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	PaymentID       uint       `json:"payment_id" gorm:"not null;index"`
+	Payment         *Payment   `json:"payment,omitempty" gorm:"foreignKey:PaymentID"`
+	OrderID         uint       `json:"order_id" gorm:"not null;index"`
+	Type            string     `json:"type" gorm:"not null"`
+	Status          string     `json:"status" gorm:"default:pending"`
+	Amount          float64    `json:"amount" gorm:"not null"`
+	Currency        string     `json:"currency" gorm:"default:VND"`
+	Reason          string     `json:"reason"`
+	RequestID       string     `json:"request_id" gorm:"uniqueIndex;not null"`
+	TransactionNo   string     `json:"transaction_no"`
+	GatewayResponse string     `json:"gateway_response,omitempty"`
+	RequestedBy     *uint      `json:"requested_by"`
+	ProcessedAt     *time.Time `json:"processed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ReconciliationReport compares one day's payments with what the gateways
+// report for them. Only mismatches are kept as items for finance to review.
+type ReconciliationReport struct {
+	ID              uint                 `json:"id" gorm:"primaryKey"`
+	ReportDate      time.Time            `json:"report_date" gorm:"type:date;uniqueIndex;not null"`
+	Status          string               `json:"status" gorm:"default:running"` // running, completed, failed
+	PaymentsChecked int                  `json:"payments_checked"`
+	MismatchCount   int                  `json:"mismatch_count"`
+	OurTotal        float64              `json:"our_total"`
+	GatewayTotal    float64              `json:"gateway_total"`
+	Items           []ReconciliationItem `json:"items,omitempty" gorm:"foreignKey:ReportID"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+type ReconciliationItem struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	ReportID      uint      `json:"report_id" gorm:"index;not null"`
+	PaymentID     uint      `json:"payment_id" gorm:"index"`
+	OrderID       uint      `json:"order_id"`
+	PaymentMethod string    `json:"payment_method"`
+	TransactionID string    `json:"transaction_id"`
+	OurStatus     string    `json:"our_status"`
+	GatewayStatus string    `json:"gateway_status"`
+	OurAmount     float64   `json:"our_amount"`
+	GatewayAmount float64   `json:"gateway_amount"`
+	Reason        string    `json:"reason"` // status_mismatch, amount_mismatch, query_failed
+	Detail        string    `json:"detail,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WalletAccount is one account in the store-credit ledger: a customer's
+// wallet, a gift card, or a system account that credit enters or leaves
+// through. Balances of system accounts are derived from their entries and
+// may go negative; user and gift card balances never do.
+type WalletAccount struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"uniqueIndex;not null"` // user:<id>, gift_card:<code>, system:<name>
+	Type      string    `json:"type" gorm:"not null"`             // user, gift_card, system
+	UserID    *uint     `json:"user_id" gorm:"index"`
+	Balance   float64   `json:"balance" gorm:"default:0"`
+	Currency  string    `json:"currency" gorm:"default:VND"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LedgerTransaction groups the entries of one movement of store credit. Its
+// entries always sum to zero.
+type LedgerTransaction struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	Type        string        `json:"type" gorm:"not null"` // gift_card_issue, gift_card_purchase, gift_card_redeem, promotion, refund, payment
+	Reference   string        `json:"reference" gorm:"index"`
+	Description string        `json:"description"`
+	CreatedBy   *uint         `json:"created_by"`
+	Entries     []LedgerEntry `json:"entries,omitempty" gorm:"foreignKey:TransactionID"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// LedgerEntry is one side of a LedgerTransaction. A positive amount raises
+// the account's balance, a negative one lowers it.
+type LedgerEntry struct {
+	ID            uint               `json:"id" gorm:"primaryKey"`
+	TransactionID uint               `json:"transaction_id" gorm:"index;not null"`
+	Transaction   *LedgerTransaction `json:"transaction,omitempty" gorm:"foreignKey:TransactionID"`
+	AccountID     uint               `json:"account_id" gorm:"index;not null"`
+	Amount        float64            `json:"amount" gorm:"not null"`
+	BalanceAfter  float64            `json:"balance_after"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// GiftCard is a code backed by its own WalletAccount. Redeeming it moves the
+// balance into the redeeming customer's wallet.
+type GiftCard struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Code        string     `json:"code,omitempty" gorm:"uniqueIndex;not null"`
+	AccountID   uint       `json:"account_id" gorm:"not null"`
+	Amount      float64    `json:"amount" gorm:"not null"`
+	Currency    string     `json:"currency" gorm:"default:VND"`
+	Status      string     `json:"status" gorm:"default:active"` // pending_payment, active, redeemed, disabled
+	IssuedBy    *uint      `json:"issued_by"`
+	PurchasedBy *uint      `json:"purchased_by" gorm:"index"`
+	OrderID     *uint      `json:"order_id" gorm:"index"`
+	RedeemedBy  *uint      `json:"redeemed_by"`
+	RedeemedAt  *time.Time `json:"redeemed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
 type Coupon struct {
 	ID               uint           `json:"id" gorm:"primaryKey"`
 	Code             string         `json:"code" gorm:"uniqueIndex;not null"`
@@ -202,23 +395,211 @@ type Partner struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Name        string         `json:"name" gorm:"not null"`
 	Email       string         `json:"email" gorm:"uniqueIndex;not null"`
-	APIKey      string         `json:"api_key" gorm:"uniqueIndex;not null"`
-	SecretKey   string         `json:"-" gorm:"not null"`
+	EncryptedSecret string     `json:"-"` // webhook signing secret, encrypted with PARTNER_SECRET_KEY
+	APIKeys     []PartnerAPIKey `json:"api_keys,omitempty" gorm:"foreignKey:PartnerID"`
 	WebhookURL  string         `json:"webhook_url"`
+	ContactName string         `json:"contact_name"`
+	Phone       string         `json:"phone"`
+	Address     string         `json:"address"`
+	TaxCode     string         `json:"tax_code"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
 	CommissionRate float64     `json:"commission_rate" gorm:"default:0"`
+	IsHouse     bool           `json:"is_house" gorm:"default:false"` // the store itself; owns products created by admins
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// PartnerApplication is a business's request to sell on the marketplace.
+// Approving it creates the Partner and emails the first credentials.
+type PartnerApplication struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	BusinessName string     `json:"business_name" gorm:"not null"`
+	ContactName  string     `json:"contact_name" gorm:"not null"`
+	Email        string     `json:"email" gorm:"not null;index"`
+	Phone        string     `json:"phone"`
+	Address      string     `json:"address"`
+	TaxCode      string     `json:"tax_code" gorm:"not null;index"`
+	Website      string     `json:"website"`
+	Description  string     `json:"description" gorm:"type:text"`
+	Status       string     `json:"status" gorm:"not null;default:'pending';index"` // pending, approved, rejected
+	ReviewNote   string     `json:"review_note"`
+	ReviewedBy   *uint      `json:"reviewed_by"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	PartnerID    *uint      `json:"partner_id"` // set on approval
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// PartnerAPIKey is a credential a partner signs API requests with. A partner
+// may hold several, each limited to its scopes. A rotated key keeps working
+// until ExpiresAt so the partner can redeploy without downtime.
+type PartnerAPIKey struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	PartnerID       uint       `json:"partner_id" gorm:"not null;index"`
+	Name            string     `json:"name" gorm:"not null"`
+	APIKey          string     `json:"api_key" gorm:"uniqueIndex;not null"` // sent as X-API-Key
+	EncryptedSecret string     `json:"-"`                                   // signing secret, encrypted with PARTNER_SECRET_KEY
+	Scopes          string     `json:"scopes" gorm:"not null"`              // comma-separated, e.g. catalog:read,orders:read
+	ExpiresAt       *time.Time `json:"expires_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	ReplacedByID    *uint      `json:"replaced_by_id"` // the key this one was rotated to
+	Sandbox         bool       `json:"sandbox" gorm:"not null;default:false"` // works only on test products and orders
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PartnerLedgerEntry records money owed to a partner. Earnings are written
+// per order item when its sub-order is delivered, reversals when it is refunded
+// and payouts settle the balance. PartnerAmount is signed: the partner's
+// balance is the sum of it.
+type PartnerLedgerEntry struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	PartnerID        uint      `json:"partner_id" gorm:"index;not null"`
+	OrderID          *uint     `json:"order_id" gorm:"index"`
+	OrderItemID      *uint     `json:"order_item_id" gorm:"index"`
+	RefundID         *uint     `json:"refund_id"`
+	PayoutID         *uint     `json:"payout_id" gorm:"index"`
+	Type             string    `json:"type" gorm:"not null"` // earning, reversal
+	GrossAmount      float64   `json:"gross_amount"`
+	CommissionRate   float64   `json:"commission_rate"`
+	CommissionAmount float64   `json:"commission_amount"`
+	PartnerAmount    float64   `json:"partner_amount"`
+	Currency         string    `json:"currency" gorm:"default:VND"`
+	Description      string    `json:"description"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// PartnerProductDailyStat aggregates one partner product's sales and reviews
+// for a UTC day, so partner dashboards never scan orders. Sales are counted on
+// the day the order was placed once it is paid; refunds are attributed to the
+// lines in proportion to the share of the order refunded.
+type PartnerProductDailyStat struct {
+	ID             uint      `json:"-" gorm:"primaryKey"`
+	PartnerID      uint      `json:"partner_id" gorm:"not null;uniqueIndex:idx_partner_product_day,priority:1"`
+	ProductID      uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_partner_product_day,priority:3"`
+	Day            time.Time `json:"day" gorm:"type:date;not null;uniqueIndex:idx_partner_product_day,priority:2"`
+	Orders         int       `json:"orders"`
+	UnitsSold      int       `json:"units_sold"`
+	Revenue        float64   `json:"revenue"`
+	RefundedAmount float64   `json:"refunded_amount"`
+	ReviewCount    int       `json:"review_count"`
+	RatingSum      int       `json:"rating_sum"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AggregateWatermark records how far an incrementally refreshed aggregate
+// has caught up with its source tables.
+type AggregateWatermark struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	Through   time.Time `json:"through"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PartnerPayout is a statement of ledger entries paid out to a partner
+// together.
+type PartnerPayout struct {
+	ID         uint                 `json:"id" gorm:"primaryKey"`
+	PartnerID  uint                 `json:"partner_id" gorm:"index;not null"`
+	PeriodEnd  time.Time            `json:"period_end" gorm:"type:date"`
+	Amount     float64              `json:"amount"`
+	Commission float64              `json:"commission"`
+	EntryCount int                  `json:"entry_count"`
+	Currency   string               `json:"currency" gorm:"default:VND"`
+	Status     string               `json:"status" gorm:"default:pending"` // pending, paid
+	Reference  string               `json:"reference"`
+	PaidAt     *time.Time           `json:"paid_at"`
+	Entries    []PartnerLedgerEntry `json:"entries,omitempty" gorm:"foreignKey:PayoutID"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// WebhookDelivery is one event queued for a partner's webhook URL. Deliveries
+// are retried with exponential backoff until they succeed or run out of
+// attempts.
+type WebhookDelivery struct {
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	PartnerID      uint             `json:"partner_id" gorm:"index;not null"`
+	EventID        string           `json:"event_id" gorm:"uniqueIndex;not null"`
+	EventType      string           `json:"event_type" gorm:"index;not null"`
+	URL            string           `json:"url" gorm:"not null"`
+	Payload        string           `json:"payload" gorm:"type:text"`
+	Status         string           `json:"status" gorm:"index;default:pending"` // pending, delivered, failed
+	Attempts       int              `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  time.Time        `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int              `json:"last_status_code"`
+	LastError      string           `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time       `json:"delivered_at"`
+	AttemptLog     []WebhookAttempt `json:"attempt_log,omitempty" gorm:"foreignKey:DeliveryID"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+type WebhookAttempt struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	DeliveryID   uint      `json:"delivery_id" gorm:"index;not null"`
+	StatusCode   int       `json:"status_code"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty" gorm:"type:text"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IdempotencyKey stores the first response to a request carrying an
+// Idempotency-Key header when Redis is not available.
+type IdempotencyKey struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Key          string    `json:"key" gorm:"uniqueIndex;not null"`
+	RequestHash  string    `json:"request_hash" gorm:"not null"`
+	Completed    bool      `json:"completed" gorm:"default:false"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type"`
+	ResponseBody string    `json:"response_body" gorm:"type:text"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Session is one sign-in of a user on a device. Its ID is the FamilyID of
+// the sign-in's refresh tokens and the sid claim of its access tokens.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:32"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RefreshToken is one refresh token of a sign-in. Tokens are rotated on every
+// use; all tokens descending from the same sign-in share a FamilyID. Only the
+// SHA-256 hash of the token is stored.
 type RefreshToken struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
 	User      User      `json:"user" gorm:"foreignKey:UserID"`
-	Token     string    `json:"token" gorm:"uniqueIndex;not null"`
-	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
+	TokenHash string    `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	FamilyID  string    `json:"family_id" gorm:"size:32;not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	// RotatedAt is set once the token has been exchanged for a new one.
+	RotatedAt *time.Time `json:"rotated_at"`
+	// RevokedAt is set when the family is signed out or revoked.
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PasswordResetToken is a single-use password reset link. Only the SHA-256
+// hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 type AuditLog struct {
@@ -0,0 +1,45 @@
+package payments
+
+import (
+	"context"
+	"net/http"
+)
+
+// CODProvider is cash on delivery. There is no gateway: the payment stays
+// pending until an admin records the cash as collected, and refunds are
+// handed back in person.
+type CODProvider struct{}
+
+func NewCODProvider() *CODProvider {
+	return &CODProvider{}
+}
+
+func (p *CODProvider) Method() string {
+	return "cod"
+}
+
+func (p *CODProvider) Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error) {
+	return &InitiateResult{}, nil
+}
+
+func (p *CODProvider) VerifyCallback(cb Callback) (*CallbackResult, error) {
+	return nil, ErrNotSupported
+}
+
+// QueryStatus is not supported: only the courier knows whether the cash was
+// collected, so reconciliation leaves COD payments alone.
+func (p *CODProvider) QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *CODProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	return &RefundResult{Status: StatusCompleted, Message: "Cash refunded manually"}, nil
+}
+
+func (p *CODProvider) QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *CODProvider) CallbackResponse(outcome CallbackOutcome) (int, interface{}) {
+	return http.StatusNotFound, map[string]string{"error": "Cash on delivery has no callbacks"}
+}
@@ -0,0 +1,60 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ecommerce-backend/internal/config"
+)
+
+func TestCODProvider(t *testing.T) {
+	provider := NewCODProvider()
+	ctx := context.Background()
+
+	initiated, err := provider.Initiate(ctx, InitiateRequest{TxnRef: "240201_7_1", Amount: 50000})
+	if err != nil || initiated.PaymentURL != "" {
+		t.Errorf("Initiate() = %+v, %v; want no payment URL", initiated, err)
+	}
+
+	if _, err := provider.VerifyCallback(Callback{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("VerifyCallback() error = %v, want ErrNotSupported", err)
+	}
+
+	if _, err := provider.QueryStatus(ctx, QueryRequest{TxnRef: "240201_7_1"}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("QueryStatus() error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestNewRegistryFromConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		expected []string
+	}{
+		{
+			name:     "Gateways without credentials are disabled",
+			cfg:      config.Config{},
+			expected: []string{"cod", "vnpay"},
+		},
+		{
+			name:     "All gateways configured",
+			cfg:      config.Config{MoMoPartnerCode: "MOMO", ZaloPayAppID: "2553"},
+			expected: []string{"cod", "momo", "vnpay", "zalopay"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewRegistryFromConfig(&tt.cfg).Methods()
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Methods() = %v, want %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Methods() = %v, want %v", got, tt.expected)
+				}
+			}
+		})
+	}
+}
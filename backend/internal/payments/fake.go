@@ -0,0 +1,117 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// FakeProvider is an in-memory Provider for tests. It records every refund and
+// query, answers with the configured statuses, and returns Err when set.
+type FakeProvider struct {
+	mu           sync.Mutex
+	MethodName   string
+	PaymentURL   string
+	CallbackData *CallbackResult
+	QueryResult  *StatusResult
+	RefundStatus string
+	// RefundQueryStatus answers QueryRefund; it defaults to StatusPending.
+	RefundQueryStatus string
+	Err               error
+	Initiated         []InitiateRequest
+	Queries           []QueryRequest
+	Refunds           []RefundRequest
+	RefundQueries     []RefundQueryRequest
+}
+
+func NewFakeProvider(method string) *FakeProvider {
+	return &FakeProvider{
+		MethodName:   method,
+		PaymentURL:   "http://fake-gateway.local/pay",
+		RefundStatus: StatusCompleted,
+	}
+}
+
+func (f *FakeProvider) Method() string {
+	return f.MethodName
+}
+
+func (f *FakeProvider) Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Initiated = append(f.Initiated, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return &InitiateResult{PaymentURL: f.PaymentURL + "?ref=" + req.TxnRef}, nil
+}
+
+// VerifyCallback returns CallbackData, or ErrInvalidSignature when unset.
+func (f *FakeProvider) VerifyCallback(cb Callback) (*CallbackResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.CallbackData == nil {
+		return nil, ErrInvalidSignature
+	}
+	result := *f.CallbackData
+	return &result, nil
+}
+
+func (f *FakeProvider) QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Queries = append(f.Queries, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.QueryResult == nil {
+		return &StatusResult{Status: StatusPending}, nil
+	}
+	result := *f.QueryResult
+	return &result, nil
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Refunds = append(f.Refunds, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return &RefundResult{
+		Status:        f.RefundStatus,
+		Message:       "fake refund",
+		TransactionNo: fmt.Sprintf("FAKE%d", len(f.Refunds)),
+		RawResponse:   fmt.Sprintf(`{"status":"%s"}`, f.RefundStatus),
+	}, nil
+}
+
+func (f *FakeProvider) QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.RefundQueries = append(f.RefundQueries, req)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	status := f.RefundQueryStatus
+	if status == "" {
+		status = StatusPending
+	}
+	return &RefundResult{
+		Status:      status,
+		Message:     "fake refund query",
+		RawResponse: fmt.Sprintf(`{"status":"%s"}`, status),
+	}, nil
+}
+
+func (f *FakeProvider) CallbackResponse(outcome CallbackOutcome) (int, interface{}) {
+	return http.StatusOK, map[string]int{"outcome": int(outcome)}
+}
@@ -0,0 +1,80 @@
+package payments
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// sensitiveFields are masked before gateway payloads are stored. Keys are
+// compared case-insensitively.
+var sensitiveFields = map[string]bool{
+	"vnp_securehash":  true,
+	"signature":       true,
+	"mac":             true,
+	"checksum":        true,
+	"accesskey":       true,
+	"secretkey":       true,
+	"key1":            true,
+	"key2":            true,
+	"vnp_cardnumber":  true,
+	"cardnumber":      true,
+	"vnp_bankaccount": true,
+}
+
+// maskValue keeps the first four characters of long values so stored
+// payloads can still be matched against gateway logs.
+func maskValue(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
+
+// MaskParams returns params encoded as a query string with sensitive values masked.
+func MaskParams(params url.Values) string {
+	masked := url.Values{}
+	for key, values := range params {
+		for _, value := range values {
+			if sensitiveFields[strings.ToLower(key)] {
+				value = maskValue(value)
+			}
+			masked.Add(key, value)
+		}
+	}
+	return masked.Encode()
+}
+
+// MaskPayload masks a raw gateway payload, which is either a JSON object or a
+// query string. Anything else is returned unchanged.
+func MaskPayload(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "{") {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &data); err != nil {
+			return raw
+		}
+		maskJSON(data)
+		masked, _ := json.Marshal(data)
+		return string(masked)
+	}
+
+	params, err := url.ParseQuery(trimmed)
+	if err != nil || !strings.Contains(trimmed, "=") {
+		return raw
+	}
+	return MaskParams(params)
+}
+
+func maskJSON(data map[string]interface{}) {
+	for key, value := range data {
+		switch v := value.(type) {
+		case string:
+			if sensitiveFields[strings.ToLower(key)] {
+				data[key] = maskValue(v)
+			}
+		case map[string]interface{}:
+			maskJSON(v)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package payments
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMaskPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{
+			name:     "VNPay query string",
+			raw:      "vnp_Amount=5000000&vnp_SecureHash=0b54100d71b9779f&vnp_TxnRef=240201_7_1",
+			expected: "vnp_Amount=5000000&vnp_SecureHash=0b54%2A%2A%2A%2A&vnp_TxnRef=240201_7_1",
+		},
+		{
+			name:     "MoMo JSON body",
+			raw:      `{"orderId":"240201_7_1","signature":"364a5f936ded3174"}`,
+			expected: `{"orderId":"240201_7_1","signature":"364a****"}`,
+		},
+		{
+			name:     "Short secrets are fully masked",
+			raw:      `{"mac":"abc"}`,
+			expected: `{"mac":"****"}`,
+		},
+		{
+			name:     "Plain text is kept",
+			raw:      "gateway timeout",
+			expected: "gateway timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskPayload(tt.raw); got != tt.expected {
+				t.Errorf("MaskPayload() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMaskParams_CaseInsensitive(t *testing.T) {
+	params := url.Values{"CHECKSUM": {"37d24bf9d1fef1a2"}, "amount": {"50000"}}
+	if got := MaskParams(params); got != "CHECKSUM=37d2%2A%2A%2A%2A&amount=50000" {
+		t.Errorf("MaskParams() = %v", got)
+	}
+}
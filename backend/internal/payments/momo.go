@@ -0,0 +1,437 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MoMo result codes
+const (
+	momoSuccess       = 0
+	momoPending       = 1000
+	momoTxnNotFound   = 1005
+	momoProcessing    = 7000
+	momoRefundPending = 7002
+)
+
+const momoRequestType = "captureWallet"
+
+// MoMoProvider talks to the MoMo v2 "captureWallet" API.
+type MoMoProvider struct {
+	partnerCode string
+	accessKey   string
+	secretKey   string
+	endpoint    string
+	returnURL   string
+	ipnURL      string
+	client      *http.Client
+}
+
+func NewMoMoProvider(partnerCode, accessKey, secretKey, endpoint, returnURL, ipnURL string) *MoMoProvider {
+	return &MoMoProvider{
+		partnerCode: partnerCode,
+		accessKey:   accessKey,
+		secretKey:   secretKey,
+		endpoint:    endpoint,
+		returnURL:   returnURL,
+		ipnURL:      ipnURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (m *MoMoProvider) Method() string {
+	return "momo"
+}
+
+// SignMoMo returns the hex encoded HMAC-SHA256 of rawSignature keyed with secretKey.
+func SignMoMo(secretKey, rawSignature string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(rawSignature))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type momoCreateRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	OrderID     string `json:"orderId"`
+	OrderInfo   string `json:"orderInfo"`
+	RedirectURL string `json:"redirectUrl"`
+	IPNURL      string `json:"ipnUrl"`
+	RequestType string `json:"requestType"`
+	ExtraData   string `json:"extraData"`
+	Lang        string `json:"lang"`
+	Signature   string `json:"signature"`
+}
+
+func (r *momoCreateRequest) rawSignature(accessKey string) string {
+	return fmt.Sprintf("accessKey=%s&amount=%d&extraData=%s&ipnUrl=%s&orderId=%s&orderInfo=%s&partnerCode=%s&redirectUrl=%s&requestId=%s&requestType=%s",
+		accessKey, r.Amount, r.ExtraData, r.IPNURL, r.OrderID, r.OrderInfo, r.PartnerCode, r.RedirectURL, r.RequestID, r.RequestType)
+}
+
+type momoCreateResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	Amount       int64  `json:"amount"`
+	ResponseTime int64  `json:"responseTime"`
+	Message      string `json:"message"`
+	ResultCode   int    `json:"resultCode"`
+	PayURL       string `json:"payUrl"`
+}
+
+func (m *MoMoProvider) Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error) {
+	payload := momoCreateRequest{
+		PartnerCode: m.partnerCode,
+		RequestID:   req.TxnRef,
+		Amount:      req.Amount,
+		OrderID:     req.TxnRef,
+		OrderInfo:   req.OrderInfo,
+		RedirectURL: m.returnURL,
+		IPNURL:      m.ipnURL,
+		RequestType: momoRequestType,
+		Lang:        "vi",
+	}
+	payload.Signature = SignMoMo(m.secretKey, payload.rawSignature(m.accessKey))
+
+	var resp momoCreateResponse
+	raw, err := m.post(ctx, "/v2/gateway/api/create", payload, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ResultCode != momoSuccess {
+		return nil, fmt.Errorf("MoMo create failed with code %d: %s", resp.ResultCode, resp.Message)
+	}
+
+	return &InitiateResult{PaymentURL: resp.PayURL, RawResponse: raw}, nil
+}
+
+// momoCallback is the payload of both the IPN body and the redirect query string.
+type momoCallback struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	Amount       int64  `json:"amount"`
+	OrderInfo    string `json:"orderInfo"`
+	OrderType    string `json:"orderType"`
+	TransID      int64  `json:"transId"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	PayType      string `json:"payType"`
+	ResponseTime int64  `json:"responseTime"`
+	ExtraData    string `json:"extraData"`
+	Signature    string `json:"signature"`
+}
+
+func (c *momoCallback) rawSignature(accessKey string) string {
+	return fmt.Sprintf("accessKey=%s&amount=%d&extraData=%s&message=%s&orderId=%s&orderInfo=%s&orderType=%s&partnerCode=%s&payType=%s&requestId=%s&responseTime=%d&resultCode=%d&transId=%d",
+		accessKey, c.Amount, c.ExtraData, c.Message, c.OrderID, c.OrderInfo, c.OrderType, c.PartnerCode, c.PayType, c.RequestID, c.ResponseTime, c.ResultCode, c.TransID)
+}
+
+// VerifyCallback accepts the JSON IPN body or the redirect query string.
+func (m *MoMoProvider) VerifyCallback(cb Callback) (*CallbackResult, error) {
+	var data momoCallback
+	if len(cb.Body) > 0 {
+		if err := json.Unmarshal(cb.Body, &data); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	} else {
+		p := cb.Params
+		data = momoCallback{
+			PartnerCode: p.Get("partnerCode"),
+			OrderID:     p.Get("orderId"),
+			RequestID:   p.Get("requestId"),
+			OrderInfo:   p.Get("orderInfo"),
+			OrderType:   p.Get("orderType"),
+			Message:     p.Get("message"),
+			PayType:     p.Get("payType"),
+			ExtraData:   p.Get("extraData"),
+			Signature:   p.Get("signature"),
+		}
+		var err error
+		if data.Amount, err = strconv.ParseInt(p.Get("amount"), 10, 64); err != nil {
+			return nil, ErrInvalidSignature
+		}
+		if data.TransID, err = strconv.ParseInt(p.Get("transId"), 10, 64); err != nil {
+			return nil, ErrInvalidSignature
+		}
+		if data.ResultCode, err = strconv.Atoi(p.Get("resultCode")); err != nil {
+			return nil, ErrInvalidSignature
+		}
+		if data.ResponseTime, err = strconv.ParseInt(p.Get("responseTime"), 10, 64); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	expected := SignMoMo(m.secretKey, data.rawSignature(m.accessKey))
+	if data.Signature == "" || !hmac.Equal([]byte(data.Signature), []byte(expected)) {
+		return nil, ErrInvalidSignature
+	}
+	if data.PartnerCode != m.partnerCode {
+		return nil, ErrMerchantMismatch
+	}
+
+	return &CallbackResult{
+		TxnRef:               data.OrderID,
+		Amount:               float64(data.Amount),
+		Currency:             "VND",
+		Success:              data.ResultCode == momoSuccess,
+		ResponseCode:         strconv.Itoa(data.ResultCode),
+		GatewayTransactionNo: strconv.FormatInt(data.TransID, 10),
+	}, nil
+}
+
+// MoMo only needs a 204 to stop retrying; anything else is retried, so
+// rejected callbacks answer 400.
+func (m *MoMoProvider) CallbackResponse(outcome CallbackOutcome) (int, interface{}) {
+	switch outcome {
+	case OutcomeOK, OutcomeAlreadyConfirmed:
+		return http.StatusNoContent, nil
+	default:
+		return http.StatusBadRequest, map[string]string{"message": "rejected"}
+	}
+}
+
+type momoQueryRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	OrderID     string `json:"orderId"`
+	Lang        string `json:"lang"`
+	Signature   string `json:"signature"`
+}
+
+type momoQueryResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	ExtraData    string `json:"extraData"`
+	Amount       int64  `json:"amount"`
+	TransID      int64  `json:"transId"`
+	PayType      string `json:"payType"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	ResponseTime int64  `json:"responseTime"`
+	Signature    string `json:"signature"`
+}
+
+func (r *momoQueryResponse) rawSignature(accessKey string) string {
+	return fmt.Sprintf("accessKey=%s&amount=%d&extraData=%s&message=%s&orderId=%s&partnerCode=%s&payType=%s&requestId=%s&responseTime=%d&resultCode=%d&transId=%d",
+		accessKey, r.Amount, r.ExtraData, r.Message, r.OrderID, r.PartnerCode, r.PayType, r.RequestID, r.ResponseTime, r.ResultCode, r.TransID)
+}
+
+func (m *MoMoProvider) QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error) {
+	payload := momoQueryRequest{
+		PartnerCode: m.partnerCode,
+		RequestID:   req.RequestID,
+		OrderID:     req.TxnRef,
+		Lang:        "vi",
+	}
+	payload.Signature = SignMoMo(m.secretKey, fmt.Sprintf("accessKey=%s&orderId=%s&partnerCode=%s&requestId=%s",
+		m.accessKey, payload.OrderID, payload.PartnerCode, payload.RequestID))
+
+	var resp momoQueryResponse
+	raw, err := m.post(ctx, "/v2/gateway/api/query", payload, &resp)
+	if err != nil {
+		return nil, err
+	}
+	// The status settles payments, so it is only trusted when MoMo signed it.
+	expected := SignMoMo(m.secretKey, resp.rawSignature(m.accessKey))
+	if resp.Signature == "" || !hmac.Equal([]byte(resp.Signature), []byte(expected)) {
+		return nil, ErrInvalidSignature
+	}
+
+	result := &StatusResult{
+		Amount:       float64(resp.Amount),
+		ResponseCode: strconv.Itoa(resp.ResultCode),
+		Message:      resp.Message,
+		RawResponse:  raw,
+	}
+	if resp.TransID != 0 {
+		result.GatewayTransactionNo = strconv.FormatInt(resp.TransID, 10)
+	}
+
+	switch resp.ResultCode {
+	case momoSuccess:
+		result.Status = StatusCompleted
+	case momoPending, momoProcessing:
+		result.Status = StatusPending
+	case momoTxnNotFound:
+		result.Status = StatusNotFound
+	default:
+		result.Status = StatusFailed
+	}
+
+	return result, nil
+}
+
+type momoRefundRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	OrderID     string `json:"orderId"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	TransID     int64  `json:"transId"`
+	Lang        string `json:"lang"`
+	Description string `json:"description"`
+	Signature   string `json:"signature"`
+}
+
+type momoRefundResponse struct {
+	PartnerCode string `json:"partnerCode"`
+	OrderID     string `json:"orderId"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	TransID     int64  `json:"transId"`
+	ResultCode  int    `json:"resultCode"`
+	Message     string `json:"message"`
+}
+
+func (m *MoMoProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	transID, err := strconv.ParseInt(req.GatewayTransactionNo, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MoMo transaction id %q", req.GatewayTransactionNo)
+	}
+
+	payload := momoRefundRequest{
+		PartnerCode: m.partnerCode,
+		OrderID:     req.RequestID,
+		RequestID:   req.RequestID,
+		Amount:      req.Amount,
+		TransID:     transID,
+		Lang:        "vi",
+		Description: req.Description,
+	}
+	payload.Signature = SignMoMo(m.secretKey, fmt.Sprintf("accessKey=%s&amount=%d&description=%s&orderId=%s&partnerCode=%s&requestId=%s&transId=%d",
+		m.accessKey, payload.Amount, payload.Description, payload.OrderID, payload.PartnerCode, payload.RequestID, payload.TransID))
+
+	var resp momoRefundResponse
+	raw, err := m.post(ctx, "/v2/gateway/api/refund", payload, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{
+		Status:       StatusFailed,
+		ResponseCode: strconv.Itoa(resp.ResultCode),
+		Message:      resp.Message,
+		RawResponse:  raw,
+	}
+	if resp.TransID != 0 {
+		result.TransactionNo = strconv.FormatInt(resp.TransID, 10)
+	}
+
+	switch resp.ResultCode {
+	case momoSuccess:
+		result.Status = StatusCompleted
+	case momoPending, momoRefundPending:
+		result.Status = StatusPending
+	}
+
+	return result, nil
+}
+
+type momoRefundTrans struct {
+	OrderID    string `json:"orderId"`
+	Amount     int64  `json:"amount"`
+	ResultCode int    `json:"resultCode"`
+	TransID    int64  `json:"transId"`
+}
+
+type momoRefundQueryResponse struct {
+	PartnerCode string            `json:"partnerCode"`
+	OrderID     string            `json:"orderId"`
+	RequestID   string            `json:"requestId"`
+	ResultCode  int               `json:"resultCode"`
+	Message     string            `json:"message"`
+	RefundTrans []momoRefundTrans `json:"refundTrans"`
+}
+
+// QueryRefund looks the refund up among the refunds MoMo lists for the
+// original payment. Refund uses the refund's RequestID as its orderId.
+func (m *MoMoProvider) QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error) {
+	payload := momoQueryRequest{
+		PartnerCode: m.partnerCode,
+		RequestID:   fmt.Sprintf("Q%s", req.RequestID),
+		OrderID:     req.TxnRef,
+		Lang:        "vi",
+	}
+	payload.Signature = SignMoMo(m.secretKey, fmt.Sprintf("accessKey=%s&orderId=%s&partnerCode=%s&requestId=%s",
+		m.accessKey, payload.OrderID, payload.PartnerCode, payload.RequestID))
+
+	var resp momoRefundQueryResponse
+	raw, err := m.post(ctx, "/v2/gateway/api/refund/query", payload, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{
+		Status:       StatusNotFound,
+		ResponseCode: strconv.Itoa(resp.ResultCode),
+		Message:      resp.Message,
+		RawResponse:  raw,
+	}
+	if resp.ResultCode != momoSuccess {
+		return result, nil
+	}
+
+	for _, trans := range resp.RefundTrans {
+		if trans.OrderID != req.RequestID {
+			continue
+		}
+		result.ResponseCode = strconv.Itoa(trans.ResultCode)
+		if trans.TransID != 0 {
+			result.TransactionNo = strconv.FormatInt(trans.TransID, 10)
+		}
+		switch trans.ResultCode {
+		case momoSuccess:
+			result.Status = StatusCompleted
+		case momoPending, momoProcessing, momoRefundPending:
+			result.Status = StatusPending
+		default:
+			result.Status = StatusFailed
+		}
+	}
+
+	return result, nil
+}
+
+func (m *MoMoProvider) post(ctx context.Context, path string, payload, dest interface{}) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal MoMo request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create MoMo request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to contact MoMo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read MoMo response: %w", err)
+	}
+
+	if err := json.Unmarshal(respBody, dest); err != nil {
+		return "", fmt.Errorf("failed to parse MoMo response (status %d): %w", resp.StatusCode, err)
+	}
+
+	return string(respBody), nil
+}
@@ -0,0 +1,180 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testMoMoPartnerCode = "MOMO"
+	testMoMoAccessKey   = "F8BBA842ECF85"
+	testMoMoSecretKey   = "K951B6PE1waDMi640xX08PD3vg6EkVlz"
+)
+
+func TestSignMoMo(t *testing.T) {
+	request := momoCreateRequest{
+		PartnerCode: testMoMoPartnerCode,
+		RequestID:   "240201_7_1706790000000",
+		Amount:      50000,
+		OrderID:     "240201_7_1706790000000",
+		OrderInfo:   "Payment for order ORD-1",
+		RedirectURL: "https://shop.example/return",
+		IPNURL:      "https://shop.example/ipn",
+		RequestType: momoRequestType,
+	}
+
+	want := "364a5f936ded317473fd82ba4010a6f2ae4573e4aecd27671fa3584b9cb088df"
+	if got := SignMoMo(testMoMoSecretKey, request.rawSignature(testMoMoAccessKey)); got != want {
+		t.Errorf("SignMoMo() = %v, want %v", got, want)
+	}
+}
+
+// newFakeMoMoServer implements the create, query and refund endpoints of the
+// MoMo v2 API against an in-memory order.
+func newFakeMoMoServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v2/gateway/api/create", func(w http.ResponseWriter, r *http.Request) {
+		var req momoCreateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Signature != SignMoMo(testMoMoSecretKey, req.rawSignature(testMoMoAccessKey)) {
+			json.NewEncoder(w).Encode(momoCreateResponse{ResultCode: 11007, Message: "invalid signature"})
+			return
+		}
+		json.NewEncoder(w).Encode(momoCreateResponse{
+			PartnerCode: req.PartnerCode, OrderID: req.OrderID, RequestID: req.RequestID, Amount: req.Amount,
+			ResultCode: momoSuccess, Message: "Successful.", PayURL: "https://test-payment.momo.vn/pay?o=" + req.OrderID,
+		})
+	})
+
+	mux.HandleFunc("/v2/gateway/api/query", func(w http.ResponseWriter, r *http.Request) {
+		var req momoQueryRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		raw := fmt.Sprintf("accessKey=%s&orderId=%s&partnerCode=%s&requestId=%s", testMoMoAccessKey, req.OrderID, req.PartnerCode, req.RequestID)
+		if req.Signature != SignMoMo(testMoMoSecretKey, raw) {
+			t.Errorf("fake MoMo: query signature mismatch")
+		}
+		resp := momoQueryResponse{
+			PartnerCode: req.PartnerCode, OrderID: req.OrderID, RequestID: req.RequestID,
+			Amount: 50000, TransID: 2588659987, PayType: "qr", ResultCode: momoSuccess, Message: "Successful.", ResponseTime: 1706790000000,
+		}
+		resp.Signature = SignMoMo(testMoMoSecretKey, resp.rawSignature(testMoMoAccessKey))
+		if req.OrderID == "240201_7_tampered" {
+			resp.Amount = 5000000
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	mux.HandleFunc("/v2/gateway/api/refund", func(w http.ResponseWriter, r *http.Request) {
+		var req momoRefundRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		raw := fmt.Sprintf("accessKey=%s&amount=%d&description=%s&orderId=%s&partnerCode=%s&requestId=%s&transId=%d",
+			testMoMoAccessKey, req.Amount, req.Description, req.OrderID, req.PartnerCode, req.RequestID, req.TransID)
+		if req.Signature != SignMoMo(testMoMoSecretKey, raw) {
+			t.Errorf("fake MoMo: refund signature mismatch")
+		}
+		json.NewEncoder(w).Encode(momoRefundResponse{
+			PartnerCode: req.PartnerCode, OrderID: req.OrderID, RequestID: req.RequestID,
+			Amount: req.Amount, TransID: 2588660001, ResultCode: momoRefundPending, Message: "Refund is being processed.",
+		})
+	})
+
+	mux.HandleFunc("/v2/gateway/api/refund/query", func(w http.ResponseWriter, r *http.Request) {
+		var req momoQueryRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		raw := fmt.Sprintf("accessKey=%s&orderId=%s&partnerCode=%s&requestId=%s", testMoMoAccessKey, req.OrderID, req.PartnerCode, req.RequestID)
+		if req.Signature != SignMoMo(testMoMoSecretKey, raw) {
+			t.Errorf("fake MoMo: refund query signature mismatch")
+		}
+		json.NewEncoder(w).Encode(momoRefundQueryResponse{
+			PartnerCode: req.PartnerCode, OrderID: req.OrderID, RequestID: req.RequestID, ResultCode: momoSuccess, Message: "Successful.",
+			RefundTrans: []momoRefundTrans{
+				{OrderID: "R0", Amount: 10000, ResultCode: 1001, TransID: 2588660000},
+				{OrderID: "R1", Amount: 20000, ResultCode: momoSuccess, TransID: 2588660001},
+			},
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestMoMoProvider_EndToEnd(t *testing.T) {
+	server := newFakeMoMoServer(t)
+	defer server.Close()
+
+	provider := NewMoMoProvider(testMoMoPartnerCode, testMoMoAccessKey, testMoMoSecretKey, server.URL, "http://shop.local/return", "http://shop.local/ipn")
+	ctx := context.Background()
+
+	initiated, err := provider.Initiate(ctx, InitiateRequest{TxnRef: "240201_7_1", Amount: 50000, OrderInfo: "Order 7"})
+	if err != nil {
+		t.Fatalf("Initiate() error = %v", err)
+	}
+	if initiated.PaymentURL != "https://test-payment.momo.vn/pay?o=240201_7_1" {
+		t.Errorf("Initiate() PaymentURL = %v", initiated.PaymentURL)
+	}
+
+	// Simulate the IPN MoMo posts after a successful payment.
+	ipn := momoCallback{
+		PartnerCode: testMoMoPartnerCode, OrderID: "240201_7_1", RequestID: "240201_7_1", Amount: 50000,
+		OrderInfo: "Order 7", OrderType: "momo_wallet", TransID: 2588659987, ResultCode: momoSuccess,
+		Message: "Successful.", PayType: "qr", ResponseTime: 1706790000000,
+	}
+	ipn.Signature = SignMoMo(testMoMoSecretKey, ipn.rawSignature(testMoMoAccessKey))
+	body, _ := json.Marshal(ipn)
+
+	result, err := provider.VerifyCallback(Callback{Body: body})
+	if err != nil {
+		t.Fatalf("VerifyCallback() error = %v", err)
+	}
+	if !result.Success || result.Amount != 50000 || result.TxnRef != "240201_7_1" || result.GatewayTransactionNo != "2588659987" {
+		t.Errorf("VerifyCallback() = %+v", result)
+	}
+
+	ipn.Amount = 1000
+	body, _ = json.Marshal(ipn)
+	if _, err := provider.VerifyCallback(Callback{Body: body}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyCallback() with tampered amount error = %v, want ErrInvalidSignature", err)
+	}
+
+	status, err := provider.QueryStatus(ctx, QueryRequest{RequestID: "Q1", TxnRef: "240201_7_1"})
+	if err != nil {
+		t.Fatalf("QueryStatus() error = %v", err)
+	}
+	if status.Status != StatusCompleted || status.GatewayTransactionNo != "2588659987" {
+		t.Errorf("QueryStatus() = %+v", status)
+	}
+	if _, err := provider.QueryStatus(ctx, QueryRequest{RequestID: "Q2", TxnRef: "240201_7_tampered"}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("QueryStatus() with tampered response error = %v, want ErrInvalidSignature", err)
+	}
+
+	refund, err := provider.Refund(ctx, RefundRequest{RequestID: "R1", GatewayTransactionNo: "2588659987", Amount: 20000, Description: "Refund"})
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	if refund.Status != StatusPending {
+		t.Errorf("Refund() status = %v, want %v", refund.Status, StatusPending)
+	}
+
+	tests := []struct {
+		requestID string
+		expected  string
+	}{
+		{"R1", StatusCompleted},
+		{"R0", StatusFailed},
+		{"R9", StatusNotFound},
+	}
+	for _, tt := range tests {
+		refund, err := provider.QueryRefund(ctx, RefundQueryRequest{RequestID: tt.requestID, TxnRef: "240201_7_1"})
+		if err != nil {
+			t.Fatalf("QueryRefund(%s) error = %v", tt.requestID, err)
+		}
+		if refund.Status != tt.expected {
+			t.Errorf("QueryRefund(%s) status = %v, want %v", tt.requestID, refund.Status, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"ecommerce-backend/internal/config"
+)
+
+var (
+	// ErrInvalidSignature is returned when a callback or gateway response is not signed by the gateway.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrMerchantMismatch is returned when a correctly signed callback was
+	// issued for another merchant account than the one configured.
+	ErrMerchantMismatch = errors.New("callback is for another merchant")
+	// ErrNotSupported is returned by providers that cannot perform an operation.
+	ErrNotSupported = errors.New("operation not supported by payment provider")
+)
+
+// Statuses reported by providers for payments and refunds.
+const (
+	StatusCompleted = "completed"
+	StatusPending   = "pending"
+	StatusFailed    = "failed"
+	StatusNotFound  = "not_found"
+	// StatusRefunded is reported by queries for payments the gateway has
+	// already refunded in full or in part.
+	StatusRefunded = "refunded"
+)
+
+// CallbackOutcome is the result of checking a callback against our records.
+// Providers translate it into the acknowledgement format their gateway expects.
+type CallbackOutcome int
+
+const (
+	OutcomeOK CallbackOutcome = iota
+	OutcomeNotFound
+	OutcomeAlreadyConfirmed
+	OutcomeInvalidAmount
+	OutcomeInvalidSignature
+	OutcomeMerchantMismatch
+)
+
+func (o CallbackOutcome) String() string {
+	switch o {
+	case OutcomeOK:
+		return "ok"
+	case OutcomeNotFound:
+		return "not_found"
+	case OutcomeAlreadyConfirmed:
+		return "already_confirmed"
+	case OutcomeInvalidAmount:
+		return "invalid_amount"
+	case OutcomeInvalidSignature:
+		return "invalid_signature"
+	case OutcomeMerchantMismatch:
+		return "merchant_mismatch"
+	}
+	return "unknown"
+}
+
+// InitiateRequest starts a payment for TxnRef. Amounts are whole VND.
+type InitiateRequest struct {
+	TxnRef    string
+	Amount    int64
+	OrderInfo string
+	IPAddr    string
+	// CreatedAt is when the payment was recorded. Gateways that key queries
+	// and refunds on the transaction date are sent this rather than the
+	// time of the request, so the stored timestamp finds the transaction
+	// again. The zero value means now.
+	CreatedAt time.Time
+}
+
+type InitiateResult struct {
+	// PaymentURL is where the customer completes the payment. It is empty for
+	// offline methods such as cash on delivery.
+	PaymentURL  string
+	RawResponse string
+}
+
+// Callback carries what a gateway sent to us, either on the customer's return
+// redirect or on a server-to-server notification.
+type Callback struct {
+	Params url.Values
+	Body   []byte
+}
+
+type CallbackResult struct {
+	TxnRef               string
+	Amount               float64
+	Currency             string
+	Success              bool
+	ResponseCode         string
+	GatewayTransactionNo string
+}
+
+type QueryRequest struct {
+	RequestID            string
+	TxnRef               string
+	GatewayTransactionNo string
+	TransactionDate      time.Time
+	OrderInfo            string
+	IPAddr               string
+}
+
+type StatusResult struct {
+	Status               string
+	Amount               float64
+	ResponseCode         string
+	Message              string
+	GatewayTransactionNo string
+	RawResponse          string
+}
+
+// RefundRequest describes a refund against a previously settled gateway transaction.
+type RefundRequest struct {
+	RequestID            string
+	TxnRef               string
+	GatewayTransactionNo string
+	TransactionDate      time.Time
+	Amount               int64
+	Full                 bool
+	Description          string
+	CreatedBy            string
+	IPAddr               string
+	// RequestedAt is when the refund was created. Gateways that put the date
+	// in their refund ids need it again to query the refund.
+	RequestedAt time.Time
+}
+
+// RefundResult is the gateway's answer to a refund request. Status is
+// StatusPending when the gateway accepted the refund but has not settled it.
+type RefundResult struct {
+	Status        string
+	ResponseCode  string
+	Message       string
+	TransactionNo string
+	RawResponse   string
+}
+
+// RefundQueryRequest asks for the state of a refund the gateway accepted but
+// had not settled. The fields repeat those of the original RefundRequest.
+type RefundQueryRequest struct {
+	RequestID   string
+	TxnRef      string
+	RequestedAt time.Time
+}
+
+// Provider is a payment method such as a gateway or cash on delivery.
+type Provider interface {
+	// Method is the value stored in Order.PaymentMethod and Payment.PaymentMethod.
+	Method() string
+	Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error)
+	// VerifyCallback authenticates a callback and extracts its result. It
+	// returns ErrInvalidSignature when the callback cannot be trusted and
+	// ErrMerchantMismatch when it was issued for another merchant.
+	VerifyCallback(cb Callback) (*CallbackResult, error)
+	QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error)
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+	// QueryRefund reports whether a refund that Refund left pending has
+	// completed or failed since.
+	QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error)
+	// CallbackResponse builds the HTTP status and body the gateway expects in
+	// reply to a server-to-server callback.
+	CallbackResponse(outcome CallbackOutcome) (int, interface{})
+}
+
+// Registry holds the enabled providers keyed by payment method.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// NewRegistryFromConfig registers VNPay and cash on delivery, plus MoMo and
+// ZaloPay when their credentials are configured.
+func NewRegistryFromConfig(cfg *config.Config) *Registry {
+	r := NewRegistry(
+		NewVNPayProvider(cfg.VNPayTMNCode, cfg.VNPayHashKey, cfg.VNPayURL, cfg.VNPayAPIURL, cfg.VNPayReturnURL),
+		NewCODProvider(),
+	)
+
+	if cfg.MoMoPartnerCode != "" {
+		r.Register(NewMoMoProvider(cfg.MoMoPartnerCode, cfg.MoMoAccessKey, cfg.MoMoSecretKey, cfg.MoMoEndpoint, cfg.MoMoReturnURL, cfg.MoMoIPNURL))
+	}
+
+	if cfg.ZaloPayAppID != "" {
+		r.Register(NewZaloPayProvider(cfg.ZaloPayAppID, cfg.ZaloPayKey1, cfg.ZaloPayKey2, cfg.ZaloPayEndpoint, cfg.ZaloPayReturnURL, cfg.ZaloPayCallbackURL))
+	}
+
+	return r
+}
+
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Method()] = p
+}
+
+func (r *Registry) Get(method string) (Provider, bool) {
+	p, ok := r.providers[method]
+	return p, ok
+}
+
+// Methods returns the registered payment methods in sorted order.
+func (r *Registry) Methods() []string {
+	methods := make([]string, 0, len(r.providers))
+	for method := range r.providers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// gatewayTimezone is the GMT+7 clock the Vietnamese gateways use for dates.
+var gatewayTimezone = time.FixedZone("GMT+7", 7*60*60)
+
+// NewTxnRef builds a merchant transaction reference for an order. The
+// yymmdd prefix is required by ZaloPay and accepted by every other gateway.
+func NewTxnRef(orderID uint) string {
+	now := time.Now()
+	return fmt.Sprintf("%s_%d_%d", now.In(gatewayTimezone).Format("060102"), orderID, now.UnixNano()/int64(time.Millisecond))
+}
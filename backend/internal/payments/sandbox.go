@@ -0,0 +1,49 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SandboxProvider is the fake gateway behind partner sandbox orders. Nothing
+// leaves the server: payments are settled by the sandbox itself or by the
+// partner's payment webhook, and refunds complete at once. It is not
+// registered for customers, so reconciliation never sees its payments.
+type SandboxProvider struct{}
+
+func NewSandboxProvider() *SandboxProvider {
+	return &SandboxProvider{}
+}
+
+func (p *SandboxProvider) Method() string {
+	return "sandbox"
+}
+
+func (p *SandboxProvider) Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error) {
+	return &InitiateResult{RawResponse: fmt.Sprintf(`{"txn_ref":"%s","amount":%d}`, req.TxnRef, req.Amount)}, nil
+}
+
+func (p *SandboxProvider) VerifyCallback(cb Callback) (*CallbackResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *SandboxProvider) QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *SandboxProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	return &RefundResult{
+		Status:        StatusCompleted,
+		Message:       "Sandbox refund",
+		TransactionNo: "SANDBOX_" + req.RequestID,
+	}, nil
+}
+
+func (p *SandboxProvider) QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *SandboxProvider) CallbackResponse(outcome CallbackOutcome) (int, interface{}) {
+	return http.StatusNotFound, map[string]string{"error": "Sandbox payments have no callbacks"}
+}
@@ -0,0 +1,30 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSandboxProvider(t *testing.T) {
+	provider := NewSandboxProvider()
+	ctx := context.Background()
+
+	initiated, err := provider.Initiate(ctx, InitiateRequest{TxnRef: "240201_7_1", Amount: 50000})
+	if err != nil || initiated.PaymentURL != "" {
+		t.Errorf("Initiate() = %+v, %v; want no payment URL", initiated, err)
+	}
+
+	if _, err := provider.VerifyCallback(Callback{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("VerifyCallback() error = %v, want ErrNotSupported", err)
+	}
+
+	if _, err := provider.QueryStatus(ctx, QueryRequest{TxnRef: "240201_7_1"}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("QueryStatus() error = %v, want ErrNotSupported", err)
+	}
+
+	refund, err := provider.Refund(ctx, RefundRequest{RequestID: "RF1", TxnRef: "240201_7_1", Amount: 50000})
+	if err != nil || refund.Status != StatusCompleted {
+		t.Errorf("Refund() = %+v, %v; want completed", refund, err)
+	}
+}
@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoSettlement is returned when a gateway's settlement file for a day has
+// not been delivered.
+var ErrNoSettlement = errors.New("no settlement file for day")
+
+// SettlementRecord is one transaction in a gateway's settlement file: money
+// the gateway collected for us and will pay out.
+type SettlementRecord struct {
+	TxnRef               string
+	GatewayTransactionNo string
+	Amount               float64
+	// Status is StatusCompleted, or StatusRefunded when the gateway refunded
+	// the transaction in full or in part before settling.
+	Status string
+}
+
+// SettlementSource provides the settlement files the gateways send for each
+// business day.
+type SettlementSource interface {
+	Settlements(method string, day time.Time) ([]SettlementRecord, error)
+}
+
+// DirSettlementSource reads settlement files synced from the gateways'
+// merchant portals into Dir as <method>/<YYYY-MM-DD>.csv.
+type DirSettlementSource struct {
+	Dir string
+}
+
+func NewDirSettlementSource(dir string) *DirSettlementSource {
+	return &DirSettlementSource{Dir: dir}
+}
+
+func (s *DirSettlementSource) Settlements(method string, day time.Time) ([]SettlementRecord, error) {
+	file, err := os.Open(filepath.Join(s.Dir, method, day.Format("2006-01-02")+".csv"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSettlement
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseSettlementCSV(file)
+}
+
+// ParseSettlementCSV reads a settlement file. The header row names the
+// columns: txn_ref and amount are required, transaction_no and status are
+// optional. Amounts are whole VND; a missing status means completed.
+func ParseSettlementCSV(r io.Reader) ([]SettlementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settlement header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"txn_ref", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("settlement file has no %s column", required)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []SettlementRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("settlement line %d: %w", line, err)
+		}
+
+		amount, err := strconv.ParseFloat(field(row, "amount"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("settlement line %d: invalid amount %q", line, field(row, "amount"))
+		}
+
+		record := SettlementRecord{
+			TxnRef:               field(row, "txn_ref"),
+			GatewayTransactionNo: field(row, "transaction_no"),
+			Amount:               amount,
+			Status:               StatusCompleted,
+		}
+		switch strings.ToLower(field(row, "status")) {
+		case "", StatusCompleted:
+		case StatusRefunded:
+			record.Status = StatusRefunded
+		default:
+			return nil, fmt.Errorf("settlement line %d: unknown status %q", line, field(row, "status"))
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
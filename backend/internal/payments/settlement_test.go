@@ -0,0 +1,59 @@
+package payments
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSettlementCSV(t *testing.T) {
+	records, err := ParseSettlementCSV(strings.NewReader("Amount, TXN_REF, transaction_no, status\n150000, 240201_7_1, 14226112,\n50000, 240201_8_1, 14226113, refunded\n"))
+	if err != nil {
+		t.Fatalf("ParseSettlementCSV() error = %v", err)
+	}
+	want := []SettlementRecord{
+		{TxnRef: "240201_7_1", GatewayTransactionNo: "14226112", Amount: 150000, Status: StatusCompleted},
+		{TxnRef: "240201_8_1", GatewayTransactionNo: "14226113", Amount: 50000, Status: StatusRefunded},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("ParseSettlementCSV() = %+v, want %+v", records, want)
+	}
+	for i := range want {
+		if records[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, records[i], want[i])
+		}
+	}
+
+	for name, input := range map[string]string{
+		"missing column": "txn_ref\n240201_7_1\n",
+		"bad amount":     "txn_ref,amount\n240201_7_1,abc\n",
+		"unknown status": "txn_ref,amount,status\n240201_7_1,1000,chargeback\n",
+		"empty":          "",
+	} {
+		if _, err := ParseSettlementCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseSettlementCSV(%s) error = nil, want error", name)
+		}
+	}
+}
+
+func TestDirSettlementSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "vnpay"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vnpay", "2024-02-01.csv"), []byte("txn_ref,amount\n240201_7_1,150000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := NewDirSettlementSource(dir)
+	records, err := source.Settlements("vnpay", time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local))
+	if err != nil || len(records) != 1 || records[0].TxnRef != "240201_7_1" {
+		t.Errorf("Settlements() = %+v, %v", records, err)
+	}
+	if _, err := source.Settlements("vnpay", time.Date(2024, 2, 2, 0, 0, 0, 0, time.Local)); !errors.Is(err, ErrNoSettlement) {
+		t.Errorf("Settlements() for missing day error = %v, want ErrNoSettlement", err)
+	}
+}
@@ -0,0 +1,395 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const vnpayDateLayout = "20060102150405"
+
+// VNPay response codes used by the payment, query and refund APIs
+const (
+	vnpaySuccess          = "00"
+	vnpayTxnNotFound      = "91"
+	vnpayTxnStatusPending = "01"
+	// Refund in progress at VNPay / refund sent to the bank
+	vnpayTxnStatusRefunding = "05"
+	vnpayTxnStatusRefunded  = "06"
+)
+
+type VNPayProvider struct {
+	tmnCode   string
+	hashKey   string
+	payURL    string
+	apiURL    string
+	returnURL string
+	client    *http.Client
+}
+
+func NewVNPayProvider(tmnCode, hashKey, payURL, apiURL, returnURL string) *VNPayProvider {
+	return &VNPayProvider{
+		tmnCode:   tmnCode,
+		hashKey:   hashKey,
+		payURL:    payURL,
+		apiURL:    apiURL,
+		returnURL: returnURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (v *VNPayProvider) Method() string {
+	return "vnpay"
+}
+
+// SignVNPay returns the hex encoded HMAC-SHA512 of data keyed with hashKey.
+func SignVNPay(hashKey, data string) string {
+	mac := hmac.New(sha512.New, []byte(hashKey))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VNPaySignParams signs the vnp_ parameters the way VNPay 2.1.0 does: sorted
+// by key, URL encoded and joined with '&', ignoring the hash fields.
+func VNPaySignParams(hashKey string, params url.Values) string {
+	signed := url.Values{}
+	for key, values := range params {
+		if !strings.HasPrefix(key, "vnp_") || key == "vnp_SecureHash" || key == "vnp_SecureHashType" || len(values) == 0 {
+			continue
+		}
+		signed.Set(key, values[0])
+	}
+	return SignVNPay(hashKey, signed.Encode())
+}
+
+// FormatVNPayDate formats t in the GMT+7 layout used by the VNPay APIs.
+func FormatVNPayDate(t time.Time) string {
+	return t.In(gatewayTimezone).Format(vnpayDateLayout)
+}
+
+func (v *VNPayProvider) Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error) {
+	params := url.Values{}
+	params.Set("vnp_Version", "2.1.0")
+	params.Set("vnp_Command", "pay")
+	params.Set("vnp_TmnCode", v.tmnCode)
+	params.Set("vnp_Amount", strconv.FormatInt(req.Amount*100, 10))
+	params.Set("vnp_CurrCode", "VND")
+	params.Set("vnp_TxnRef", req.TxnRef)
+	params.Set("vnp_OrderInfo", req.OrderInfo)
+	params.Set("vnp_OrderType", "other")
+	params.Set("vnp_Locale", "vn")
+	params.Set("vnp_ReturnUrl", v.returnURL)
+	createdAt := req.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	params.Set("vnp_CreateDate", FormatVNPayDate(createdAt))
+	params.Set("vnp_IpAddr", req.IPAddr)
+
+	query := params.Encode()
+	query += "&vnp_SecureHash=" + SignVNPay(v.hashKey, query)
+
+	return &InitiateResult{PaymentURL: v.payURL + "?" + query}, nil
+}
+
+func (v *VNPayProvider) VerifyCallback(cb Callback) (*CallbackResult, error) {
+	params := cb.Params
+	signature := params.Get("vnp_SecureHash")
+	if signature == "" || !hmac.Equal([]byte(strings.ToLower(signature)), []byte(VNPaySignParams(v.hashKey, params))) {
+		return nil, ErrInvalidSignature
+	}
+
+	if params.Get("vnp_TmnCode") != v.tmnCode {
+		return nil, ErrMerchantMismatch
+	}
+
+	result := &CallbackResult{
+		TxnRef:               params.Get("vnp_TxnRef"),
+		Amount:               -1,
+		Currency:             params.Get("vnp_CurrCode"),
+		ResponseCode:         params.Get("vnp_ResponseCode"),
+		GatewayTransactionNo: params.Get("vnp_TransactionNo"),
+	}
+	if result.Currency == "" {
+		result.Currency = "VND"
+	}
+
+	if amount, err := strconv.ParseInt(params.Get("vnp_Amount"), 10, 64); err == nil && amount%100 == 0 {
+		result.Amount = float64(amount / 100)
+	}
+
+	transactionStatus := params.Get("vnp_TransactionStatus")
+	result.Success = result.ResponseCode == vnpaySuccess && (transactionStatus == "" || transactionStatus == vnpaySuccess)
+
+	return result, nil
+}
+
+// VNPay IPN response codes
+var vnpayCallbackCodes = map[CallbackOutcome][2]string{
+	OutcomeOK:               {"00", "Confirm Success"},
+	OutcomeNotFound:         {"01", "Order not found"},
+	OutcomeAlreadyConfirmed: {"02", "Order already confirmed"},
+	OutcomeInvalidAmount:    {"04", "Invalid amount"},
+	OutcomeInvalidSignature: {"97", "Invalid signature"},
+	OutcomeMerchantMismatch: {"99", "Invalid merchant"},
+}
+
+// VNPay expects HTTP 200 with the outcome carried in RspCode.
+func (v *VNPayProvider) CallbackResponse(outcome CallbackOutcome) (int, interface{}) {
+	code := vnpayCallbackCodes[outcome]
+	return http.StatusOK, map[string]string{"RspCode": code[0], "Message": code[1]}
+}
+
+type vnpayQueryPayload struct {
+	RequestID       string `json:"vnp_RequestId"`
+	Version         string `json:"vnp_Version"`
+	Command         string `json:"vnp_Command"`
+	TmnCode         string `json:"vnp_TmnCode"`
+	TxnRef          string `json:"vnp_TxnRef"`
+	OrderInfo       string `json:"vnp_OrderInfo"`
+	TransactionNo   string `json:"vnp_TransactionNo,omitempty"`
+	TransactionDate string `json:"vnp_TransactionDate"`
+	CreateDate      string `json:"vnp_CreateDate"`
+	IPAddr          string `json:"vnp_IpAddr"`
+	SecureHash      string `json:"vnp_SecureHash"`
+}
+
+func (p *vnpayQueryPayload) signData() string {
+	return strings.Join([]string{
+		p.RequestID, p.Version, p.Command, p.TmnCode, p.TxnRef,
+		p.TransactionDate, p.CreateDate, p.IPAddr, p.OrderInfo,
+	}, "|")
+}
+
+type vnpayQueryResponse struct {
+	ResponseID        string `json:"vnp_ResponseId"`
+	Command           string `json:"vnp_Command"`
+	ResponseCode      string `json:"vnp_ResponseCode"`
+	Message           string `json:"vnp_Message"`
+	TmnCode           string `json:"vnp_TmnCode"`
+	TxnRef            string `json:"vnp_TxnRef"`
+	Amount            string `json:"vnp_Amount"`
+	BankCode          string `json:"vnp_BankCode"`
+	PayDate           string `json:"vnp_PayDate"`
+	TransactionNo     string `json:"vnp_TransactionNo"`
+	TransactionType   string `json:"vnp_TransactionType"`
+	TransactionStatus string `json:"vnp_TransactionStatus"`
+	OrderInfo         string `json:"vnp_OrderInfo"`
+	PromotionCode     string `json:"vnp_PromotionCode"`
+	PromotionAmount   string `json:"vnp_PromotionAmount"`
+	SecureHash        string `json:"vnp_SecureHash"`
+}
+
+func (r *vnpayQueryResponse) signData() string {
+	return strings.Join([]string{
+		r.ResponseID, r.Command, r.ResponseCode, r.Message, r.TmnCode,
+		r.TxnRef, r.Amount, r.BankCode, r.PayDate, r.TransactionNo,
+		r.TransactionType, r.TransactionStatus, r.OrderInfo,
+		r.PromotionCode, r.PromotionAmount,
+	}, "|")
+}
+
+// QueryStatus calls the querydr API for a payment's current state.
+func (v *VNPayProvider) QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error) {
+	payload := vnpayQueryPayload{
+		RequestID:       req.RequestID,
+		Version:         "2.1.0",
+		Command:         "querydr",
+		TmnCode:         v.tmnCode,
+		TxnRef:          req.TxnRef,
+		OrderInfo:       req.OrderInfo,
+		TransactionNo:   req.GatewayTransactionNo,
+		TransactionDate: FormatVNPayDate(req.TransactionDate),
+		CreateDate:      FormatVNPayDate(time.Now()),
+		IPAddr:          req.IPAddr,
+	}
+	payload.SecureHash = SignVNPay(v.hashKey, payload.signData())
+
+	var queryResp vnpayQueryResponse
+	raw, err := v.post(ctx, payload, &queryResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(queryResp.SecureHash), []byte(SignVNPay(v.hashKey, queryResp.signData()))) {
+		return nil, fmt.Errorf("VNPay query response: %w", ErrInvalidSignature)
+	}
+
+	result := &StatusResult{
+		ResponseCode:         queryResp.ResponseCode,
+		Message:              queryResp.Message,
+		GatewayTransactionNo: queryResp.TransactionNo,
+		RawResponse:          raw,
+	}
+	if amount, err := strconv.ParseInt(queryResp.Amount, 10, 64); err == nil {
+		result.Amount = float64(amount / 100)
+	}
+
+	switch {
+	case queryResp.ResponseCode == vnpayTxnNotFound:
+		result.Status = StatusNotFound
+	case queryResp.ResponseCode != vnpaySuccess:
+		return nil, fmt.Errorf("VNPay query failed with code %s: %s", queryResp.ResponseCode, queryResp.Message)
+	case queryResp.TransactionStatus == vnpaySuccess:
+		result.Status = StatusCompleted
+	case queryResp.TransactionStatus == vnpayTxnStatusPending:
+		result.Status = StatusPending
+	case queryResp.TransactionStatus == vnpayTxnStatusRefunding, queryResp.TransactionStatus == vnpayTxnStatusRefunded:
+		result.Status = StatusRefunded
+	default:
+		result.Status = StatusFailed
+	}
+
+	return result, nil
+}
+
+type vnpayRefundPayload struct {
+	RequestID       string `json:"vnp_RequestId"`
+	Version         string `json:"vnp_Version"`
+	Command         string `json:"vnp_Command"`
+	TmnCode         string `json:"vnp_TmnCode"`
+	TransactionType string `json:"vnp_TransactionType"`
+	TxnRef          string `json:"vnp_TxnRef"`
+	Amount          string `json:"vnp_Amount"`
+	OrderInfo       string `json:"vnp_OrderInfo"`
+	TransactionNo   string `json:"vnp_TransactionNo"`
+	TransactionDate string `json:"vnp_TransactionDate"`
+	CreateBy        string `json:"vnp_CreateBy"`
+	CreateDate      string `json:"vnp_CreateDate"`
+	IPAddr          string `json:"vnp_IpAddr"`
+	SecureHash      string `json:"vnp_SecureHash"`
+}
+
+// signData joins the request fields in the order mandated by the refund API.
+func (p *vnpayRefundPayload) signData() string {
+	return strings.Join([]string{
+		p.RequestID, p.Version, p.Command, p.TmnCode, p.TransactionType,
+		p.TxnRef, p.Amount, p.TransactionNo, p.TransactionDate,
+		p.CreateBy, p.CreateDate, p.IPAddr, p.OrderInfo,
+	}, "|")
+}
+
+type vnpayRefundResponse struct {
+	ResponseID        string `json:"vnp_ResponseId"`
+	Command           string `json:"vnp_Command"`
+	ResponseCode      string `json:"vnp_ResponseCode"`
+	Message           string `json:"vnp_Message"`
+	TmnCode           string `json:"vnp_TmnCode"`
+	TxnRef            string `json:"vnp_TxnRef"`
+	Amount            string `json:"vnp_Amount"`
+	BankCode          string `json:"vnp_BankCode"`
+	PayDate           string `json:"vnp_PayDate"`
+	TransactionNo     string `json:"vnp_TransactionNo"`
+	TransactionType   string `json:"vnp_TransactionType"`
+	TransactionStatus string `json:"vnp_TransactionStatus"`
+	OrderInfo         string `json:"vnp_OrderInfo"`
+	SecureHash        string `json:"vnp_SecureHash"`
+}
+
+func (r *vnpayRefundResponse) signData() string {
+	return strings.Join([]string{
+		r.ResponseID, r.Command, r.ResponseCode, r.Message, r.TmnCode,
+		r.TxnRef, r.Amount, r.BankCode, r.PayDate, r.TransactionNo,
+		r.TransactionType, r.TransactionStatus, r.OrderInfo,
+	}, "|")
+}
+
+func (v *VNPayProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	transactionType := "03"
+	if req.Full {
+		transactionType = "02"
+	}
+
+	payload := vnpayRefundPayload{
+		RequestID:       req.RequestID,
+		Version:         "2.1.0",
+		Command:         "refund",
+		TmnCode:         v.tmnCode,
+		TransactionType: transactionType,
+		TxnRef:          req.TxnRef,
+		Amount:          strconv.FormatInt(req.Amount*100, 10),
+		OrderInfo:       req.Description,
+		TransactionNo:   req.GatewayTransactionNo,
+		TransactionDate: FormatVNPayDate(req.TransactionDate),
+		CreateBy:        req.CreatedBy,
+		CreateDate:      FormatVNPayDate(time.Now()),
+		IPAddr:          req.IPAddr,
+	}
+	payload.SecureHash = SignVNPay(v.hashKey, payload.signData())
+
+	var refundResp vnpayRefundResponse
+	raw, err := v.post(ctx, payload, &refundResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(refundResp.SecureHash), []byte(SignVNPay(v.hashKey, refundResp.signData()))) {
+		return nil, fmt.Errorf("VNPay refund response: %w", ErrInvalidSignature)
+	}
+
+	result := &RefundResult{
+		Status:        StatusFailed,
+		ResponseCode:  refundResp.ResponseCode,
+		Message:       refundResp.Message,
+		TransactionNo: refundResp.TransactionNo,
+		RawResponse:   raw,
+	}
+	if refundResp.ResponseCode == vnpaySuccess {
+		result.Status = StatusCompleted
+	}
+
+	return result, nil
+}
+
+// QueryRefund is not supported: the refund API settles or rejects a refund in
+// its reply, so VNPay refunds are never left pending.
+func (v *VNPayProvider) QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error) {
+	return nil, ErrNotSupported
+}
+
+// post sends a JSON request to the merchant API and decodes the reply into dest.
+func (v *VNPayProvider) post(ctx context.Context, payload, dest interface{}) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal VNPay request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, v.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create VNPay request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to contact VNPay: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read VNPay response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("VNPay request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, dest); err != nil {
+		return "", fmt.Errorf("failed to parse VNPay response: %w", err)
+	}
+
+	return string(respBody), nil
+}
@@ -0,0 +1,202 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testVNPayTmnCode = "TESTTMN1"
+	testVNPayHashKey = "SECRETKEY"
+)
+
+func TestSignVNPay(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		data     string
+		expected string
+	}{
+		{
+			name:     "Pay URL query",
+			key:      "SECRETKEY",
+			data:     "vnp_Amount=1000000&vnp_Command=pay",
+			expected: "0b54100d71b9779f4e445ee15edfd3f91c181d32e04f7ca8f5968359131e6bcb769eb622525778d9177981a8896263386cc580c39658b0bada24906c8f1ce561",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignVNPay(tt.key, tt.data); got != tt.expected {
+				t.Errorf("SignVNPay() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVNPaySignParams_IgnoresHashFields(t *testing.T) {
+	params := url.Values{}
+	params.Set("vnp_Command", "pay")
+	params.Set("vnp_Amount", "1000000")
+	params.Set("vnp_SecureHash", "ignored")
+	params.Set("vnp_SecureHashType", "HmacSHA512")
+	params.Set("utm_source", "ignored")
+
+	want := SignVNPay(testVNPayHashKey, "vnp_Amount=1000000&vnp_Command=pay")
+	if got := VNPaySignParams(testVNPayHashKey, params); got != want {
+		t.Errorf("VNPaySignParams() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatVNPayDate(t *testing.T) {
+	date := time.Date(2024, 1, 31, 20, 30, 0, 0, time.UTC)
+	if got := FormatVNPayDate(date); got != "20240201033000" {
+		t.Errorf("FormatVNPayDate() = %v, want 20240201033000", got)
+	}
+}
+
+// newFakeVNPayServer answers querydr and refund calls the way the VNPay
+// merchant API does, rejecting requests that are not correctly signed.
+func newFakeVNPayServer(t *testing.T, transactionStatus string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("fake VNPay: invalid request body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch req["vnp_Command"] {
+		case "querydr":
+			payload := vnpayQueryPayload{
+				RequestID: req["vnp_RequestId"], Version: req["vnp_Version"], Command: req["vnp_Command"],
+				TmnCode: req["vnp_TmnCode"], TxnRef: req["vnp_TxnRef"], TransactionDate: req["vnp_TransactionDate"],
+				CreateDate: req["vnp_CreateDate"], IPAddr: req["vnp_IpAddr"], OrderInfo: req["vnp_OrderInfo"],
+			}
+			if req["vnp_SecureHash"] != SignVNPay(testVNPayHashKey, payload.signData()) {
+				t.Errorf("fake VNPay: querydr signature mismatch")
+			}
+
+			resp := vnpayQueryResponse{
+				ResponseID: "Q1", Command: "querydr", ResponseCode: vnpaySuccess, Message: "QueryDR Success",
+				TmnCode: testVNPayTmnCode, TxnRef: payload.TxnRef, Amount: "5000000", BankCode: "NCB",
+				PayDate: "20240201033000", TransactionNo: "14226112", TransactionType: "01",
+				TransactionStatus: transactionStatus, OrderInfo: payload.OrderInfo,
+			}
+			resp.SecureHash = SignVNPay(testVNPayHashKey, resp.signData())
+			json.NewEncoder(w).Encode(resp)
+		case "refund":
+			payload := vnpayRefundPayload{
+				RequestID: req["vnp_RequestId"], Version: req["vnp_Version"], Command: req["vnp_Command"],
+				TmnCode: req["vnp_TmnCode"], TransactionType: req["vnp_TransactionType"], TxnRef: req["vnp_TxnRef"],
+				Amount: req["vnp_Amount"], TransactionNo: req["vnp_TransactionNo"], TransactionDate: req["vnp_TransactionDate"],
+				CreateBy: req["vnp_CreateBy"], CreateDate: req["vnp_CreateDate"], IPAddr: req["vnp_IpAddr"], OrderInfo: req["vnp_OrderInfo"],
+			}
+			if req["vnp_SecureHash"] != SignVNPay(testVNPayHashKey, payload.signData()) {
+				t.Errorf("fake VNPay: refund signature mismatch")
+			}
+
+			resp := vnpayRefundResponse{
+				ResponseID: "R1", Command: "refund", ResponseCode: vnpaySuccess, Message: "Refund Success",
+				TmnCode: testVNPayTmnCode, TxnRef: payload.TxnRef, Amount: payload.Amount, BankCode: "NCB",
+				PayDate: "20240201040000", TransactionNo: "14226199", TransactionType: payload.TransactionType,
+				TransactionStatus: "05", OrderInfo: payload.OrderInfo,
+			}
+			resp.SecureHash = SignVNPay(testVNPayHashKey, resp.signData())
+			json.NewEncoder(w).Encode(resp)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestVNPayProvider_EndToEnd(t *testing.T) {
+	server := newFakeVNPayServer(t, vnpaySuccess)
+	defer server.Close()
+
+	provider := NewVNPayProvider(testVNPayTmnCode, testVNPayHashKey, server.URL+"/paymentv2/vpcpay.html", server.URL, "http://shop.local/return")
+	ctx := context.Background()
+
+	initiated, err := provider.Initiate(ctx, InitiateRequest{TxnRef: "240201_7_1", Amount: 50000, OrderInfo: "Order 7", IPAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Initiate() error = %v", err)
+	}
+
+	payURL, err := url.Parse(initiated.PaymentURL)
+	if err != nil {
+		t.Fatalf("Initiate() returned invalid URL: %v", err)
+	}
+	params := payURL.Query()
+	if params.Get("vnp_SecureHash") != VNPaySignParams(testVNPayHashKey, params) {
+		t.Fatalf("Initiate() URL is not correctly signed")
+	}
+	if params.Get("vnp_Amount") != "5000000" {
+		t.Errorf("vnp_Amount = %v, want 5000000", params.Get("vnp_Amount"))
+	}
+
+	// Simulate the gateway redirecting back after a successful payment.
+	callback := url.Values{}
+	for _, key := range []string{"vnp_TmnCode", "vnp_Amount", "vnp_TxnRef", "vnp_OrderInfo"} {
+		callback.Set(key, params.Get(key))
+	}
+	callback.Set("vnp_ResponseCode", "00")
+	callback.Set("vnp_TransactionStatus", "00")
+	callback.Set("vnp_TransactionNo", "14226112")
+	callback.Set("vnp_SecureHash", VNPaySignParams(testVNPayHashKey, callback))
+
+	result, err := provider.VerifyCallback(Callback{Params: callback})
+	if err != nil {
+		t.Fatalf("VerifyCallback() error = %v", err)
+	}
+	if !result.Success || result.Amount != 50000 || result.TxnRef != "240201_7_1" || result.GatewayTransactionNo != "14226112" {
+		t.Errorf("VerifyCallback() = %+v", result)
+	}
+
+	callback.Set("vnp_Amount", "100")
+	if _, err := provider.VerifyCallback(Callback{Params: callback}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyCallback() with tampered amount error = %v, want ErrInvalidSignature", err)
+	}
+
+	callback.Set("vnp_Amount", params.Get("vnp_Amount"))
+	callback.Set("vnp_TmnCode", "OTHERTMN")
+	callback.Set("vnp_SecureHash", VNPaySignParams(testVNPayHashKey, callback))
+	if _, err := provider.VerifyCallback(Callback{Params: callback}); !errors.Is(err, ErrMerchantMismatch) {
+		t.Errorf("VerifyCallback() for another merchant error = %v, want ErrMerchantMismatch", err)
+	}
+
+	status, err := provider.QueryStatus(ctx, QueryRequest{RequestID: "Q1", TxnRef: "240201_7_1", TransactionDate: time.Now(), OrderInfo: "Query", IPAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("QueryStatus() error = %v", err)
+	}
+	if status.Status != StatusCompleted || status.Amount != 50000 {
+		t.Errorf("QueryStatus() = %+v", status)
+	}
+
+	refund, err := provider.Refund(ctx, RefundRequest{RequestID: "R1", TxnRef: "240201_7_1", GatewayTransactionNo: "14226112", TransactionDate: time.Now(), Amount: 20000, Description: "Refund", CreatedBy: "admin", IPAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	if refund.Status != StatusCompleted || refund.TransactionNo != "14226199" {
+		t.Errorf("Refund() = %+v", refund)
+	}
+}
+
+func TestVNPayProvider_QueryStatusPending(t *testing.T) {
+	server := newFakeVNPayServer(t, vnpayTxnStatusPending)
+	defer server.Close()
+
+	provider := NewVNPayProvider(testVNPayTmnCode, testVNPayHashKey, server.URL, server.URL, "")
+	status, err := provider.QueryStatus(context.Background(), QueryRequest{RequestID: "Q2", TxnRef: "240201_7_2", TransactionDate: time.Now()})
+	if err != nil {
+		t.Fatalf("QueryStatus() error = %v", err)
+	}
+	if status.Status != StatusPending {
+		t.Errorf("QueryStatus() status = %v, want %v", status.Status, StatusPending)
+	}
+}
@@ -0,0 +1,403 @@
+// Package vnpaysim is a local stand-in for the VNPay sandbox. It serves the
+// pay page, redirects the customer back with signed parameters, fires the IPN
+// and answers the querydr and refund APIs, so the whole payment flow can run
+// offline.
+package vnpaysim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ecommerce-backend/internal/payments"
+)
+
+// Paths served by the simulator, matching the real VNPay endpoints.
+const (
+	PayPath      = "/paymentv2/vpcpay.html"
+	CompletePath = "/paymentv2/complete"
+	APIPath      = "/merchant_webapi/api/transaction"
+)
+
+// Outcome is the customer's choice on the pay page.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	OutcomeCancel  Outcome = "cancel"
+)
+
+// vnp_ResponseCode and vnp_TransactionStatus sent back for each outcome.
+var outcomeCodes = map[Outcome][2]string{
+	OutcomeSuccess: {"00", "00"},
+	OutcomeFailure: {"51", "02"}, // insufficient funds
+	OutcomeCancel:  {"24", "02"}, // customer cancelled
+}
+
+var (
+	ErrInvalidSignature = errors.New("vnpaysim: invalid signature")
+	ErrUnknownTxn       = errors.New("vnpaysim: unknown transaction")
+)
+
+// Transaction is the simulator's record of one payment.
+type Transaction struct {
+	TxnRef            string
+	OrderInfo         string
+	Amount            int64 // vnp_Amount, i.e. VND x 100
+	ReturnURL         string
+	CreateDate        string
+	ResponseCode      string
+	TransactionStatus string
+	TransactionNo     string
+	PayDate           string
+	RefundedAmount    int64
+	IPNResponse       string
+}
+
+type Server struct {
+	TmnCode string
+	HashKey string
+	// IPNURL receives the IPN after each payment; leave empty to skip it.
+	IPNURL string
+
+	client       *http.Client
+	mu           sync.Mutex
+	transactions map[string]*Transaction
+	nextTxnNo    int64
+}
+
+func NewServer(tmnCode, hashKey, ipnURL string) *Server {
+	return &Server{
+		TmnCode:      tmnCode,
+		HashKey:      hashKey,
+		IPNURL:       ipnURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		transactions: make(map[string]*Transaction),
+		nextTxnNo:    14000000,
+	}
+}
+
+// NewTestServer starts a simulator on a local httptest server. Point
+// VNPayURL at srv.URL+PayPath and VNPayAPIURL at srv.URL+APIPath.
+func NewTestServer(tmnCode, hashKey, ipnURL string) (*Server, *httptest.Server) {
+	s := NewServer(tmnCode, hashKey, ipnURL)
+	return s, httptest.NewServer(s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case PayPath:
+		s.handlePayPage(w, r)
+	case CompletePath:
+		s.handleComplete(w, r)
+	case APIPath:
+		s.handleAPI(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Transaction returns a copy of the simulator's record for txnRef.
+func (s *Server) Transaction(txnRef string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.transactions[txnRef]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *txn, true
+}
+
+// Pay does what a customer would on the pay page: it opens paymentURL,
+// chooses outcome and returns the URL the browser is redirected to.
+func (s *Server) Pay(paymentURL string, outcome Outcome) (string, error) {
+	u, err := url.Parse(paymentURL)
+	if err != nil {
+		return "", err
+	}
+
+	txn, err := s.register(u.Query())
+	if err != nil {
+		return "", err
+	}
+	return s.Complete(txn.TxnRef, outcome)
+}
+
+// register validates a pay URL's parameters and records the transaction.
+func (s *Server) register(params url.Values) (*Transaction, error) {
+	if params.Get("vnp_SecureHash") != payments.VNPaySignParams(s.HashKey, params) {
+		return nil, ErrInvalidSignature
+	}
+	if params.Get("vnp_TmnCode") != s.TmnCode {
+		return nil, fmt.Errorf("vnpaysim: unknown merchant %q", params.Get("vnp_TmnCode"))
+	}
+
+	amount, err := strconv.ParseInt(params.Get("vnp_Amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		return nil, fmt.Errorf("vnpaysim: invalid amount %q", params.Get("vnp_Amount"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn, ok := s.transactions[params.Get("vnp_TxnRef")]
+	if !ok {
+		txn = &Transaction{
+			TxnRef:            params.Get("vnp_TxnRef"),
+			OrderInfo:         params.Get("vnp_OrderInfo"),
+			Amount:            amount,
+			ReturnURL:         params.Get("vnp_ReturnUrl"),
+			CreateDate:        params.Get("vnp_CreateDate"),
+			TransactionStatus: "01",
+		}
+		s.transactions[txn.TxnRef] = txn
+	}
+	return txn, nil
+}
+
+// Complete settles txnRef with outcome, fires the IPN and returns the signed
+// return URL.
+func (s *Server) Complete(txnRef string, outcome Outcome) (string, error) {
+	codes, ok := outcomeCodes[outcome]
+	if !ok {
+		return "", fmt.Errorf("vnpaysim: unknown outcome %q", outcome)
+	}
+
+	s.mu.Lock()
+	txn, ok := s.transactions[txnRef]
+	if !ok {
+		s.mu.Unlock()
+		return "", ErrUnknownTxn
+	}
+	if txn.TransactionStatus != "01" {
+		s.mu.Unlock()
+		return "", fmt.Errorf("vnpaysim: transaction %s already completed", txnRef)
+	}
+
+	s.nextTxnNo++
+	txn.ResponseCode = codes[0]
+	txn.TransactionStatus = codes[1]
+	txn.TransactionNo = strconv.FormatInt(s.nextTxnNo, 10)
+	txn.PayDate = payments.FormatVNPayDate(time.Now())
+	params := s.callbackParams(txn)
+	returnURL := txn.ReturnURL
+	s.mu.Unlock()
+
+	if s.IPNURL != "" {
+		ipnResponse := s.sendIPN(params)
+		s.mu.Lock()
+		txn.IPNResponse = ipnResponse
+		s.mu.Unlock()
+	}
+
+	separator := "?"
+	if strings.Contains(returnURL, "?") {
+		separator = "&"
+	}
+	return returnURL + separator + params.Encode(), nil
+}
+
+func (s *Server) callbackParams(txn *Transaction) url.Values {
+	params := url.Values{}
+	params.Set("vnp_Amount", strconv.FormatInt(txn.Amount, 10))
+	params.Set("vnp_BankCode", "NCB")
+	params.Set("vnp_BankTranNo", "VNP"+txn.TransactionNo)
+	params.Set("vnp_CardType", "ATM")
+	params.Set("vnp_OrderInfo", txn.OrderInfo)
+	params.Set("vnp_PayDate", txn.PayDate)
+	params.Set("vnp_ResponseCode", txn.ResponseCode)
+	params.Set("vnp_TmnCode", s.TmnCode)
+	params.Set("vnp_TransactionNo", txn.TransactionNo)
+	params.Set("vnp_TransactionStatus", txn.TransactionStatus)
+	params.Set("vnp_TxnRef", txn.TxnRef)
+	params.Set("vnp_SecureHash", payments.VNPaySignParams(s.HashKey, params))
+	return params
+}
+
+// sendIPN delivers the IPN as a GET, the way VNPay does, and returns the
+// merchant's reply.
+func (s *Server) sendIPN(params url.Values) string {
+	resp, err := s.client.Get(s.IPNURL + "?" + params.Encode())
+	if err != nil {
+		log.Printf("vnpaysim: IPN for %s failed: %v", params.Get("vnp_TxnRef"), err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	log.Printf("vnpaysim: IPN for %s answered %d %s", params.Get("vnp_TxnRef"), resp.StatusCode, body)
+	return string(body)
+}
+
+var payPage = template.Must(template.New("pay").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>VNPay Simulator</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 40px auto">
+  <h2>VNPay Simulator</h2>
+  <p><strong>Merchant:</strong> {{.TmnCode}}</p>
+  <p><strong>Order:</strong> {{.Txn.OrderInfo}}</p>
+  <p><strong>Reference:</strong> {{.Txn.TxnRef}}</p>
+  <p><strong>Amount:</strong> {{.Amount}} VND</p>
+  <form method="POST" action="` + CompletePath + `">
+    <input type="hidden" name="vnp_TxnRef" value="{{.Txn.TxnRef}}">
+    <button type="submit" name="outcome" value="success">Pay successfully</button>
+    <button type="submit" name="outcome" value="failure">Fail payment</button>
+    <button type="submit" name="outcome" value="cancel">Cancel</button>
+  </form>
+</body>
+</html>`))
+
+func (s *Server) handlePayPage(w http.ResponseWriter, r *http.Request) {
+	txn, err := s.register(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	payPage.Execute(w, map[string]interface{}{
+		"TmnCode": s.TmnCode,
+		"Txn":     txn,
+		"Amount":  txn.Amount / 100,
+	})
+}
+
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	returnURL, err := s.Complete(r.FormValue("vnp_TxnRef"), Outcome(r.FormValue("outcome")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, returnURL, http.StatusFound)
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var resp map[string]string
+	switch req["vnp_Command"] {
+	case "querydr":
+		resp = s.query(req)
+	case "refund":
+		resp = s.refund(req)
+	default:
+		resp = map[string]string{"vnp_ResponseCode": "99", "vnp_Message": "Unknown command"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func joinFields(m map[string]string, keys ...string) string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = m[key]
+	}
+	return strings.Join(values, "|")
+}
+
+func (s *Server) query(req map[string]string) map[string]string {
+	resp := map[string]string{
+		"vnp_ResponseId": req["vnp_RequestId"],
+		"vnp_Command":    "querydr",
+		"vnp_TmnCode":    s.TmnCode,
+		"vnp_TxnRef":     req["vnp_TxnRef"],
+	}
+
+	signData := joinFields(req, "vnp_RequestId", "vnp_Version", "vnp_Command", "vnp_TmnCode", "vnp_TxnRef",
+		"vnp_TransactionDate", "vnp_CreateDate", "vnp_IpAddr", "vnp_OrderInfo")
+	s.mu.Lock()
+	txn, found := s.transactions[req["vnp_TxnRef"]]
+	switch {
+	case req["vnp_SecureHash"] != payments.SignVNPay(s.HashKey, signData):
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "97", "Invalid signature"
+	case !found || req["vnp_TransactionDate"] != txn.CreateDate:
+		// Like VNPay, the transaction date must repeat the vnp_CreateDate
+		// the payment was started with.
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "91", "Transaction not found"
+	default:
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "00", "QueryDR Success"
+		resp["vnp_Amount"] = strconv.FormatInt(txn.Amount, 10)
+		resp["vnp_BankCode"] = "NCB"
+		resp["vnp_PayDate"] = txn.PayDate
+		resp["vnp_TransactionNo"] = txn.TransactionNo
+		resp["vnp_TransactionType"] = "01"
+		resp["vnp_TransactionStatus"] = txn.TransactionStatus
+		resp["vnp_OrderInfo"] = txn.OrderInfo
+	}
+	s.mu.Unlock()
+
+	resp["vnp_SecureHash"] = payments.SignVNPay(s.HashKey, joinFields(resp,
+		"vnp_ResponseId", "vnp_Command", "vnp_ResponseCode", "vnp_Message", "vnp_TmnCode", "vnp_TxnRef",
+		"vnp_Amount", "vnp_BankCode", "vnp_PayDate", "vnp_TransactionNo", "vnp_TransactionType",
+		"vnp_TransactionStatus", "vnp_OrderInfo", "vnp_PromotionCode", "vnp_PromotionAmount"))
+	return resp
+}
+
+func (s *Server) refund(req map[string]string) map[string]string {
+	resp := map[string]string{
+		"vnp_ResponseId":      req["vnp_RequestId"],
+		"vnp_Command":         "refund",
+		"vnp_TmnCode":         s.TmnCode,
+		"vnp_TxnRef":          req["vnp_TxnRef"],
+		"vnp_Amount":          req["vnp_Amount"],
+		"vnp_TransactionType": req["vnp_TransactionType"],
+		"vnp_OrderInfo":       req["vnp_OrderInfo"],
+	}
+
+	signData := joinFields(req, "vnp_RequestId", "vnp_Version", "vnp_Command", "vnp_TmnCode", "vnp_TransactionType",
+		"vnp_TxnRef", "vnp_Amount", "vnp_TransactionNo", "vnp_TransactionDate", "vnp_CreateBy", "vnp_CreateDate",
+		"vnp_IpAddr", "vnp_OrderInfo")
+	amount, amountErr := strconv.ParseInt(req["vnp_Amount"], 10, 64)
+
+	s.mu.Lock()
+	txn, found := s.transactions[req["vnp_TxnRef"]]
+	switch {
+	case req["vnp_SecureHash"] != payments.SignVNPay(s.HashKey, signData):
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "97", "Invalid signature"
+	case !found || req["vnp_TransactionDate"] != txn.CreateDate:
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "91", "Transaction not found"
+	case txn.ResponseCode != "00":
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "95", "Transaction was not successful"
+	case amountErr != nil || amount <= 0 || amount > txn.Amount-txn.RefundedAmount:
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "93", "Invalid refund amount"
+	default:
+		s.nextTxnNo++
+		txn.RefundedAmount += amount
+		txn.TransactionStatus = "06"
+		resp["vnp_ResponseCode"], resp["vnp_Message"] = "00", "Refund Success"
+		resp["vnp_BankCode"] = "NCB"
+		resp["vnp_PayDate"] = payments.FormatVNPayDate(time.Now())
+		resp["vnp_TransactionNo"] = strconv.FormatInt(s.nextTxnNo, 10)
+		resp["vnp_TransactionStatus"] = "06"
+	}
+	s.mu.Unlock()
+
+	resp["vnp_SecureHash"] = payments.SignVNPay(s.HashKey, joinFields(resp,
+		"vnp_ResponseId", "vnp_Command", "vnp_ResponseCode", "vnp_Message", "vnp_TmnCode", "vnp_TxnRef",
+		"vnp_Amount", "vnp_BankCode", "vnp_PayDate", "vnp_TransactionNo", "vnp_TransactionType",
+		"vnp_TransactionStatus", "vnp_OrderInfo"))
+	return resp
+}
@@ -0,0 +1,178 @@
+package vnpaysim
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/payments"
+)
+
+const (
+	testTmnCode = "SIMTMN01"
+	testHashKey = "SIMSECRET"
+)
+
+// newMerchant starts an IPN endpoint that verifies callbacks with the real
+// VNPay provider and records what it accepted.
+func newMerchant(t *testing.T, provider *payments.VNPayProvider, received chan<- *payments.CallbackResult) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := provider.VerifyCallback(payments.Callback{Params: r.URL.Query()})
+		if err != nil {
+			status, body := provider.CallbackResponse(payments.OutcomeInvalidSignature)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+		received <- result
+		status, body := provider.CallbackResponse(payments.OutcomeOK)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func TestSimulator_PayPageSuccessFlow(t *testing.T) {
+	sim, server := NewTestServer(testTmnCode, testHashKey, "")
+	defer server.Close()
+
+	provider := payments.NewVNPayProvider(testTmnCode, testHashKey, server.URL+PayPath, server.URL+APIPath, "http://shop.local/payment/return")
+
+	received := make(chan *payments.CallbackResult, 1)
+	merchant := newMerchant(t, provider, received)
+	defer merchant.Close()
+	sim.IPNURL = merchant.URL
+
+	ctx := context.Background()
+	createdAt := time.Now().Add(-time.Hour)
+	initiated, err := provider.Initiate(ctx, payments.InitiateRequest{TxnRef: "240201_7_1", Amount: 150000, OrderInfo: "Order 7", IPAddr: "127.0.0.1", CreatedAt: createdAt})
+	if err != nil {
+		t.Fatalf("Initiate() error = %v", err)
+	}
+
+	resp, err := http.Get(initiated.PaymentURL)
+	if err != nil {
+		t.Fatalf("GET pay page error = %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), `value="success"`) {
+		t.Fatalf("pay page = %d %s", resp.StatusCode, page)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.PostForm(server.URL+CompletePath, url.Values{"vnp_TxnRef": {"240201_7_1"}, "outcome": {"success"}})
+	if err != nil {
+		t.Fatalf("POST complete error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("complete status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	returnURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(returnURL.String(), "http://shop.local/payment/return?") {
+		t.Fatalf("redirect Location = %q", resp.Header.Get("Location"))
+	}
+
+	result, err := provider.VerifyCallback(payments.Callback{Params: returnURL.Query()})
+	if err != nil {
+		t.Fatalf("VerifyCallback(return) error = %v", err)
+	}
+	if !result.Success || result.Amount != 150000 || result.TxnRef != "240201_7_1" {
+		t.Errorf("VerifyCallback(return) = %+v", result)
+	}
+
+	select {
+	case ipn := <-received:
+		if !ipn.Success || ipn.GatewayTransactionNo != result.GatewayTransactionNo {
+			t.Errorf("IPN = %+v, want success for %s", ipn, result.GatewayTransactionNo)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("IPN was not delivered")
+	}
+	if txn, _ := sim.Transaction("240201_7_1"); !strings.Contains(txn.IPNResponse, `"RspCode":"00"`) {
+		t.Errorf("IPN response = %q, want RspCode 00", txn.IPNResponse)
+	}
+
+	status, err := provider.QueryStatus(ctx, payments.QueryRequest{RequestID: "Q1", TxnRef: "240201_7_1", TransactionDate: createdAt})
+	if err != nil {
+		t.Fatalf("QueryStatus() error = %v", err)
+	}
+	if status.Status != payments.StatusCompleted || status.Amount != 150000 {
+		t.Errorf("QueryStatus() = %+v", status)
+	}
+
+	status, err = provider.QueryStatus(ctx, payments.QueryRequest{RequestID: "Q0", TxnRef: "240201_7_1", TransactionDate: time.Now()})
+	if err != nil || status.Status != payments.StatusNotFound {
+		t.Errorf("QueryStatus() with another transaction date = %+v, %v; want not found", status, err)
+	}
+
+	refund, err := provider.Refund(ctx, payments.RefundRequest{RequestID: "R1", TxnRef: "240201_7_1", GatewayTransactionNo: result.GatewayTransactionNo, TransactionDate: createdAt, Amount: 50000, CreatedBy: "admin"})
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	if refund.Status != payments.StatusCompleted {
+		t.Errorf("Refund() = %+v", refund)
+	}
+
+	status, err = provider.QueryStatus(ctx, payments.QueryRequest{RequestID: "Q2", TxnRef: "240201_7_1", TransactionDate: createdAt})
+	if err != nil || status.Status != payments.StatusRefunded {
+		t.Errorf("QueryStatus() after refund = %+v, %v; want refunded", status, err)
+	}
+}
+
+func TestSimulator_Outcomes(t *testing.T) {
+	tests := []struct {
+		name         string
+		outcome      Outcome
+		responseCode string
+		success      bool
+	}{
+		{name: "Success", outcome: OutcomeSuccess, responseCode: "00", success: true},
+		{name: "Failure", outcome: OutcomeFailure, responseCode: "51", success: false},
+		{name: "Cancel", outcome: OutcomeCancel, responseCode: "24", success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim, server := NewTestServer(testTmnCode, testHashKey, "")
+			defer server.Close()
+
+			provider := payments.NewVNPayProvider(testTmnCode, testHashKey, server.URL+PayPath, server.URL+APIPath, "http://shop.local/return")
+			initiated, _ := provider.Initiate(context.Background(), payments.InitiateRequest{TxnRef: "240201_8_1", Amount: 20000, OrderInfo: "Order 8"})
+
+			returnURL, err := sim.Pay(initiated.PaymentURL, tt.outcome)
+			if err != nil {
+				t.Fatalf("Pay() error = %v", err)
+			}
+
+			u, _ := url.Parse(returnURL)
+			result, err := provider.VerifyCallback(payments.Callback{Params: u.Query()})
+			if err != nil {
+				t.Fatalf("VerifyCallback() error = %v", err)
+			}
+			if result.Success != tt.success || result.ResponseCode != tt.responseCode {
+				t.Errorf("VerifyCallback() = %+v, want success=%v code=%s", result, tt.success, tt.responseCode)
+			}
+		})
+	}
+}
+
+func TestSimulator_RejectsTamperedPayURL(t *testing.T) {
+	sim, server := NewTestServer(testTmnCode, testHashKey, "")
+	defer server.Close()
+
+	provider := payments.NewVNPayProvider(testTmnCode, testHashKey, server.URL+PayPath, server.URL+APIPath, "http://shop.local/return")
+	initiated, _ := provider.Initiate(context.Background(), payments.InitiateRequest{TxnRef: "240201_9_1", Amount: 20000, OrderInfo: "Order 9"})
+
+	tampered := strings.Replace(initiated.PaymentURL, "vnp_Amount=2000000", "vnp_Amount=100", 1)
+	if _, err := sim.Pay(tampered, OutcomeSuccess); err != ErrInvalidSignature {
+		t.Errorf("Pay() error = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,339 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZaloPay return codes
+const (
+	zaloPaySuccess    = 1
+	zaloPayProcessing = 3
+)
+
+// ZaloPayProvider talks to the ZaloPay v2 order API.
+type ZaloPayProvider struct {
+	appID       string
+	key1        string
+	key2        string
+	endpoint    string
+	returnURL   string
+	callbackURL string
+	client      *http.Client
+}
+
+func NewZaloPayProvider(appID, key1, key2, endpoint, returnURL, callbackURL string) *ZaloPayProvider {
+	return &ZaloPayProvider{
+		appID:       appID,
+		key1:        key1,
+		key2:        key2,
+		endpoint:    endpoint,
+		returnURL:   returnURL,
+		callbackURL: callbackURL,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (z *ZaloPayProvider) Method() string {
+	return "zalopay"
+}
+
+// SignZaloPay returns the hex encoded HMAC-SHA256 of the '|' joined fields keyed with key.
+func SignZaloPay(key string, fields ...string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type zaloPayCreateResponse struct {
+	ReturnCode    int    `json:"return_code"`
+	ReturnMessage string `json:"return_message"`
+	OrderURL      string `json:"order_url"`
+	ZPTransToken  string `json:"zp_trans_token"`
+}
+
+// Initiate uses the TxnRef as app_trans_id, which is why NewTxnRef prefixes
+// references with the yymmdd date ZaloPay requires.
+func (z *ZaloPayProvider) Initiate(ctx context.Context, req InitiateRequest) (*InitiateResult, error) {
+	embedData, _ := json.Marshal(map[string]string{"redirecturl": z.returnURL})
+	appTime := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	amount := strconv.FormatInt(req.Amount, 10)
+
+	form := url.Values{}
+	form.Set("app_id", z.appID)
+	form.Set("app_trans_id", req.TxnRef)
+	form.Set("app_user", "ecommerce")
+	form.Set("app_time", appTime)
+	form.Set("amount", amount)
+	form.Set("item", "[]")
+	form.Set("embed_data", string(embedData))
+	form.Set("description", req.OrderInfo)
+	form.Set("bank_code", "")
+	form.Set("callback_url", z.callbackURL)
+	form.Set("mac", SignZaloPay(z.key1, z.appID, req.TxnRef, "ecommerce", amount, appTime, string(embedData), "[]"))
+
+	var resp zaloPayCreateResponse
+	raw, err := z.post(ctx, "/v2/create", form, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ReturnCode != zaloPaySuccess {
+		return nil, fmt.Errorf("ZaloPay create failed with code %d: %s", resp.ReturnCode, resp.ReturnMessage)
+	}
+
+	return &InitiateResult{PaymentURL: resp.OrderURL, RawResponse: raw}, nil
+}
+
+type zaloPayCallbackBody struct {
+	Data string `json:"data"`
+	MAC  string `json:"mac"`
+	Type int    `json:"type"`
+}
+
+type zaloPayCallbackData struct {
+	AppID      int64  `json:"app_id"`
+	AppTransID string `json:"app_trans_id"`
+	AppTime    int64  `json:"app_time"`
+	Amount     int64  `json:"amount"`
+	ZPTransID  int64  `json:"zp_trans_id"`
+	ServerTime int64  `json:"server_time"`
+}
+
+// VerifyCallback accepts the server callback (JSON body signed with key2) or
+// the redirect query string (checksum signed with key2).
+func (z *ZaloPayProvider) VerifyCallback(cb Callback) (*CallbackResult, error) {
+	if len(cb.Body) > 0 {
+		var body zaloPayCallbackBody
+		if err := json.Unmarshal(cb.Body, &body); err != nil {
+			return nil, ErrInvalidSignature
+		}
+		if body.MAC == "" || !hmac.Equal([]byte(body.MAC), []byte(SignZaloPay(z.key2, body.Data))) {
+			return nil, ErrInvalidSignature
+		}
+
+		var data zaloPayCallbackData
+		if err := json.Unmarshal([]byte(body.Data), &data); err != nil {
+			return nil, ErrInvalidSignature
+		}
+		if strconv.FormatInt(data.AppID, 10) != z.appID {
+			return nil, ErrMerchantMismatch
+		}
+
+		// The server callback is only sent for successful payments.
+		return &CallbackResult{
+			TxnRef:               data.AppTransID,
+			Amount:               float64(data.Amount),
+			Currency:             "VND",
+			Success:              true,
+			ResponseCode:         strconv.Itoa(zaloPaySuccess),
+			GatewayTransactionNo: strconv.FormatInt(data.ZPTransID, 10),
+		}, nil
+	}
+
+	p := cb.Params
+	checksum := SignZaloPay(z.key2, p.Get("appid"), p.Get("apptransid"), p.Get("pmcid"), p.Get("bankcode"), p.Get("amount"), p.Get("discountamount"), p.Get("status"))
+	if p.Get("checksum") == "" || !hmac.Equal([]byte(p.Get("checksum")), []byte(checksum)) {
+		return nil, ErrInvalidSignature
+	}
+	if p.Get("appid") != z.appID {
+		return nil, ErrMerchantMismatch
+	}
+
+	amount, err := strconv.ParseInt(p.Get("amount"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return &CallbackResult{
+		TxnRef:       p.Get("apptransid"),
+		Amount:       float64(amount),
+		Currency:     "VND",
+		Success:      p.Get("status") == strconv.Itoa(zaloPaySuccess),
+		ResponseCode: p.Get("status"),
+	}, nil
+}
+
+// ZaloPay retries while return_code is not 1; -1 tells it the callback was rejected.
+func (z *ZaloPayProvider) CallbackResponse(outcome CallbackOutcome) (int, interface{}) {
+	switch outcome {
+	case OutcomeOK, OutcomeAlreadyConfirmed:
+		return http.StatusOK, map[string]interface{}{"return_code": 1, "return_message": "success"}
+	case OutcomeInvalidSignature:
+		return http.StatusOK, map[string]interface{}{"return_code": -1, "return_message": "mac not equal"}
+	default:
+		return http.StatusOK, map[string]interface{}{"return_code": 0, "return_message": "rejected"}
+	}
+}
+
+type zaloPayQueryResponse struct {
+	ReturnCode    int    `json:"return_code"`
+	ReturnMessage string `json:"return_message"`
+	IsProcessing  bool   `json:"is_processing"`
+	Amount        int64  `json:"amount"`
+	ZPTransID     int64  `json:"zp_trans_id"`
+}
+
+func (z *ZaloPayProvider) QueryStatus(ctx context.Context, req QueryRequest) (*StatusResult, error) {
+	form := url.Values{}
+	form.Set("app_id", z.appID)
+	form.Set("app_trans_id", req.TxnRef)
+	form.Set("mac", SignZaloPay(z.key1, z.appID, req.TxnRef, z.key1))
+
+	var resp zaloPayQueryResponse
+	raw, err := z.post(ctx, "/v2/query", form, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StatusResult{
+		Amount:       float64(resp.Amount),
+		ResponseCode: strconv.Itoa(resp.ReturnCode),
+		Message:      resp.ReturnMessage,
+		RawResponse:  raw,
+	}
+	if resp.ZPTransID != 0 {
+		result.GatewayTransactionNo = strconv.FormatInt(resp.ZPTransID, 10)
+	}
+
+	switch resp.ReturnCode {
+	case zaloPaySuccess:
+		result.Status = StatusCompleted
+	case zaloPayProcessing:
+		result.Status = StatusPending
+	default:
+		result.Status = StatusFailed
+	}
+
+	return result, nil
+}
+
+type zaloPayRefundResponse struct {
+	ReturnCode    int    `json:"return_code"`
+	ReturnMessage string `json:"return_message"`
+	RefundID      int64  `json:"refund_id"`
+}
+
+func (z *ZaloPayProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	amount := strconv.FormatInt(req.Amount, 10)
+
+	form := url.Values{}
+	form.Set("app_id", z.appID)
+	form.Set("m_refund_id", z.refundID(req.RequestID, req.RequestedAt))
+	form.Set("zp_trans_id", req.GatewayTransactionNo)
+	form.Set("amount", amount)
+	form.Set("timestamp", timestamp)
+	form.Set("description", req.Description)
+	form.Set("mac", SignZaloPay(z.key1, z.appID, req.GatewayTransactionNo, amount, req.Description, timestamp))
+
+	var resp zaloPayRefundResponse
+	raw, err := z.post(ctx, "/v2/refund", form, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{
+		Status:       StatusFailed,
+		ResponseCode: strconv.Itoa(resp.ReturnCode),
+		Message:      resp.ReturnMessage,
+		RawResponse:  raw,
+	}
+	if resp.RefundID != 0 {
+		result.TransactionNo = strconv.FormatInt(resp.RefundID, 10)
+	}
+
+	switch resp.ReturnCode {
+	case zaloPaySuccess:
+		result.Status = StatusCompleted
+	case zaloPayProcessing:
+		result.Status = StatusPending
+	}
+
+	return result, nil
+}
+
+// refundID builds the m_refund_id for a refund, which ZaloPay requires to
+// start with the yymmdd date the refund was requested on.
+func (z *ZaloPayProvider) refundID(requestID string, requestedAt time.Time) string {
+	if requestedAt.IsZero() {
+		requestedAt = time.Now()
+	}
+	return fmt.Sprintf("%s_%s_%s", requestedAt.In(gatewayTimezone).Format("060102"), z.appID, requestID)
+}
+
+type zaloPayRefundQueryResponse struct {
+	ReturnCode    int    `json:"return_code"`
+	ReturnMessage string `json:"return_message"`
+}
+
+// QueryRefund asks for the status of the refund by the m_refund_id Refund sent.
+func (z *ZaloPayProvider) QueryRefund(ctx context.Context, req RefundQueryRequest) (*RefundResult, error) {
+	refundID := z.refundID(req.RequestID, req.RequestedAt)
+	timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+
+	form := url.Values{}
+	form.Set("app_id", z.appID)
+	form.Set("m_refund_id", refundID)
+	form.Set("timestamp", timestamp)
+	form.Set("mac", SignZaloPay(z.key1, z.appID, refundID, timestamp))
+
+	var resp zaloPayRefundQueryResponse
+	raw, err := z.post(ctx, "/v2/query_refund", form, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{
+		Status:       StatusFailed,
+		ResponseCode: strconv.Itoa(resp.ReturnCode),
+		Message:      resp.ReturnMessage,
+		RawResponse:  raw,
+	}
+
+	switch resp.ReturnCode {
+	case zaloPaySuccess:
+		result.Status = StatusCompleted
+	case zaloPayProcessing:
+		result.Status = StatusPending
+	}
+
+	return result, nil
+}
+
+func (z *ZaloPayProvider) post(ctx context.Context, path string, form url.Values, dest interface{}) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, z.endpoint+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create ZaloPay request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := z.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to contact ZaloPay: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ZaloPay response: %w", err)
+	}
+
+	if err := json.Unmarshal(respBody, dest); err != nil {
+		return "", fmt.Errorf("failed to parse ZaloPay response (status %d): %w", resp.StatusCode, err)
+	}
+
+	return string(respBody), nil
+}
@@ -0,0 +1,158 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testZaloPayAppID = "2553"
+	testZaloPayKey1  = "sdngKKJmqEMzvh5QQcdD2A9XBSKUNaYn"
+	testZaloPayKey2  = "trMrHtvjo6myautxDUiAcYsVtaeQ8nhf"
+)
+
+func TestSignZaloPay(t *testing.T) {
+	got := SignZaloPay(testZaloPayKey1, "2553", "240201_7_1706790000000", "ecommerce", "50000", "1706790000000",
+		`{"redirecturl":"https://shop.example/return"}`, "[]")
+
+	want := "37d24bf9d1fef1a234bce7290eac4a5fd5f03618ce2322ad18afa7d12af1f2e3"
+	if got != want {
+		t.Errorf("SignZaloPay() = %v, want %v", got, want)
+	}
+}
+
+// newFakeZaloPayServer implements the create, query and refund endpoints of
+// the ZaloPay v2 API, checking each request's key1 MAC.
+func newFakeZaloPayServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v2/create", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f := r.PostForm
+		mac := SignZaloPay(testZaloPayKey1, f.Get("app_id"), f.Get("app_trans_id"), f.Get("app_user"), f.Get("amount"), f.Get("app_time"), f.Get("embed_data"), f.Get("item"))
+		if f.Get("mac") != mac {
+			json.NewEncoder(w).Encode(zaloPayCreateResponse{ReturnCode: 2, ReturnMessage: "invalid mac"})
+			return
+		}
+		json.NewEncoder(w).Encode(zaloPayCreateResponse{
+			ReturnCode: zaloPaySuccess, ReturnMessage: "success",
+			OrderURL: "https://qcgateway.zalopay.vn/openinapp?order=" + f.Get("app_trans_id"),
+		})
+	})
+
+	mux.HandleFunc("/v2/query", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f := r.PostForm
+		if f.Get("mac") != SignZaloPay(testZaloPayKey1, f.Get("app_id"), f.Get("app_trans_id"), testZaloPayKey1) {
+			t.Errorf("fake ZaloPay: query mac mismatch")
+		}
+		json.NewEncoder(w).Encode(zaloPayQueryResponse{ReturnCode: zaloPayProcessing, ReturnMessage: "processing", IsProcessing: true, Amount: 50000})
+	})
+
+	mux.HandleFunc("/v2/refund", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f := r.PostForm
+		if f.Get("mac") != SignZaloPay(testZaloPayKey1, f.Get("app_id"), f.Get("zp_trans_id"), f.Get("amount"), f.Get("description"), f.Get("timestamp")) {
+			t.Errorf("fake ZaloPay: refund mac mismatch")
+		}
+		if f.Get("m_refund_id") != "240201_"+testZaloPayAppID+"_R1" {
+			t.Errorf("fake ZaloPay: m_refund_id = %s", f.Get("m_refund_id"))
+		}
+		json.NewEncoder(w).Encode(zaloPayRefundResponse{ReturnCode: zaloPaySuccess, ReturnMessage: "success", RefundID: 190508000000017})
+	})
+
+	mux.HandleFunc("/v2/query_refund", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f := r.PostForm
+		if f.Get("mac") != SignZaloPay(testZaloPayKey1, f.Get("app_id"), f.Get("m_refund_id"), f.Get("timestamp")) {
+			t.Errorf("fake ZaloPay: refund query mac mismatch")
+		}
+		if f.Get("m_refund_id") != "240201_"+testZaloPayAppID+"_R1" {
+			json.NewEncoder(w).Encode(zaloPayRefundQueryResponse{ReturnCode: 2, ReturnMessage: "refund not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(zaloPayRefundQueryResponse{ReturnCode: zaloPayProcessing, ReturnMessage: "processing"})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestZaloPayProvider_EndToEnd(t *testing.T) {
+	server := newFakeZaloPayServer(t)
+	defer server.Close()
+
+	provider := NewZaloPayProvider(testZaloPayAppID, testZaloPayKey1, testZaloPayKey2, server.URL, "http://shop.local/return", "http://shop.local/ipn")
+	ctx := context.Background()
+
+	initiated, err := provider.Initiate(ctx, InitiateRequest{TxnRef: "240201_7_1", Amount: 50000, OrderInfo: "Order 7"})
+	if err != nil {
+		t.Fatalf("Initiate() error = %v", err)
+	}
+	if initiated.PaymentURL != "https://qcgateway.zalopay.vn/openinapp?order=240201_7_1" {
+		t.Errorf("Initiate() PaymentURL = %v", initiated.PaymentURL)
+	}
+
+	// Simulate the server callback ZaloPay sends after a successful payment.
+	data := `{"app_id":2553,"app_trans_id":"240201_7_1","app_time":1706790000000,"amount":50000,"zp_trans_id":240201000000123,"server_time":1706790005000}`
+	body, _ := json.Marshal(zaloPayCallbackBody{Data: data, MAC: SignZaloPay(testZaloPayKey2, data), Type: 1})
+
+	result, err := provider.VerifyCallback(Callback{Body: body})
+	if err != nil {
+		t.Fatalf("VerifyCallback() error = %v", err)
+	}
+	if !result.Success || result.Amount != 50000 || result.TxnRef != "240201_7_1" || result.GatewayTransactionNo != "240201000000123" {
+		t.Errorf("VerifyCallback() = %+v", result)
+	}
+
+	// Callbacks signed with key1 instead of key2 must be rejected.
+	body, _ = json.Marshal(zaloPayCallbackBody{Data: data, MAC: SignZaloPay(testZaloPayKey1, data), Type: 1})
+	if _, err := provider.VerifyCallback(Callback{Body: body}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyCallback() with key1 MAC error = %v, want ErrInvalidSignature", err)
+	}
+
+	// The customer's redirect carries a key2 checksum over the query fields.
+	redirect := url.Values{}
+	redirect.Set("appid", testZaloPayAppID)
+	redirect.Set("apptransid", "240201_7_1")
+	redirect.Set("pmcid", "38")
+	redirect.Set("bankcode", "")
+	redirect.Set("amount", "50000")
+	redirect.Set("discountamount", "0")
+	redirect.Set("status", "1")
+	redirect.Set("checksum", SignZaloPay(testZaloPayKey2, testZaloPayAppID, "240201_7_1", "38", "", "50000", "0", "1"))
+	if result, err := provider.VerifyCallback(Callback{Params: redirect}); err != nil || !result.Success {
+		t.Errorf("VerifyCallback() redirect = %+v, %v", result, err)
+	}
+
+	status, err := provider.QueryStatus(ctx, QueryRequest{TxnRef: "240201_7_1"})
+	if err != nil {
+		t.Fatalf("QueryStatus() error = %v", err)
+	}
+	if status.Status != StatusPending {
+		t.Errorf("QueryStatus() status = %v, want %v", status.Status, StatusPending)
+	}
+
+	// Refund ids carry the date the refund was requested, in GMT+7.
+	requestedAt := time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)
+	refund, err := provider.Refund(ctx, RefundRequest{RequestID: "R1", GatewayTransactionNo: "240201000000123", Amount: 20000, Description: "Refund", RequestedAt: requestedAt})
+	if err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	if refund.Status != StatusCompleted || refund.TransactionNo != "190508000000017" {
+		t.Errorf("Refund() = %+v", refund)
+	}
+
+	refund, err = provider.QueryRefund(ctx, RefundQueryRequest{RequestID: "R1", RequestedAt: requestedAt})
+	if err != nil {
+		t.Fatalf("QueryRefund() error = %v", err)
+	}
+	if refund.Status != StatusPending {
+		t.Errorf("QueryRefund() status = %v, want %v", refund.Status, StatusPending)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"time"
+
+	"ecommerce-backend/internal/models"
+)
+
+//go:embed templates/*.html
+var emailTemplateFiles embed.FS
+
+var emailTemplates = template.Must(template.ParseFS(emailTemplateFiles, "templates/*.html"))
+
+// accountEmailData fills the HTML account email templates.
+type accountEmailData struct {
+	Subject   string
+	Name      string
+	Email     string
+	Link      string
+	Action    string
+	ExpiresIn string
+}
+
+// VerificationEmail asks user to confirm their address by following link,
+// which stops working after ttl.
+func VerificationEmail(user *models.User, link string, ttl time.Duration) (Email, error) {
+	data := accountEmailData{
+		Subject:   "Verify your email address",
+		Name:      displayName(user),
+		Email:     user.Email,
+		Link:      link,
+		Action:    "Verify email",
+		ExpiresIn: formatExpiry(ttl),
+	}
+
+	text := fmt.Sprintf(`Hello %s,
+
+Thanks for signing up. Please confirm that %s is your email address by opening this link:
+
+%s
+
+The link expires in %s. If you did not create an account, you can ignore this email.
+`, data.Name, data.Email, data.Link, data.ExpiresIn)
+
+	return renderAccountEmail(user.Email, "verify_email.html", text, data)
+}
+
+// PasswordResetEmail sends user a single-use link for choosing a new
+// password, which stops working after ttl.
+func PasswordResetEmail(user *models.User, link string, ttl time.Duration) (Email, error) {
+	data := accountEmailData{
+		Subject:   "Reset your password",
+		Name:      displayName(user),
+		Email:     user.Email,
+		Link:      link,
+		Action:    "Reset password",
+		ExpiresIn: formatExpiry(ttl),
+	}
+
+	text := fmt.Sprintf(`Hello %s,
+
+We received a request to reset the password for %s. Choose a new password by opening this link:
+
+%s
+
+The link expires in %s and can only be used once. If you did not ask to reset your password, you can ignore this email; your password will not change.
+`, data.Name, data.Email, data.Link, data.ExpiresIn)
+
+	return renderAccountEmail(user.Email, "reset_password.html", text, data)
+}
+
+func renderAccountEmail(to, name, text string, data accountEmailData) (Email, error) {
+	var html bytes.Buffer
+	if err := emailTemplates.ExecuteTemplate(&html, name, data); err != nil {
+		return Email{}, err
+	}
+	return Email{To: to, Subject: data.Subject, Text: text, HTML: html.String()}, nil
+}
+
+func displayName(user *models.User) string {
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	return user.Email
+}
+
+// formatExpiry writes ttl in whole hours, or minutes when shorter, for
+// "expires in" sentences.
+func formatExpiry(ttl time.Duration) string {
+	if ttl >= time.Hour {
+		return plural(int((ttl+time.Hour-1)/time.Hour), "hour")
+	}
+	return plural(int((ttl+time.Minute-1)/time.Minute), "minute")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+)
+
+func TestVerificationEmail(t *testing.T) {
+	user := &models.User{Email: "alice@example.com", FirstName: "Alice"}
+	email, err := VerificationEmail(user, "http://localhost:3000/verify-email?token=a&b", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("VerificationEmail: %v", err)
+	}
+
+	if email.To != "alice@example.com" {
+		t.Errorf("To = %q", email.To)
+	}
+	for _, want := range []string{"Hello Alice", "token=a&b", "24 hours"} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("Text missing %q:\n%s", want, email.Text)
+		}
+	}
+	for _, want := range []string{"Hello Alice", "token=a&amp;b", "Verify email", "24 hours"} {
+		if !strings.Contains(email.HTML, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+}
+
+func TestPasswordResetEmail(t *testing.T) {
+	user := &models.User{Email: "bob@example.com"}
+	email, err := PasswordResetEmail(user, "http://localhost:3000/reset-password?token=abc", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("PasswordResetEmail: %v", err)
+	}
+
+	for _, want := range []string{"Hello bob@example.com", "reset-password?token=abc", "30 minutes"} {
+		if !strings.Contains(email.Text, want) {
+			t.Errorf("Text missing %q:\n%s", want, email.Text)
+		}
+		if !strings.Contains(email.HTML, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+}
+
+func TestFormatExpiry(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{24 * time.Hour, "24 hours"},
+		{time.Hour, "1 hour"},
+		{90 * time.Minute, "2 hours"},
+		{30 * time.Minute, "30 minutes"},
+		{time.Minute, "1 minute"},
+	}
+	for _, tt := range tests {
+		if got := formatExpiry(tt.ttl); got != tt.want {
+			t.Errorf("formatExpiry(%v) = %q, want %q", tt.ttl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		expected string
+		err      error
+	}{
+		{"single", []string{ScopeOrdersRead}, "orders:read", nil},
+		{"sorted", []string{ScopeWebhooks, ScopeCatalogRead}, "catalog:read,webhooks", nil},
+		{"duplicates", []string{ScopeOrdersRead, " orders:read "}, "orders:read", nil},
+		{"unknown", []string{ScopeOrdersRead, "admin"}, "", ErrUnknownScope},
+		{"empty", nil, "", ErrNoScopes},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeScopes(tt.scopes)
+		if got != tt.expected || err != tt.err {
+			t.Errorf("%s: NormalizeScopes(%v) = %q, %v; want %q, %v", tt.name, tt.scopes, got, err, tt.expected, tt.err)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	scopes := ParseScopes("catalog:read,orders:write")
+	if !HasScope(scopes, ScopeOrdersWrite) {
+		t.Errorf("expected %q to be granted", ScopeOrdersWrite)
+	}
+	if HasScope(scopes, ScopeOrdersRead) {
+		t.Errorf("expected %q not to be granted", ScopeOrdersRead)
+	}
+	if HasScope(ParseScopes(""), ScopeCatalogRead) {
+		t.Error("expected an empty scope list to grant nothing")
+	}
+}
+
+func TestAPIKeyUsable(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name     string
+		key      models.PartnerAPIKey
+		expected bool
+	}{
+		{"active", models.PartnerAPIKey{}, true},
+		{"in grace period", models.PartnerAPIKey{ExpiresAt: &future}, true},
+		{"expired", models.PartnerAPIKey{ExpiresAt: &past}, false},
+		{"revoked", models.PartnerAPIKey{RevokedAt: &past}, false},
+		{"revoked before expiry", models.PartnerAPIKey{ExpiresAt: &future, RevokedAt: &past}, false},
+	}
+
+	for _, tt := range tests {
+		if got := APIKeyUsable(&tt.key, now); got != tt.expected {
+			t.Errorf("%s: APIKeyUsable = %v; want %v", tt.name, got, tt.expected)
+		}
+	}
+}
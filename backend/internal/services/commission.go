@@ -0,0 +1,229 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CommissionSplit divides a line's gross amount between the platform and the
+// partner. rate is the platform's commission in percent.
+func CommissionSplit(gross, rate float64) (commission, partnerAmount float64) {
+	commission = math.Round(gross * rate / 100)
+	return commission, gross - commission
+}
+
+// RecordOrderEarnings writes an earning entry for every delivered order item
+// sold by a partner other than the house account. An item counts as
+// delivered once its sub-order is, or once the whole order is for items
+// without a sub-order; lines reported out of stock never are. Items already
+// earned are skipped, so calling it again never pays a partner twice. Sandbox
+// orders earn nothing. Refunds completed before the items were earned are
+// reversed against the new entries straight away. It returns how many
+// earning entries were written.
+func RecordOrderEarnings(tx *gorm.DB, orderID uint) (int, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return 0, err
+	}
+	if order.IsTest {
+		return 0, nil
+	}
+
+	var earned []uint
+	if err := tx.Model(&models.PartnerLedgerEntry{}).
+		Where("order_id = ? AND type = ?", orderID, "earning").
+		Pluck("order_item_id", &earned).Error; err != nil {
+		return 0, err
+	}
+	skip := make(map[uint]bool, len(earned))
+	for _, id := range earned {
+		skip[id] = true
+	}
+
+	var delivered []uint
+	if err := tx.Model(&models.SubOrder{}).
+		Where("order_id = ? AND status = ?", orderID, "delivered").
+		Pluck("id", &delivered).Error; err != nil {
+		return 0, err
+	}
+	deliveredSubOrders := make(map[uint]bool, len(delivered))
+	for _, id := range delivered {
+		deliveredSubOrders[id] = true
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", orderID).
+		Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Find(&items).Error; err != nil {
+		return 0, err
+	}
+
+	partners := make(map[uint]*models.Partner)
+	var written []models.PartnerLedgerEntry
+	for i := range items {
+		item := &items[i]
+		partnerID := item.Product.PartnerID
+		if partnerID == 0 || skip[item.ID] || item.Status == "out_of_stock" {
+			continue
+		}
+		if item.SubOrderID != nil {
+			if !deliveredSubOrders[*item.SubOrderID] {
+				continue
+			}
+		} else if order.Status != "delivered" {
+			continue
+		}
+
+		partner, ok := partners[partnerID]
+		if !ok {
+			partner = &models.Partner{}
+			if err := tx.Unscoped().First(partner, partnerID).Error; err != nil {
+				return len(written), err
+			}
+			partners[partnerID] = partner
+		}
+		if partner.IsHouse {
+			continue
+		}
+
+		commission, partnerAmount := CommissionSplit(item.TotalPrice, partner.CommissionRate)
+		entry := models.PartnerLedgerEntry{
+			PartnerID:        partnerID,
+			OrderID:          &order.ID,
+			OrderItemID:      &item.ID,
+			Type:             "earning",
+			GrossAmount:      item.TotalPrice,
+			CommissionRate:   partner.CommissionRate,
+			CommissionAmount: commission,
+			PartnerAmount:    partnerAmount,
+			Currency:         order.Currency,
+			Description:      fmt.Sprintf("Order %s: %d x %s", order.OrderNumber, item.Quantity, item.Product.Name),
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return len(written), err
+		}
+		written = append(written, entry)
+	}
+	if len(written) == 0 || order.Total <= 0 {
+		return len(written), nil
+	}
+
+	var refunds []models.Refund
+	if err := tx.Where("order_id = ? AND status = ?", orderID, "completed").
+		Order("processed_at, id").Find(&refunds).Error; err != nil {
+		return len(written), err
+	}
+	var refunded float64
+	for i := range refunds {
+		refunded += refunds[i].Amount
+		if err := reverseEarnings(tx, &order, &refunds[i], written, refunded >= order.Total); err != nil {
+			return len(written), err
+		}
+	}
+	return len(written), nil
+}
+
+// ReverseOrderEarnings claws back partner earnings for a refund, in
+// proportion to the share of the order that was refunded. Once the order is
+// fully refunded whatever is left of each earning is reversed, so rounding
+// never leaves a partner with a residue. Earnings already reversed for refund
+// are skipped, so settling a refund twice never claws back twice.
+func ReverseOrderEarnings(tx *gorm.DB, refund *models.Refund) error {
+	var order models.Order
+	if err := tx.First(&order, refund.OrderID).Error; err != nil {
+		return err
+	}
+	if order.Total <= 0 {
+		return nil
+	}
+
+	var earnings []models.PartnerLedgerEntry
+	if err := tx.Where("order_id = ? AND type = ?", order.ID, "earning").Find(&earnings).Error; err != nil {
+		return err
+	}
+	if len(earnings) == 0 {
+		return nil
+	}
+
+	var refunded float64
+	if err := tx.Model(&models.Refund{}).
+		Where("order_id = ? AND status = ?", order.ID, "completed").
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+		return err
+	}
+	return reverseEarnings(tx, &order, refund, earnings, refunded >= order.Total)
+}
+
+// reverseEarnings writes refund's reversal of each earning: its share of the
+// order's total, or whatever is left of the earning once fullyRefunded.
+func reverseEarnings(tx *gorm.DB, order *models.Order, refund *models.Refund, earnings []models.PartnerLedgerEntry, fullyRefunded bool) error {
+	var done []uint
+	if err := tx.Model(&models.PartnerLedgerEntry{}).
+		Where("refund_id = ? AND type = ?", refund.ID, "reversal").
+		Pluck("order_item_id", &done).Error; err != nil {
+		return err
+	}
+	reversedItems := make(map[uint]bool, len(done))
+	for _, id := range done {
+		reversedItems[id] = true
+	}
+
+	fraction := refund.Amount / order.Total
+	for _, earning := range earnings {
+		if reversedItems[*earning.OrderItemID] {
+			continue
+		}
+
+		var reversed struct {
+			Gross      float64
+			Commission float64
+			Partner    float64
+		}
+		if err := tx.Model(&models.PartnerLedgerEntry{}).
+			Where("order_item_id = ? AND type = ?", *earning.OrderItemID, "reversal").
+			Select("COALESCE(SUM(gross_amount), 0) AS gross, COALESCE(SUM(commission_amount), 0) AS commission, COALESCE(SUM(partner_amount), 0) AS partner").
+			Scan(&reversed).Error; err != nil {
+			return err
+		}
+
+		// Reversal amounts are stored negative.
+		remainingGross := earning.GrossAmount + reversed.Gross
+		remainingCommission := earning.CommissionAmount + reversed.Commission
+		remainingPartner := earning.PartnerAmount + reversed.Partner
+		if remainingGross <= 0 {
+			continue
+		}
+
+		gross, commission, partnerAmount := remainingGross, remainingCommission, remainingPartner
+		if !fullyRefunded {
+			gross = math.Min(math.Round(earning.GrossAmount*fraction), remainingGross)
+			commission, partnerAmount = CommissionSplit(gross, earning.CommissionRate)
+		}
+		if gross <= 0 {
+			continue
+		}
+
+		entry := models.PartnerLedgerEntry{
+			PartnerID:        earning.PartnerID,
+			OrderID:          earning.OrderID,
+			OrderItemID:      earning.OrderItemID,
+			RefundID:         &refund.ID,
+			Type:             "reversal",
+			GrossAmount:      -gross,
+			CommissionRate:   earning.CommissionRate,
+			CommissionAmount: -commission,
+			PartnerAmount:    -partnerAmount,
+			Currency:         earning.Currency,
+			Description:      fmt.Sprintf("Refund %s for order %s", refund.RequestID, order.OrderNumber),
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestCommissionSplit(t *testing.T) {
+	tests := []struct {
+		name               string
+		gross              float64
+		rate               float64
+		expectedCommission float64
+		expectedPartner    float64
+	}{
+		{name: "No commission", gross: 100000, rate: 0, expectedCommission: 0, expectedPartner: 100000},
+		{name: "Ten percent", gross: 250000, rate: 10, expectedCommission: 25000, expectedPartner: 225000},
+		{name: "Fractional rate rounds commission", gross: 99999, rate: 12.5, expectedCommission: 12500, expectedPartner: 87499},
+		{name: "Full commission", gross: 50000, rate: 100, expectedCommission: 50000, expectedPartner: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commission, partner := CommissionSplit(tt.gross, tt.rate)
+			if commission != tt.expectedCommission || partner != tt.expectedPartner {
+				t.Errorf("CommissionSplit(%v, %v) = %v, %v; want %v, %v", tt.gross, tt.rate, commission, partner, tt.expectedCommission, tt.expectedPartner)
+			}
+			if commission+partner != tt.gross {
+				t.Errorf("split %v + %v does not add up to %v", commission, partner, tt.gross)
+			}
+		})
+	}
+}
+
+// createEarningOrder creates a delivered order with one line per price, all
+// sold by a partner charging 10% commission.
+func createEarningOrder(t *testing.T, db *gorm.DB, prices ...float64) models.Order {
+	t.Helper()
+	partner := models.Partner{Name: "Acme", Email: "acme@example.com", IsActive: true, CommissionRate: 10}
+	if err := db.Create(&partner).Error; err != nil {
+		t.Fatalf("creating partner: %v", err)
+	}
+
+	order := models.Order{OrderNumber: "ORD-1", UserID: 1, Status: "delivered", PaymentStatus: "paid", Currency: "VND"}
+	for i, price := range prices {
+		product := models.Product{Name: fmt.Sprintf("Product %d", i), Slug: fmt.Sprintf("product-%d", i), SKU: fmt.Sprintf("SKU-%d", i), Price: price, PartnerID: partner.ID}
+		db.Create(&product)
+		order.Items = append(order.Items, models.OrderItem{ProductID: product.ID, Quantity: 1, UnitPrice: price, TotalPrice: price})
+		order.Subtotal += price
+	}
+	order.Total = order.Subtotal
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	return order
+}
+
+func completeRefund(t *testing.T, db *gorm.DB, order models.Order, amount float64) models.Refund {
+	t.Helper()
+	now := time.Now()
+	refund := models.Refund{
+		PaymentID:   1,
+		OrderID:     order.ID,
+		Type:        "partial",
+		Status:      "completed",
+		Amount:      amount,
+		RequestID:   fmt.Sprintf("RF-%d-%d", order.ID, now.UnixNano()),
+		ProcessedAt: &now,
+	}
+	if err := db.Create(&refund).Error; err != nil {
+		t.Fatalf("creating refund: %v", err)
+	}
+	return refund
+}
+
+// netEarnings sums the ledger for each order item.
+func netEarnings(t *testing.T, db *gorm.DB, orderID uint) map[uint]models.PartnerLedgerEntry {
+	t.Helper()
+	var entries []models.PartnerLedgerEntry
+	if err := db.Where("order_id = ?", orderID).Find(&entries).Error; err != nil {
+		t.Fatalf("loading ledger: %v", err)
+	}
+	net := make(map[uint]models.PartnerLedgerEntry)
+	for _, entry := range entries {
+		sum := net[*entry.OrderItemID]
+		sum.GrossAmount += entry.GrossAmount
+		sum.CommissionAmount += entry.CommissionAmount
+		sum.PartnerAmount += entry.PartnerAmount
+		net[*entry.OrderItemID] = sum
+	}
+	return net
+}
+
+func countLedgerEntries(db *gorm.DB, orderID uint, entryType string) int64 {
+	var count int64
+	db.Model(&models.PartnerLedgerEntry{}).Where("order_id = ? AND type = ?", orderID, entryType).Count(&count)
+	return count
+}
+
+func TestRecordOrderEarnings_Idempotent(t *testing.T) {
+	db := testdb.Open(t)
+	order := createEarningOrder(t, db, 100000, 50000)
+
+	if written, err := RecordOrderEarnings(db, order.ID); err != nil || written != 2 {
+		t.Fatalf("RecordOrderEarnings() = %d, %v; want 2 entries", written, err)
+	}
+	if written, err := RecordOrderEarnings(db, order.ID); err != nil || written != 0 {
+		t.Errorf("RecordOrderEarnings() again = %d, %v; want 0 entries", written, err)
+	}
+	if got := countLedgerEntries(db, order.ID, "earning"); got != 2 {
+		t.Errorf("earning entries = %d; want 2", got)
+	}
+
+	for _, item := range order.Items {
+		earning := netEarnings(t, db, order.ID)[item.ID]
+		if earning.GrossAmount != item.TotalPrice || earning.CommissionAmount != item.TotalPrice/10 {
+			t.Errorf("item %d earning = %+v; want gross %v with 10%% commission", item.ID, earning, item.TotalPrice)
+		}
+	}
+}
+
+func TestReverseOrderEarnings_Idempotent(t *testing.T) {
+	db := testdb.Open(t)
+	order := createEarningOrder(t, db, 100000, 50000)
+	if _, err := RecordOrderEarnings(db, order.ID); err != nil {
+		t.Fatalf("RecordOrderEarnings: %v", err)
+	}
+
+	refund := completeRefund(t, db, order, 30000)
+	for i := 0; i < 2; i++ {
+		if err := ReverseOrderEarnings(db, &refund); err != nil {
+			t.Fatalf("ReverseOrderEarnings: %v", err)
+		}
+	}
+	if got := countLedgerEntries(db, order.ID, "reversal"); got != 2 {
+		t.Errorf("reversal entries = %d; want one per item", got)
+	}
+
+	net := netEarnings(t, db, order.ID)
+	if got := net[order.Items[0].ID].GrossAmount; got != 80000 {
+		t.Errorf("first item net gross = %v; want 80000", got)
+	}
+	if got := net[order.Items[1].ID].GrossAmount; got != 40000 {
+		t.Errorf("second item net gross = %v; want 40000", got)
+	}
+}
+
+func TestReverseOrderEarnings_FullyRefundedLeavesNoResidue(t *testing.T) {
+	db := testdb.Open(t)
+	// Uneven prices so the proportional reversals round.
+	order := createEarningOrder(t, db, 33333, 66668)
+	if _, err := RecordOrderEarnings(db, order.ID); err != nil {
+		t.Fatalf("RecordOrderEarnings: %v", err)
+	}
+
+	for _, amount := range []float64{33333, 33333, 33335} {
+		refund := completeRefund(t, db, order, amount)
+		if err := ReverseOrderEarnings(db, &refund); err != nil {
+			t.Fatalf("ReverseOrderEarnings: %v", err)
+		}
+	}
+
+	for itemID, net := range netEarnings(t, db, order.ID) {
+		if net.GrossAmount != 0 || net.CommissionAmount != 0 || net.PartnerAmount != 0 {
+			t.Errorf("item %d net after a full refund = %+v; want zero", itemID, net)
+		}
+	}
+}
+
+func TestRecordOrderEarnings_NetsEarlierRefunds(t *testing.T) {
+	db := testdb.Open(t)
+	order := createEarningOrder(t, db, 100000, 50000)
+
+	// Refunded before delivery, when there was nothing to reverse yet.
+	first := completeRefund(t, db, order, 75000)
+	if err := ReverseOrderEarnings(db, &first); err != nil {
+		t.Fatalf("ReverseOrderEarnings: %v", err)
+	}
+
+	if written, err := RecordOrderEarnings(db, order.ID); err != nil || written != 2 {
+		t.Fatalf("RecordOrderEarnings() = %d, %v; want 2 entries", written, err)
+	}
+	net := netEarnings(t, db, order.ID)
+	if got := net[order.Items[0].ID]; got.GrossAmount != 50000 || got.CommissionAmount != 5000 || got.PartnerAmount != 45000 {
+		t.Errorf("first item net = %+v; want half of the earning", got)
+	}
+	if got := net[order.Items[1].ID].GrossAmount; got != 25000 {
+		t.Errorf("second item net gross = %v; want 25000", got)
+	}
+
+	// Refunding the rest afterwards leaves nothing behind.
+	rest := completeRefund(t, db, order, 75000)
+	if err := ReverseOrderEarnings(db, &rest); err != nil {
+		t.Fatalf("ReverseOrderEarnings: %v", err)
+	}
+	for itemID, net := range netEarnings(t, db, order.ID) {
+		if net.GrossAmount != 0 || net.PartnerAmount != 0 {
+			t.Errorf("item %d net after a full refund = %+v; want zero", itemID, net)
+		}
+	}
+}
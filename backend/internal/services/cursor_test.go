@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 10, 30, 0, 123456000, time.UTC)
+
+	gotTime, gotID, err := DecodeCursor(EncodeCursor(createdAt, 42))
+	if err != nil {
+		t.Fatalf("DecodeCursor returned error: %v", err)
+	}
+	if !gotTime.Equal(createdAt) || gotID != 42 {
+		t.Errorf("DecodeCursor = %v, %d; want %v, 42", gotTime, gotID, createdAt)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "MTIz", "YWJjOjQy", "MTIzOmFiYw"} {
+		if _, _, err := DecodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) error = %v; want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
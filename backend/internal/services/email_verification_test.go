@@ -0,0 +1,38 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailVerifier(t *testing.T) {
+	verifier := NewEmailVerifier("jwt-secret", 24*time.Hour)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	token := verifier.Token(42, "Alice@Example.com", now)
+
+	if id, err := verifier.UserID(token); err != nil || id != 42 {
+		t.Errorf("UserID = %d, %v; want 42", id, err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		email string
+		at    time.Time
+		want  error
+	}{
+		{"valid", token, "Alice@Example.com", now.Add(time.Hour), nil},
+		{"email case ignored", token, "alice@example.com", now, nil},
+		{"expired", token, "Alice@Example.com", now.Add(24 * time.Hour), ErrInvalidVerificationToken},
+		{"email changed", token, "bob@example.com", now, ErrInvalidVerificationToken},
+		{"other user", strings.Replace(token, "42.", "43.", 1), "Alice@Example.com", now, ErrInvalidVerificationToken},
+		{"other secret", NewEmailVerifier("other", 24*time.Hour).Token(42, "Alice@Example.com", now), "Alice@Example.com", now, ErrInvalidVerificationToken},
+		{"malformed", "not-a-token", "Alice@Example.com", now, ErrInvalidVerificationToken},
+	}
+	for _, tt := range tests {
+		if err := verifier.Check(tt.token, tt.email, tt.at); err != tt.want {
+			t.Errorf("%s: Check = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
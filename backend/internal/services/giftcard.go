@@ -0,0 +1,35 @@
+package services
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// giftCardAlphabet leaves out 0/O and 1/I so codes survive being read aloud
+// or typed from a printed card.
+const (
+	giftCardAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	giftCardCodeLength = 16
+)
+
+// NewGiftCardCode returns a random 16 character gift card code.
+func NewGiftCardCode() string {
+	bytes := make([]byte, giftCardCodeLength)
+	rand.Read(bytes)
+	for i, b := range bytes {
+		bytes[i] = giftCardAlphabet[int(b)%len(giftCardAlphabet)]
+	}
+	return string(bytes)
+}
+
+// NormalizeGiftCardCode turns a code as typed by a customer, e.g.
+// "abcd-efgh jkmn-pqrs", into the stored form.
+func NormalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewGiftCardCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code := NewGiftCardCode()
+		if len(code) != giftCardCodeLength {
+			t.Fatalf("code %q has length %d, want %d", code, len(code), giftCardCodeLength)
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(giftCardAlphabet, r) {
+				t.Fatalf("code %q contains %q outside the alphabet", code, r)
+			}
+		}
+		if seen[code] {
+			t.Fatalf("code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeGiftCardCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name:     "Stored form",
+			code:     "ABCDEFGHJKMNPQRS",
+			expected: "ABCDEFGHJKMNPQRS",
+		},
+		{
+			name:     "Dashes and lowercase",
+			code:     "abcd-efgh-jkmn-pqrs",
+			expected: "ABCDEFGHJKMNPQRS",
+		},
+		{
+			name:     "Spaces",
+			code:     "ABCD EFGH JKMN PQRS",
+			expected: "ABCDEFGHJKMNPQRS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeGiftCardCode(tt.code); got != tt.expected {
+				t.Errorf("NormalizeGiftCardCode(%q) = %q, want %q", tt.code, got, tt.expected)
+			}
+		})
+	}
+}
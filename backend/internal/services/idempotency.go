@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRecord is what is kept for an idempotency key: the hash of the
+// request that claimed it and, once the handler finished, its response.
+type IdempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Completed   bool   `json:"completed"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// IdempotencyStore persists idempotency records.
+type IdempotencyStore interface {
+	// Reserve claims key for record. When the key is already taken it returns
+	// the existing record and leaves it untouched.
+	Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error)
+	// Save stores the completed record for a key previously reserved.
+	Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release frees a reserved key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+type RedisIdempotencyStore struct {
+	redis *RedisService
+}
+
+func NewRedisIdempotencyStore(redisService *RedisService) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{redis: redisService}
+}
+
+func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error) {
+	// Retry once in case the existing key expires between SETNX and GET.
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := s.redis.SetNX(ctx, key, record, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		var existing IdempotencyRecord
+		err = s.redis.Get(ctx, key, &existing)
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+	return nil, errors.New("idempotency key could not be reserved")
+}
+
+func (s *RedisIdempotencyStore) Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	return s.redis.Set(ctx, key, record, ttl)
+}
+
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.redis.Delete(ctx, key)
+}
+
+// DBIdempotencyStore keeps records in the idempotency_keys table.
+type DBIdempotencyStore struct {
+	db *gorm.DB
+}
+
+func NewDBIdempotencyStore(db *gorm.DB) *DBIdempotencyStore {
+	return &DBIdempotencyStore{db: db}
+}
+
+func (s *DBIdempotencyStore) Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error) {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("key = ? AND expires_at < ?", key, time.Now()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	row := models.IdempotencyKey{
+		Key:         key,
+		RequestHash: record.RequestHash,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Where("key = ?", key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &IdempotencyRecord{
+		RequestHash: existing.RequestHash,
+		Completed:   existing.Completed,
+		StatusCode:  existing.StatusCode,
+		ContentType: existing.ContentType,
+		Body:        []byte(existing.ResponseBody),
+	}, nil
+}
+
+func (s *DBIdempotencyStore) Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Model(&models.IdempotencyKey{}).Where("key = ?", key).Updates(map[string]interface{}{
+		"completed":     true,
+		"status_code":   record.StatusCode,
+		"content_type":  record.ContentType,
+		"response_body": string(record.Body),
+		"expires_at":    time.Now().Add(ttl),
+	}).Error
+}
+
+func (s *DBIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
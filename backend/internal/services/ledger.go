@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// System accounts store credit enters the ledger from or leaves it to.
+const (
+	AccountGiftCardsIssued = "system:gift_cards_issued"
+	AccountGiftCardSales   = "system:gift_card_sales"
+	AccountPromotions      = "system:promotions"
+	AccountRefunds         = "system:refunds"
+	AccountSales           = "system:sales"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient store credit")
+	ErrInvalidAmount     = errors.New("amount must be greater than zero")
+)
+
+// Transfer describes one movement of store credit between two accounts.
+type Transfer struct {
+	From        *models.WalletAccount
+	To          *models.WalletAccount
+	Amount      float64
+	Type        string
+	Reference   string
+	Description string
+	CreatedBy   *uint
+}
+
+// UserWalletCode and GiftCardAccountCode name the accounts behind a customer
+// wallet and a gift card.
+func UserWalletCode(userID uint) string { return fmt.Sprintf("user:%d", userID) }
+
+func GiftCardAccountCode(code string) string { return "gift_card:" + code }
+
+// UserWallet returns the wallet account for userID, creating it on first use.
+// The row is locked until tx ends.
+func UserWallet(tx *gorm.DB, userID uint) (*models.WalletAccount, error) {
+	id := userID
+	return lockAccount(tx, models.WalletAccount{Code: UserWalletCode(userID), Type: "user", UserID: &id, Currency: "VND"})
+}
+
+// SystemAccount returns the system account named code, creating it on first
+// use. System rows are not locked because their balance is never stored.
+func SystemAccount(tx *gorm.DB, code string) (*models.WalletAccount, error) {
+	account := models.WalletAccount{Code: code, Type: "system", Currency: "VND"}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("code = ?", code).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// LockAccount locks and returns an existing account.
+func LockAccount(tx *gorm.DB, id uint) (*models.WalletAccount, error) {
+	var account models.WalletAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func lockAccount(tx *gorm.DB, account models.WalletAccount) (*models.WalletAccount, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+	var locked models.WalletAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", account.Code).First(&locked).Error; err != nil {
+		return nil, err
+	}
+	return &locked, nil
+}
+
+// PostTransfer records t as a balanced transaction with one debit and one
+// credit entry and updates the stored balances. Non-system accounts must be
+// locked by the caller, and the source cannot go below zero. It must run
+// inside tx so the entries and balances commit together.
+func PostTransfer(tx *gorm.DB, t Transfer) (*models.LedgerTransaction, error) {
+	amount := math.Round(t.Amount)
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if t.From.Type != "system" && t.From.Balance < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	txn := models.LedgerTransaction{
+		Type:        t.Type,
+		Reference:   t.Reference,
+		Description: t.Description,
+		CreatedBy:   t.CreatedBy,
+	}
+	if err := tx.Create(&txn).Error; err != nil {
+		return nil, err
+	}
+
+	for _, side := range []struct {
+		account *models.WalletAccount
+		amount  float64
+	}{
+		{t.From, -amount},
+		{t.To, amount},
+	} {
+		entry := models.LedgerEntry{TransactionID: txn.ID, AccountID: side.account.ID, Amount: side.amount}
+		if side.account.Type != "system" {
+			side.account.Balance += side.amount
+			entry.BalanceAfter = side.account.Balance
+			if err := tx.Model(side.account).Update("balance", side.account.Balance).Error; err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		txn.Entries = append(txn.Entries, entry)
+	}
+
+	return &txn, nil
+}
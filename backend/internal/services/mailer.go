@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// Email is one outgoing message. HTML is optional; when it is set the
+// message carries both versions and mail clients pick the one they can show.
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(email Email) error
+}
+
+// SMTPMailer sends mail through an SMTP relay, authenticating with PLAIN auth
+// when a user is configured. A local stand-in such as MailHog works without
+// a user.
+type SMTPMailer struct {
+	host     string
+	port     string
+	user     string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host, port, user, password, from string) *SMTPMailer {
+	return &SMTPMailer{host: host, port: port, user: user, password: password, from: from}
+}
+
+func (m *SMTPMailer) Send(email Email) error {
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, m.host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.host, m.port), auth, m.from, []string{email.To}, buildMessage(m.from, email))
+}
+
+// LogMailer writes mail to the log instead of sending it. It is used when no
+// SMTP host is configured, so development setups work without a relay.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the plain-text version only.
+func (m *LogMailer) Send(email Email) error {
+	log.Printf("📧 Email to %s: %s\n%s", email.To, email.Subject, email.Text)
+	return nil
+}
+
+func buildMessage(from string, email Email) []byte {
+	// Header values come from our own templates and stored addresses; strip
+	// line breaks anyway so they cannot inject headers.
+	clean := strings.NewReplacer("\r", "", "\n", "").Replace
+	crlf := strings.NewReplacer("\r\n", "\r\n", "\n", "\r\n").Replace
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", clean(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean(email.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", clean(email.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if email.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(crlf(email.Text))
+		return b.Bytes()
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", email.Text},
+		{"text/html; charset=UTF-8", email.HTML},
+	} {
+		w, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		w.Write([]byte(crlf(part.content)))
+	}
+	parts.Close()
+
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n", parts.Boundary())
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes()
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+)
+
+func TestBuildMessage(t *testing.T) {
+	msg := string(buildMessage("shop@example.com", Email{
+		To:      "buyer@example.com",
+		Subject: "Hello\r\nBcc: attacker@example.com",
+		Text:    "line one\nline two",
+	}))
+
+	header, body, found := strings.Cut(msg, "\r\n\r\n")
+	if !found {
+		t.Fatalf("expected a blank line between headers and body, got %q", msg)
+	}
+	if !strings.Contains(header, "Subject: HelloBcc: attacker@example.com\r\n") {
+		t.Errorf("expected line breaks to be stripped from the subject, got %q", header)
+	}
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("subject injected a header: %q", header)
+	}
+	if body != "line one\r\nline two" {
+		t.Errorf("body = %q; want CRLF line endings", body)
+	}
+}
+
+func TestBuildMessageWithHTML(t *testing.T) {
+	msg := string(buildMessage("shop@example.com", Email{
+		To:      "buyer@example.com",
+		Subject: "Hello",
+		Text:    "plain\nversion",
+		HTML:    "<p>html version</p>",
+	}))
+
+	header, body, found := strings.Cut(msg, "\r\n\r\n")
+	if !found {
+		t.Fatalf("expected a blank line between headers and body, got %q", msg)
+	}
+	var contentType string
+	for _, line := range strings.Split(header, "\r\n") {
+		if value, ok := strings.CutPrefix(line, "Content-Type: "); ok {
+			contentType = value
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q; want multipart/alternative", contentType)
+	}
+
+	expected := []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", "plain\r\nversion"},
+		{"text/html; charset=UTF-8", "<p>html version</p>"},
+	}
+	reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	for _, want := range expected {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		content, _ := io.ReadAll(part)
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q; want %q", got, want.contentType)
+		}
+		if string(content) != want.content {
+			t.Errorf("part content = %q; want %q", content, want.content)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected exactly two parts, got error %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Product moderation statuses. Only approved products are listed publicly.
+const (
+	ModerationDraft         = "draft"
+	ModerationPendingReview = "pending_review"
+	ModerationApproved      = "approved"
+	ModerationRejected      = "rejected"
+	ModerationSuspended     = "suspended"
+)
+
+var ErrInvalidModerationTransition = errors.New("invalid moderation status transition")
+
+var moderationTransitions = map[string][]string{
+	ModerationDraft:         {ModerationPendingReview},
+	ModerationPendingReview: {ModerationApproved, ModerationRejected},
+	ModerationApproved:      {ModerationPendingReview, ModerationSuspended},
+	ModerationRejected:      {ModerationPendingReview},
+	ModerationSuspended:     {ModerationApproved},
+}
+
+// CanModerate reports whether a product in status from may move to to.
+func CanModerate(from, to string) bool {
+	for _, next := range moderationTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ModerationActor identifies who changed a product's moderation status.
+type ModerationActor struct {
+	Type string // admin, partner
+	ID   uint
+}
+
+// ModerateProduct moves product to status, keeps IsActive in step with it
+// and records the change in the product's moderation history.
+func ModerateProduct(tx *gorm.DB, product *models.Product, status, note string, actor ModerationActor) error {
+	from := product.ModerationStatus
+	if !CanModerate(from, status) {
+		return ErrInvalidModerationTransition
+	}
+
+	product.ModerationStatus = status
+	product.IsActive = status == ModerationApproved
+	// Partner resubmissions keep the reviewer's last note visible.
+	if actor.Type == "admin" {
+		product.ModerationNote = note
+	}
+	if err := tx.Model(product).Select("moderation_status", "is_active", "moderation_note").Updates(product).Error; err != nil {
+		return err
+	}
+
+	return RecordModeration(tx, product.ID, from, status, note, actor)
+}
+
+// SandboxApprovalNote is recorded when a test product skips review.
+const SandboxApprovalNote = "Sandbox products are approved automatically"
+
+// SubmitForReview moves product to pending review. Test products have no
+// reviewer, so they are approved straight away.
+func SubmitForReview(tx *gorm.DB, product *models.Product, note string, actor ModerationActor) error {
+	if err := ModerateProduct(tx, product, ModerationPendingReview, note, actor); err != nil {
+		return err
+	}
+	if !product.IsTest {
+		return nil
+	}
+	return ModerateProduct(tx, product, ModerationApproved, SandboxApprovalNote, actor)
+}
+
+// RecordModeration appends a step to a product's moderation history. New
+// products are recorded with an empty from status.
+func RecordModeration(tx *gorm.DB, productID uint, from, to, note string, actor ModerationActor) error {
+	return tx.Create(&models.ProductModeration{
+		ProductID:  productID,
+		FromStatus: from,
+		ToStatus:   to,
+		Note:       note,
+		ActorType:  actor.Type,
+		ActorID:    actor.ID,
+	}).Error
+}
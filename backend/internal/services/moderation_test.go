@@ -0,0 +1,29 @@
+package services
+
+import "testing"
+
+func TestCanModerate(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expected bool
+	}{
+		{ModerationDraft, ModerationPendingReview, true},
+		{ModerationDraft, ModerationApproved, false},
+		{ModerationPendingReview, ModerationApproved, true},
+		{ModerationPendingReview, ModerationRejected, true},
+		{ModerationApproved, ModerationPendingReview, true},
+		{ModerationApproved, ModerationSuspended, true},
+		{ModerationApproved, ModerationRejected, false},
+		{ModerationRejected, ModerationPendingReview, true},
+		{ModerationRejected, ModerationApproved, false},
+		{ModerationSuspended, ModerationApproved, true},
+		{ModerationSuspended, ModerationPendingReview, false},
+		{"", ModerationApproved, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanModerate(tt.from, tt.to); got != tt.expected {
+			t.Errorf("CanModerate(%q, %q) = %v; want %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
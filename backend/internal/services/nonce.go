@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// NonceStore remembers nonces of signed requests so they cannot be replayed.
+type NonceStore interface {
+	// Claim records key for ttl. It returns false when key was already seen.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type RedisNonceStore struct {
+	redis *RedisService
+}
+
+func NewRedisNonceStore(redisService *RedisService) *RedisNonceStore {
+	return &RedisNonceStore{redis: redisService}
+}
+
+func (s *RedisNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.redis.SetNX(ctx, key, 1, ttl)
+}
+
+// MemoryNonceStore is the NonceStore used when Redis is not configured.
+type MemoryNonceStore struct {
+	nonces *ttlSet
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: newTTLSet()}
+}
+
+func (s *MemoryNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.nonces.addNew(key, ttl), nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+	ctx := context.Background()
+
+	if fresh, _ := store.Claim(ctx, "nonce-1", time.Minute); !fresh {
+		t.Error("first claim was rejected")
+	}
+	if fresh, _ := store.Claim(ctx, "nonce-1", time.Minute); fresh {
+		t.Error("replayed nonce was accepted")
+	}
+
+	if fresh, _ := store.Claim(ctx, "nonce-2", -time.Second); !fresh {
+		t.Error("first claim of nonce-2 was rejected")
+	}
+	if fresh, _ := store.Claim(ctx, "nonce-2", time.Minute); !fresh {
+		t.Error("expired nonce was not forgotten")
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// HousePartnerEmail identifies the partner account that owns the store's own
+// catalogue.
+const HousePartnerEmail = "house@partners.internal"
+
+// HousePartner returns the house partner, creating it on first use. It has
+// no API keys, so it can never authenticate against the partner API.
+func HousePartner(db *gorm.DB) (*models.Partner, error) {
+	partner := models.Partner{
+		Name:    "House",
+		Email:   HousePartnerEmail,
+		IsHouse: true,
+	}
+	if err := db.Where(models.Partner{Email: HousePartnerEmail}).FirstOrCreate(&partner).Error; err != nil {
+		return nil, err
+	}
+	return &partner, nil
+}
+
+// SandboxCustomerEmail identifies the customer account that places partner
+// sandbox orders.
+const SandboxCustomerEmail = "sandbox@customers.internal"
+
+// SandboxCustomer returns the sandbox customer, creating it on first use. It
+// has no password, so nobody can sign in as it.
+func SandboxCustomer(db *gorm.DB) (*models.User, error) {
+	user := models.User{
+		Email:     SandboxCustomerEmail,
+		FirstName: "Sandbox",
+		LastName:  "Customer",
+		Role:      "customer",
+	}
+	if err := db.Where(models.User{Email: SandboxCustomerEmail}).FirstOrCreate(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Partner application statuses.
+const (
+	ApplicationPending  = "pending"
+	ApplicationApproved = "approved"
+	ApplicationRejected = "rejected"
+)
+
+// PartnerCredentials are the secrets issued to a new partner. Only their
+// encrypted forms are stored, so they are shown or sent exactly once.
+type PartnerCredentials struct {
+	Key           *models.PartnerAPIKey
+	SecretKey     string
+	WebhookSecret string
+}
+
+// OnboardPartner creates partner with a webhook signing secret and a first
+// "Default" API key with every scope.
+func OnboardPartner(tx *gorm.DB, secrets *SecretBox, partner *models.Partner) (*PartnerCredentials, error) {
+	webhookSecret := randomHex(64)
+	encrypted, err := secrets.Encrypt(webhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	partner.EncryptedSecret = encrypted
+	if err := tx.Create(partner).Error; err != nil {
+		return nil, err
+	}
+
+	scopes, _ := NormalizeScopes(AllPartnerScopes)
+	key, secretKey, err := IssueAPIKey(tx, secrets, partner.ID, "Default", scopes, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return &PartnerCredentials{Key: key, SecretKey: secretKey, WebhookSecret: webhookSecret}, nil
+}
+
+// PartnerApprovedEmail is sent when an application is approved and carries
+// the partner's first credentials.
+func PartnerApprovedEmail(partner *models.Partner, credentials *PartnerCredentials) (subject, body string) {
+	subject = "Your partner application has been approved"
+	body = fmt.Sprintf(`Hello %s,
+
+Your application to sell as %s has been approved.
+
+Your API credentials:
+
+  API key:        %s
+  Secret key:     %s
+  Webhook secret: %s
+
+Store these somewhere safe. The secrets are not stored in readable form and
+cannot be sent again; if they are lost, ask us to issue a new key, or rotate
+the key yourself through the partner API.
+
+Commission rate: %g%%
+`, partner.ContactName, partner.Name, credentials.Key.APIKey, credentials.SecretKey, credentials.WebhookSecret, partner.CommissionRate)
+	return subject, body
+}
+
+// PartnerRejectedEmail is sent when an application is rejected.
+func PartnerRejectedEmail(application *models.PartnerApplication) (subject, body string) {
+	subject = "Your partner application"
+	body = fmt.Sprintf(`Hello %s,
+
+Thank you for applying to sell as %s. We are unable to approve your
+application at this time.
+
+Reason: %s
+`, application.ContactName, application.BusinessName, application.ReviewNote)
+	return subject, body
+}
+
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
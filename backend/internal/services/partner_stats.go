@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Statistics bucket sizes.
+const (
+	StatsIntervalDay   = "day"
+	StatsIntervalWeek  = "week"
+	StatsIntervalMonth = "month"
+)
+
+// PartnerStatsAggregate names the watermark of PartnerProductDailyStat.
+const PartnerStatsAggregate = "partner_product_daily_stats"
+
+const (
+	// partnerStatsOverlap re-reads changes from a little before the watermark,
+	// so rows committed late by transactions still open during the last
+	// refresh are not missed. Recomputing a day is idempotent.
+	partnerStatsOverlap = 5 * time.Minute
+	// partnerStatsLockKey is the advisory lock that keeps two instances from
+	// refreshing at once.
+	partnerStatsLockKey = 45001
+)
+
+// soldPaymentStatuses are the order payment statuses that count as a sale.
+// Refunded orders still count; their refunds show up in the return rate.
+var soldPaymentStatuses = []string{"paid", "partially_refunded", "refunded"}
+
+// changedPartnerDaysSQL finds every partner day whose statistics may have
+// changed since a point in time: orders that were updated or deleted,
+// sub-orders that were updated (cancelled, or lines reported out of stock)
+// and reviews written or edited.
+const changedPartnerDaysSQL = `
+SELECT COALESCE(so.partner_id, p.partner_id) AS partner_id, (o.created_at AT TIME ZONE 'UTC')::date AS day
+FROM orders o
+JOIN order_items oi ON oi.order_id = o.id
+JOIN products p ON p.id = oi.product_id
+LEFT JOIN sub_orders so ON so.id = oi.sub_order_id
+WHERE o.updated_at > @since OR o.deleted_at > @since
+UNION
+SELECT so.partner_id, (o.created_at AT TIME ZONE 'UTC')::date
+FROM sub_orders so
+JOIN orders o ON o.id = so.order_id
+WHERE so.updated_at > @since
+UNION
+SELECT p.partner_id, (r.created_at AT TIME ZONE 'UTC')::date
+FROM product_reviews r
+JOIN products p ON p.id = r.product_id
+WHERE r.updated_at > @since`
+
+// partnerDayStatsSQL recomputes one partner's rows for one day from orders
+// and reviews. A line's refunded amount is its share of the order's
+// completed refunds, as with ReverseOrderEarnings. Sandbox orders and test
+// products are left out.
+const partnerDayStatsSQL = `
+INSERT INTO partner_product_daily_stats
+	(partner_id, product_id, day, orders, units_sold, revenue, refunded_amount, review_count, rating_sum, updated_at)
+SELECT partner_id, product_id, CAST(@day AS date), SUM(orders), SUM(units_sold), SUM(revenue), SUM(refunded_amount), SUM(review_count), SUM(rating_sum), NOW()
+FROM (
+	SELECT COALESCE(so.partner_id, p.partner_id) AS partner_id, oi.product_id,
+		COUNT(DISTINCT o.id) AS orders,
+		SUM(oi.quantity) AS units_sold,
+		SUM(oi.total_price) AS revenue,
+		SUM(oi.total_price * LEAST(COALESCE(
+			(SELECT SUM(r.amount) FROM refunds r WHERE r.order_id = o.id AND r.status = 'completed') / NULLIF(o.total, 0), 0), 1)) AS refunded_amount,
+		0 AS review_count,
+		0 AS rating_sum
+	FROM order_items oi
+	JOIN orders o ON o.id = oi.order_id
+	JOIN products p ON p.id = oi.product_id
+	LEFT JOIN sub_orders so ON so.id = oi.sub_order_id
+	WHERE COALESCE(so.partner_id, p.partner_id) = @partner
+		AND o.created_at >= @from AND o.created_at < @to
+		AND o.deleted_at IS NULL
+		AND o.is_test = false
+		AND o.status <> 'cancelled'
+		AND o.payment_status IN @sold
+		AND oi.status <> 'out_of_stock'
+		AND (so.id IS NULL OR so.status <> 'cancelled')
+	GROUP BY 1, 2
+	UNION ALL
+	SELECT p.partner_id, r.product_id, 0, 0, 0, 0, COUNT(*), SUM(r.rating)
+	FROM product_reviews r
+	JOIN products p ON p.id = r.product_id
+	WHERE p.partner_id = @partner AND p.is_test = false AND r.created_at >= @from AND r.created_at < @to
+	GROUP BY 1, 2
+) s
+GROUP BY partner_id, product_id`
+
+// RunPartnerStatsRefresher keeps partner statistics up to date, refreshing
+// every interval until ctx is cancelled.
+func RunPartnerStatsRefresher(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if days, err := RefreshPartnerStats(db); err != nil {
+			log.Printf("❌ Partner stats refresh failed: %v", err)
+		} else if days > 0 {
+			log.Printf("📊 Refreshed partner stats for %d partner days", days)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshPartnerStats recomputes PartnerProductDailyStat for every partner
+// day touched since the last refresh; the first run backfills everything.
+// It returns how many partner days were recomputed, and does nothing if
+// another instance is already refreshing.
+func RefreshPartnerStats(db *gorm.DB) (int, error) {
+	started := time.Now()
+	refreshed := 0
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", partnerStatsLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var watermark models.AggregateWatermark
+		if err := tx.Where("name = ?", PartnerStatsAggregate).Limit(1).Find(&watermark).Error; err != nil {
+			return err
+		}
+		var since time.Time
+		if !watermark.Through.IsZero() {
+			since = watermark.Through.Add(-partnerStatsOverlap)
+		}
+
+		var days []struct {
+			PartnerID uint
+			Day       time.Time
+		}
+		if err := tx.Raw(changedPartnerDaysSQL, map[string]interface{}{"since": since}).Scan(&days).Error; err != nil {
+			return err
+		}
+
+		for _, d := range days {
+			if err := refreshPartnerDay(tx, d.PartnerID, d.Day); err != nil {
+				return err
+			}
+		}
+		refreshed = len(days)
+
+		watermark.Name = PartnerStatsAggregate
+		watermark.Through = started
+		return tx.Save(&watermark).Error
+	})
+	return refreshed, err
+}
+
+func refreshPartnerDay(tx *gorm.DB, partnerID uint, day time.Time) error {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if err := tx.Where("partner_id = ? AND day = ?", partnerID, from).Delete(&models.PartnerProductDailyStat{}).Error; err != nil {
+		return err
+	}
+	return tx.Exec(partnerDayStatsSQL, map[string]interface{}{
+		"day":     from,
+		"partner": partnerID,
+		"from":    from,
+		"to":      from.AddDate(0, 0, 1),
+		"sold":    soldPaymentStatuses,
+	}).Error
+}
+
+// IsStatsInterval reports whether interval is a supported bucket size.
+func IsStatsInterval(interval string) bool {
+	return interval == StatsIntervalDay || interval == StatsIntervalWeek || interval == StatsIntervalMonth
+}
+
+// StatsPeriodStart returns the start of the bucket holding day. Weeks start
+// on Monday.
+func StatsPeriodStart(day time.Time, interval string) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case StatsIntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case StatsIntervalMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// StatsPeriods lists the start of every bucket between from and to
+// inclusive, so periods without sales still appear in a series.
+func StatsPeriods(from, to time.Time, interval string) []time.Time {
+	var periods []time.Time
+	end := StatsPeriodStart(to, interval)
+	for period := StatsPeriodStart(from, interval); !period.After(end); {
+		periods = append(periods, period)
+		switch interval {
+		case StatsIntervalWeek:
+			period = period.AddDate(0, 0, 7)
+		case StatsIntervalMonth:
+			period = period.AddDate(0, 1, 0)
+		default:
+			period = period.AddDate(0, 0, 1)
+		}
+	}
+	return periods
+}
+
+// ReturnRate is the share of revenue that was refunded.
+func ReturnRate(refunded, revenue float64) float64 {
+	if revenue <= 0 {
+		return 0
+	}
+	return refunded / revenue
+}
+
+// AverageRating returns the mean of count ratings summing to sum.
+func AverageRating(sum, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(sum) / float64(count)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func statsDate(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestStatsPeriodStart(t *testing.T) {
+	tests := []struct {
+		day, interval, expected string
+	}{
+		{"2024-03-13", StatsIntervalDay, "2024-03-13"},
+		{"2024-03-13", StatsIntervalWeek, "2024-03-11"}, // Wednesday
+		{"2024-03-11", StatsIntervalWeek, "2024-03-11"}, // Monday
+		{"2024-03-17", StatsIntervalWeek, "2024-03-11"}, // Sunday
+		{"2024-03-13", StatsIntervalMonth, "2024-03-01"},
+	}
+
+	for _, tt := range tests {
+		got := StatsPeriodStart(statsDate(tt.day), tt.interval)
+		if !got.Equal(statsDate(tt.expected)) {
+			t.Errorf("StatsPeriodStart(%s, %s) = %s; want %s", tt.day, tt.interval, got.Format("2006-01-02"), tt.expected)
+		}
+	}
+}
+
+func TestStatsPeriods(t *testing.T) {
+	tests := []struct {
+		from, to, interval string
+		expected           []string
+	}{
+		{"2024-01-30", "2024-02-01", StatsIntervalDay, []string{"2024-01-30", "2024-01-31", "2024-02-01"}},
+		{"2024-03-13", "2024-03-25", StatsIntervalWeek, []string{"2024-03-11", "2024-03-18", "2024-03-25"}},
+		{"2024-01-31", "2024-03-01", StatsIntervalMonth, []string{"2024-01-01", "2024-02-01", "2024-03-01"}},
+		{"2024-05-05", "2024-05-05", StatsIntervalDay, []string{"2024-05-05"}},
+	}
+
+	for _, tt := range tests {
+		got := StatsPeriods(statsDate(tt.from), statsDate(tt.to), tt.interval)
+		if len(got) != len(tt.expected) {
+			t.Errorf("StatsPeriods(%s, %s, %s) returned %d periods; want %d", tt.from, tt.to, tt.interval, len(got), len(tt.expected))
+			continue
+		}
+		for i, period := range got {
+			if !period.Equal(statsDate(tt.expected[i])) {
+				t.Errorf("StatsPeriods(%s, %s, %s)[%d] = %s; want %s", tt.from, tt.to, tt.interval, i, period.Format("2006-01-02"), tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestStatsRates(t *testing.T) {
+	if got := ReturnRate(25000, 100000); got != 0.25 {
+		t.Errorf("ReturnRate = %v; want 0.25", got)
+	}
+	if got := ReturnRate(0, 0); got != 0 {
+		t.Errorf("ReturnRate with no revenue = %v; want 0", got)
+	}
+	if got := AverageRating(9, 2); got != 4.5 {
+		t.Errorf("AverageRating = %v; want 4.5", got)
+	}
+	if got := AverageRating(0, 0); got != 0 {
+		t.Errorf("AverageRating with no reviews = %v; want 0", got)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// hashToken returns the form a bearer token is stored in: its hex SHA-256.
+// The tokens are long random strings, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssuePasswordResetToken creates a reset token for userID valid for ttl and
+// returns it. Earlier unused tokens for the user stop working, so only the
+// most recent email's link can be used.
+func IssuePasswordResetToken(tx *gorm.DB, userID uint, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	token := randomHex(32)
+	record := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RedeemPasswordResetToken marks token used and returns the user it was
+// issued for. The claim is a single conditional update, so a token can only
+// be redeemed once even when requests race.
+func RedeemPasswordResetToken(tx *gorm.DB, token string, now time.Time) (uint, error) {
+	hash := hashToken(token)
+	result := tx.Model(&models.PasswordResetToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrInvalidResetToken
+	}
+
+	var record models.PasswordResetToken
+	if err := tx.Where("token_hash = ?", hash).First(&record).Error; err != nil {
+		return 0, err
+	}
+	return record.UserID, nil
+}
+
+// SetPassword replaces user's password and signs them out everywhere by
+// revoking their refresh tokens. Outstanding reset links stop working too.
+func SetPassword(tx *gorm.DB, user *models.User, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(user).Update("password", string(hashed)).Error; err != nil {
+		return err
+	}
+	user.Password = string(hashed)
+
+	if err := RevokeUserRefreshTokens(tx, user.ID, time.Now()); err != nil {
+		return err
+	}
+	return tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error
+}
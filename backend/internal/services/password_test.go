@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/testdb"
+)
+
+func TestRedeemPasswordResetToken(t *testing.T) {
+	db := testdb.Open(t)
+	user := models.User{Email: "alice@example.com", Password: "hash", IsActive: true}
+	db.Create(&user)
+
+	token, err := IssuePasswordResetToken(db, user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePasswordResetToken: %v", err)
+	}
+	var stored models.PasswordResetToken
+	db.Where("user_id = ?", user.ID).First(&stored)
+	if stored.TokenHash == token {
+		t.Error("token stored in plain text")
+	}
+
+	if id, err := RedeemPasswordResetToken(db, token, time.Now()); err != nil || id != user.ID {
+		t.Fatalf("RedeemPasswordResetToken = %d, %v; want %d", id, err, user.ID)
+	}
+	if _, err := RedeemPasswordResetToken(db, token, time.Now()); err != ErrInvalidResetToken {
+		t.Errorf("redeeming a used token = %v, want %v", err, ErrInvalidResetToken)
+	}
+	if _, err := RedeemPasswordResetToken(db, "unknown", time.Now()); err != ErrInvalidResetToken {
+		t.Errorf("redeeming an unknown token = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestRedeemPasswordResetToken_Expired(t *testing.T) {
+	db := testdb.Open(t)
+	user := models.User{Email: "alice@example.com", Password: "hash", IsActive: true}
+	db.Create(&user)
+
+	token, err := IssuePasswordResetToken(db, user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePasswordResetToken: %v", err)
+	}
+
+	if _, err := RedeemPasswordResetToken(db, token, time.Now().Add(time.Hour+time.Second)); err != ErrInvalidResetToken {
+		t.Errorf("redeeming an expired token = %v, want %v", err, ErrInvalidResetToken)
+	}
+	// The failed attempt did not use it up.
+	if id, err := RedeemPasswordResetToken(db, token, time.Now()); err != nil || id != user.ID {
+		t.Errorf("RedeemPasswordResetToken before expiry = %d, %v; want %d", id, err, user.ID)
+	}
+}
+
+func TestIssuePasswordResetToken_SupersedesEarlierTokens(t *testing.T) {
+	db := testdb.Open(t)
+	alice := models.User{Email: "alice@example.com", Password: "hash", IsActive: true}
+	bob := models.User{Email: "bob@example.com", Password: "hash", IsActive: true}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	first, err := IssuePasswordResetToken(db, alice.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePasswordResetToken: %v", err)
+	}
+	bobs, err := IssuePasswordResetToken(db, bob.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePasswordResetToken: %v", err)
+	}
+	second, err := IssuePasswordResetToken(db, alice.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePasswordResetToken: %v", err)
+	}
+
+	if _, err := RedeemPasswordResetToken(db, first, time.Now()); err != ErrInvalidResetToken {
+		t.Errorf("redeeming a superseded token = %v, want %v", err, ErrInvalidResetToken)
+	}
+	if id, err := RedeemPasswordResetToken(db, second, time.Now()); err != nil || id != alice.ID {
+		t.Errorf("redeeming the latest token = %d, %v; want %d", id, err, alice.ID)
+	}
+	// Another user's token is unaffected.
+	if id, err := RedeemPasswordResetToken(db, bobs, time.Now()); err != nil || id != bob.ID {
+		t.Errorf("redeeming another user's token = %d, %v; want %d", id, err, bob.ID)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter counts requests per key in fixed windows.
+type RateLimiter interface {
+	// Allow records a request for key and reports whether it is within limit
+	// requests per window.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (RateLimitResult, error)
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	ResetIn   time.Duration
+}
+
+func newRateLimitResult(count int64, limit int, resetIn time.Duration) RateLimitResult {
+	remaining := limit - int(count)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateLimitResult{Allowed: count <= int64(limit), Remaining: remaining, ResetIn: resetIn}
+}
+
+type RedisRateLimiter struct {
+	redis *RedisService
+}
+
+func NewRedisRateLimiter(redisService *RedisService) *RedisRateLimiter {
+	return &RedisRateLimiter{redis: redisService}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (RateLimitResult, error) {
+	count, ttl, err := l.redis.IncrWindow(ctx, key, window)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return newRateLimitResult(count, limit, ttl), nil
+}
+
+// MemoryRateLimiter is used when Redis is not configured. Limits only apply
+// per instance of the API.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{windows: make(map[string]*rateWindow)}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for k, w := range l.windows {
+		if !now.Before(w.expiresAt) {
+			delete(l.windows, k)
+		}
+	}
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &rateWindow{expiresAt: now.Add(window)}
+		l.windows[key] = w
+	}
+	w.count++
+	return newRateLimitResult(w.count, limit, w.expiresAt.Sub(now)), nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		result, err := limiter.Allow(ctx, "partner:1", 3, time.Minute)
+		if err != nil {
+			t.Fatalf("Allow returned error: %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d was limited", i)
+		}
+		if result.Remaining != 3-i {
+			t.Errorf("request %d: remaining = %d; want %d", i, result.Remaining, 3-i)
+		}
+	}
+
+	result, _ := limiter.Allow(ctx, "partner:1", 3, time.Minute)
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("fourth request = %+v; want limited with nothing remaining", result)
+	}
+	if result.ResetIn <= 0 || result.ResetIn > time.Minute {
+		t.Errorf("ResetIn = %v; want within the window", result.ResetIn)
+	}
+
+	if result, _ := limiter.Allow(ctx, "partner:2", 3, time.Minute); !result.Allowed {
+		t.Error("another key shares the first key's window")
+	}
+
+	if result, _ := limiter.Allow(ctx, "partner:3", 1, time.Nanosecond); !result.Allowed {
+		t.Fatal("first request in a window was limited")
+	}
+	time.Sleep(time.Millisecond)
+	if result, _ := limiter.Allow(ctx, "partner:3", 1, time.Nanosecond); !result.Allowed {
+		t.Error("request after the window expired was limited")
+	}
+}
@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reconcileBatchSize caps how many pending payments one reconciliation pass
+// queries, so a backlog cannot hold the gateway APIs for minutes.
+const reconcileBatchSize = 100
+
+// Reconciler settles payments and refunds whose outcome the gateway never
+// told us about, and compares each day's payments with the gateways'
+// settlement files.
+type Reconciler struct {
+	db          *gorm.DB
+	providers   *payments.Registry
+	settlements payments.SettlementSource
+	// pendingThreshold is how long a payment or refund may wait for its
+	// callback before the gateway is asked about it.
+	pendingThreshold time.Duration
+	// expiry is when a payment the gateway does not know as paid is given up.
+	expiry time.Duration
+
+	// OnOrderPaid, when set, is called after reconciliation commits a payment
+	// that made its order paid.
+	OnOrderPaid func(orderID uint)
+}
+
+// NewReconciler creates a Reconciler. settlements may be nil, in which case
+// daily reports query the gateway for every payment instead.
+func NewReconciler(db *gorm.DB, providers *payments.Registry, settlements payments.SettlementSource, pendingThreshold, expiry time.Duration) *Reconciler {
+	return &Reconciler{
+		db:               db,
+		providers:        providers,
+		settlements:      settlements,
+		pendingThreshold: pendingThreshold,
+		expiry:           expiry,
+	}
+}
+
+// Run periodically settles payments whose callbacks never arrived and
+// refunds the gateway left pending, and makes sure yesterday's reconciliation
+// report exists. It blocks until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.ReconcilePendingPayments(ctx); err != nil {
+			log.Printf("❌ Payment reconciliation failed: %v", err)
+		}
+		if err := r.ReconcilePendingRefunds(ctx); err != nil {
+			log.Printf("❌ Refund reconciliation failed: %v", err)
+		}
+		r.ensureDailyReport(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconcilePendingPayments asks the gateway for the status of payments that
+// have been pending longer than the threshold. Settled payments go through
+// TransitionPayment like a callback would; payments the customer abandoned
+// are expired once they pass the expiry.
+func (r *Reconciler) ReconcilePendingPayments(ctx context.Context) error {
+	var pending []models.Payment
+	if err := r.db.Where("status = ? AND created_at < ?", "pending", time.Now().Add(-r.pendingThreshold)).
+		Order("created_at").Limit(reconcileBatchSize).Find(&pending).Error; err != nil {
+		return err
+	}
+
+	for i := range pending {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r.reconcilePayment(ctx, &pending[i])
+	}
+	return nil
+}
+
+func (r *Reconciler) reconcilePayment(ctx context.Context, payment *models.Payment) {
+	provider, ok := r.providers.Get(payment.PaymentMethod)
+	if !ok {
+		return
+	}
+
+	status, err := r.queryGateway(ctx, provider, payment)
+	if errors.Is(err, payments.ErrNotSupported) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to query %s payment %d: %v", payment.PaymentMethod, payment.ID, err)
+		return
+	}
+
+	switch status.Status {
+	case payments.StatusCompleted:
+		if status.Amount != payment.Amount {
+			// Left pending on purpose: the daily report flags it for finance.
+			log.Printf("⚠️  Payment %d completed at gateway for %.0f, expected %.0f", payment.ID, status.Amount, payment.Amount)
+			return
+		}
+		applied, orderPaid, err := TransitionPayment(r.db, payment, payments.StatusCompleted, status.GatewayTransactionNo,
+			fmt.Sprintf("Reconciled: ResponseCode: %s, Amount: %.0f", status.ResponseCode, status.Amount))
+		if err != nil {
+			log.Printf("Failed to complete payment %d: %v", payment.ID, err)
+			return
+		}
+		if applied {
+			log.Printf("✅ Payment %d completed by reconciliation", payment.ID)
+		}
+		if orderPaid && r.OnOrderPaid != nil {
+			r.OnOrderPaid(payment.OrderID)
+		}
+	case payments.StatusFailed:
+		if _, _, err := TransitionPayment(r.db, payment, payments.StatusFailed, status.GatewayTransactionNo,
+			fmt.Sprintf("Reconciled: ResponseCode: %s, %s", status.ResponseCode, status.Message)); err != nil {
+			log.Printf("Failed to fail payment %d: %v", payment.ID, err)
+		}
+	case payments.StatusPending, payments.StatusNotFound:
+		if time.Since(payment.CreatedAt) > r.expiry {
+			if _, _, err := TransitionPayment(r.db, payment, "expired", "",
+				fmt.Sprintf("Expired: gateway status %s after %s", status.Status, r.expiry)); err != nil {
+				log.Printf("Failed to expire payment %d: %v", payment.ID, err)
+			}
+		}
+	}
+}
+
+// ReconcilePendingRefunds asks the gateway for the status of refunds it
+// accepted without settling them. Completed refunds are settled like an
+// immediate refund would be; failed ones stop counting against the
+// refundable balance. Refunds the gateway never heard of, because we failed
+// before sending them, are failed once they pass the expiry.
+func (r *Reconciler) ReconcilePendingRefunds(ctx context.Context) error {
+	var pending []models.Refund
+	if err := r.db.Where("status = ? AND created_at < ?", "pending", time.Now().Add(-r.pendingThreshold)).
+		Order("created_at").Limit(reconcileBatchSize).Find(&pending).Error; err != nil {
+		return err
+	}
+
+	for i := range pending {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r.reconcileRefund(ctx, &pending[i])
+	}
+	return nil
+}
+
+func (r *Reconciler) reconcileRefund(ctx context.Context, refund *models.Refund) {
+	var payment models.Payment
+	if err := r.db.First(&payment, refund.PaymentID).Error; err != nil {
+		log.Printf("Failed to load payment %d of refund %d: %v", refund.PaymentID, refund.ID, err)
+		return
+	}
+
+	provider, ok := r.providers.Get(payment.PaymentMethod)
+	if !ok {
+		return
+	}
+
+	result, err := provider.QueryRefund(ctx, payments.RefundQueryRequest{
+		RequestID:   refund.RequestID,
+		TxnRef:      payment.TransactionID,
+		RequestedAt: refund.CreatedAt,
+	})
+	if errors.Is(err, payments.ErrNotSupported) {
+		return
+	}
+
+	event := models.PaymentEvent{
+		PaymentID:      &payment.ID,
+		Provider:       provider.Method(),
+		Type:           "refund_query",
+		TxnRef:         payment.TransactionID,
+		HTTPMethod:     http.MethodPost,
+		SignatureValid: !errors.Is(err, payments.ErrInvalidSignature),
+	}
+	if err != nil {
+		event.Outcome = err.Error()
+	} else {
+		event.Outcome = result.Status
+		event.RawParams = payments.MaskPayload(result.RawResponse)
+	}
+	RecordPaymentEvent(r.db, &event)
+
+	if err != nil {
+		log.Printf("Failed to query %s refund %d: %v", payment.PaymentMethod, refund.ID, err)
+		return
+	}
+
+	switch result.Status {
+	case payments.StatusCompleted:
+		if result.TransactionNo != "" {
+			refund.TransactionNo = result.TransactionNo
+		}
+		refund.GatewayResponse = result.RawResponse
+		if r.transitionRefund(refund, func(tx *gorm.DB) error { return SettleRefund(tx, refund) }) {
+			log.Printf("✅ Refund %d completed by reconciliation", refund.ID)
+		}
+	case payments.StatusFailed:
+		r.failPendingRefund(refund, result.RawResponse)
+	case payments.StatusNotFound:
+		if time.Since(refund.CreatedAt) > r.expiry {
+			r.failPendingRefund(refund, fmt.Sprintf("Expired: refund unknown to gateway after %s", r.expiry))
+		}
+	}
+}
+
+func (r *Reconciler) failPendingRefund(refund *models.Refund, reason string) {
+	r.transitionRefund(refund, func(tx *gorm.DB) error {
+		refund.Status = "failed"
+		refund.GatewayResponse = reason
+		return tx.Save(refund).Error
+	})
+}
+
+// transitionRefund runs apply on a refund that is still pending, locking it so
+// overlapping reconciliation passes cannot settle it twice.
+func (r *Reconciler) transitionRefund(refund *models.Refund, apply func(tx *gorm.DB) error) bool {
+	applied := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Refund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, refund.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != "pending" {
+			return nil
+		}
+
+		if err := apply(tx); err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to update refund %d: %v", refund.ID, err)
+		return false
+	}
+	return applied
+}
+
+func (r *Reconciler) queryGateway(ctx context.Context, provider payments.Provider, payment *models.Payment) (*payments.StatusResult, error) {
+	status, err := provider.QueryStatus(ctx, payments.QueryRequest{
+		RequestID:            fmt.Sprintf("Q%d%d", payment.ID, time.Now().UnixNano()),
+		TxnRef:               payment.TransactionID,
+		GatewayTransactionNo: payment.GatewayTransactionNo,
+		TransactionDate:      payment.CreatedAt,
+		OrderInfo:            fmt.Sprintf("Query payment %d", payment.ID),
+		IPAddr:               "127.0.0.1",
+	})
+	if errors.Is(err, payments.ErrNotSupported) {
+		return nil, err
+	}
+
+	event := models.PaymentEvent{
+		PaymentID:      &payment.ID,
+		Provider:       provider.Method(),
+		Type:           "query",
+		TxnRef:         payment.TransactionID,
+		HTTPMethod:     http.MethodPost,
+		SignatureValid: !errors.Is(err, payments.ErrInvalidSignature),
+	}
+	if err != nil {
+		event.Outcome = err.Error()
+	} else {
+		event.Outcome = status.Status
+		event.RawParams = payments.MaskPayload(status.RawResponse)
+	}
+	RecordPaymentEvent(r.db, &event)
+
+	return status, err
+}
+
+// ensureDailyReport builds yesterday's report unless one already exists.
+func (r *Reconciler) ensureDailyReport(ctx context.Context) {
+	day := StartOfDay(time.Now()).AddDate(0, 0, -1)
+
+	var count int64
+	r.db.Model(&models.ReconciliationReport{}).Where("report_date = ?", day).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	if _, err := r.GenerateReport(ctx, day); err != nil {
+		log.Printf("❌ Failed to generate reconciliation report for %s: %v", day.Format("2006-01-02"), err)
+	}
+}
+
+// GenerateReport compares every gateway payment created on day with the
+// gateway's settlement file for the day. Gateways whose file has not been
+// delivered are asked about each payment instead. Regenerating a day
+// replaces its items.
+func (r *Reconciler) GenerateReport(ctx context.Context, day time.Time) (*models.ReconciliationReport, error) {
+	day = StartOfDay(day)
+
+	var report models.ReconciliationReport
+	if err := r.db.Where(models.ReconciliationReport{ReportDate: day}).FirstOrCreate(&report).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("report_id = ?", report.ID).Delete(&models.ReconciliationItem{}).Error; err != nil {
+		return nil, err
+	}
+
+	var dayPayments []models.Payment
+	if err := r.db.Where("created_at >= ? AND created_at < ?", day, day.AddDate(0, 0, 1)).Order("id").Find(&dayPayments).Error; err != nil {
+		r.db.Model(&report).Update("status", "failed")
+		return nil, err
+	}
+
+	byMethod := make(map[string][]*models.Payment)
+	for i := range dayPayments {
+		byMethod[dayPayments[i].PaymentMethod] = append(byMethod[dayPayments[i].PaymentMethod], &dayPayments[i])
+	}
+
+	report.Status = "completed"
+	report.PaymentsChecked = 0
+	report.OurTotal = 0
+	report.GatewayTotal = 0
+	report.Items = nil
+
+	for _, method := range r.providers.Methods() {
+		provider, _ := r.providers.Get(method)
+
+		records, err := r.settlementRecords(method, day)
+		switch {
+		case err == nil:
+			if err := r.compareWithSettlement(&report, method, byMethod[method], records, day); err != nil {
+				r.db.Model(&report).Update("status", "failed")
+				return nil, err
+			}
+		case errors.Is(err, payments.ErrNoSettlement):
+			r.compareWithQueries(ctx, &report, provider, byMethod[method])
+		default:
+			r.db.Model(&report).Update("status", "failed")
+			return nil, fmt.Errorf("failed to read %s settlement: %w", method, err)
+		}
+	}
+	for i := range report.Items {
+		report.Items[i].ReportID = report.ID
+	}
+	report.MismatchCount = len(report.Items)
+
+	if err := r.db.Save(&report).Error; err != nil {
+		return nil, err
+	}
+
+	if report.MismatchCount > 0 {
+		log.Printf("⚠️  Reconciliation %s: %d of %d payments do not match the gateway", day.Format("2006-01-02"), report.MismatchCount, report.PaymentsChecked)
+	}
+	return &report, nil
+}
+
+func (r *Reconciler) settlementRecords(method string, day time.Time) ([]payments.SettlementRecord, error) {
+	if r.settlements == nil {
+		return nil, payments.ErrNoSettlement
+	}
+	return r.settlements.Settlements(method, day)
+}
+
+// compareWithSettlement checks the day's payments of one method against the
+// gateway's settlement file. A payment made just before midnight may only be
+// settled the next day, so the next day's file is searched too when it is
+// there. Settled transactions we have no payment for are flagged as well.
+func (r *Reconciler) compareWithSettlement(report *models.ReconciliationReport, method string, dayPayments []*models.Payment, records []payments.SettlementRecord, day time.Time) error {
+	settled := make(map[string]payments.SettlementRecord, len(records))
+	for _, record := range records {
+		settled[record.TxnRef] = record
+		report.GatewayTotal += record.Amount
+	}
+	next, err := r.settlementRecords(method, day.AddDate(0, 0, 1))
+	if err != nil && !errors.Is(err, payments.ErrNoSettlement) {
+		return err
+	}
+	late := make(map[string]payments.SettlementRecord, len(next))
+	for _, record := range next {
+		late[record.TxnRef] = record
+	}
+
+	ours := make(map[string]bool, len(dayPayments))
+	for _, payment := range dayPayments {
+		ours[payment.TransactionID] = true
+		record, ok := settled[payment.TransactionID]
+		if !ok {
+			record, ok = late[payment.TransactionID]
+		}
+		r.addSettlementComparison(report, payment, record, ok)
+	}
+
+	for _, record := range records {
+		if ours[record.TxnRef] {
+			continue
+		}
+
+		// Created the day before and settled on this one.
+		var payment models.Payment
+		err := r.db.Where("transaction_id = ? AND payment_method = ?", record.TxnRef, method).First(&payment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			report.Items = append(report.Items, models.ReconciliationItem{
+				PaymentMethod: method,
+				TransactionID: record.TxnRef,
+				GatewayStatus: record.Status,
+				GatewayAmount: record.Amount,
+				Reason:        "unknown_transaction",
+				Detail:        fmt.Sprintf("Gateway transaction %s has no matching payment", record.GatewayTransactionNo),
+			})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.addSettlementComparison(report, &payment, record, true)
+	}
+	return nil
+}
+
+func (r *Reconciler) addSettlementComparison(report *models.ReconciliationReport, payment *models.Payment, record payments.SettlementRecord, found bool) {
+	report.PaymentsChecked++
+	if isSettledPaymentStatus(payment.Status) {
+		report.OurTotal += payment.Amount
+	}
+
+	status := &payments.StatusResult{Status: payments.StatusNotFound, Message: "Not in settlement file"}
+	if found {
+		status = &payments.StatusResult{
+			Status:               record.Status,
+			Amount:               record.Amount,
+			GatewayTransactionNo: record.GatewayTransactionNo,
+		}
+	}
+	if item := compareWithGateway(payment, status, nil); item != nil {
+		report.Items = append(report.Items, *item)
+	}
+}
+
+// compareWithQueries checks each payment with the gateway's status API, for
+// gateways whose settlement file is not available.
+func (r *Reconciler) compareWithQueries(ctx context.Context, report *models.ReconciliationReport, provider payments.Provider, dayPayments []*models.Payment) {
+	for _, payment := range dayPayments {
+		status, err := r.queryGateway(ctx, provider, payment)
+		if errors.Is(err, payments.ErrNotSupported) {
+			continue
+		}
+		report.PaymentsChecked++
+
+		if item := compareWithGateway(payment, status, err); item != nil {
+			report.Items = append(report.Items, *item)
+		}
+		if isSettledPaymentStatus(payment.Status) {
+			report.OurTotal += payment.Amount
+		}
+		if err == nil && (status.Status == payments.StatusCompleted || status.Status == payments.StatusRefunded) {
+			report.GatewayTotal += status.Amount
+		}
+	}
+}
+
+// compareWithGateway returns a mismatch item, or nil when our record agrees
+// with the gateway's.
+func compareWithGateway(payment *models.Payment, status *payments.StatusResult, queryErr error) *models.ReconciliationItem {
+	item := &models.ReconciliationItem{
+		PaymentID:     payment.ID,
+		OrderID:       payment.OrderID,
+		PaymentMethod: payment.PaymentMethod,
+		TransactionID: payment.TransactionID,
+		OurStatus:     payment.Status,
+		OurAmount:     payment.Amount,
+	}
+
+	if queryErr != nil {
+		item.Reason = "query_failed"
+		item.Detail = queryErr.Error()
+		return item
+	}
+
+	item.GatewayStatus = status.Status
+	item.GatewayAmount = status.Amount
+	item.Detail = status.Message
+
+	gatewaySettled := status.Status == payments.StatusCompleted || status.Status == payments.StatusRefunded
+	switch {
+	case gatewaySettled != isSettledPaymentStatus(payment.Status):
+		item.Reason = "status_mismatch"
+	case gatewaySettled && status.Amount != payment.Amount:
+		item.Reason = "amount_mismatch"
+	default:
+		return nil
+	}
+	return item
+}
+
+func isSettledPaymentStatus(status string) bool {
+	for _, collected := range CollectedPaymentStatuses {
+		if status == collected {
+			return true
+		}
+	}
+	return false
+}
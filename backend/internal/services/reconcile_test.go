@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/payments"
+	"ecommerce-backend/internal/testdb"
+
+	"gorm.io/gorm"
+)
+
+// settlementFiles is a SettlementSource keyed by "<method>/<YYYY-MM-DD>".
+type settlementFiles map[string][]payments.SettlementRecord
+
+func (f settlementFiles) Settlements(method string, day time.Time) ([]payments.SettlementRecord, error) {
+	records, ok := f[method+"/"+day.Format("2006-01-02")]
+	if !ok {
+		return nil, payments.ErrNoSettlement
+	}
+	return records, nil
+}
+
+func newTestReconciler(t *testing.T, settlements payments.SettlementSource, providers ...payments.Provider) (*Reconciler, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t)
+	return NewReconciler(db, payments.NewRegistry(providers...), settlements, 0, time.Hour), db
+}
+
+func createPaidOrder(t *testing.T, db *gorm.DB, payment *models.Payment) models.Order {
+	t.Helper()
+	order := models.Order{OrderNumber: "ORD-" + payment.TransactionID, UserID: 1, Subtotal: payment.Amount, Total: payment.Amount, Status: "pending", PaymentStatus: "pending"}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	payment.OrderID = order.ID
+	if err := db.Create(payment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestReconcilePendingPayments(t *testing.T) {
+	gateway := payments.NewFakeProvider("vnpay")
+	r, db := newTestReconciler(t, nil, gateway)
+	ctx := context.Background()
+
+	createdAt := time.Now().Add(-20 * time.Minute).Truncate(time.Second)
+	paid := models.Payment{PaymentMethod: "vnpay", Status: "pending", Amount: 150000, Currency: "VND", TransactionID: "240201_1_1", CreatedAt: createdAt}
+	order := createPaidOrder(t, db, &paid)
+
+	gateway.QueryResult = &payments.StatusResult{Status: payments.StatusCompleted, Amount: 150000, ResponseCode: "00", GatewayTransactionNo: "14226112"}
+	if err := r.ReconcilePendingPayments(ctx); err != nil {
+		t.Fatalf("ReconcilePendingPayments() error = %v", err)
+	}
+
+	if len(gateway.Queries) != 1 {
+		t.Fatalf("Queries = %+v, want one query", gateway.Queries)
+	}
+	if query := gateway.Queries[0]; query.TxnRef != "240201_1_1" || !query.TransactionDate.Equal(createdAt) {
+		t.Errorf("query = {%s, %s}; want the payment's txn ref and creation time %s", query.TxnRef, query.TransactionDate, createdAt)
+	}
+	db.First(&paid, paid.ID)
+	db.First(&order, order.ID)
+	if paid.Status != "completed" || paid.GatewayTransactionNo != "14226112" {
+		t.Errorf("payment = {%s, %s}; want {completed, 14226112}", paid.Status, paid.GatewayTransactionNo)
+	}
+	if order.PaymentStatus != "paid" {
+		t.Errorf("order payment status = %s; want paid", order.PaymentStatus)
+	}
+
+	var events int64
+	db.Model(&models.PaymentEvent{}).Where("payment_id = ? AND type = ?", paid.ID, "query").Count(&events)
+	if events != 1 {
+		t.Errorf("query events = %d; want 1", events)
+	}
+
+	abandoned := models.Payment{PaymentMethod: "vnpay", Status: "pending", Amount: 50000, Currency: "VND", TransactionID: "240201_2_1", CreatedAt: time.Now().Add(-2 * time.Hour)}
+	createPaidOrder(t, db, &abandoned)
+	gateway.QueryResult = &payments.StatusResult{Status: payments.StatusNotFound}
+	if err := r.ReconcilePendingPayments(ctx); err != nil {
+		t.Fatalf("ReconcilePendingPayments() error = %v", err)
+	}
+	db.First(&abandoned, abandoned.ID)
+	if abandoned.Status != "expired" {
+		t.Errorf("abandoned payment status = %s; want expired", abandoned.Status)
+	}
+}
+
+func TestReconcilePendingRefunds(t *testing.T) {
+	gateway := payments.NewFakeProvider("momo")
+	r, db := newTestReconciler(t, nil, gateway)
+	ctx := context.Background()
+
+	order := models.Order{OrderNumber: "ORD-1", UserID: 1, Subtotal: 100000, Total: 100000, Status: "confirmed", PaymentStatus: "paid"}
+	db.Create(&order)
+	payment := models.Payment{OrderID: order.ID, PaymentMethod: "momo", Status: "completed", Amount: 100000, Currency: "VND", TransactionID: "240201_1_1"}
+	db.Create(&payment)
+	refund := models.Refund{PaymentID: payment.ID, OrderID: order.ID, Type: "partial", Status: "pending", Amount: 40000, RequestID: "REFUND_1"}
+	db.Create(&refund)
+
+	reload := func() {
+		db.First(&order, order.ID)
+		db.First(&payment, payment.ID)
+		db.First(&refund, refund.ID)
+	}
+
+	if err := r.ReconcilePendingRefunds(ctx); err != nil {
+		t.Fatalf("ReconcilePendingRefunds() error = %v", err)
+	}
+	reload()
+	if refund.Status != "pending" || payment.RefundedAmount != 0 {
+		t.Fatalf("refund still processing at gateway: refund %s, refunded %.0f; want pending, 0", refund.Status, payment.RefundedAmount)
+	}
+	if len(gateway.RefundQueries) != 1 || gateway.RefundQueries[0].RequestID != "REFUND_1" || gateway.RefundQueries[0].TxnRef != "240201_1_1" {
+		t.Fatalf("RefundQueries = %+v", gateway.RefundQueries)
+	}
+
+	gateway.RefundQueryStatus = payments.StatusCompleted
+	for i := 0; i < 2; i++ {
+		if err := r.ReconcilePendingRefunds(ctx); err != nil {
+			t.Fatalf("ReconcilePendingRefunds() error = %v", err)
+		}
+	}
+	reload()
+	if refund.Status != "completed" || refund.ProcessedAt == nil {
+		t.Errorf("refund status = %s; want completed", refund.Status)
+	}
+	if payment.Status != "partially_refunded" || payment.RefundedAmount != 40000 {
+		t.Errorf("payment = {%s, %.0f}; want {partially_refunded, 40000}", payment.Status, payment.RefundedAmount)
+	}
+	if order.PaymentStatus != "partially_refunded" {
+		t.Errorf("order payment status = %s; want partially_refunded", order.PaymentStatus)
+	}
+
+	rejected := models.Refund{PaymentID: payment.ID, OrderID: order.ID, Type: "partial", Status: "pending", Amount: 60000, RequestID: "REFUND_2"}
+	db.Create(&rejected)
+	gateway.RefundQueryStatus = payments.StatusFailed
+	if err := r.ReconcilePendingRefunds(ctx); err != nil {
+		t.Fatalf("ReconcilePendingRefunds() error = %v", err)
+	}
+	reload()
+	db.First(&rejected, rejected.ID)
+	if rejected.Status != "failed" {
+		t.Errorf("rejected refund status = %s; want failed", rejected.Status)
+	}
+	if payment.RefundedAmount != 40000 || order.PaymentStatus != "partially_refunded" {
+		t.Errorf("failed refund changed the payment: refunded %.0f, order %s", payment.RefundedAmount, order.PaymentStatus)
+	}
+}
+
+func TestGenerateReport_Settlement(t *testing.T) {
+	gateway := payments.NewFakeProvider("vnpay")
+	day := StartOfDay(time.Now()).AddDate(0, 0, -3)
+	files := settlementFiles{
+		"vnpay/" + day.Format("2006-01-02"): {
+			{TxnRef: "matched", GatewayTransactionNo: "1", Amount: 100000, Status: payments.StatusCompleted},
+			{TxnRef: "refunded", GatewayTransactionNo: "2", Amount: 80000, Status: payments.StatusRefunded},
+			{TxnRef: "short", GatewayTransactionNo: "3", Amount: 90000, Status: payments.StatusCompleted},
+			{TxnRef: "still_pending", GatewayTransactionNo: "4", Amount: 70000, Status: payments.StatusCompleted},
+			{TxnRef: "day_before", GatewayTransactionNo: "5", Amount: 60000, Status: payments.StatusCompleted},
+			{TxnRef: "stranger", GatewayTransactionNo: "6", Amount: 10000, Status: payments.StatusCompleted},
+		},
+		"vnpay/" + day.AddDate(0, 0, 1).Format("2006-01-02"): {
+			{TxnRef: "before_midnight", GatewayTransactionNo: "7", Amount: 50000, Status: payments.StatusCompleted},
+		},
+	}
+	r, db := newTestReconciler(t, files, gateway)
+
+	at := func(hours float64) time.Time { return day.Add(time.Duration(hours * float64(time.Hour))) }
+	for _, payment := range []models.Payment{
+		{TransactionID: "matched", Status: "completed", Amount: 100000, CreatedAt: at(9)},
+		{TransactionID: "refunded", Status: "partially_refunded", Amount: 80000, CreatedAt: at(10)},
+		{TransactionID: "short", Status: "completed", Amount: 100000, CreatedAt: at(11)},
+		{TransactionID: "still_pending", Status: "pending", Amount: 70000, CreatedAt: at(12)},
+		{TransactionID: "unsettled", Status: "completed", Amount: 40000, CreatedAt: at(13)},
+		{TransactionID: "abandoned", Status: "expired", Amount: 30000, CreatedAt: at(14)},
+		{TransactionID: "before_midnight", Status: "completed", Amount: 50000, CreatedAt: at(23.9)},
+		{TransactionID: "day_before", Status: "completed", Amount: 60000, CreatedAt: at(-0.1)},
+	} {
+		payment.PaymentMethod = "vnpay"
+		payment.Currency = "VND"
+		createPaidOrder(t, db, &payment)
+	}
+
+	report, err := r.GenerateReport(context.Background(), day.Add(15*time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateReport() error = %v", err)
+	}
+	if len(gateway.Queries) != 0 {
+		t.Errorf("GenerateReport() queried the gateway %d times; want settlement file only", len(gateway.Queries))
+	}
+
+	reasons := make(map[string]string)
+	for _, item := range report.Items {
+		reasons[item.TransactionID] = item.Reason
+	}
+	want := map[string]string{
+		"short":         "amount_mismatch",
+		"still_pending": "status_mismatch",
+		"unsettled":     "status_mismatch",
+		"stranger":      "unknown_transaction",
+	}
+	if len(reasons) != len(want) {
+		t.Errorf("mismatches = %v; want %v", reasons, want)
+	}
+	for txnRef, reason := range want {
+		if reasons[txnRef] != reason {
+			t.Errorf("mismatch for %s = %q; want %q", txnRef, reasons[txnRef], reason)
+		}
+	}
+	if report.MismatchCount != len(want) || report.PaymentsChecked != 8 {
+		t.Errorf("report = {checked %d, mismatches %d}; want {8, %d}", report.PaymentsChecked, report.MismatchCount, len(want))
+	}
+	if report.GatewayTotal != 410000 {
+		t.Errorf("gateway total = %.0f; want 410000", report.GatewayTotal)
+	}
+
+	var stored int64
+	db.Model(&models.ReconciliationItem{}).Where("report_id = ?", report.ID).Count(&stored)
+	if stored != int64(len(want)) {
+		t.Errorf("stored items = %d; want %d", stored, len(want))
+	}
+
+	// Regenerating the day replaces its items instead of adding to them.
+	if _, err := r.GenerateReport(context.Background(), day); err != nil {
+		t.Fatalf("GenerateReport() again error = %v", err)
+	}
+	db.Model(&models.ReconciliationItem{}).Where("report_id = ?", report.ID).Count(&stored)
+	if stored != int64(len(want)) {
+		t.Errorf("stored items after regenerating = %d; want %d", stored, len(want))
+	}
+}
+
+func TestGenerateReport_QueryFallback(t *testing.T) {
+	gateway := payments.NewFakeProvider("vnpay")
+	gateway.QueryResult = &payments.StatusResult{Status: payments.StatusCompleted, Amount: 100000}
+	r, db := newTestReconciler(t, settlementFiles{}, gateway)
+
+	day := StartOfDay(time.Now()).AddDate(0, 0, -1)
+	payment := models.Payment{PaymentMethod: "vnpay", Status: "pending", Amount: 100000, Currency: "VND", TransactionID: "240201_1_1", CreatedAt: day.Add(time.Hour)}
+	createPaidOrder(t, db, &payment)
+
+	report, err := r.GenerateReport(context.Background(), day)
+	if err != nil {
+		t.Fatalf("GenerateReport() error = %v", err)
+	}
+	if len(gateway.Queries) != 1 || !gateway.Queries[0].TransactionDate.Equal(payment.CreatedAt) {
+		t.Fatalf("Queries = %+v; want one query dated %s", gateway.Queries, payment.CreatedAt)
+	}
+	if report.MismatchCount != 1 || report.Items[0].Reason != "status_mismatch" {
+		t.Errorf("report items = %+v; want one status_mismatch", report.Items)
+	}
+}
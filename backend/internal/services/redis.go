@@ -34,6 +34,40 @@ func (r *RedisService) Set(ctx context.Context, key string, value interface{}, e
 	return r.client.Set(ctx, key, data, expiration).Err()
 }
 
+// SetNX stores value only if key does not exist yet and reports whether it did.
+func (r *RedisService) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return r.client.SetNX(ctx, key, data, expiration).Result()
+}
+
+// IncrWindow increments the counter at key, starting a window of length
+// window on the first increment. It returns the new count and the time left
+// in the window.
+func (r *RedisService) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	count, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	// A negative TTL means the window has not been started, or setting it
+	// failed after an earlier increment.
+	if ttl < 0 {
+		if err := r.client.PExpire(ctx, key, window).Err(); err != nil {
+			return 0, 0, err
+		}
+		ttl = window
+	}
+	return count, ttl, nil
+}
+
 func (r *RedisService) Get(ctx context.Context, key string, dest interface{}) error {
 	data, err := r.client.Get(ctx, key).Result()
 	if err != nil {
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RefreshTokenTTL is how long a refresh token works if it is not used.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused means a token that was already exchanged was
+	// presented again, so a copy of it is in the wrong hands.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// issueRefreshToken creates a token in the family of a session.
+func issueRefreshToken(db *gorm.DB, userID uint, familyID string, now time.Time) (string, error) {
+	token := randomHex(32)
+	record := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken exchanges token for a new one in the same family and
+// returns it with the record of the token it replaced. A token can be
+// exchanged once: if it is presented again, the whole family is revoked,
+// signing out both the user and whoever copied the token, and
+// ErrRefreshTokenReused is returned with the reused token's record.
+func RotateRefreshToken(db *gorm.DB, token string, now time.Time) (string, *models.RefreshToken, error) {
+	var current models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(token)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrInvalidRefreshToken
+		}
+		return "", nil, err
+	}
+
+	for {
+		if current.RotatedAt != nil {
+			if err := RevokeRefreshTokenFamily(db, current.FamilyID, now); err != nil {
+				return "", nil, err
+			}
+			return "", &current, ErrRefreshTokenReused
+		}
+		if current.RevokedAt != nil || !now.Before(current.ExpiresAt) {
+			return "", &current, ErrInvalidRefreshToken
+		}
+
+		var next string
+		claimed := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.RefreshToken{}).
+				Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", current.ID).
+				Update("rotated_at", now)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			claimed = true
+			var err error
+			next, err = issueRefreshToken(tx, current.UserID, current.FamilyID, now)
+			return err
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		if claimed {
+			return next, &current, nil
+		}
+
+		// Another request changed the token since it was loaded; look again
+		// to tell a concurrent exchange from a revocation.
+		if err := db.First(&current, current.ID).Error; err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// RevokeRefreshTokenFamily revokes every token of a sign-in.
+func RevokeRefreshTokenFamily(db *gorm.DB, familyID string, now time.Time) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+// RevokeUserRefreshTokens revokes every sign-in of userID.
+func RevokeUserRefreshTokens(db *gorm.DB, userID uint, now time.Time) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// CleanupExpiredTokens deletes refresh tokens, password reset tokens and
+// sessions that expired before now. Rotated refresh tokens are kept until
+// then so that reuse can still be detected. It returns how many rows were
+// deleted.
+func CleanupExpiredTokens(db *gorm.DB, now time.Time) (int64, error) {
+	var deleted int64
+	for _, model := range []interface{}{&models.RefreshToken{}, &models.PasswordResetToken{}, &models.Session{}} {
+		result := db.Where("expires_at < ?", now).Delete(model)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
+
+// RunTokenCleanup deletes expired tokens and sessions every interval until
+// ctx is cancelled.
+func RunTokenCleanup(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := CleanupExpiredTokens(db, time.Now()); err != nil {
+			log.Printf("❌ Token cleanup failed: %v", err)
+		} else if deleted > 0 {
+			log.Printf("🧹 Deleted %d expired tokens", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
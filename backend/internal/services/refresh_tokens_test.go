@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/testdb"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Now()
+	first, err := issueRefreshToken(db, 1, "family-1", now)
+	if err != nil {
+		t.Fatalf("issueRefreshToken: %v", err)
+	}
+
+	second, previous, err := RotateRefreshToken(db, first, now)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if second == "" || second == first {
+		t.Fatalf("RotateRefreshToken returned %q; want a new token", second)
+	}
+	if previous.UserID != 1 || previous.FamilyID != "family-1" {
+		t.Errorf("replaced token = user %d family %s; want user 1 family family-1", previous.UserID, previous.FamilyID)
+	}
+
+	var rotated models.RefreshToken
+	db.First(&rotated, previous.ID)
+	if rotated.RotatedAt == nil {
+		t.Error("exchanged token was not marked rotated")
+	}
+	var next models.RefreshToken
+	db.Where("token_hash = ?", hashToken(second)).First(&next)
+	if next.FamilyID != "family-1" || next.RotatedAt != nil || next.RevokedAt != nil {
+		t.Errorf("new token = %+v; want an unused token in family-1", next)
+	}
+
+	// The new token can be exchanged in turn.
+	if _, _, err := RotateRefreshToken(db, second, now.Add(time.Minute)); err != nil {
+		t.Errorf("RotateRefreshToken with the new token: %v", err)
+	}
+}
+
+func TestRotateRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Now()
+	first, _ := issueRefreshToken(db, 1, "family-1", now)
+	other, _ := issueRefreshToken(db, 1, "family-2", now)
+
+	second, _, err := RotateRefreshToken(db, first, now)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+
+	_, reused, err := RotateRefreshToken(db, first, now.Add(time.Minute))
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing an exchanged token = %v; want %v", err, ErrRefreshTokenReused)
+	}
+	if reused == nil || reused.FamilyID != "family-1" {
+		t.Errorf("reused token record = %+v; want the family-1 token", reused)
+	}
+
+	var active int64
+	db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", "family-1").Count(&active)
+	if active != 0 {
+		t.Errorf("%d tokens of the family still active; want all revoked", active)
+	}
+	if _, _, err := RotateRefreshToken(db, second, now.Add(time.Minute)); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("exchanging the revoked successor = %v; want %v", err, ErrInvalidRefreshToken)
+	}
+	// Other sign-ins of the user are left alone.
+	if _, _, err := RotateRefreshToken(db, other, now.Add(time.Minute)); err != nil {
+		t.Errorf("exchanging another family's token: %v", err)
+	}
+}
+
+func TestRotateRefreshToken_Invalid(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Now()
+	token, _ := issueRefreshToken(db, 1, "family-1", now)
+
+	if _, _, err := RotateRefreshToken(db, "unknown", now); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token = %v; want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, _, err := RotateRefreshToken(db, token, now.Add(RefreshTokenTTL)); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expired token = %v; want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestCleanupExpiredTokens(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Now()
+
+	expired := now.Add(-time.Minute)
+	valid := now.Add(time.Hour)
+	db.Create(&models.RefreshToken{UserID: 1, TokenHash: "expired", FamilyID: "family-1", ExpiresAt: expired, RotatedAt: &expired})
+	db.Create(&models.RefreshToken{UserID: 1, TokenHash: "valid", FamilyID: "family-1", ExpiresAt: valid, RotatedAt: &expired})
+	db.Create(&models.PasswordResetToken{UserID: 1, TokenHash: "expired", ExpiresAt: expired})
+	db.Create(&models.PasswordResetToken{UserID: 1, TokenHash: "valid", ExpiresAt: valid})
+	db.Create(&models.Session{ID: "expired", UserID: 1, LastSeenAt: now, ExpiresAt: expired})
+	db.Create(&models.Session{ID: "valid", UserID: 1, LastSeenAt: now, ExpiresAt: valid})
+
+	deleted, err := CleanupExpiredTokens(db, now)
+	if err != nil || deleted != 3 {
+		t.Fatalf("CleanupExpiredTokens() = %d, %v; want 3 rows", deleted, err)
+	}
+
+	// Rotated tokens that have not expired stay, so reuse is still caught.
+	for _, model := range []interface{}{&models.RefreshToken{}, &models.PasswordResetToken{}, &models.Session{}} {
+		var left int64
+		db.Model(model).Count(&left)
+		if left != 1 {
+			t.Errorf("%T rows left = %d; want only the unexpired one", model, left)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package services
+
+import "testing"
+
+func TestSecretBox(t *testing.T) {
+	box := NewSecretBox("encryption-key")
+
+	encrypted, err := box.Encrypt("partner-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if encrypted == "partner-secret" {
+		t.Fatal("Encrypt returned the plaintext")
+	}
+
+	if got, err := box.Decrypt(encrypted); err != nil || got != "partner-secret" {
+		t.Errorf("Decrypt = %q, %v; want partner-secret", got, err)
+	}
+	if _, err := NewSecretBox("other-key").Decrypt(encrypted); err != ErrInvalidCiphertext {
+		t.Errorf("Decrypt with another key = %v, want %v", err, ErrInvalidCiphertext)
+	}
+	if _, err := box.Decrypt(""); err != ErrInvalidCiphertext {
+		t.Errorf("Decrypt(\"\") = %v, want %v", err, ErrInvalidCiphertext)
+	}
+}
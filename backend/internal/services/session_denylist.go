@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// SessionDenylist remembers revoked sessions until their access tokens have
+// expired, so the tokens stop working at once.
+type SessionDenylist interface {
+	// Deny rejects tokens of sessionID for ttl.
+	Deny(ctx context.Context, sessionID string, ttl time.Duration) error
+	// Denied reports whether sessionID has been revoked.
+	Denied(ctx context.Context, sessionID string) (bool, error)
+}
+
+func sessionDenylistKey(sessionID string) string {
+	return "session:revoked:" + sessionID
+}
+
+type RedisSessionDenylist struct {
+	redis *RedisService
+}
+
+func NewRedisSessionDenylist(redisService *RedisService) *RedisSessionDenylist {
+	return &RedisSessionDenylist{redis: redisService}
+}
+
+func (d *RedisSessionDenylist) Deny(ctx context.Context, sessionID string, ttl time.Duration) error {
+	return d.redis.Set(ctx, sessionDenylistKey(sessionID), 1, ttl)
+}
+
+func (d *RedisSessionDenylist) Denied(ctx context.Context, sessionID string) (bool, error) {
+	return d.redis.Exists(ctx, sessionDenylistKey(sessionID))
+}
+
+// MemorySessionDenylist is the SessionDenylist used when Redis is not
+// configured.
+type MemorySessionDenylist struct {
+	sessions *ttlSet
+}
+
+func NewMemorySessionDenylist() *MemorySessionDenylist {
+	return &MemorySessionDenylist{sessions: newTTLSet()}
+}
+
+func (d *MemorySessionDenylist) Deny(ctx context.Context, sessionID string, ttl time.Duration) error {
+	d.sessions.add(sessionID, ttl)
+	return nil
+}
+
+func (d *MemorySessionDenylist) Denied(ctx context.Context, sessionID string) (bool, error) {
+	return d.sessions.has(sessionID), nil
+}
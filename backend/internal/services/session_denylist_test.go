@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemorySessionDenylist(t *testing.T) {
+	denylist := NewMemorySessionDenylist()
+	ctx := context.Background()
+
+	if denied, _ := denylist.Denied(ctx, "session-1"); denied {
+		t.Error("session denied before it was revoked")
+	}
+	denylist.Deny(ctx, "session-1", time.Minute)
+	if denied, _ := denylist.Denied(ctx, "session-1"); !denied {
+		t.Error("revoked session was not denied")
+	}
+
+	denylist.Deny(ctx, "session-2", -time.Second)
+	if denied, _ := denylist.Denied(ctx, "session-2"); denied {
+		t.Error("expired denial was still in force")
+	}
+}
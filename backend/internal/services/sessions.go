@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/pkg/auth"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionManager tracks the sign-ins of users. Revoking a session revokes its
+// refresh tokens and puts it on the denylist, so its access tokens stop
+// working straight away instead of when they expire.
+type SessionManager struct {
+	db       *gorm.DB
+	denylist SessionDenylist
+}
+
+func NewSessionManager(db *gorm.DB, denylist SessionDenylist) *SessionManager {
+	return &SessionManager{db: db, denylist: denylist}
+}
+
+// Start records a new sign-in of userID and returns it with its first
+// refresh token.
+func (m *SessionManager) Start(userID uint, ipAddress, userAgent string) (*models.Session, string, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         randomHex(16),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+
+	var token string
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		token, err = issueRefreshToken(tx, userID, session.ID, now)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &session, token, nil
+}
+
+// Refresh exchanges a refresh token as RotateRefreshToken does and records the
+// session as last seen from ipAddress. When the token was reused, the session
+// is revoked and returned with ErrRefreshTokenReused so the caller can report
+// it.
+func (m *SessionManager) Refresh(ctx context.Context, token, ipAddress, userAgent string) (*models.Session, string, error) {
+	now := time.Now()
+	next, previous, err := RotateRefreshToken(m.db, token, now)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := m.revoke(ctx, previous.FamilyID, now); err != nil {
+			return nil, "", err
+		}
+		return &models.Session{ID: previous.FamilyID, UserID: previous.UserID}, "", ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Sign-ins from before sessions were recorded get their session here.
+	session := models.Session{
+		ID:         previous.FamilyID,
+		UserID:     previous.UserID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+	err = m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_agent", "ip_address", "last_seen_at", "expires_at"}),
+	}).Create(&session).Error
+	if err != nil {
+		return nil, "", err
+	}
+	return &session, next, nil
+}
+
+// Active lists the sessions of userID that have not ended, most recently
+// seen first.
+func (m *SessionManager) Active(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := m.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke ends one of userID's sessions.
+func (m *SessionManager) Revoke(ctx context.Context, userID uint, sessionID string) error {
+	var session models.Session
+	err := m.db.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return m.revoke(ctx, session.ID, time.Now())
+}
+
+// RevokeAll ends every session of userID except exceptSessionID, which may
+// be empty, and returns how many were ended.
+func (m *SessionManager) RevokeAll(ctx context.Context, userID uint, exceptSessionID string) (int, error) {
+	now := time.Now()
+	var ids []string
+	err := m.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, exceptSessionID, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := m.revoke(ctx, id, now); err != nil {
+			return 0, err
+		}
+	}
+
+	// Refresh tokens from before sessions were recorded have no session.
+	err = m.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, exceptSessionID).
+		Update("revoked_at", now).Error
+	return len(ids), err
+}
+
+func (m *SessionManager) revoke(ctx context.Context, sessionID string, now time.Time) error {
+	// Deny first: once the session is marked revoked it is no longer listed,
+	// so a failure after that could not be retried.
+	if err := m.denylist.Deny(ctx, sessionID, auth.AccessTokenTTL); err != nil {
+		return err
+	}
+	if err := m.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return RevokeRefreshTokenFamily(m.db, sessionID, now)
+}
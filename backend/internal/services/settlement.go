@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CollectedPaymentStatuses are the payment statuses whose amount was taken
+// from the customer, including payments refunded since.
+var CollectedPaymentStatuses = []string{"completed", "partially_refunded", "refunded"}
+
+// OrderPaidAmount sums the payments collected for an order so far. An order
+// paid with split tender has one payment per tender.
+func OrderPaidAmount(tx *gorm.DB, orderID uint) (float64, error) {
+	var paid float64
+	err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status IN ?", orderID, CollectedPaymentStatuses).
+		Select("COALESCE(SUM(amount), 0)").Scan(&paid).Error
+	return paid, err
+}
+
+// SettleOrderPayment marks an order paid, and confirms it if still pending,
+// once its payments cover the total, or partially_paid while the rest is
+// still owed. Gift cards bought with the order are funded when it becomes
+// paid. It reports whether this call is the one that made the order paid, so
+// the caller can announce the order once its transaction commits.
+func SettleOrderPayment(tx *gorm.DB, orderID uint) (bool, error) {
+	var order models.Order
+	if err := tx.First(&order, orderID).Error; err != nil {
+		return false, err
+	}
+
+	paid, err := OrderPaidAmount(tx, orderID)
+	if err != nil {
+		return false, err
+	}
+
+	if paid < order.Total {
+		return false, tx.Model(&order).Update("payment_status", "partially_paid").Error
+	}
+	if order.PaymentStatus == "paid" {
+		return false, nil
+	}
+
+	updates := map[string]interface{}{"payment_status": "paid"}
+	if order.Status == "pending" {
+		// Cash on delivery is collected after the order shipped; its status
+		// has moved on and must not go back.
+		updates["status"] = "confirmed"
+	}
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	return true, activateGiftCards(tx, orderID)
+}
+
+// activateGiftCards funds the gift cards bought with an order once it is paid.
+func activateGiftCards(tx *gorm.DB, orderID uint) error {
+	var cards []models.GiftCard
+	if err := tx.Where("order_id = ? AND status = ?", orderID, "pending_payment").Find(&cards).Error; err != nil {
+		return err
+	}
+	if len(cards) == 0 {
+		return nil
+	}
+
+	source, err := SystemAccount(tx, AccountGiftCardSales)
+	if err != nil {
+		return err
+	}
+
+	for i := range cards {
+		account, err := LockAccount(tx, cards[i].AccountID)
+		if err != nil {
+			return err
+		}
+		if _, err := PostTransfer(tx, Transfer{
+			From:        source,
+			To:          account,
+			Amount:      cards[i].Amount,
+			Type:        "gift_card_purchase",
+			Reference:   fmt.Sprintf("gift_card:%d", cards[i].ID),
+			Description: fmt.Sprintf("Gift card purchased with order %d", orderID),
+			CreatedBy:   cards[i].PurchasedBy,
+		}); err != nil {
+			return err
+		}
+		if err := tx.Model(&cards[i]).Update("status", "active").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransitionPayment moves a pending payment to status and, on completion,
+// settles its order. It is a no-op when the payment already left pending, so
+// concurrent callbacks and reconciliation cannot apply a result twice.
+// orderPaid reports whether the transition made the payment's order paid.
+// Nothing is applied when err is set.
+func TransitionPayment(db *gorm.DB, payment *models.Payment, status, gatewayTransactionNo, gatewayResponse string) (applied, orderPaid bool, err error) {
+	updates := map[string]interface{}{
+		"status":           status,
+		"gateway_response": gatewayResponse,
+	}
+	if status == "completed" {
+		updates["gateway_transaction_no"] = gatewayTransactionNo
+		updates["processed_at"] = time.Now()
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Payment{}).Where("id = ? AND status = ?", payment.ID, "pending").Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		paid := false
+		if status == "completed" {
+			var err error
+			if paid, err = SettleOrderPayment(tx, payment.OrderID); err != nil {
+				return err
+			}
+		}
+
+		applied, orderPaid = true, paid
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+
+	db.First(payment, payment.ID)
+	return applied, orderPaid, nil
+}
+
+// SettleRefund marks refund completed and rolls the settled total up into the
+// payment and order statuses, reversing the partners' share of it.
+func SettleRefund(tx *gorm.DB, refund *models.Refund) error {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	refund.Status = "completed"
+	refund.ProcessedAt = &now
+	if err := tx.Save(refund).Error; err != nil {
+		return err
+	}
+
+	payment.RefundedAmount += refund.Amount
+	payment.Status = "partially_refunded"
+	if payment.RefundedAmount >= payment.Amount {
+		payment.Status = "refunded"
+	}
+	if err := tx.Save(&payment).Error; err != nil {
+		return err
+	}
+
+	// With split tender one payment can be fully refunded while the order
+	// still holds money from another, so the order status looks at all of
+	// its payments.
+	var totals struct {
+		Paid     float64
+		Refunded float64
+	}
+	if err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status IN ?", payment.OrderID, CollectedPaymentStatuses).
+		Select("COALESCE(SUM(amount), 0) AS paid, COALESCE(SUM(refunded_amount), 0) AS refunded").
+		Scan(&totals).Error; err != nil {
+		return err
+	}
+
+	orderUpdates := map[string]interface{}{"payment_status": "partially_refunded"}
+	if totals.Refunded >= totals.Paid {
+		orderUpdates["payment_status"] = "refunded"
+		orderUpdates["status"] = "refunded"
+	}
+	if err := tx.Model(&models.Order{}).Where("id = ?", payment.OrderID).Updates(orderUpdates).Error; err != nil {
+		return err
+	}
+
+	return ReverseOrderEarnings(tx, refund)
+}
+
+// RecordPaymentEvent stores a gateway interaction. Failing to write the audit
+// trail must not fail the payment itself, so errors are only logged.
+func RecordPaymentEvent(db *gorm.DB, event *models.PaymentEvent) {
+	if err := db.Create(event).Error; err != nil {
+		log.Printf("Failed to store %s %s event for %s: %v", event.Provider, event.Type, event.TxnRef, err)
+	}
+}
+
+// StartOfDay truncates t to midnight in its location.
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"ecommerce-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSubOrderTransition = errors.New("invalid sub-order status transition")
+	ErrOrderNotFulfillable       = errors.New("order is not paid or confirmed")
+)
+
+// fulfillablePaymentStatuses are the payment statuses of prepaid orders that
+// partners may fulfil.
+var fulfillablePaymentStatuses = []string{"paid", "partially_refunded"}
+
+// unconfirmedCODStatuses are the statuses of cash-on-delivery orders an admin
+// has not confirmed, or that will not go out anymore.
+var unconfirmedCODStatuses = []string{"pending", "cancelled", "refunded"}
+
+// IsOrderFulfillable reports whether partners may work on the order: it is
+// paid, or it is a cash-on-delivery order an admin has confirmed.
+func IsOrderFulfillable(order *models.Order) bool {
+	for _, status := range fulfillablePaymentStatuses {
+		if order.PaymentStatus == status {
+			return true
+		}
+	}
+	if order.PaymentMethod != "cod" {
+		return false
+	}
+	for _, status := range unconfirmedCODStatuses {
+		if order.Status == status {
+			return false
+		}
+	}
+	return true
+}
+
+// FulfillableOrders restricts a sub-order query to those whose order
+// IsOrderFulfillable.
+func FulfillableOrders(db *gorm.DB, query *gorm.DB) *gorm.DB {
+	return query.Where("order_id IN (?)", db.Model(&models.Order{}).Select("id").
+		Where("payment_status IN ? OR (payment_method = ? AND status NOT IN ?)", fulfillablePaymentStatuses, "cod", unconfirmedCODStatuses))
+}
+
+// subOrderRank orders the fulfilment steps. A sub-order only moves forward,
+// possibly skipping steps; cancelled is reachable until it has shipped.
+var subOrderRank = map[string]int{
+	"pending":    0,
+	"processing": 1,
+	"shipped":    2,
+	"delivered":  3,
+}
+
+// IsSubOrderStatus reports whether status is a fulfilment status.
+func IsSubOrderStatus(status string) bool {
+	_, ok := subOrderRank[status]
+	return ok || status == "cancelled"
+}
+
+// CanTransitionSubOrder reports whether a sub-order in status from may move to to.
+func CanTransitionSubOrder(from, to string) bool {
+	fromRank, ok := subOrderRank[from]
+	if !ok || from == "delivered" {
+		return false
+	}
+	if to == "cancelled" {
+		return fromRank < subOrderRank["shipped"]
+	}
+	toRank, ok := subOrderRank[to]
+	return ok && toRank > fromRank
+}
+
+// SplitOrder creates one sub-order per partner selling items in the order and
+// links each item to its sub-order. Items of products without a partner go
+// to the house partner.
+func SplitOrder(tx *gorm.DB, order *models.Order) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ? AND sub_order_id IS NULL", order.ID).
+		Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	groups := make(map[uint][]models.OrderItem)
+	for _, item := range items {
+		partnerID := item.Product.PartnerID
+		if partnerID == 0 {
+			house, err := HousePartner(tx)
+			if err != nil {
+				return err
+			}
+			partnerID = house.ID
+		}
+		groups[partnerID] = append(groups[partnerID], item)
+	}
+
+	partnerIDs := make([]uint, 0, len(groups))
+	for partnerID := range groups {
+		partnerIDs = append(partnerIDs, partnerID)
+	}
+	sort.Slice(partnerIDs, func(i, j int) bool { return partnerIDs[i] < partnerIDs[j] })
+
+	var existing int64
+	if err := tx.Model(&models.SubOrder{}).Where("order_id = ?", order.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+
+	for i, partnerID := range partnerIDs {
+		subOrder := models.SubOrder{
+			OrderID:   order.ID,
+			PartnerID: partnerID,
+			Number:    fmt.Sprintf("%s-%d", order.OrderNumber, int(existing)+i+1),
+			Status:    "pending",
+			IsTest:    order.IsTest,
+		}
+		ids := make([]uint, 0, len(groups[partnerID]))
+		for _, item := range groups[partnerID] {
+			subOrder.Subtotal += item.TotalPrice
+			ids = append(ids, item.ID)
+		}
+		if err := tx.Create(&subOrder).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.OrderItem{}).Where("id IN ?", ids).Update("sub_order_id", subOrder.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetSubOrderStatus moves subOrder to status and stamps the matching
+// timestamps. It does not save.
+func SetSubOrderStatus(subOrder *models.SubOrder, status string) error {
+	if !CanTransitionSubOrder(subOrder.Status, status) {
+		return ErrInvalidSubOrderTransition
+	}
+
+	now := time.Now()
+	subOrder.Status = status
+	switch status {
+	case "processing":
+		subOrder.AcknowledgedAt = &now
+	case "shipped":
+		subOrder.ShippedAt = &now
+	case "delivered":
+		if subOrder.ShippedAt == nil {
+			subOrder.ShippedAt = &now
+		}
+		subOrder.DeliveredAt = &now
+	case "cancelled":
+		subOrder.CancelledAt = &now
+	}
+	return nil
+}
+
+// MarkItemOutOfStock records that the partner cannot supply item, a line of
+// subOrder. Lines can only be dropped before the sub-order ships; once none
+// is left to ship the sub-order is cancelled. It does not save subOrder and
+// reports whether it was cancelled.
+func MarkItemOutOfStock(tx *gorm.DB, subOrder *models.SubOrder, item *models.OrderItem) (bool, error) {
+	if subOrder.Status != "pending" && subOrder.Status != "processing" {
+		return false, ErrInvalidSubOrderTransition
+	}
+	if item.Status == "out_of_stock" {
+		return false, nil
+	}
+
+	now := time.Now()
+	item.Status = "out_of_stock"
+	item.OutOfStockAt = &now
+	if err := tx.Model(item).Select("status", "out_of_stock_at").Updates(item).Error; err != nil {
+		return false, err
+	}
+
+	var remaining int64
+	if err := tx.Model(&models.OrderItem{}).
+		Where("sub_order_id = ? AND status <> ?", subOrder.ID, "out_of_stock").
+		Count(&remaining).Error; err != nil {
+		return false, err
+	}
+	if remaining > 0 {
+		return false, nil
+	}
+	return true, SetSubOrderStatus(subOrder, "cancelled")
+}
+
+// DeriveOrderStatus returns the order status implied by its sub-orders'
+// statuses. Orders without sub-orders, refunded orders and orders whose
+// sub-orders have not started keep current.
+func DeriveOrderStatus(current string, subOrderStatuses []string) string {
+	if len(subOrderStatuses) == 0 || current == "refunded" {
+		return current
+	}
+
+	var active, processing, shipped, delivered int
+	for _, status := range subOrderStatuses {
+		switch status {
+		case "cancelled":
+			continue
+		case "processing":
+			processing++
+		case "shipped":
+			shipped++
+		case "delivered":
+			delivered++
+		}
+		active++
+	}
+
+	switch {
+	case active == 0:
+		return "cancelled"
+	case delivered == active:
+		return "delivered"
+	case shipped+delivered == active:
+		return "shipped"
+	case shipped+delivered > 0:
+		return "partially_shipped"
+	case processing > 0:
+		return "processing"
+	default:
+		return current
+	}
+}
+
+// SyncOrderStatus re-derives the order's status from its sub-orders, saves
+// it and records partner earnings for anything newly delivered. It returns
+// the order's previous status.
+func SyncOrderStatus(tx *gorm.DB, order *models.Order) (string, error) {
+	previous := order.Status
+
+	var statuses []string
+	if err := tx.Model(&models.SubOrder{}).Where("order_id = ?", order.ID).Pluck("status", &statuses).Error; err != nil {
+		return previous, err
+	}
+
+	order.Status = DeriveOrderStatus(order.Status, statuses)
+	if order.Status != previous {
+		now := time.Now()
+		switch order.Status {
+		case "shipped":
+			order.ShippedAt = &now
+		case "delivered":
+			if order.ShippedAt == nil {
+				order.ShippedAt = &now
+			}
+			order.DeliveredAt = &now
+		case "cancelled":
+			order.CancelledAt = &now
+		}
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"status":       order.Status,
+			"shipped_at":   order.ShippedAt,
+			"delivered_at": order.DeliveredAt,
+			"cancelled_at": order.CancelledAt,
+		}).Error; err != nil {
+			return previous, err
+		}
+	}
+
+	if _, err := RecordOrderEarnings(tx, order.ID); err != nil {
+		return previous, err
+	}
+	return previous, nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	"ecommerce-backend/internal/models"
+)
+
+func TestDeriveOrderStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		statuses []string
+		expected string
+	}{
+		{name: "No sub-orders keeps status", current: "confirmed", statuses: nil, expected: "confirmed"},
+		{name: "Nothing started keeps status", current: "confirmed", statuses: []string{"pending", "pending"}, expected: "confirmed"},
+		{name: "One processing", current: "confirmed", statuses: []string{"processing", "pending"}, expected: "processing"},
+		{name: "One shipped", current: "processing", statuses: []string{"shipped", "processing"}, expected: "partially_shipped"},
+		{name: "All shipped", current: "partially_shipped", statuses: []string{"shipped", "delivered"}, expected: "shipped"},
+		{name: "All delivered", current: "shipped", statuses: []string{"delivered", "delivered"}, expected: "delivered"},
+		{name: "Cancelled sub-orders are ignored", current: "shipped", statuses: []string{"delivered", "cancelled"}, expected: "delivered"},
+		{name: "All cancelled", current: "confirmed", statuses: []string{"cancelled", "cancelled"}, expected: "cancelled"},
+		{name: "Refunded is kept", current: "refunded", statuses: []string{"delivered"}, expected: "refunded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeriveOrderStatus(tt.current, tt.statuses); got != tt.expected {
+				t.Errorf("DeriveOrderStatus(%q, %v) = %q; want %q", tt.current, tt.statuses, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCanTransitionSubOrder(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expected bool
+	}{
+		{"pending", "processing", true},
+		{"pending", "shipped", true},
+		{"processing", "delivered", true},
+		{"shipped", "delivered", true},
+		{"processing", "pending", false},
+		{"shipped", "processing", false},
+		{"pending", "cancelled", true},
+		{"processing", "cancelled", true},
+		{"shipped", "cancelled", false},
+		{"delivered", "cancelled", false},
+		{"cancelled", "processing", false},
+		{"pending", "refunded", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransitionSubOrder(tt.from, tt.to); got != tt.expected {
+			t.Errorf("CanTransitionSubOrder(%q, %q) = %v; want %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestIsOrderFulfillable(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    models.Order
+		expected bool
+	}{
+		{name: "Unpaid", order: models.Order{PaymentMethod: "vnpay", PaymentStatus: "pending", Status: "pending"}, expected: false},
+		{name: "Partially paid", order: models.Order{PaymentMethod: "vnpay", PaymentStatus: "partially_paid", Status: "pending"}, expected: false},
+		{name: "Paid", order: models.Order{PaymentMethod: "vnpay", PaymentStatus: "paid", Status: "confirmed"}, expected: true},
+		{name: "Paid and partially refunded", order: models.Order{PaymentMethod: "momo", PaymentStatus: "partially_refunded", Status: "processing"}, expected: true},
+		{name: "Refunded", order: models.Order{PaymentMethod: "momo", PaymentStatus: "refunded", Status: "refunded"}, expected: false},
+		{name: "COD awaiting confirmation", order: models.Order{PaymentMethod: "cod", PaymentStatus: "pending", Status: "pending"}, expected: false},
+		{name: "COD confirmed", order: models.Order{PaymentMethod: "cod", PaymentStatus: "pending", Status: "confirmed"}, expected: true},
+		{name: "COD in fulfilment", order: models.Order{PaymentMethod: "cod", PaymentStatus: "pending", Status: "partially_shipped"}, expected: true},
+		{name: "COD cancelled", order: models.Order{PaymentMethod: "cod", PaymentStatus: "pending", Status: "cancelled"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOrderFulfillable(&tt.order); got != tt.expected {
+				t.Errorf("IsOrderFulfillable(%+v) = %v; want %v", tt.order, got, tt.expected)
+			}
+		})
+	}
+}
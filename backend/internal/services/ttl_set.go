@@ -0,0 +1,61 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlSet is a set of keys that each expire after their own ttl. It backs the
+// in-memory stand-ins used when Redis is not configured, which only protect a
+// single instance of the API.
+type ttlSet struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+func newTTLSet() *ttlSet {
+	return &ttlSet{keys: make(map[string]time.Time)}
+}
+
+// add records key for ttl, replacing an earlier expiry.
+func (s *ttlSet) add(key string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+	s.keys[key] = now.Add(ttl)
+}
+
+// addNew records key for ttl unless it is already present, and reports
+// whether it was added.
+func (s *ttlSet) addNew(key string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	s.keys[key] = now.Add(ttl)
+	return true
+}
+
+// has reports whether key is present and not expired.
+func (s *ttlSet) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.keys[key]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// prune drops expired keys. The caller holds mu.
+func (s *ttlSet) prune(now time.Time) {
+	for key, expiresAt := range s.keys {
+		if !now.Before(expiresAt) {
+			delete(s.keys, key)
+		}
+	}
+}
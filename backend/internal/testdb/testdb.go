@@ -0,0 +1,37 @@
+// Package testdb opens throwaway databases for tests that need to exercise
+// queries and transactions.
+package testdb
+
+import (
+	"testing"
+
+	"ecommerce-backend/internal/database"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns an in-memory SQLite database with every model migrated. It is
+// closed when the test ends.
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	// Every connection to :memory: gets its own empty database.
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := database.NewAutoMigrator(db).RunAutoMigration(); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
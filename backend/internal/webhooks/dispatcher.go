@@ -0,0 +1,283 @@
+// Package webhooks delivers partner events to partners' webhook URLs.
+//
+// Emit only writes a WebhookDelivery row, so request handlers never wait on
+// a partner's server. A background worker started with Run sends due
+// deliveries, signs them with the partner's secret and retries failures with
+// exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/internal/models"
+	"ecommerce-backend/internal/services"
+	"ecommerce-backend/pkg/partnerauth"
+
+	"gorm.io/gorm"
+)
+
+// Event types sent to partners.
+const (
+	EventProductApproved  = "product.approved"
+	EventProductRejected  = "product.rejected"
+	EventProductSuspended = "product.suspended"
+	EventOrderPlaced      = "order.placed"
+	EventOrderShipped     = "order.shipped"
+	EventOrderCancelled   = "order.cancelled"
+	EventPayoutCreated    = "payout.created"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is marked
+	// failed and waits for a manual replay.
+	MaxAttempts = 8
+
+	baseBackoff     = time.Minute
+	maxBackoff      = 6 * time.Hour
+	deliveryTimeout = 10 * time.Second
+	// claimLease keeps other workers off a delivery while it is being sent.
+	claimLease = time.Minute
+	batchSize  = 50
+	// maxStoredResponse caps how much of a partner's response is logged.
+	maxStoredResponse = 2048
+)
+
+var ErrNotReplayable = errors.New("only failed deliveries can be replayed")
+
+// Event is the JSON body of every webhook.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type Dispatcher struct {
+	db      *gorm.DB
+	secrets *services.SecretBox
+	client  *http.Client
+	wake    chan struct{}
+}
+
+func NewDispatcher(db *gorm.DB, secrets *services.SecretBox) *Dispatcher {
+	return &Dispatcher{
+		db:      db,
+		secrets: secrets,
+		client:  &http.Client{Timeout: deliveryTimeout},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Emit queues eventType for partnerID. Partners without a webhook URL, and
+// inactive partners, are skipped. Errors are logged rather than returned
+// because a missed notification must not fail the caller's request.
+func (d *Dispatcher) Emit(partnerID uint, eventType string, data interface{}) {
+	var partner models.Partner
+	if err := d.db.Where("id = ? AND is_active = ?", partnerID, true).First(&partner).Error; err != nil {
+		return
+	}
+	if partner.WebhookURL == "" {
+		return
+	}
+
+	event := Event{
+		ID:        newEventID(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s webhook for partner %d: %v", eventType, partnerID, err)
+		return
+	}
+
+	delivery := models.WebhookDelivery{
+		PartnerID:     partnerID,
+		EventID:       event.ID,
+		EventType:     eventType,
+		URL:           partner.WebhookURL,
+		Payload:       string(payload),
+		Status:        "pending",
+		NextAttemptAt: time.Now(),
+	}
+	if err := d.db.Create(&delivery).Error; err != nil {
+		log.Printf("Failed to queue %s webhook for partner %d: %v", eventType, partnerID, err)
+		return
+	}
+
+	d.notify()
+}
+
+// Replay queues a failed delivery to be sent again with a fresh set of
+// attempts.
+func (d *Dispatcher) Replay(delivery *models.WebhookDelivery) error {
+	result := d.db.Model(delivery).Where("status = ?", "failed").Updates(map[string]interface{}{
+		"status":          "pending",
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotReplayable
+	}
+
+	d.notify()
+	return nil
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries every interval, or immediately after Emit. It
+// blocks until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	var due []models.WebhookDelivery
+	if err := d.db.Where("status = ? AND next_attempt_at <= ?", "pending", time.Now()).
+		Order("next_attempt_at").Limit(batchSize).Find(&due).Error; err != nil {
+		log.Printf("❌ Failed to load webhook deliveries: %v", err)
+		return
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if d.claim(&due[i]) {
+			d.deliver(ctx, &due[i])
+		}
+	}
+}
+
+// claim pushes next_attempt_at past the lease so another instance polling at
+// the same time does not send the delivery twice.
+func (d *Dispatcher) claim(delivery *models.WebhookDelivery) bool {
+	result := d.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, "pending", delivery.NextAttemptAt).
+		Update("next_attempt_at", time.Now().Add(claimLease))
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	attempt := models.WebhookAttempt{DeliveryID: delivery.ID}
+	started := time.Now()
+
+	statusCode, body, err := d.send(ctx, delivery)
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	attempt.StatusCode = statusCode
+	attempt.ResponseBody = body
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := d.db.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to log webhook attempt for delivery %d: %v", delivery.ID, err)
+	}
+
+	delivery.Attempts++
+	updates := map[string]interface{}{
+		"attempts":         delivery.Attempts,
+		"last_status_code": statusCode,
+		"last_error":       attempt.Error,
+	}
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		updates["status"] = "delivered"
+		updates["delivered_at"] = &now
+	case delivery.Attempts >= MaxAttempts:
+		updates["status"] = "failed"
+		log.Printf("⚠️  Webhook delivery %d to partner %d failed after %d attempts: %v", delivery.ID, delivery.PartnerID, delivery.Attempts, err)
+	default:
+		updates["next_attempt_at"] = time.Now().Add(Backoff(delivery.Attempts))
+	}
+
+	if err := d.db.Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts the delivery. Any non-2xx response counts as a failure.
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (int, string, error) {
+	var partner models.Partner
+	if err := d.db.First(&partner, delivery.PartnerID).Error; err != nil {
+		return 0, "", fmt.Errorf("partner not found: %w", err)
+	}
+	secret, err := d.secrets.Decrypt(partner.EncryptedSecret)
+	if err != nil {
+		return 0, "", fmt.Errorf("partner secret unavailable: %w", err)
+	}
+
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(partnerauth.HeaderWebhookID, delivery.EventID)
+	req.Header.Set(partnerauth.HeaderWebhookEvent, delivery.EventType)
+	req.Header.Set(partnerauth.HeaderWebhookSignature, partnerauth.SignWebhook(secret, payload, time.Now()))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxStoredResponse))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("partner responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// Backoff returns the wait before the next attempt after attempts failures:
+// 1m, 2m, 4m, ... capped at 6h.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return baseBackoff
+	}
+	wait := baseBackoff << uint(attempts-1)
+	if wait > maxBackoff || wait <= 0 {
+		return maxBackoff
+	}
+	return wait
+}
+
+func newEventID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return "evt_" + hex.EncodeToString(bytes)
+}
@@ -0,0 +1,27 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 0, expected: time.Minute},
+		{attempts: 1, expected: time.Minute},
+		{attempts: 2, expected: 2 * time.Minute},
+		{attempts: 5, expected: 16 * time.Minute},
+		{attempts: 9, expected: 256 * time.Minute},
+		{attempts: 10, expected: 6 * time.Hour},
+		{attempts: 100, expected: 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.expected {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.expected)
+		}
+	}
+}
@@ -6,33 +6,29 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// AccessTokenTTL is how long an access token is valid. A revoked session
+// stays on the denylist for this long.
+const AccessTokenTTL = 24 * time.Hour
+
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// SessionID names the sign-in the token was issued for, so revoking the
+	// session revokes the token.
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, email, role, secret string) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
-func GenerateRefreshToken(userID uint, secret string) (string, error) {
+// GenerateToken issues an access token for a session of the user.
+func GenerateToken(userID uint, email, role, sessionID, secret string) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -0,0 +1,59 @@
+package partnerauth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	stringToSign := StringToSign("post", "/api/v1/partners/products?page=2", "1700000000", "abc123", []byte(`{"name":"Lamp"}`))
+
+	wantString := "POST\n/api/v1/partners/products?page=2\n1700000000\nabc123\n79df8fe3594b394d8d92eef740a7c0cc4b1fa08f0db9b2c38f3014e27abec0a5"
+	if stringToSign != wantString {
+		t.Fatalf("StringToSign = %q, want %q", stringToSign, wantString)
+	}
+
+	want := "a63b21cc740a0b5391bffd0e5fa2c497d32e947cbe959c45edd09f5aa4300d2d"
+	if got := Sign("partner-secret", stringToSign); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if !Verify("partner-secret", stringToSign, strings.ToUpper(want)) {
+		t.Error("Verify rejected an upper-case signature")
+	}
+	if Verify("other-secret", stringToSign, want) {
+		t.Error("Verify accepted a signature made with another secret")
+	}
+}
+
+func TestClient_SignsRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		stringToSign := StringToSign(r.Method, r.URL.RequestURI(), r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), body)
+
+		if r.Header.Get(HeaderAPIKey) != "key" || !Verify("secret", stringToSign, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if string(body) != `{"sku":"LAMP-1"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/api/v1/", "key", "secret")
+	resp, err := client.Do(context.Background(), http.MethodPost, "/partners/products?draft=true", map[string]string{"sku": "LAMP-1"})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+}
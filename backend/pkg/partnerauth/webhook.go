@@ -0,0 +1,53 @@
+package partnerauth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every outbound webhook.
+const (
+	HeaderWebhookID        = "X-Webhook-ID"
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// SignWebhook returns the X-Webhook-Signature value for body, in the form
+// "t=<unix>,v1=<hex>" where v1 is the HMAC-SHA256 of "<unix>.<body>" keyed
+// with the partner's secret.
+func SignWebhook(secret string, body []byte, now time.Time) string {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + Sign(secret, timestamp+"."+string(body))
+}
+
+// VerifyWebhook checks an X-Webhook-Signature header against the raw body.
+// Signatures older than MaxClockSkew are rejected so a captured delivery
+// cannot be replayed later.
+func VerifyWebhook(secret, header string, body []byte, now time.Time) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidWebhookSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrInvalidWebhookSignature
+	}
+	if !Verify(secret, timestamp+"."+string(body), signature) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package partnerauth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1","type":"order.placed"}`)
+	header := SignWebhook("secret", body, now)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		body    []byte
+		at      time.Time
+		wantErr bool
+	}{
+		{name: "Valid", secret: "secret", header: header, body: body, at: now},
+		{name: "Wrong secret", secret: "other", header: header, body: body, at: now, wantErr: true},
+		{name: "Modified body", secret: "secret", header: header, body: []byte(`{}`), at: now, wantErr: true},
+		{name: "Too old", secret: "secret", header: header, body: body, at: now.Add(10 * time.Minute), wantErr: true},
+		{name: "Malformed header", secret: "secret", header: "v1=abc", body: body, at: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook(tt.secret, tt.header, tt.body, tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -85,6 +85,19 @@ const UserModal: React.FC<UserModalProps> = ({ user, onClose, onUpdate }) => {
     }
   };
 
+  const onForceLogout = async () => {
+    if (!window.confirm(`Sign ${user.email} out of all sessions?`)) return;
+    try {
+      setLoading(true);
+      const response = await userAPI.forceLogout(user.id);
+      alert(`Signed out of ${response.data.revoked} session(s)`);
+    } catch (err: any) {
+      alert(err.response?.data?.error || 'Failed to sign user out');
+    } finally {
+      setLoading(false);
+    }
+  };
+
   const watchPassword = watch('new_password');
 
   return (
@@ -481,6 +494,30 @@ const UserModal: React.FC<UserModalProps> = ({ user, onClose, onUpdate }) => {
               </div>
             </form>
           )}
+
+          {activeTab === 'password' && (
+            <div style={{ marginTop: '1.5rem', paddingTop: '1.5rem', borderTop: '1px solid #e5e7eb' }}>
+              <p style={{ color: '#6b7280', fontSize: '0.875rem', marginBottom: '0.75rem' }}>
+                Signing the user out ends every session immediately, including access tokens that have not expired yet.
+              </p>
+              <button
+                type="button"
+                onClick={onForceLogout}
+                disabled={loading}
+                style={{
+                  width: '100%',
+                  padding: '0.75rem',
+                  background: loading ? '#9ca3af' : '#111827',
+                  color: 'white',
+                  border: 'none',
+                  borderRadius: '4px',
+                  cursor: loading ? 'not-allowed' : 'pointer'
+                }}
+              >
+                Sign Out of All Sessions
+              </button>
+            </div>
+          )}
         </div>
       </div>
     </div>
@@ -0,0 +1,154 @@
+import React, { useEffect, useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { useAppDispatch } from '../../hooks/redux';
+import { logout } from '../../store/slices/authSlice';
+import { userAPI } from '../../services/api';
+import { Session } from '../../types';
+
+// ActiveSessions lists the devices the user is signed in on and lets them
+// sign any of them out.
+const ActiveSessions: React.FC = () => {
+  const dispatch = useAppDispatch();
+  const navigate = useNavigate();
+  const [sessions, setSessions] = useState<Session[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+
+  const loadSessions = async () => {
+    try {
+      const response = await userAPI.getSessions();
+      setSessions(response.data.sessions);
+      setError(null);
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to load sessions');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  useEffect(() => {
+    loadSessions();
+  }, []);
+
+  const signOutEverywhere = async () => {
+    await dispatch(logout());
+    navigate('/login');
+  };
+
+  const revoke = async (session: Session) => {
+    try {
+      await userAPI.revokeSession(session.id);
+      if (session.current) {
+        await signOutEverywhere();
+        return;
+      }
+      loadSessions();
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to sign out session');
+    }
+  };
+
+  const revokeOthers = async () => {
+    try {
+      await userAPI.revokeAllSessions(true);
+      loadSessions();
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to sign out sessions');
+    }
+  };
+
+  return (
+    <div style={{
+      marginTop: '2rem',
+      backgroundColor: 'white',
+      borderRadius: '12px',
+      boxShadow: '0 1px 3px rgba(0, 0, 0, 0.1)',
+      padding: '2rem'
+    }}>
+      <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', marginBottom: '1.5rem' }}>
+        <h2 style={{ fontSize: '1.25rem', fontWeight: '600', color: '#111827', margin: 0 }}>
+          Active Sessions
+        </h2>
+        {sessions.length > 1 && (
+          <button
+            onClick={revokeOthers}
+            style={{
+              padding: '0.5rem 1rem',
+              backgroundColor: 'white',
+              color: '#dc2626',
+              border: '1px solid #fca5a5',
+              borderRadius: '8px',
+              fontSize: '0.875rem',
+              cursor: 'pointer'
+            }}
+          >
+            Sign out other sessions
+          </button>
+        )}
+      </div>
+
+      {error && (
+        <div style={{
+          background: '#fef2f2',
+          border: '1px solid #fca5a5',
+          color: '#dc2626',
+          padding: '0.75rem 1rem',
+          borderRadius: '8px',
+          marginBottom: '1rem'
+        }}>
+          {error}
+        </div>
+      )}
+
+      {loading ? (
+        <p style={{ color: '#6b7280' }}>Loading sessions...</p>
+      ) : (
+        <div style={{ display: 'flex', flexDirection: 'column', gap: '0.75rem' }}>
+          {sessions.map((session) => (
+            <div
+              key={session.id}
+              style={{
+                display: 'flex',
+                justifyContent: 'space-between',
+                alignItems: 'center',
+                padding: '1rem',
+                border: '1px solid #e5e7eb',
+                borderRadius: '8px'
+              }}
+            >
+              <div style={{ minWidth: 0 }}>
+                <p style={{ margin: 0, fontWeight: '500', color: '#111827', overflow: 'hidden', textOverflow: 'ellipsis', whiteSpace: 'nowrap' }}>
+                  {session.user_agent || 'Unknown device'}
+                  {session.current && (
+                    <span style={{ marginLeft: '0.5rem', fontSize: '0.75rem', color: '#10b981' }}>This device</span>
+                  )}
+                </p>
+                <p style={{ margin: '0.25rem 0 0', fontSize: '0.875rem', color: '#6b7280' }}>
+                  {session.ip_address} · Last seen {new Date(session.last_seen_at).toLocaleString()}
+                </p>
+              </div>
+              <button
+                onClick={() => revoke(session)}
+                style={{
+                  marginLeft: '1rem',
+                  padding: '0.5rem 1rem',
+                  backgroundColor: '#f3f4f6',
+                  color: '#374151',
+                  border: 'none',
+                  borderRadius: '8px',
+                  fontSize: '0.875rem',
+                  cursor: 'pointer',
+                  flexShrink: 0
+                }}
+              >
+                Sign out
+              </button>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default ActiveSessions;
@@ -0,0 +1,129 @@
+import React, { useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { useForm } from 'react-hook-form';
+import { useAppDispatch } from '../../hooks/redux';
+import { logout } from '../../store/slices/authSlice';
+import { userAPI } from '../../services/api';
+
+interface ChangePasswordForm {
+  currentPassword: string;
+  newPassword: string;
+  confirmPassword: string;
+}
+
+// ChangePassword lets the signed-in user change their password. The server
+// signs out every session afterwards, so the user is sent back to sign in.
+const ChangePassword: React.FC = () => {
+  const dispatch = useAppDispatch();
+  const navigate = useNavigate();
+  const [error, setError] = useState<string | null>(null);
+
+  const { register, handleSubmit, watch, formState: { errors, isSubmitting } } = useForm<ChangePasswordForm>();
+
+  const onSubmit = async (data: ChangePasswordForm) => {
+    setError(null);
+    try {
+      await userAPI.changePassword(data.currentPassword, data.newPassword);
+      await dispatch(logout());
+      navigate('/login');
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to change password');
+    }
+  };
+
+  const inputStyle: React.CSSProperties = {
+    width: '100%',
+    padding: '0.75rem',
+    border: '1px solid #d1d5db',
+    borderRadius: '8px',
+    fontSize: '1rem'
+  };
+
+  const errorStyle: React.CSSProperties = { color: '#ef4444', fontSize: '0.875rem', marginTop: '0.25rem' };
+
+  return (
+    <div style={{
+      marginTop: '2rem',
+      backgroundColor: 'white',
+      borderRadius: '12px',
+      boxShadow: '0 1px 3px rgba(0, 0, 0, 0.1)',
+      padding: '2rem'
+    }}>
+      <h2 style={{ fontSize: '1.25rem', fontWeight: '600', color: '#111827', marginBottom: '1.5rem' }}>
+        Change Password
+      </h2>
+      <form style={{ display: 'flex', flexDirection: 'column', gap: '1rem' }} onSubmit={handleSubmit(onSubmit)}>
+        <div>
+          <input
+            {...register('currentPassword', { required: 'Current password is required' })}
+            type="password"
+            placeholder="Current password"
+            style={inputStyle}
+          />
+          {errors.currentPassword && <p style={errorStyle}>{errors.currentPassword.message}</p>}
+        </div>
+        <div>
+          <input
+            {...register('newPassword', {
+              required: 'New password is required',
+              minLength: { value: 6, message: 'Password must be at least 6 characters' }
+            })}
+            type="password"
+            placeholder="New password"
+            style={inputStyle}
+          />
+          {errors.newPassword && <p style={errorStyle}>{errors.newPassword.message}</p>}
+        </div>
+        <div>
+          <input
+            {...register('confirmPassword', {
+              required: 'Please confirm your new password',
+              validate: (value) => value === watch('newPassword') || 'Passwords do not match'
+            })}
+            type="password"
+            placeholder="Confirm new password"
+            style={inputStyle}
+          />
+          {errors.confirmPassword && <p style={errorStyle}>{errors.confirmPassword.message}</p>}
+        </div>
+
+        {error && (
+          <div style={{
+            background: '#fef2f2',
+            border: '1px solid #fca5a5',
+            color: '#dc2626',
+            padding: '0.75rem 1rem',
+            borderRadius: '8px'
+          }}>
+            {error}
+          </div>
+        )}
+
+        <p style={{ color: '#6b7280', fontSize: '0.875rem', margin: 0 }}>
+          You will be signed out on all devices after changing your password.
+        </p>
+
+        <div style={{ display: 'flex', justifyContent: 'flex-end' }}>
+          <button
+            type="submit"
+            disabled={isSubmitting}
+            style={{
+              padding: '0.75rem 1.5rem',
+              backgroundColor: isSubmitting ? '#9ca3af' : '#2563eb',
+              color: 'white',
+              border: 'none',
+              borderRadius: '8px',
+              fontSize: '1rem',
+              fontWeight: '600',
+              cursor: isSubmitting ? 'not-allowed' : 'pointer'
+            }}
+          >
+            {isSubmitting ? 'Saving...' : 'Change Password'}
+          </button>
+        </div>
+      </form>
+    </div>
+  );
+};
+
+export default ChangePassword;
@@ -290,10 +290,10 @@ const Partners: React.FC = () => {
                             fontSize: '0.8rem',
                             fontFamily: 'monospace'
                           }}>
-                            {partner.api_key.substring(0, 8)}...
+                            {(partner.api_keys?.[0]?.api_key ?? '').substring(0, 8)}...
                           </code>
                           <button
-                            onClick={() => copyToClipboard(partner.api_key)}
+                            onClick={() => copyToClipboard(partner.api_keys?.[0]?.api_key ?? '')}
                             style={{
                               background: 'none',
                               border: 'none',
@@ -0,0 +1,128 @@
+import React, { useState } from 'react';
+import { Link } from 'react-router-dom';
+import { useForm } from 'react-hook-form';
+import { authAPI } from '../../services/api';
+
+interface ForgotPasswordForm {
+  email: string;
+}
+
+const ForgotPassword: React.FC = () => {
+  const [message, setMessage] = useState<string | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  const { register, handleSubmit, formState: { errors, isSubmitting } } = useForm<ForgotPasswordForm>();
+
+  const onSubmit = async (data: ForgotPasswordForm) => {
+    setError(null);
+    try {
+      const response = await authAPI.forgotPassword(data.email);
+      setMessage(response.data.message);
+    } catch {
+      setError('Could not send a password reset email. Please try again later.');
+    }
+  };
+
+  return (
+    <div style={{
+      minHeight: '100vh',
+      display: 'flex',
+      alignItems: 'center',
+      justifyContent: 'center',
+      background: '#f3f4f6',
+      padding: '2rem'
+    }}>
+      <div style={{
+        width: '100%',
+        maxWidth: '400px',
+        background: 'white',
+        padding: '2rem',
+        borderRadius: '8px',
+        boxShadow: '0 4px 6px rgba(0, 0, 0, 0.1)'
+      }}>
+        <h2 style={{
+          textAlign: 'center',
+          fontSize: '2rem',
+          fontWeight: 'bold',
+          color: '#111827',
+          marginBottom: '1rem'
+        }}>
+          Forgot your password?
+        </h2>
+
+        {message ? (
+          <p style={{ color: '#6b7280', textAlign: 'center' }}>{message}</p>
+        ) : (
+          <form style={{ display: 'flex', flexDirection: 'column', gap: '1rem' }} onSubmit={handleSubmit(onSubmit)}>
+            <p style={{ color: '#6b7280', textAlign: 'center' }}>
+              Enter your email address and we will send you a link to reset your password.
+            </p>
+            <div>
+              <input
+                {...register('email', {
+                  required: 'Email is required',
+                  pattern: {
+                    value: /^[A-Z0-9._%+-]+@[A-Z0-9.-]+\.[A-Z]{2,}$/i,
+                    message: 'Invalid email address'
+                  }
+                })}
+                type="email"
+                placeholder="Email address"
+                style={{
+                  width: '100%',
+                  padding: '0.75rem',
+                  border: '1px solid #d1d5db',
+                  borderRadius: '4px',
+                  fontSize: '1rem'
+                }}
+              />
+              {errors.email && (
+                <p style={{ color: '#ef4444', fontSize: '0.875rem', marginTop: '0.25rem' }}>
+                  {errors.email.message}
+                </p>
+              )}
+            </div>
+
+            {error && (
+              <div style={{
+                background: '#fef2f2',
+                border: '1px solid #fca5a5',
+                color: '#dc2626',
+                padding: '0.75rem 1rem',
+                borderRadius: '4px'
+              }}>
+                {error}
+              </div>
+            )}
+
+            <button
+              type="submit"
+              disabled={isSubmitting}
+              style={{
+                width: '100%',
+                padding: '0.75rem',
+                background: isSubmitting ? '#9ca3af' : '#2563eb',
+                color: 'white',
+                border: 'none',
+                borderRadius: '4px',
+                fontSize: '1rem',
+                fontWeight: '500',
+                cursor: isSubmitting ? 'not-allowed' : 'pointer'
+              }}
+            >
+              {isSubmitting ? 'Sending...' : 'Send reset link'}
+            </button>
+          </form>
+        )}
+
+        <div style={{ textAlign: 'center', marginTop: '1.5rem' }}>
+          <Link to="/login" style={{ color: '#2563eb', textDecoration: 'none' }}>
+            Back to sign in
+          </Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ForgotPassword;
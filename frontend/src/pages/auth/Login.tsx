@@ -100,6 +100,11 @@ const Login: React.FC = () => {
                 {errors.password.message}
               </p>
             )}
+            <div style={{ textAlign: 'right', marginTop: '0.5rem' }}>
+              <Link to="/forgot-password" style={{ color: '#2563eb', textDecoration: 'none', fontSize: '0.875rem' }}>
+                Forgot your password?
+              </Link>
+            </div>
           </div>
 
           {error && (
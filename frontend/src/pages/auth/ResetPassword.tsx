@@ -0,0 +1,148 @@
+import React, { useState } from 'react';
+import { Link, useNavigate, useSearchParams } from 'react-router-dom';
+import { useForm } from 'react-hook-form';
+import { authAPI } from '../../services/api';
+
+interface ResetPasswordForm {
+  password: string;
+  confirmPassword: string;
+}
+
+const ResetPassword: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const navigate = useNavigate();
+  const token = searchParams.get('token');
+  const [error, setError] = useState<string | null>(null);
+
+  const { register, handleSubmit, watch, formState: { errors, isSubmitting } } = useForm<ResetPasswordForm>();
+
+  const onSubmit = async (data: ResetPasswordForm) => {
+    if (!token) {
+      return;
+    }
+    setError(null);
+    try {
+      await authAPI.resetPassword(token, data.password);
+      navigate('/login');
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to reset password');
+    }
+  };
+
+  const inputStyle: React.CSSProperties = {
+    width: '100%',
+    padding: '0.75rem',
+    border: '1px solid #d1d5db',
+    borderRadius: '4px',
+    fontSize: '1rem'
+  };
+
+  return (
+    <div style={{
+      minHeight: '100vh',
+      display: 'flex',
+      alignItems: 'center',
+      justifyContent: 'center',
+      background: '#f3f4f6',
+      padding: '2rem'
+    }}>
+      <div style={{
+        width: '100%',
+        maxWidth: '400px',
+        background: 'white',
+        padding: '2rem',
+        borderRadius: '8px',
+        boxShadow: '0 4px 6px rgba(0, 0, 0, 0.1)'
+      }}>
+        <h2 style={{
+          textAlign: 'center',
+          fontSize: '2rem',
+          fontWeight: 'bold',
+          color: '#111827',
+          marginBottom: '2rem'
+        }}>
+          Choose a new password
+        </h2>
+
+        {!token ? (
+          <p style={{ color: '#6b7280', textAlign: 'center' }}>
+            This reset link is incomplete. Please request a new one.
+          </p>
+        ) : (
+          <form style={{ display: 'flex', flexDirection: 'column', gap: '1rem' }} onSubmit={handleSubmit(onSubmit)}>
+            <div>
+              <input
+                {...register('password', {
+                  required: 'Password is required',
+                  minLength: { value: 6, message: 'Password must be at least 6 characters' }
+                })}
+                type="password"
+                placeholder="New password"
+                style={inputStyle}
+              />
+              {errors.password && (
+                <p style={{ color: '#ef4444', fontSize: '0.875rem', marginTop: '0.25rem' }}>
+                  {errors.password.message}
+                </p>
+              )}
+            </div>
+            <div>
+              <input
+                {...register('confirmPassword', {
+                  required: 'Please confirm your password',
+                  validate: (value) => value === watch('password') || 'Passwords do not match'
+                })}
+                type="password"
+                placeholder="Confirm new password"
+                style={inputStyle}
+              />
+              {errors.confirmPassword && (
+                <p style={{ color: '#ef4444', fontSize: '0.875rem', marginTop: '0.25rem' }}>
+                  {errors.confirmPassword.message}
+                </p>
+              )}
+            </div>
+
+            {error && (
+              <div style={{
+                background: '#fef2f2',
+                border: '1px solid #fca5a5',
+                color: '#dc2626',
+                padding: '0.75rem 1rem',
+                borderRadius: '4px'
+              }}>
+                {error}
+              </div>
+            )}
+
+            <button
+              type="submit"
+              disabled={isSubmitting}
+              style={{
+                width: '100%',
+                padding: '0.75rem',
+                background: isSubmitting ? '#9ca3af' : '#2563eb',
+                color: 'white',
+                border: 'none',
+                borderRadius: '4px',
+                fontSize: '1rem',
+                fontWeight: '500',
+                cursor: isSubmitting ? 'not-allowed' : 'pointer'
+              }}
+            >
+              {isSubmitting ? 'Saving...' : 'Reset password'}
+            </button>
+          </form>
+        )}
+
+        <div style={{ textAlign: 'center', marginTop: '1.5rem' }}>
+          <Link to="/forgot-password" style={{ color: '#2563eb', textDecoration: 'none' }}>
+            Request a new link
+          </Link>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ResetPassword;
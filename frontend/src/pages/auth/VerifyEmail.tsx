@@ -0,0 +1,137 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { useForm } from 'react-hook-form';
+import { authAPI } from '../../services/api';
+
+type Status = 'verifying' | 'verified' | 'failed';
+
+interface ResendForm {
+  email: string;
+}
+
+const VerifyEmail: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const [status, setStatus] = useState<Status>(token ? 'verifying' : 'failed');
+  const [resendMessage, setResendMessage] = useState<string | null>(null);
+
+  const { register, handleSubmit, formState: { errors, isSubmitting } } = useForm<ResendForm>();
+
+  useEffect(() => {
+    if (!token) {
+      return;
+    }
+    authAPI.verifyEmail(token)
+      .then(() => setStatus('verified'))
+      .catch(() => setStatus('failed'));
+  }, [token]);
+
+  const onResend = async (data: ResendForm) => {
+    try {
+      const response = await authAPI.resendVerification(data.email);
+      setResendMessage(response.data.message);
+    } catch {
+      setResendMessage('Could not send a verification email. Please try again later.');
+    }
+  };
+
+  return (
+    <div style={{
+      minHeight: '100vh',
+      display: 'flex',
+      alignItems: 'center',
+      justifyContent: 'center',
+      background: '#f3f4f6',
+      padding: '2rem'
+    }}>
+      <div style={{
+        width: '100%',
+        maxWidth: '400px',
+        background: 'white',
+        padding: '2rem',
+        borderRadius: '8px',
+        boxShadow: '0 4px 6px rgba(0, 0, 0, 0.1)',
+        textAlign: 'center'
+      }}>
+        {status === 'verifying' && (
+          <p style={{ color: '#6b7280' }}>Verifying your email address...</p>
+        )}
+
+        {status === 'verified' && (
+          <>
+            <h2 style={{ fontSize: '1.5rem', fontWeight: 'bold', color: '#111827', marginBottom: '1rem' }}>
+              Email verified
+            </h2>
+            <p style={{ color: '#6b7280', marginBottom: '1.5rem' }}>
+              Thanks! Your email address has been confirmed.
+            </p>
+            <Link to="/products" style={{ color: '#2563eb', textDecoration: 'none' }}>
+              Continue shopping
+            </Link>
+          </>
+        )}
+
+        {status === 'failed' && (
+          <>
+            <h2 style={{ fontSize: '1.5rem', fontWeight: 'bold', color: '#111827', marginBottom: '1rem' }}>
+              Link invalid or expired
+            </h2>
+            <p style={{ color: '#6b7280', marginBottom: '1.5rem' }}>
+              Enter your email address and we will send you a new verification link.
+            </p>
+            <form style={{ display: 'flex', flexDirection: 'column', gap: '1rem' }} onSubmit={handleSubmit(onResend)}>
+              <div>
+                <input
+                  {...register('email', {
+                    required: 'Email is required',
+                    pattern: {
+                      value: /^[A-Z0-9._%+-]+@[A-Z0-9.-]+\.[A-Z]{2,}$/i,
+                      message: 'Invalid email address'
+                    }
+                  })}
+                  type="email"
+                  placeholder="Email address"
+                  style={{
+                    width: '100%',
+                    padding: '0.75rem',
+                    border: '1px solid #d1d5db',
+                    borderRadius: '4px',
+                    fontSize: '1rem'
+                  }}
+                />
+                {errors.email && (
+                  <p style={{ color: '#ef4444', fontSize: '0.875rem', marginTop: '0.25rem' }}>
+                    {errors.email.message}
+                  </p>
+                )}
+              </div>
+
+              {resendMessage && (
+                <p style={{ color: '#6b7280', fontSize: '0.875rem' }}>{resendMessage}</p>
+              )}
+
+              <button
+                type="submit"
+                disabled={isSubmitting}
+                style={{
+                  width: '100%',
+                  padding: '0.75rem',
+                  background: isSubmitting ? '#9ca3af' : '#2563eb',
+                  color: 'white',
+                  border: 'none',
+                  borderRadius: '4px',
+                  fontSize: '1rem',
+                  cursor: isSubmitting ? 'not-allowed' : 'pointer'
+                }}
+              >
+                {isSubmitting ? 'Sending...' : 'Resend verification email'}
+              </button>
+            </form>
+          </>
+        )}
+      </div>
+    </div>
+  );
+};
+
+export default VerifyEmail;
@@ -1,6 +1,8 @@
 import React, { useState, useEffect, useRef } from 'react';
 import { useAppSelector } from '../../hooks/redux';
 import { userAPI } from '../../services/api';
+import ChangePassword from '../../components/auth/ChangePassword';
+import ActiveSessions from '../../components/auth/ActiveSessions';
 const Profile: React.FC = () => {
   const { user } = useAppSelector((state) => state.auth);
   const fileInputRef = useRef<HTMLInputElement>(null);
@@ -767,6 +769,9 @@ const Profile: React.FC = () => {
             </form>
           </div>
         </div>
+
+        <ChangePassword />
+        <ActiveSessions />
       </div>
     </div>
   );
@@ -8,10 +8,12 @@ import {
   Product,
   ProductsResponse,
   User,
+  Session,
   Order,
   Cart,
   Category,
   Partner,
+  PartnerAPIKey,
   CreatePartnerRequest,
   UpdatePartnerRequest,
   ShippingAddress,
@@ -34,18 +36,35 @@ api.interceptors.request.use((config) => {
   return config;
 });
 
+// Refresh tokens can only be used once, so requests failing together share
+// a single refresh instead of each spending the same token.
+let refreshing: Promise<string> | null = null;
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!refreshing) {
+    refreshing = axios
+      .post(`${API_BASE_URL}/auth/refresh`, {
+        refresh_token: localStorage.getItem('refresh_token'),
+      })
+      .then((response) => {
+        const { access_token, refresh_token } = response.data;
+        localStorage.setItem('access_token', access_token);
+        localStorage.setItem('refresh_token', refresh_token);
+        return access_token as string;
+      })
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
 api.interceptors.response.use(
   (response) => response,
   async (error) => {
     if (error.response?.status === 401 && localStorage.getItem('refresh_token')) {
       try {
-        const refreshToken = localStorage.getItem('refresh_token');
-        const response = await axios.post(`${API_BASE_URL}/auth/refresh`, {
-          refresh_token: refreshToken,
-        });
-        
-        const { access_token } = response.data;
-        localStorage.setItem('access_token', access_token);
+        const access_token = await refreshAccessToken();
         
         error.config.headers.Authorization = `Bearer ${access_token}`;
         return api.request(error.config);
@@ -74,8 +93,20 @@ export const authAPI = {
   logout: (refreshToken: string): Promise<AxiosResponse<{ message: string }>> =>
     api.post('/auth/logout', { refresh_token: refreshToken }),
   
-  refreshToken: (refreshToken: string): Promise<AxiosResponse<{ access_token: string; user: User }>> =>
+  refreshToken: (refreshToken: string): Promise<AxiosResponse<{ access_token: string; refresh_token: string; user: User }>> =>
     api.post('/auth/refresh', { refresh_token: refreshToken }),
+  
+  verifyEmail: (token: string): Promise<AxiosResponse<{ message: string; user: User }>> =>
+    api.post('/auth/verify-email', { token }),
+  
+  resendVerification: (email: string): Promise<AxiosResponse<{ message: string }>> =>
+    api.post('/auth/resend-verification', { email }),
+  
+  forgotPassword: (email: string): Promise<AxiosResponse<{ message: string }>> =>
+    api.post('/auth/forgot-password', { email }),
+  
+  resetPassword: (token: string, newPassword: string): Promise<AxiosResponse<{ message: string }>> =>
+    api.post('/auth/reset-password', { token, new_password: newPassword }),
 };
 
 export const userAPI = {
@@ -84,6 +115,18 @@ export const userAPI = {
   
   updateProfile: (data: Partial<User>): Promise<AxiosResponse<User>> =>
     api.put('/users/profile', data),
+  
+  changePassword: (currentPassword: string, newPassword: string): Promise<AxiosResponse<{ message: string }>> =>
+    api.put('/users/password', { current_password: currentPassword, new_password: newPassword }),
+  
+  getSessions: (): Promise<AxiosResponse<{ sessions: Session[] }>> =>
+    api.get('/users/sessions'),
+  
+  revokeSession: (id: string): Promise<AxiosResponse<{ message: string }>> =>
+    api.delete(`/users/sessions/${id}`),
+  
+  revokeAllSessions: (keepCurrent: boolean): Promise<AxiosResponse<{ message: string; revoked: number }>> =>
+    api.delete('/users/sessions', { params: { keep_current: keepCurrent } }),
     
   uploadAvatar: (file: File): Promise<AxiosResponse<{ avatar_url: string }>> => {
     const formData = new FormData();
@@ -123,6 +166,9 @@ export const userAPI = {
   changeUserPassword: (id: number, newPassword: string): Promise<AxiosResponse<{ message: string }>> =>
     api.put(`/admin/users/${id}/password`, { new_password: newPassword }),
   
+  forceLogout: (id: number): Promise<AxiosResponse<{ message: string; revoked: number }>> =>
+    api.post(`/admin/users/${id}/logout`),
+  
   deleteUser: (id: number): Promise<AxiosResponse<{ message: string }>> =>
     api.delete(`/admin/users/${id}`),
 };
@@ -243,7 +289,7 @@ export const partnersAPI = {
   togglePartnerStatus: (id: number): Promise<AxiosResponse<Partner>> =>
     api.patch(`/admin/partners/${id}/toggle-status`),
   
-  regenerateApiKey: (id: number): Promise<AxiosResponse<{ api_key: string; secret_key: string }>> =>
+  regenerateApiKey: (id: number): Promise<AxiosResponse<{ api_key: string; secret_key: string; key: PartnerAPIKey; previous_key_expires_at?: string }>> =>
     api.post(`/admin/partners/${id}/regenerate-keys`),
 };
 
@@ -219,12 +219,13 @@ const partnersSlice = createSlice({
 
       // Regenerate API Keys
       .addCase(regenerateApiKeys.fulfilled, (state, action) => {
+        // Older keys stay listed until their grace period ends.
         const index = state.partners.findIndex(p => p.id === action.payload.id);
         if (index !== -1) {
-          state.partners[index].api_key = action.payload.keys.api_key;
+          state.partners[index].api_keys = [action.payload.keys.key, ...(state.partners[index].api_keys ?? [])];
         }
         if (state.selectedPartner?.id === action.payload.id) {
-          state.selectedPartner.api_key = action.payload.keys.api_key;
+          state.selectedPartner.api_keys = [action.payload.keys.key, ...(state.selectedPartner.api_keys ?? [])];
         }
       });
   },
@@ -16,6 +16,16 @@ export interface User {
   updated_at: string;
 }
 
+export interface Session {
+  id: string;
+  user_agent: string;
+  ip_address: string;
+  last_seen_at: string;
+  expires_at: string;
+  created_at: string;
+  current: boolean;
+}
+
 export interface Category {
   id: number;
   name: string;
@@ -123,6 +133,8 @@ export interface OrderItem {
   quantity: number;
   unit_price: number;
   total_price: number;
+  status?: 'active' | 'out_of_stock';
+  out_of_stock_at?: string | null;
 }
 
 export interface Payment {
@@ -241,12 +253,30 @@ export interface ProductsResponse {
   };
 }
 
+export interface PartnerAPIKey {
+  id: number;
+  partner_id: number;
+  name: string;
+  api_key: string;
+  scopes: string;
+  expires_at?: string | null;
+  last_used_at?: string | null;
+  revoked_at?: string | null;
+  replaced_by_id?: number | null;
+  created_at: string;
+  updated_at: string;
+}
+
 export interface Partner {
   id: number;
   name: string;
   email: string;
-  api_key: string;
+  api_keys?: PartnerAPIKey[];
   webhook_url?: string;
+  contact_name?: string;
+  phone?: string;
+  address?: string;
+  tax_code?: string;
   is_active: boolean;
   commission_rate: number;
   created_at: string;